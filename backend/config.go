@@ -3,6 +3,8 @@
  *
  * Handles loading and validation of configuration settings including
  * Ollama endpoint, model selection, database paths, and tool permissions.
+ * Settings are layered: built-in defaults, then a TOML config file, then
+ * NIRA_* environment variables, then command-line flags.
  *
  * Author: KleaSCM
  * Email: KleaSCM@gmail.com
@@ -12,24 +14,393 @@
 
 package main
 
+import (
+    "flag"
+    "fmt"
+    "net/url"
+    "os"
+    "path/filepath"
+    "reflect"
+    "sort"
+    "strconv"
+    "strings"
 
+    "github.com/BurntSushi/toml"
+    "nira/tools"
+)
+
+// DefaultConfigFile is read when neither -config nor NIRA_CONFIG names a file.
+const DefaultConfigFile = "nira.toml"
 
 type Config struct {
-    OllamaEndpoint string
-    DefaultModel   string
-    DatabasePath   string
-    WebSocketPort  int
-    AllowedPaths   []string
+    OllamaEndpoint string   `toml:"ollama_endpoint"`
+    DefaultModel   string   `toml:"model"`
+    // Generation holds Ollama model options (temperature, top_p, num_ctx,
+    // ...) sent with every chat request; empty uses the model's defaults.
+    Generation     map[string]interface{} `toml:"generation"`
+    DatabasePath   string   `toml:"database_path"`
+    WebSocketPort  int      `toml:"port"`
+    // LogLevel is debug, info, warn or error.
+    LogLevel       string   `toml:"log_level"`
+    AllowedPaths   []string `toml:"allowed_paths"`
+    // ToolPolicy maps a permission tier (read, write, destructive, network,
+    // permission) or a tool name to allow, ask or deny.
+    ToolPolicy     map[string]string `toml:"tool_policy"`
+    // ConfirmTimeoutSeconds is how long a tool call waits for the user's answer
+    // before it is treated as denied.
+    ConfirmTimeoutSeconds int `toml:"confirm_timeout_seconds"`
+    // MaxToolIterations caps how many tool-call rounds the model may run for a
+    // single user message before NIRA stops and tells the user.
+    MaxToolIterations int `toml:"max_tool_iterations"`
+    // ToolWorkers bounds how many independent tool calls from one reply run concurrently.
+    ToolWorkers int `toml:"tool_workers"`
+    // PluginsDir holds external tool plugins, one subdirectory each with a plugin.json manifest.
+    PluginsDir string `toml:"plugins_dir"`
+    // TrashDir keeps files and directories removed by delete_path (or
+    // replaced by move_path/copy_path) so trash_restore can bring them back.
+    TrashDir string `toml:"trash_dir"`
+    // MCPServers are stdio Model Context Protocol servers whose tools are
+    // registered as "<name>__<tool>".
+    MCPServers []tools.MCPServerConfig `toml:"mcp_servers"`
+    // PreviewFileChanges shows the diff of every model-initiated file change
+    // and applies it only after approval.
+    PreviewFileChanges bool `toml:"preview_file_changes"`
+    // PreviewAutoApplyLines applies previewed changes of at most this many
+    // changed lines without asking (0 = always ask; deletions always ask).
+    PreviewAutoApplyLines int `toml:"preview_auto_apply_lines"`
+    // SearchProviders are the web_search backends, tried in order; a provider
+    // that errors (or finds nothing) falls through to the next. For a local
+    // SearXNG instance add {Type: "searxng", URL: "http://localhost:8888"}
+    // first; Brave needs {Type: "brave", APIKey: "..."}.
+    SearchProviders []tools.SearchProviderConfig `toml:"search_providers"`
+    // Network governs outbound HTTP from tools: Offline disables every
+    // network tool, AllowDomains/DenyDomains limit the hosts tools may reach
+    // (a local SearXNG host must be allowed too), TimeoutSeconds is the
+    // default request timeout and LogRequests logs each outbound call.
+    // The Ollama endpoint is not affected.
+    Network tools.EgressConfig `toml:"network"`
 }
 
-func LoadConfig() (Config, error) {
+// DefaultConfig returns the built-in settings.
+func DefaultConfig() Config {
     return Config{
         OllamaEndpoint: "http://localhost:11434",
         DefaultModel:   "HammerAI/mythomax-l2",
         DatabasePath:   "./nira.db",
         WebSocketPort:  8080,
+        LogLevel:       "info",
         // Allow tools to access files within the project directory by default.
         // You can extend this list later (e.g., to specific folders) for tighter security.
         AllowedPaths:   []string{"."},
-    }, nil
+        ToolPolicy: map[string]string{
+            "read":        "allow",
+            "network":     "allow",
+            "write":       "ask",
+            "destructive": "ask",
+            "permission":  "ask",
+        },
+        ConfirmTimeoutSeconds: 120,
+        MaxToolIterations:     5,
+        ToolWorkers:           4,
+        PluginsDir:            "./plugins",
+        TrashDir:              "./.nira_trash",
+        PreviewFileChanges:    true,
+        PreviewAutoApplyLines: 0,
+        SearchProviders:       []tools.SearchProviderConfig{
+            {Type: tools.SearchDuckDuckGo},
+        },
+        Network: tools.EgressConfig{
+            Offline:        false,
+            TimeoutSeconds: 30,
+            LogRequests:    true,
+        },
+    }
+}
+
+// configSetting is a scalar or list setting that can also be given as an
+// environment variable and a flag. Both names derive from the key:
+// network.offline is NIRA_NETWORK_OFFLINE and -network-offline.
+type configSetting struct {
+    key   string
+    usage string
+    // value is a *string, *int, *bool or *[]string inside a Config.
+    value interface{}
+    // paths splits list values like PATH instead of at commas.
+    paths bool
+}
+
+// fileOnlySettings are the structured settings only a config file can set.
+var fileOnlySettings = []string{"generation", "tool_policy", "search_providers", "mcp_servers"}
+
+func (c *Config) settings() []configSetting {
+    return []configSetting{
+        {key: "ollama_endpoint", usage: "Ollama API base URL", value: &c.OllamaEndpoint},
+        {key: "model", usage: "Ollama model used for chat", value: &c.DefaultModel},
+        {key: "database_path", usage: "SQLite database file", value: &c.DatabasePath},
+        {key: "port", usage: "WebSocket server port", value: &c.WebSocketPort},
+        {key: "log_level", usage: "minimum log level: debug, info, warn or error", value: &c.LogLevel},
+        {key: "allowed_paths", usage: "directories file tools may use, granted read_write", value: &c.AllowedPaths, paths: true},
+        {key: "confirm_timeout_seconds", usage: "seconds a tool call waits for confirmation before it is denied", value: &c.ConfirmTimeoutSeconds},
+        {key: "max_tool_iterations", usage: "tool-call rounds per user message", value: &c.MaxToolIterations},
+        {key: "tool_workers", usage: "independent tool calls from one reply that run concurrently", value: &c.ToolWorkers},
+        {key: "plugins_dir", usage: "directory of external tool plugins", value: &c.PluginsDir},
+        {key: "trash_dir", usage: "where deleted and overwritten files are kept", value: &c.TrashDir},
+        {key: "preview_file_changes", usage: "show a diff of model file changes and apply them only after approval", value: &c.PreviewFileChanges},
+        {key: "preview_auto_apply_lines", usage: "apply previewed changes of at most this many lines without asking (0 always asks)", value: &c.PreviewAutoApplyLines},
+        {key: "network.offline", usage: "disable every network tool", value: &c.Network.Offline},
+        {key: "network.allow_domains", usage: "the only hosts tools may reach (empty allows all)", value: &c.Network.AllowDomains},
+        {key: "network.deny_domains", usage: "hosts tools may never reach", value: &c.Network.DenyDomains},
+        {key: "network.timeout_seconds", usage: "default timeout of outbound requests", value: &c.Network.TimeoutSeconds},
+        {key: "network.log_requests", usage: "log every outbound request", value: &c.Network.LogRequests},
+    }
+}
+
+// settingValue returns the addressable field behind a setting key.
+func (c *Config) settingValue(key string) (reflect.Value, bool) {
+    tables := map[string]interface{}{
+        "generation":       &c.Generation,
+        "tool_policy":      &c.ToolPolicy,
+        "search_providers": &c.SearchProviders,
+        "mcp_servers":      &c.MCPServers,
+    }
+    if v, ok := tables[key]; ok {
+        return reflect.ValueOf(v).Elem(), true
+    }
+    for _, s := range c.settings() {
+        if s.key == key {
+            return reflect.ValueOf(s.value).Elem(), true
+        }
+    }
+    return reflect.Value{}, false
+}
+
+// settingKeys lists every setting key, flag-settable ones first.
+func settingKeys() []string {
+    var keys []string
+    for _, s := range (&Config{}).settings() {
+        keys = append(keys, s.key)
+    }
+    return append(keys, fileOnlySettings...)
+}
+
+func (s configSetting) envName() string {
+    return "NIRA_" + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+func (s configSetting) flagName() string {
+    return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// set parses raw, as given in the environment or on the command line.
+func (s configSetting) set(raw string) error {
+    switch v := s.value.(type) {
+    case *string:
+        *v = raw
+    case *int:
+        n, err := strconv.Atoi(strings.TrimSpace(raw))
+        if err != nil {
+            return fmt.Errorf("'%s' is not a whole number", raw)
+        }
+        *v = n
+    case *bool:
+        b, err := strconv.ParseBool(strings.TrimSpace(raw))
+        if err != nil {
+            return fmt.Errorf("'%s' is not true or false", raw)
+        }
+        *v = b
+    case *[]string:
+        parts := strings.Split(raw, ",")
+        if s.paths {
+            parts = filepath.SplitList(raw)
+        }
+        list := []string{}
+        for _, p := range parts {
+            if p = strings.TrimSpace(p); p != "" {
+                list = append(list, p)
+            }
+        }
+        *v = list
+    }
+    return nil
+}
+
+// ConfigSources records where the effective configuration came from.
+type ConfigSources struct {
+    // File is the config file that was read; empty when there was none.
+    File string
+    // Settings maps each setting key to "default", "file", "env NIRA_..."
+    // or "flag -...".
+    Settings map[string]string
+}
+
+// LoadConfig builds the configuration from the defaults, the config file,
+// NIRA_* environment variables and the flags in args, each layer overriding
+// the one before, and validates the result. The setting flags and -config
+// are added to flags, which may already hold flags of the calling command.
+func LoadConfig(flags *flag.FlagSet, args []string) (Config, ConfigSources, error) {
+    config, sources, err := loadConfigLayers(flags, args)
+    if err != nil {
+        return config, sources, err
+    }
+    return config, sources, config.Validate(sources)
+}
+
+func loadConfigLayers(flags *flag.FlagSet, args []string) (Config, ConfigSources, error) {
+    config := DefaultConfig()
+    settings := config.settings()
+    sources := ConfigSources{Settings: map[string]string{}}
+    for _, key := range settingKeys() {
+        sources.Settings[key] = "default"
+    }
+
+    // Flags are parsed first to find -config but applied last.
+    type flagValue struct {
+        setting configSetting
+        raw     string
+    }
+    var flagged []flagValue
+    configFile := flags.String("config", "", "config file (default $NIRA_CONFIG, else ./"+DefaultConfigFile+" when present)")
+    for _, s := range settings {
+        record := func(raw string) error {
+            flagged = append(flagged, flagValue{s, raw})
+            return nil
+        }
+        usage := s.usage + " (env " + s.envName() + ")"
+        if _, ok := s.value.(*bool); ok {
+            flags.BoolFunc(s.flagName(), usage, record)
+        } else {
+            flags.Func(s.flagName(), usage, record)
+        }
+    }
+    if err := flags.Parse(args); err != nil {
+        return config, sources, err
+    }
+
+    path, explicit := *configFile, true
+    if path == "" {
+        path = os.Getenv("NIRA_CONFIG")
+    }
+    if path == "" {
+        path, explicit = DefaultConfigFile, false
+    }
+    if _, err := os.Stat(path); err == nil || explicit {
+        md, err := toml.DecodeFile(path, &config)
+        if err != nil {
+            return config, sources, fmt.Errorf("config file %s: %w", path, err)
+        }
+        if undecoded := md.Undecoded(); len(undecoded) > 0 {
+            var keys []string
+            for _, k := range undecoded {
+                keys = append(keys, k.String())
+            }
+            sort.Strings(keys)
+            return config, sources, fmt.Errorf("config file %s: unknown setting(s) %s (run `nira config init` for a file listing every setting)", path, strings.Join(keys, ", "))
+        }
+        sources.File = path
+        for key := range sources.Settings {
+            if md.IsDefined(strings.Split(key, ".")...) {
+                sources.Settings[key] = "file"
+            }
+        }
+    }
+
+    for _, s := range settings {
+        raw, ok := os.LookupEnv(s.envName())
+        if !ok {
+            continue
+        }
+        if err := s.set(raw); err != nil {
+            return config, sources, fmt.Errorf("environment variable %s: %w", s.envName(), err)
+        }
+        sources.Settings[s.key] = "env " + s.envName()
+    }
+    for _, f := range flagged {
+        if err := f.setting.set(f.raw); err != nil {
+            return config, sources, fmt.Errorf("flag -%s: %w", f.setting.flagName(), err)
+        }
+        sources.Settings[f.setting.key] = "flag -" + f.setting.flagName()
+    }
+    return config, sources, nil
+}
+
+// Validate reports every problem in the configuration at once, naming each
+// setting and where its value came from.
+func (c Config) Validate(sources ConfigSources) error {
+    var problems []string
+    bad := func(key string, format string, args ...interface{}) {
+        msg := key + ": " + fmt.Sprintf(format, args...)
+        if src := sources.Settings[key]; src != "" && src != "default" {
+            msg += " (from " + src + ")"
+        }
+        problems = append(problems, msg)
+    }
+
+    if u, err := url.Parse(c.OllamaEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        bad("ollama_endpoint", "must be an http(s) URL such as http://localhost:11434, got '%s'", c.OllamaEndpoint)
+    }
+    if strings.TrimSpace(c.DefaultModel) == "" {
+        bad("model", "must name an Ollama model")
+    }
+    if strings.TrimSpace(c.DatabasePath) == "" {
+        bad("database_path", "must not be empty")
+    }
+    if c.WebSocketPort < 1 || c.WebSocketPort > 65535 {
+        bad("port", "must be between 1 and 65535, got %d", c.WebSocketPort)
+    }
+    if _, err := ParseLogLevel(c.LogLevel); err != nil {
+        bad("log_level", "%v", err)
+    }
+    for name, v := range c.Generation {
+        switch v := v.(type) {
+        case int64, float64, bool, string:
+        case []interface{}:
+            for _, item := range v {
+                if _, ok := item.(string); !ok {
+                    bad("generation", "%s: lists may only hold strings (as in stop = [\"###\"])", name)
+                    break
+                }
+            }
+        default:
+            bad("generation", "%s: must be a number, boolean, string or list of strings", name)
+        }
+    }
+    for _, p := range c.AllowedPaths {
+        if strings.TrimSpace(p) == "" {
+            bad("allowed_paths", "contains an empty path")
+            break
+        }
+    }
+    if c.ConfirmTimeoutSeconds < 1 {
+        bad("confirm_timeout_seconds", "must be at least 1, got %d", c.ConfirmTimeoutSeconds)
+    }
+    if c.MaxToolIterations < 1 {
+        bad("max_tool_iterations", "must be at least 1, got %d", c.MaxToolIterations)
+    }
+    if c.ToolWorkers < 1 {
+        bad("tool_workers", "must be at least 1, got %d", c.ToolWorkers)
+    }
+    if c.PreviewAutoApplyLines < 0 {
+        bad("preview_auto_apply_lines", "must not be negative, got %d", c.PreviewAutoApplyLines)
+    }
+    if _, err := tools.NewPolicy(c.ToolPolicy); err != nil {
+        bad("tool_policy", "%v", err)
+    }
+    for _, p := range c.SearchProviders {
+        if _, err := tools.NewSearchProvider(p, nil); err != nil {
+            bad("search_providers", "%v", err)
+        }
+    }
+    for _, s := range c.MCPServers {
+        if _, err := tools.NewMCPClient(s); err != nil {
+            bad("mcp_servers", "%v", err)
+        }
+    }
+    if _, err := tools.NewEgress(c.Network); err != nil {
+        bad("network", "%v", err)
+    }
+
+    if len(problems) > 0 {
+        return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+    }
+    return nil
 }
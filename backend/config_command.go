@@ -0,0 +1,251 @@
+/**
+ * Config subcommand module.
+ *
+ * Implements `nira config print`, which shows the effective configuration
+ * with the source of every setting (secrets masked), and `nira config
+ * init`, which writes a commented config file holding the defaults.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: config_command.go
+ * Description: `nira config` subcommand and TOML rendering of a Config.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"nira/tools"
+)
+
+const configUsage = "usage: nira config print [setting flags] | nira config init [-force] [path]"
+
+// configFileHeader opens a file written by `nira config init`.
+const configFileHeader = `# NIRA configuration.
+#
+# Settings are layered: built-in defaults, then this file, then NIRA_*
+# environment variables, then command-line flags. Every setting outside
+# the tables below can be overridden with NIRA_<KEY> (dots become
+# underscores, e.g. NIRA_NETWORK_OFFLINE=true) or -<key> (underscores and
+# dots become dashes, e.g. -network-offline). Lists are comma separated;
+# NIRA_ALLOWED_PATHS is separated like PATH.
+#
+# Run "nira config print" to see the effective settings and their sources.
+# A running NIRA picks up edits to model, log_level, allowed_paths and the
+# generation and tool_policy tables; other changes need a restart.
+
+`
+
+// tableDocs describe the settings only a config file can set.
+var tableDocs = map[string]string{
+	"generation": "Ollama model options sent with every chat request, e.g.\n" +
+		"[generation]\n" +
+		"temperature = 0.7\n" +
+		"num_ctx = 8192\n" +
+		"Empty uses the model's own defaults.",
+	"tool_policy": "Per permission tier (read, write, destructive, network, permission) or\n" +
+		"per tool name: allow, ask or deny. Entries are merged over the defaults.",
+	"search_providers": "web_search backends, tried in order. Types: duckduckgo, searxng (url),\n" +
+		"brave (api_key) and json (url with {query}, results_path, *_field).",
+	"mcp_servers": "Stdio MCP servers whose tools are registered as <name>__<tool>, e.g.\n" +
+		"[[mcp_servers]]\n" +
+		"name = \"github\"\n" +
+		"command = \"npx\"\n" +
+		"args = [\"-y\", \"@modelcontextprotocol/server-github\"]\n" +
+		"env = { GITHUB_PERSONAL_ACCESS_TOKEN = \"...\" }",
+}
+
+// runConfigCommand implements `nira config <print|init>`.
+func runConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	switch args[0] {
+	case "print":
+		flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+		config, sources, err := loadConfigLayers(flags, args[1:])
+		if err != nil {
+			return err
+		}
+		if sources.File != "" {
+			fmt.Printf("# Config file: %s\n\n", sources.File)
+		} else {
+			fmt.Printf("# No config file (looked for $NIRA_CONFIG and ./%s)\n\n", DefaultConfigFile)
+		}
+		note := func(key string) string { return sources.Settings[key] }
+		if err := writeConfigTOML(os.Stdout, maskConfigSecrets(config), note, true); err != nil {
+			return err
+		}
+		return config.Validate(sources)
+
+	case "init":
+		flags := flag.NewFlagSet("config init", flag.ContinueOnError)
+		force := flags.Bool("force", false, "overwrite an existing file")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		path := DefaultConfigFile
+		if flags.NArg() > 0 {
+			path = flags.Arg(0)
+		}
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("%s already exists; use -force to overwrite it", path)
+		}
+		defaults := DefaultConfig()
+		usage := map[string]string{}
+		for _, s := range defaults.settings() {
+			usage[s.key] = s.usage
+		}
+		note := func(key string) string {
+			if doc, ok := tableDocs[key]; ok {
+				return doc
+			}
+			return usage[key]
+		}
+		var buf bytes.Buffer
+		buf.WriteString(configFileHeader)
+		if err := writeConfigTOML(&buf, defaults, note, false); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		fmt.Printf("Wrote the default configuration to %s\n", path)
+		return nil
+	}
+	return fmt.Errorf("unknown config command '%s'; %s", args[0], configUsage)
+}
+
+// writeConfigTOML writes config as TOML with note(key) as a comment on each
+// setting: after the value when inline is set, otherwise on the lines
+// above. Top-level keys come first, as TOML requires, then the tables.
+func writeConfigTOML(w io.Writer, config Config, note func(key string) string, inline bool) error {
+	var top, tables bytes.Buffer
+	comment := func(buf *bytes.Buffer, key string) {
+		if text := note(key); text != "" {
+			buf.WriteString("# " + strings.ReplaceAll(text, "\n", "\n# ") + "\n")
+		}
+	}
+	section := ""
+	for _, s := range config.settings() {
+		table, leaf := "", s.key
+		if i := strings.LastIndexByte(s.key, '.'); i >= 0 {
+			table, leaf = s.key[:i], s.key[i+1:]
+		}
+		buf := &top
+		if table != "" {
+			buf = &tables
+			if table != section {
+				fmt.Fprintf(buf, "\n[%s]", table)
+				if inline {
+					buf.WriteString("\n")
+				}
+				section = table
+			}
+		}
+		value, _ := config.settingValue(s.key)
+		v := value.Interface()
+		if list, ok := v.([]string); ok && list == nil {
+			v = []string{}
+		}
+		line, err := encodeTOML(leaf, v)
+		if err != nil {
+			return err
+		}
+		if inline {
+			if text := note(s.key); text != "" {
+				line += "  # " + text
+			}
+		} else {
+			buf.WriteString("\n")
+			comment(buf, s.key)
+		}
+		buf.WriteString(line + "\n")
+	}
+
+	for _, key := range fileOnlySettings {
+		value, _ := config.settingValue(key)
+		text, err := encodeTOML(key, value.Interface())
+		if err != nil {
+			return err
+		}
+		// Empty lists encode as plain keys and must stay above the tables;
+		// nil ones encode as nothing and leave only the comment, which
+		// only the commented template wants.
+		buf := &top
+		if strings.HasPrefix(text, "[") {
+			buf = &tables
+		}
+		switch {
+		case inline && text == "":
+			continue
+		case inline && buf == &top:
+			if note := note(key); note != "" {
+				text += "  # " + note
+			}
+			buf.WriteString(text + "\n")
+			continue
+		}
+		buf.WriteString("\n")
+		comment(buf, key)
+		if text != "" {
+			buf.WriteString(text + "\n")
+		}
+	}
+
+	if _, err := w.Write(bytes.TrimLeft(top.Bytes(), "\n")); err != nil {
+		return err
+	}
+	_, err := w.Write(tables.Bytes())
+	return err
+}
+
+// encodeTOML renders one key and its value without a trailing newline.
+func encodeTOML(key string, value interface{}) (string, error) {
+	var buf bytes.Buffer
+	enc := toml.NewEncoder(&buf)
+	enc.Indent = ""
+	if err := enc.Encode(map[string]interface{}{key: value}); err != nil {
+		return "", fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// maskConfigSecrets returns a copy of config with API keys, request headers
+// and MCP server environment values replaced.
+func maskConfigSecrets(config Config) Config {
+	mask := func(m map[string]string) map[string]string {
+		if len(m) == 0 {
+			return m
+		}
+		out := make(map[string]string, len(m))
+		for k := range m {
+			out[k] = "********"
+		}
+		return out
+	}
+	providers := make([]tools.SearchProviderConfig, len(config.SearchProviders))
+	for i, p := range config.SearchProviders {
+		if p.APIKey != "" {
+			p.APIKey = "********"
+		}
+		p.Headers = mask(p.Headers)
+		providers[i] = p
+	}
+	config.SearchProviders = providers
+	servers := make([]tools.MCPServerConfig, len(config.MCPServers))
+	for i, s := range config.MCPServers {
+		s.Env = mask(s.Env)
+		servers[i] = s
+	}
+	config.MCPServers = servers
+	return config
+}
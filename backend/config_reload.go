@@ -0,0 +1,176 @@
+/**
+ * Configuration reload module.
+ *
+ * Re-reads the configuration when the config file changes or the process
+ * receives SIGHUP. Settings that can change while NIRA runs (model,
+ * generation options, log level, tool policy, allowed paths) are applied
+ * live; the rest keep their running values and are logged as needing a
+ * restart. An invalid configuration is rejected as a whole.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: config_reload.go
+ * Description: Config file watching and live reload.
+ */
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultConfigPollInterval = 2 * time.Second
+
+// liveSettings can change without a restart; Server.ApplyConfig applies them.
+var liveSettings = map[string]bool{
+	"model":         true,
+	"generation":    true,
+	"log_level":     true,
+	"tool_policy":   true,
+	"allowed_paths": true,
+}
+
+// ConfigReloader watches the configuration and applies changes to it.
+type ConfigReloader struct {
+	// Load reads the configuration the same way it was read at startup.
+	Load func() (Config, ConfigSources, error)
+	// Apply switches the running service to config. applied lists the live
+	// settings that changed, restartRequired those kept at their old values.
+	Apply  func(old, config Config, applied, restartRequired []string)
+	Logger *Logger
+	// Interval is how often the config file is checked for changes.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	current Config
+	// pending holds the restart-only settings as last read, so each new
+	// value is reported once rather than on every reload.
+	pending Config
+	file    string
+	stamp   fileStamp
+}
+
+// fileStamp identifies one version of the config file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func NewConfigReloader(current Config, sources ConfigSources, load func() (Config, ConfigSources, error), logger *Logger) *ConfigReloader {
+	r := &ConfigReloader{Load: load, Logger: logger, Interval: defaultConfigPollInterval, current: current, pending: current}
+	r.watch(sources.File)
+	return r
+}
+
+// Run reloads on SIGHUP and whenever the watched file changes. It does not
+// return.
+func (r *ConfigReloader) Run() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-hup:
+			r.Reload("SIGHUP")
+		case <-ticker.C:
+			if r.fileChanged() {
+				r.Reload("config file changed")
+			}
+		}
+	}
+}
+
+// watch starts watching path ("" watches nothing).
+func (r *ConfigReloader) watch(path string) {
+	r.file, r.stamp = path, fileStamp{}
+	if info, err := os.Stat(path); path != "" && err == nil {
+		r.stamp = fileStamp{info.ModTime(), info.Size()}
+	}
+}
+
+func (r *ConfigReloader) fileChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == "" {
+		return false
+	}
+	info, err := os.Stat(r.file)
+	if err != nil {
+		// Missing while an editor replaces it; look again next tick.
+		return false
+	}
+	return fileStamp{info.ModTime(), info.Size()} != r.stamp
+}
+
+// Reload re-reads the configuration and applies what changed.
+func (r *ConfigReloader) Reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, sources, err := r.Load()
+	if err != nil {
+		// The rejected version is not read again until the file changes.
+		r.watch(r.file)
+		r.Logger.Error("Config reload (%s) rejected; keeping the running configuration: %v", reason, err)
+		return
+	}
+	r.watch(sources.File)
+
+	applied := r.current
+	var live, restart []string
+	for _, key := range settingKeys() {
+		was, _ := r.current.settingValue(key)
+		now, _ := next.settingValue(key)
+		if !liveSettings[key] {
+			pending, _ := r.pending.settingValue(key)
+			if !sameSetting(pending, now) {
+				pending.Set(now)
+				if !sameSetting(was, now) {
+					restart = append(restart, key)
+				}
+			}
+			continue
+		}
+		if sameSetting(was, now) {
+			continue
+		}
+		dst, _ := applied.settingValue(key)
+		dst.Set(now)
+		live = append(live, key)
+	}
+
+	if len(restart) > 0 {
+		r.Logger.Warn("Config reload (%s): restart NIRA to apply %s; keeping the running values", reason, strings.Join(restart, ", "))
+	}
+	if len(live) == 0 && len(restart) == 0 {
+		r.Logger.Info("Config reload (%s): no changes", reason)
+		return
+	}
+	old := r.current
+	r.current = applied
+	if len(live) > 0 {
+		r.Logger.Info("Config reload (%s): applied %s", reason, strings.Join(live, ", "))
+	}
+	if r.Apply != nil {
+		r.Apply(old, applied, live, restart)
+	}
+}
+
+// sameSetting compares two setting values; an empty list or table equals a
+// missing one.
+func sameSetting(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
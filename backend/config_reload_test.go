@@ -0,0 +1,140 @@
+package main
+
+import (
+	"nira/tools"
+	"reflect"
+	"testing"
+)
+
+// reloadCall is one call of ConfigReloader.Apply.
+type reloadCall struct {
+	config           Config
+	applied, restart []string
+}
+
+// newTestReloader returns a reloader whose Load returns *next and the
+// Apply calls it made.
+func newTestReloader(next *Config) (*ConfigReloader, *[]reloadCall) {
+	var calls []reloadCall
+	r := NewConfigReloader(DefaultConfig(), ConfigSources{}, func() (Config, ConfigSources, error) {
+		return *next, ConfigSources{}, next.Validate(ConfigSources{})
+	}, NewLogger(LogLevelError))
+	r.Apply = func(old, config Config, applied, restart []string) {
+		calls = append(calls, reloadCall{config, applied, restart})
+	}
+	return r, &calls
+}
+
+// TestConfigReloader_RestartReportedOnce verifies that a restart-only
+// change is reported once per new value.
+func TestConfigReloader_RestartReportedOnce(t *testing.T) {
+	next := DefaultConfig()
+	r, calls := newTestReloader(&next)
+
+	next.WebSocketPort = 9000
+	r.Reload("test")
+	r.Reload("test")
+	next.DefaultModel = "other-model"
+	r.Reload("test")
+	next.WebSocketPort = 8080
+	r.Reload("test")
+	next.WebSocketPort = 9000
+	r.Reload("test")
+
+	want := []struct{ applied, restart []string }{
+		{nil, []string{"port"}},
+		{[]string{"model"}, nil},
+		{nil, []string{"port"}},
+	}
+	if len(*calls) != len(want) {
+		t.Fatalf("Expected %d notifications, got %+v", len(want), *calls)
+	}
+	for i, w := range want {
+		c := (*calls)[i]
+		if !reflect.DeepEqual(c.applied, w.applied) || !reflect.DeepEqual(c.restart, w.restart) {
+			t.Errorf("Reload %d: expected applied %v restart %v, got %v %v", i, w.applied, w.restart, c.applied, c.restart)
+		}
+		if c.config.WebSocketPort != 8080 {
+			t.Errorf("Reload %d: the running port should stay 8080, got %d", i, c.config.WebSocketPort)
+		}
+	}
+}
+
+// TestConfigReloader_LiveAndRestart verifies that live settings are applied
+// and restart-only ones keep their running values.
+func TestConfigReloader_LiveAndRestart(t *testing.T) {
+	next := DefaultConfig()
+	r, calls := newTestReloader(&next)
+
+	next.DefaultModel = "other-model"
+	next.LogLevel = "debug"
+	next.Generation = map[string]interface{}{"temperature": 0.2}
+	next.ToolPolicy = map[string]string{"write": "deny"}
+	next.WebSocketPort = 9000
+	next.DatabasePath = "./other.db"
+	r.Reload("test")
+
+	if len(*calls) != 1 {
+		t.Fatalf("Expected one notification, got %+v", *calls)
+	}
+	c := (*calls)[0]
+	if want := []string{"model", "log_level", "generation", "tool_policy"}; !reflect.DeepEqual(c.applied, want) {
+		t.Errorf("Expected applied %v, got %v", want, c.applied)
+	}
+	if want := []string{"database_path", "port"}; !reflect.DeepEqual(c.restart, want) {
+		t.Errorf("Expected restart %v, got %v", want, c.restart)
+	}
+	if c.config.DefaultModel != "other-model" || c.config.LogLevel != "debug" || c.config.ToolPolicy["write"] != "deny" {
+		t.Errorf("Live settings were not applied: %+v", c.config)
+	}
+	if c.config.WebSocketPort != 8080 || c.config.DatabasePath != "./nira.db" {
+		t.Errorf("Restart settings should keep their running values: %+v", c.config)
+	}
+
+	r.Reload("test")
+	if len(*calls) != 1 {
+		t.Errorf("Expected no notification without changes, got %+v", (*calls)[1:])
+	}
+}
+
+// TestConfigReloader_InvalidRejected verifies that an invalid configuration
+// changes nothing, not even its valid live settings.
+func TestConfigReloader_InvalidRejected(t *testing.T) {
+	next := DefaultConfig()
+	r, calls := newTestReloader(&next)
+
+	next.DefaultModel = "other-model"
+	next.LogLevel = "loud"
+	r.Reload("test")
+	if len(*calls) != 0 {
+		t.Fatalf("Expected the invalid configuration to be rejected, got %+v", *calls)
+	}
+	if r.current.DefaultModel != DefaultConfig().DefaultModel {
+		t.Errorf("Expected the running model to stay, got %s", r.current.DefaultModel)
+	}
+
+	next.LogLevel = "warn"
+	r.Reload("test")
+	if len(*calls) != 1 || !reflect.DeepEqual((*calls)[0].applied, []string{"model", "log_level"}) {
+		t.Errorf("Expected the fixed configuration to apply model and log_level, got %+v", *calls)
+	}
+}
+
+// TestSameSetting covers empty and missing lists and tables.
+func TestSameSetting(t *testing.T) {
+	var missing Config
+	empty := Config{AllowedPaths: []string{}, Generation: map[string]interface{}{}, MCPServers: []tools.MCPServerConfig{}}
+	full := Config{AllowedPaths: []string{"."}, Generation: map[string]interface{}{"top_p": 0.9}, MCPServers: []tools.MCPServerConfig{{Name: "x"}}}
+
+	for _, key := range []string{"allowed_paths", "generation", "mcp_servers"} {
+		a, _ := missing.settingValue(key)
+		b, _ := empty.settingValue(key)
+		c, _ := full.settingValue(key)
+		if !sameSetting(a, b) || !sameSetting(b, a) {
+			t.Errorf("%s: expected empty and missing to be the same", key)
+		}
+		if sameSetting(b, c) || sameSetting(c, a) {
+			t.Errorf("%s: expected a non-empty value to differ", key)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadTestConfig writes file as the config file and loads it with args.
+func loadTestConfig(t *testing.T, file string, args ...string) (Config, ConfigSources, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nira.toml")
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NIRA_CONFIG", path)
+	return LoadConfig(flag.NewFlagSet("nira", flag.ContinueOnError), args)
+}
+
+// TestLoadConfig_Layers verifies that env overrides the file and flags override both.
+func TestLoadConfig_Layers(t *testing.T) {
+	file := `
+model = "file-model"
+port = 9000
+log_level = "warn"
+allowed_paths = ["/srv/file"]
+
+[network]
+timeout_seconds = 10
+`
+	t.Setenv("NIRA_PORT", "9100")
+	t.Setenv("NIRA_LOG_LEVEL", "debug")
+	t.Setenv("NIRA_NETWORK_TIMEOUT_SECONDS", "20")
+
+	config, sources, err := loadTestConfig(t, file, "-log-level", "error", "-allowed-paths", "/a"+string(os.PathListSeparator)+"/b")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	checks := []struct {
+		key    string
+		got    interface{}
+		want   interface{}
+		source string
+	}{
+		{"ollama_endpoint", config.OllamaEndpoint, DefaultConfig().OllamaEndpoint, "default"},
+		{"model", config.DefaultModel, "file-model", "file"},
+		{"port", config.WebSocketPort, 9100, "env NIRA_PORT"},
+		{"log_level", config.LogLevel, "error", "flag -log-level"},
+		{"allowed_paths", strings.Join(config.AllowedPaths, ","), "/a,/b", "flag -allowed-paths"},
+		{"network.timeout_seconds", config.Network.TimeoutSeconds, 20, "env NIRA_NETWORK_TIMEOUT_SECONDS"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.key, c.want, c.got)
+		}
+		if got := sources.Settings[c.key]; got != c.source {
+			t.Errorf("%s: expected source %q, got %q", c.key, c.source, got)
+		}
+	}
+	if sources.File == "" {
+		t.Errorf("Expected the config file to be recorded")
+	}
+}
+
+// TestLoadConfig_Errors covers unknown keys and aggregated validation problems.
+func TestLoadConfig_Errors(t *testing.T) {
+	t.Run("Unknown keys", func(t *testing.T) {
+		_, _, err := loadTestConfig(t, "modle = \"x\"\n[network]\ntimeout = 5\n")
+		if err == nil {
+			t.Fatal("Expected unknown keys to be rejected")
+		}
+		if !strings.Contains(err.Error(), "unknown setting(s) modle, network.timeout") {
+			t.Errorf("Expected both unknown keys to be named, got: %v", err)
+		}
+	})
+
+	t.Run("Several problems in one error", func(t *testing.T) {
+		t.Setenv("NIRA_TOOL_WORKERS", "0")
+		_, _, err := loadTestConfig(t, "port = 70000\nlog_level = \"loud\"\n", "-model", " ")
+		if err == nil {
+			t.Fatal("Expected the configuration to be invalid")
+		}
+		msg := err.Error()
+		for _, want := range []string{
+			"port: must be between 1 and 65535, got 70000 (from file)",
+			"log_level:",
+			"model: must name an Ollama model (from flag -model)",
+			"tool_workers: must be at least 1, got 0 (from env NIRA_TOOL_WORKERS)",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("Expected %q in:\n%s", want, msg)
+			}
+		}
+		if n := strings.Count(msg, "\n  - "); n != 4 {
+			t.Errorf("Expected 4 problems, got %d:\n%s", n, msg)
+		}
+	})
+
+	t.Run("Bad environment value", func(t *testing.T) {
+		t.Setenv("NIRA_PORT", "eighty")
+		_, _, err := loadTestConfig(t, "")
+		if err == nil || !strings.Contains(err.Error(), "NIRA_PORT") {
+			t.Errorf("Expected the variable to be named, got: %v", err)
+		}
+	})
+}
+
+// TestWriteConfigTOML_Print verifies that empty tables leave no stray comments.
+func TestWriteConfigTOML_Print(t *testing.T) {
+	var buf bytes.Buffer
+	note := func(string) string { return "default" }
+	if err := writeConfigTOML(&buf, maskConfigSecrets(DefaultConfig()), note, true); err != nil {
+		t.Fatalf("Failed to print config: %v", err)
+	}
+	if strings.Contains(buf.String(), "generation") {
+		t.Errorf("Expected the empty generation table to be left out, got:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "mcp_servers = []  # default") {
+		t.Errorf("Expected mcp_servers with its source, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "\n# default\n\n") || strings.Contains(buf.String(), "\n# default\nmcp_servers") {
+		t.Errorf("Expected no comment without a value, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,125 @@
+/**
+ * Tool confirmation module.
+ *
+ * Implements the confirm/deny round trip used when the permission policy
+ * requires the user's approval before a tool call runs. The tool loop
+ * blocks on Request while the WebSocket read loop delivers the answer
+ * through Resolve.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: confirm.go
+ * Description: User confirmation of tool calls over WebSocket.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"nira/tools"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConfirmRequest is the payload sent to the frontend in a confirm message.
+type ConfirmRequest struct {
+	Tool       string                 `json:"tool"`
+	Arguments  map[string]interface{} `json:"arguments"`
+	Permission tools.Permission       `json:"permission"`
+	// Previews holds the diffs of file changes the call would make.
+	Previews []tools.FilePreview `json:"previews,omitempty"`
+}
+
+type ConfirmationBroker struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirm
+	seq     uint64
+	Timeout time.Duration
+}
+
+// pendingConfirm is a request waiting for an answer from the connection it
+// was sent to.
+type pendingConfirm struct {
+	conn   *websocket.Conn
+	answer chan bool
+}
+
+func NewConfirmationBroker(timeout time.Duration) *ConfirmationBroker {
+	return &ConfirmationBroker{
+		pending: make(map[string]pendingConfirm),
+		Timeout: timeout,
+	}
+}
+
+// Request asks the user to approve a call and blocks until they answer, the
+// timeout elapses or the connection goes away. Anything but an explicit
+// approval counts as a denial.
+func (b *ConfirmationBroker) Request(conn *websocket.Conn, call *tools.Call, perm tools.Permission, previews []tools.FilePreview) bool {
+	b.mu.Lock()
+	b.seq++
+	id := fmt.Sprintf("confirm_%d_%d", time.Now().UnixNano(), b.seq)
+	answer := make(chan bool, 1)
+	b.pending[id] = pendingConfirm{conn: conn, answer: answer}
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.pending, id)
+		b.mu.Unlock()
+	}()
+
+	payload, err := json.Marshal(ConfirmRequest{Tool: call.Name, Arguments: call.Arguments, Permission: perm, Previews: previews})
+	if err != nil {
+		return false
+	}
+	msg := WSMessage{
+		Type:    MessageTypeConfirm,
+		Content: string(payload),
+		ID:      id,
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		return false
+	}
+
+	timer := time.NewTimer(b.Timeout)
+	defer timer.Stop()
+	select {
+	case approved := <-answer:
+		return approved
+	case <-timer.C:
+		return false
+	}
+}
+
+// Resolve delivers the answer conn sent for a pending request. It reports
+// false when the ID is unknown (already answered or timed out) or belongs to
+// a request sent to another connection.
+func (b *ConfirmationBroker) Resolve(conn *websocket.Conn, id string, approved bool) bool {
+	b.mu.Lock()
+	p, ok := b.pending[id]
+	ok = ok && p.conn == conn
+	if ok {
+		delete(b.pending, id)
+	}
+	b.mu.Unlock()
+	if ok {
+		p.answer <- approved
+	}
+	return ok
+}
+
+// Cancel denies every outstanding request sent to conn, e.g. when that
+// client disconnects. Requests on other connections keep waiting.
+func (b *ConfirmationBroker) Cancel(conn *websocket.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, p := range b.pending {
+		if p.conn == conn {
+			p.answer <- false
+			delete(b.pending, id)
+		}
+	}
+}
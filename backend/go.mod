@@ -1,10 +1,11 @@
 module nira
 
-go 1.21
+go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gorilla/websocket v1.5.1
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/net v0.17.0
 )
-
-require golang.org/x/net v0.17.0 // indirect
@@ -13,8 +13,11 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -27,38 +30,64 @@ const (
 	LogLevelError
 )
 
+// ParseLogLevel converts a configured level name.
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LogLevelDebug, nil
+	case "info", "":
+		return LogLevelInfo, nil
+	case "warn", "warning":
+		return LogLevelWarn, nil
+	case "error":
+		return LogLevelError, nil
+	}
+	return LogLevelInfo, fmt.Errorf("unknown log level '%s' (want debug, info, warn or error)", name)
+}
+
 type Logger struct {
-	Level  LogLevel
+	// level is read on every call and may change on a config reload.
+	level  atomic.Int32
 	Logger *log.Logger
 }
 
 func NewLogger(level LogLevel) *Logger {
-	return &Logger{
-		Level:  level,
+	l := &Logger{
 		Logger: log.New(os.Stdout, "", log.LstdFlags|log.Lshortfile),
 	}
+	l.SetLevel(level)
+	return l
+}
+
+// SetLevel changes the minimum level that is logged.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
+func (l *Logger) enabled(level LogLevel) bool {
+	return LogLevel(l.level.Load()) <= level
 }
 
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.Level <= LogLevelDebug {
+	if l.enabled(LogLevelDebug) {
 		l.Logger.Printf("[DEBUG] "+format, v...)
 	}
 }
 
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.Level <= LogLevelInfo {
+	if l.enabled(LogLevelInfo) {
 		l.Logger.Printf("[INFO] "+format, v...)
 	}
 }
 
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.Level <= LogLevelWarn {
+	if l.enabled(LogLevelWarn) {
 		l.Logger.Printf("[WARN] "+format, v...)
 	}
 }
 
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.Level <= LogLevelError {
+	if l.enabled(LogLevelError) {
 		l.Logger.Printf("[ERROR] "+format, v...)
 	}
 }
@@ -14,26 +14,64 @@
 package main
 
 import (
+    "flag"
+    "fmt"
+    "io"
     "log"
     "nira/memory"
     "nira/tools"
     "os"
+    "strings"
 )
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	if len(os.Args) > 1 && os.Args[1] == "version" {
+	// nira [serve|mcp|config|version] [flags]; serve is the default.
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "version":
 		log.Println("NIRA Backend v0.1.0")
 		os.Exit(0)
+	case "config":
+		if err := runConfigCommand(args); err != nil {
+			fmt.Fprintf(os.Stderr, "nira config: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "serve", "mcp":
+	default:
+		log.Fatalf("Unknown command '%s' (want serve, mcp, config or version)", command)
 	}
 
-	config, err := LoadConfig()
+	// -write: MCP clients also get tools that ask for confirmation in chat
+	mcpIncludeAsk := false
+	newFlags := func(handling flag.ErrorHandling) *flag.FlagSet {
+		flags := flag.NewFlagSet(command, handling)
+		if command == "mcp" {
+			flags.BoolVar(&mcpIncludeAsk, "write", false, "also expose tools that require confirmation in chat (write, destructive)")
+		}
+		return flags
+	}
+	config, sources, err := LoadConfig(newFlags(flag.ExitOnError), args)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	logger := NewLogger(LogLevelInfo)
+	logLevel, _ := ParseLogLevel(config.LogLevel)
+	logger := NewLogger(logLevel)
+
+	// In MCP mode stdout carries the protocol, so all logging goes to stderr.
+	mcpMode := command == "mcp"
+	if mcpMode {
+		logger.Logger.SetOutput(os.Stderr)
+	}
+	if sources.File != "" {
+		logger.Info("Loaded config from %s", sources.File)
+	}
 
 	db, err := memory.NewDatabase(config.DatabasePath)
 	if err != nil {
@@ -55,25 +93,51 @@ func main() {
      log.Printf("Warning: failed to seed allowed directories: %v", err)
  }
  memManager.AllowedDirs = allowedStore
+ // Conversation-scoped grants are revoked once another conversation is current.
+ allowedStore.CurrentConversation = func() int64 { return memManager.CurrentConvID }
 
 	ollamaClient := NewOllamaClient(config.OllamaEndpoint, config.DefaultModel)
+	ollamaClient.Options = config.Generation
 
 	toolRegistry := tools.NewRegistry()
  // Use centralized AllowedDirs store for permission checks
  fileReadTool := tools.NewFileReadToolWithChecker(config.AllowedPaths, allowedStore)
  toolRegistry.Register(fileReadTool)
 
+ // Writes and edits keep the previous content for file_history/file_undo
  fileWriteTool := tools.NewFileWriteToolWithChecker(config.AllowedPaths, allowedStore)
+ fileWriteTool.Versions = memManager.FileVersions
  toolRegistry.Register(fileWriteTool)
+ fileEditTool := tools.NewFileEditToolWithChecker(config.AllowedPaths, allowedStore)
+ fileEditTool.Versions = memManager.FileVersions
+ toolRegistry.Register(fileEditTool)
+ toolRegistry.Register(tools.NewFileHistoryTool(memManager.FileVersions, allowedStore))
+ toolRegistry.Register(tools.NewFileUndoTool(memManager.FileVersions, allowedStore))
 
  // RAG foundation tools
  listDirTool := tools.NewListDirectoryToolWithChecker(config.AllowedPaths, allowedStore)
  toolRegistry.Register(listDirTool)
  searchByNameTool := tools.NewSearchFilesByNameToolWithChecker(config.AllowedPaths, allowedStore)
  toolRegistry.Register(searchByNameTool)
+ toolRegistry.Register(tools.NewSearchFileContentsToolWithChecker(config.AllowedPaths, allowedStore))
  fileMetaTool := tools.NewFileMetadataToolWithChecker(config.AllowedPaths, allowedStore)
  toolRegistry.Register(fileMetaTool)
 
+ // File management; deletions and overwrites go to the trash
+ trash := memory.NewTrashStore(db, config.TrashDir)
+ moveTool := tools.NewMovePathToolWithChecker(config.AllowedPaths, allowedStore)
+ moveTool.Trash = trash
+ toolRegistry.Register(moveTool)
+ copyTool := tools.NewCopyPathToolWithChecker(config.AllowedPaths, allowedStore)
+ copyTool.Trash = trash
+ toolRegistry.Register(copyTool)
+ deleteTool := tools.NewDeletePathToolWithChecker(config.AllowedPaths, allowedStore)
+ deleteTool.Trash = trash
+ toolRegistry.Register(deleteTool)
+ toolRegistry.Register(tools.NewMakeDirectoryToolWithChecker(config.AllowedPaths, allowedStore))
+ toolRegistry.Register(tools.NewTrashListTool(trash, allowedStore))
+ toolRegistry.Register(tools.NewTrashRestoreTool(trash, allowedStore))
+
  // Allowed directory management tools
  toolRegistry.Register(tools.NewAllowedDirsListTool(allowedStore))
  toolRegistry.Register(tools.NewAllowedDirsAddTool(allowedStore))
@@ -84,6 +148,20 @@ func main() {
 	toolRegistry.Register(tools.NewRagIndexFolderTool(allowedStore, ragIndex))
 	toolRegistry.Register(tools.NewRagSearchTool(ragIndex, allowedStore))
 
+	// Archive inspection; extraction can feed the RAG index
+	toolRegistry.Register(tools.NewArchiveListToolWithChecker(config.AllowedPaths, allowedStore))
+	archiveExtractTool := tools.NewArchiveExtractToolWithChecker(config.AllowedPaths, allowedStore)
+	archiveExtractTool.Index = ragIndex
+	archiveExtractTool.Trash = trash
+	toolRegistry.Register(archiveExtractTool)
+
+	// Read-only git views of repositories in allowed directories
+	toolRegistry.Register(tools.NewGitStatusToolWithChecker(config.AllowedPaths, allowedStore))
+	toolRegistry.Register(tools.NewGitLogToolWithChecker(config.AllowedPaths, allowedStore))
+	toolRegistry.Register(tools.NewGitShowToolWithChecker(config.AllowedPaths, allowedStore))
+	toolRegistry.Register(tools.NewGitDiffToolWithChecker(config.AllowedPaths, allowedStore))
+	toolRegistry.Register(tools.NewGitBlameToolWithChecker(config.AllowedPaths, allowedStore))
+
 	// RP data store and tools (backend-driven RP logic)
 	rpStore := memory.NewRPStore(db)
 	toolRegistry.Register(tools.NewRPCharacterListTool(rpStore))
@@ -95,10 +173,98 @@ func main() {
 	toolRegistry.Register(tools.NewRPStoryCardSaveTool(rpStore))
 	toolRegistry.Register(tools.NewRPStoryCardDeleteTool(rpStore))
 
-	// Register WebSearchTool
-	tools.RegisterWebSearchTool(toolRegistry.Tools)
+	// Tool-call audit history
+	toolRegistry.Register(tools.NewToolAuditListTool(memManager.ToolAudit, func() int64 { return memManager.CurrentConvID }))
+
+	// Outbound HTTP from tools goes through one egress policy
+	egress, err := tools.NewEgress(config.Network)
+	if err != nil {
+		log.Fatalf("Invalid network config: %v", err)
+	}
+	egress.Log = logger.Info
+
+	// Web search over the configured providers, in fallback order
+	searchProviders, searchErrs := tools.NewSearchProviders(config.SearchProviders, egress)
+	for _, serr := range searchErrs {
+		log.Printf("Warning: skipping %v", serr)
+	}
+	webSearchTool := tools.NewWebSearchTool(searchProviders)
+	webSearchTool.Egress = egress
+	toolRegistry.Register(webSearchTool)
+	// Reading pages found by web_search; responses are cached in SQLite
+	webFetchTool := tools.NewWebFetchTool(memory.NewWebCacheStore(db), ragIndex)
+	webFetchTool.Egress = egress
+	toolRegistry.Register(webFetchTool)
+
+	// External plugin tools; built-in tools keep their names on conflict
+	plugins, pluginErrs := tools.LoadPlugins(config.PluginsDir, allowedStore)
+	for _, perr := range pluginErrs {
+		log.Printf("Warning: skipping plugin: %v", perr)
+	}
+	for _, plugin := range plugins {
+		if _, exists := toolRegistry.Get(plugin.Name()); exists {
+			log.Printf("Warning: plugin %s conflicts with an existing tool; skipped", plugin.Name())
+			continue
+		}
+		toolRegistry.Register(plugin)
+		log.Printf("Loaded plugin tool %s", plugin.Name())
+	}
+
+	// MCP servers; their tools are namespaced by server name
+	for _, serverCfg := range config.MCPServers {
+		client, err := tools.NewMCPClient(serverCfg)
+		if err != nil {
+			log.Printf("Warning: skipping MCP server: %v", err)
+			continue
+		}
+		if err := client.Start(); err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		defer client.Close()
+		for _, proxy := range client.Tools() {
+			if _, exists := toolRegistry.Get(proxy.Name()); exists {
+				log.Printf("Warning: MCP tool %s conflicts with an existing tool; skipped", proxy.Name())
+				continue
+			}
+			toolRegistry.Register(proxy)
+		}
+	}
+
+	// Offline mode: drop every network-tier tool, built-in or external, so
+	// the model is never offered one
+	if egress.Offline() {
+		for name, tool := range toolRegistry.Tools {
+			if tool.Permission() == tools.PermissionNetwork {
+				toolRegistry.Unregister(name)
+			}
+		}
+		log.Println("Offline mode: network tools are disabled")
+	}
+
+	policy, err := tools.NewPolicy(config.ToolPolicy)
+	if err != nil {
+		log.Fatalf("Invalid tool policy: %v", err)
+	}
+
+	if mcpMode {
+		if err := runMCPServer(mcpIncludeAsk, toolRegistry, logger, memManager, policy); err != nil {
+			log.Fatalf("MCP server failed: %v", err)
+		}
+		return
+	}
+
+	server := NewServer(config, ollamaClient, toolRegistry, logger, memManager, policy)
 
-	server := NewServer(config.WebSocketPort, ollamaClient, toolRegistry, logger, memManager)
+	// Reload the config on SIGHUP or when its file changes; the same
+	// command-line flags stay in force
+	reloader := NewConfigReloader(config, sources, func() (Config, ConfigSources, error) {
+		flags := newFlags(flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		return LoadConfig(flags, args)
+	}, logger)
+	reloader.Apply = server.ApplyConfig
+	go reloader.Run()
 
 	log.Println("Starting NIRA backend...")
 	if err := server.Start(); err != nil {
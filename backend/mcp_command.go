@@ -0,0 +1,55 @@
+/**
+ * MCP subcommand module.
+ *
+ * Implements `nira mcp`: serves the tool registry over MCP stdio so other
+ * local agents and editors can use NIRA's tools. There is no user to
+ * confirm calls, so only tools the policy allows outright are offered;
+ * -write also offers tools that would ask for confirmation in chat.
+ * Permission-tier tools are never offered, so clients cannot widen the
+ * allowed directories.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: mcp_command.go
+ * Description: `nira mcp` subcommand.
+ */
+
+package main
+
+import (
+	"nira/memory"
+	"nira/tools"
+	"os"
+)
+
+// runMCPServer serves registry over stdio; includeAsk is the -write flag.
+func runMCPServer(includeAsk bool, registry *tools.Registry, logger *Logger, mem *memory.Manager, policy *tools.Policy) error {
+	handler := NewToolHandler(registry, logger, policy)
+	handler.Memory = mem
+
+	server := tools.NewMCPServer(registry)
+	server.Expose = mcpExposed(policy, includeAsk)
+	server.Invoke = func(tool tools.Tool, args map[string]interface{}) (interface{}, error) {
+		return handler.Invoke(tool, &tools.Call{Name: tool.Name(), Arguments: args}, InitiatorMCP)
+	}
+	server.Instructions = "NIRA tools. File tools only reach NIRA's allowed directories; rag_search queries indexed notes and rp_* tools manage the RolePlay library."
+
+	logger.Info("Serving tools over MCP stdio")
+	return server.Serve(os.Stdin, os.Stdout)
+}
+
+// mcpExposed reports whether a tool may be offered to MCP clients.
+func mcpExposed(policy *tools.Policy, includeAsk bool) func(tools.Tool) bool {
+	return func(tool tools.Tool) bool {
+		if tool.Permission() == tools.PermissionGrant {
+			return false
+		}
+		switch policy.Decide(tool) {
+		case tools.DecisionAllow:
+			return true
+		case tools.DecisionAsk:
+			return includeAsk
+		}
+		return false
+	}
+}
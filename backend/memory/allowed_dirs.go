@@ -2,65 +2,209 @@ package memory
 
 import (
     "database/sql"
+    "encoding/json"
+    "errors"
+    "nira/sandbox"
     "path/filepath"
+    "sync"
     "time"
 )
 
+// AllowedDir is an allowed root with its access mode and deny-list.
+type AllowedDir struct {
+    Path    string       `json:"path"`
+    Mode    sandbox.Mode `json:"mode"`
+    Deny    []string     `json:"deny,omitempty"`
+    AddedAt string       `json:"added_at"`
+    // ExpiresAt is empty for grants that do not expire.
+    ExpiresAt string `json:"expires_at,omitempty"`
+    // ConversationID is set for grants scoped to one conversation.
+    ConversationID int64 `json:"conversation_id,omitempty"`
+    // FromConfig is set for grants made from allowed_paths, which a config
+    // reload may revoke again.
+    FromConfig bool `json:"from_config,omitempty"`
+}
+
+// Remaining returns the lifetime left at now, or 0 for grants that do not expire.
+func (d AllowedDir) Remaining(now time.Time) time.Duration {
+    if d.ExpiresAt == "" {
+        return 0
+    }
+    exp, err := time.Parse(time.RFC3339, d.ExpiresAt)
+    if err != nil || !exp.After(now) {
+        return 0
+    }
+    return exp.Sub(now)
+}
+
+// DirGrant describes how a directory is allowed.
+type DirGrant struct {
+    Mode sandbox.Mode
+    Deny []string
+    // TTL makes the grant expire after the duration; 0 never expires.
+    TTL time.Duration
+    // Conversation limits the grant to the current conversation.
+    Conversation bool
+}
+
+// ErrNoConversation is returned for conversation-scoped grants when the
+// store does not know the current conversation.
+var ErrNoConversation = errors.New("no current conversation to scope the grant to")
+
+// ErrPermanentGrant is returned when an expiring or conversation-scoped
+// grant would replace a permanent one; revoking the temporary grant later
+// would otherwise take the permanent one with it.
+var ErrPermanentGrant = errors.New("directory already has a permanent grant; change it without expires_in or conversation, or remove it first")
+
 // AllowedDirsStore manages the list of allowed root directories for file tools
 type AllowedDirsStore struct {
     db *Database
+    // CurrentConversation reports the active conversation; grants scoped
+    // to any other conversation are revoked. Without it, scoped grants
+    // are never in effect.
+    CurrentConversation func() int64
+
+    mu sync.Mutex
     // in-memory cache of absolute, cleaned paths
-    cache []string
+    cache []AllowedDir
+    // sandbox resolves the cached roots for IsAllowed
+    sandbox *sandbox.Sandbox
+    // nextExpiry is the earliest expiry among cached grants
+    nextExpiry time.Time
+    // loadedConv is the conversation the cache was built for
+    loadedConv int64
 }
 
 func NewAllowedDirsStore(db *Database) (*AllowedDirsStore, error) {
     s := &AllowedDirsStore{db: db}
-    if err := s.loadCache(); err != nil {
+    if err := s.reload(); err != nil {
         return nil, err
     }
     return s, nil
 }
 
+func (s *AllowedDirsStore) currentConversation() int64 {
+    if s.CurrentConversation == nil {
+        return 0
+    }
+    return s.CurrentConversation()
+}
+
+// reload revokes stale grants and rebuilds the cache.
+func (s *AllowedDirsStore) reload() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return s.loadCache()
+}
+
+// loadCache deletes expired grants and grants scoped to a conversation
+// other than the current one, then reloads the rest. Callers hold mu.
 func (s *AllowedDirsStore) loadCache() error {
-    rows, err := s.db.DB.Query("SELECT path FROM allowed_directories ORDER BY id ASC")
+    now := time.Now().UTC()
+    conv := s.currentConversation()
+    // The table may predate this store; errors surface from the query below.
+    _, _ = s.db.DB.Exec("DELETE FROM allowed_directories WHERE expires_at != '' AND expires_at <= ?", now.Format(time.RFC3339))
+    if conv != 0 {
+        _, _ = s.db.DB.Exec("DELETE FROM allowed_directories WHERE conversation_id != 0 AND conversation_id != ?", conv)
+    }
+
+    const query = "SELECT path, mode, deny_json, added_at, expires_at, conversation_id, from_config FROM allowed_directories ORDER BY id ASC"
+    rows, err := s.db.DB.Query(query)
     if err != nil {
         // If table is missing for some reason, try to init again
         if err2 := s.db.InitializeSchema(); err2 != nil {
             return err
         }
-        rows, err = s.db.DB.Query("SELECT path FROM allowed_directories ORDER BY id ASC")
+        rows, err = s.db.DB.Query(query)
         if err != nil {
             return err
         }
     }
     defer rows.Close()
-    s.cache = []string{}
+    s.cache = []AllowedDir{}
+    s.nextExpiry = time.Time{}
+    s.loadedConv = conv
+    var specs []sandbox.Spec
     for rows.Next() {
-        var p string
-        if err := rows.Scan(&p); err == nil {
-            s.cache = append(s.cache, p)
+        var d AllowedDir
+        var mode, deny string
+        if err := rows.Scan(&d.Path, &mode, &deny, &d.AddedAt, &d.ExpiresAt, &d.ConversationID, &d.FromConfig); err != nil {
+            continue
+        }
+        if d.Mode, err = sandbox.ParseMode(mode); err != nil {
+            // An unknown mode must not widen access.
+            d.Mode = sandbox.ModeReadOnly
+        }
+        _ = json.Unmarshal([]byte(deny), &d.Deny)
+        if d.ExpiresAt != "" {
+            exp, err := time.Parse(time.RFC3339, d.ExpiresAt)
+            if err != nil || !exp.After(now) {
+                continue
+            }
+            if s.nextExpiry.IsZero() || exp.Before(s.nextExpiry) {
+                s.nextExpiry = exp
+            }
         }
+        if d.ConversationID != 0 && d.ConversationID != conv {
+            continue
+        }
+        s.cache = append(s.cache, d)
+        specs = append(specs, sandbox.Spec{Path: d.Path, Mode: d.Mode, Deny: d.Deny})
     }
+    s.sandbox = sandbox.FromSpecs(specs)
     return rows.Err()
 }
 
+// current returns the sandbox, first revoking grants that expired or whose
+// conversation is no longer current.
+func (s *AllowedDirsStore) current() *sandbox.Sandbox {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    expired := !s.nextExpiry.IsZero() && !time.Now().Before(s.nextExpiry)
+    if expired || s.currentConversation() != s.loadedConv {
+        _ = s.loadCache()
+    }
+    return s.sandbox
+}
+
 // EnsureSeed inserts initial allowed paths if the table is empty.
 func (s *AllowedDirsStore) EnsureSeed(paths []string) error {
     // if any rows exist, skip
     var count int
     _ = s.db.DB.QueryRow("SELECT COUNT(1) FROM allowed_directories").Scan(&count)
     if count > 0 {
-        return s.loadCache()
+        return s.reload()
     }
     for _, p := range paths {
         if p == "" { continue }
-        _ = s.Add(p)
+        _ = s.AddFromConfig(p)
     }
-    return s.loadCache()
+    return s.reload()
 }
 
 // List returns the cached list of allowed directories (absolute paths)
-func (s *AllowedDirsStore) List() []string { return append([]string{}, s.cache...) }
+func (s *AllowedDirsStore) List() []string {
+    entries := s.Entries()
+    paths := make([]string, 0, len(entries))
+    for _, d := range entries {
+        paths = append(paths, d.Path)
+    }
+    return paths
+}
+
+// Entries returns the allowed directories in effect with their modes,
+// deny-lists, expiry and scope.
+func (s *AllowedDirsStore) Entries() []AllowedDir {
+    s.current()
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    entries := make([]AllowedDir, 0, len(s.cache))
+    for _, d := range s.cache {
+        d.Deny = append([]string(nil), d.Deny...)
+        entries = append(entries, d)
+    }
+    return entries
+}
 
 // Add inserts a directory into the table (normalized absolute path). No-op if exists.
 func (s *AllowedDirsStore) Add(path string) error {
@@ -73,7 +217,66 @@ func (s *AllowedDirsStore) Add(path string) error {
         abs, time.Now().UTC().Format(time.RFC3339),
     )
     if err != nil { return err }
-    return s.loadCache()
+    return s.reload()
+}
+
+// AddFromConfig inserts a directory listed in allowed_paths, marked so that
+// RemoveFromConfig can revoke it. No-op if the directory already has a grant;
+// that grant stays the user's.
+func (s *AllowedDirsStore) AddFromConfig(path string) error {
+    if path == "" { return nil }
+    abs, err := filepath.Abs(path)
+    if err != nil { return err }
+    abs = filepath.Clean(abs)
+    _, err = s.db.DB.Exec(
+        "INSERT OR IGNORE INTO allowed_directories(path, added_at, from_config) VALUES(?, ?, 1)",
+        abs, time.Now().UTC().Format(time.RFC3339),
+    )
+    if err != nil { return err }
+    return s.reload()
+}
+
+// Set adds a directory or replaces the grant of an existing one with the
+// given access mode, deny patterns, lifetime and scope. A temporary grant
+// never replaces a permanent one (ErrPermanentGrant). The grant is the
+// user's afterwards, even if allowed_paths made it.
+func (s *AllowedDirsStore) Set(path string, g DirGrant) error {
+    if path == "" { return nil }
+    abs, err := filepath.Abs(path)
+    if err != nil { return err }
+    abs = filepath.Clean(abs)
+    mode := g.Mode
+    if mode == "" { mode = sandbox.ModeReadWrite }
+    deny := g.Deny
+    if deny == nil { deny = []string{} }
+    denyJSON, err := json.Marshal(deny)
+    if err != nil { return err }
+    now := time.Now().UTC()
+    expiresAt := ""
+    if g.TTL > 0 {
+        expiresAt = now.Add(g.TTL).Format(time.RFC3339)
+    }
+    var conv int64
+    if g.Conversation {
+        if conv = s.currentConversation(); conv == 0 {
+            return ErrNoConversation
+        }
+    }
+    // The temporary upsert only matches rows that are temporary themselves;
+    // a permanent row makes it a no-op, reported below.
+    query := `INSERT INTO allowed_directories(path, added_at, mode, deny_json, expires_at, conversation_id) VALUES(?, ?, ?, ?, ?, ?)
+         ON CONFLICT(path) DO UPDATE SET mode = excluded.mode, deny_json = excluded.deny_json,
+             expires_at = excluded.expires_at, conversation_id = excluded.conversation_id, from_config = 0`
+    temporary := expiresAt != "" || conv != 0
+    if temporary {
+        query += " WHERE expires_at != '' OR conversation_id != 0"
+    }
+    res, err := s.db.DB.Exec(query, abs, now.Format(time.RFC3339), string(mode), string(denyJSON), expiresAt, conv)
+    if err != nil { return err }
+    if n, err := res.RowsAffected(); err == nil && n == 0 && temporary {
+        return ErrPermanentGrant
+    }
+    return s.reload()
 }
 
 // Remove deletes a directory row (by absolute normalized path).
@@ -83,22 +286,30 @@ func (s *AllowedDirsStore) Remove(path string) error {
     abs = filepath.Clean(abs)
     _, err = s.db.DB.Exec("DELETE FROM allowed_directories WHERE path = ?", abs)
     if err != nil { return err }
-    return s.loadCache()
+    return s.reload()
+}
+
+// RemoveFromConfig deletes a directory row made by AddFromConfig; grants
+// the user made for the same path are kept.
+func (s *AllowedDirsStore) RemoveFromConfig(path string) error {
+    abs, err := filepath.Abs(path)
+    if err != nil { return err }
+    abs = filepath.Clean(abs)
+    _, err = s.db.DB.Exec("DELETE FROM allowed_directories WHERE path = ? AND from_config = 1", abs)
+    if err != nil { return err }
+    return s.reload()
 }
 
-// IsAllowed checks whether the given path is within any allowed directory.
+// IsAllowed checks whether the given path is within any allowed directory,
+// after resolving symlinks in both, and not deny-listed.
 func (s *AllowedDirsStore) IsAllowed(path string) bool {
-    if len(s.cache) == 0 { return false }
-    absPath, err := filepath.Abs(path)
-    if err != nil { return false }
-    for _, allowed := range s.cache {
-        rel, err := filepath.Rel(allowed, absPath)
-        if err != nil { continue }
-        if rel != ".." && !filepath.IsAbs(rel) {
-            return true
-        }
-    }
-    return false
+    return s.current().IsAllowed(path)
+}
+
+// CheckAccess checks path against the mode and deny-list of the innermost
+// allowed directory containing it.
+func (s *AllowedDirsStore) CheckAccess(path string, access sandbox.Access) error {
+    return s.current().CheckAccess(path, access)
 }
 
 // Helper used in tests to clear all rows
@@ -24,7 +24,8 @@ type Database struct {
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL")
+	// busy_timeout lets concurrent writers (e.g. parallel tool audits) wait instead of failing.
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -81,7 +82,12 @@ func (d *Database) InitializeSchema() error {
 	CREATE TABLE IF NOT EXISTS allowed_directories (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		path TEXT UNIQUE NOT NULL,
-		added_at TEXT NOT NULL
+		added_at TEXT NOT NULL,
+		mode TEXT NOT NULL DEFAULT 'read_write',
+		deny_json TEXT NOT NULL DEFAULT '[]',
+		expires_at TEXT NOT NULL DEFAULT '',
+		conversation_id INTEGER NOT NULL DEFAULT 0,
+		from_config INTEGER NOT NULL DEFAULT 0
 	);
 
 	-- Lightweight RAG text index (basic, non-embedding)
@@ -125,12 +131,101 @@ func (d *Database) InitializeSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_rp_story_cards_title ON rp_story_cards(title);
 	CREATE INDEX IF NOT EXISTS idx_rp_story_cards_kind ON rp_story_cards(kind);
 	CREATE INDEX IF NOT EXISTS idx_rp_story_cards_updated ON rp_story_cards(updated_at);
+
+	-- Audit log of tool invocations (user- and model-initiated)
+	CREATE TABLE IF NOT EXISTS tool_calls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		tool_name TEXT NOT NULL,
+		arguments TEXT NOT NULL DEFAULT '',
+		result_summary TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		initiator TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_tool_calls_conversation ON tool_calls(conversation_id);
+	CREATE INDEX IF NOT EXISTS idx_tool_calls_tool ON tool_calls(tool_name);
+
+	-- Previous contents of files changed by tools (backups for undo)
+	CREATE TABLE IF NOT EXISTS file_versions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		path TEXT NOT NULL,
+		existed BOOLEAN NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		content BLOB NOT NULL,
+		tool TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_file_versions_path ON file_versions(path, id);
+
+	-- Files and directories removed by delete_path, kept for restore
+	CREATE TABLE IF NOT EXISTS trash (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		original_path TEXT NOT NULL,
+		trash_path TEXT NOT NULL,
+		is_dir BOOLEAN NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		tool TEXT NOT NULL DEFAULT '',
+		deleted_at TEXT NOT NULL
+	);
+
+	-- Pages downloaded by web_fetch, revalidated by ETag/Last-Modified
+	CREATE TABLE IF NOT EXISTS web_cache (
+		url TEXT PRIMARY KEY,
+		final_url TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		body BLOB NOT NULL,
+		fetched_at TEXT NOT NULL
+	);
     `
 
 	if _, err := d.DB.Exec(schema); err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	return d.addMissingColumns()
+}
+
+// addedColumns lists columns introduced after their table was first
+// released; databases created before that get them added on startup.
+var addedColumns = []struct{ table, column, decl string }{
+	{"allowed_directories", "mode", "TEXT NOT NULL DEFAULT 'read_write'"},
+	{"allowed_directories", "deny_json", "TEXT NOT NULL DEFAULT '[]'"},
+	{"allowed_directories", "expires_at", "TEXT NOT NULL DEFAULT ''"},
+	{"allowed_directories", "conversation_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"allowed_directories", "from_config", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func (d *Database) addMissingColumns() error {
+	for _, c := range addedColumns {
+		rows, err := d.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", c.table))
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", c.table, err)
+		}
+		found := false
+		for rows.Next() {
+			var (
+				cid, notNull, pk int
+				name, typ        string
+				dflt             sql.NullString
+			)
+			if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err == nil && name == c.column {
+				found = true
+			}
+		}
+		rows.Close()
+		if found {
+			continue
+		}
+		if _, err := d.DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.decl)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,165 @@
+/**
+ * File version store module.
+ *
+ * Keeps the previous content of every file NIRA's tools overwrite, edit
+ * or restore, so changes can be listed and undone. A version with
+ * Existed=false records that the file did not exist before the change.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: file_versions.go
+ * Description: Versioned backups of files changed by tools.
+ */
+
+package memory
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// MaxVersionsPerFile bounds how many backups are kept per path; older ones are pruned.
+const MaxVersionsPerFile = 20
+
+// ErrVersionNotFound is returned when a version ID does not exist for a path.
+var ErrVersionNotFound = errors.New("file version not found")
+
+type FileVersion struct {
+	ID        int64  `json:"id"`
+	Path      string `json:"path"`
+	Existed   bool   `json:"existed"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256,omitempty"`
+	Tool      string `json:"tool"`
+	CreatedAt string `json:"created_at"`
+	Content   []byte `json:"-"`
+}
+
+type FileVersionStore struct {
+	DB *Database
+}
+
+func NewFileVersionStore(db *Database) *FileVersionStore {
+	return &FileVersionStore{DB: db}
+}
+
+// Save records the content a file had before tool changed it and prunes old versions.
+func (s *FileVersionStore) Save(path string, content []byte, existed bool, tool string) (int64, error) {
+	abs, err := normalizeVersionPath(path)
+	if err != nil {
+		return 0, err
+	}
+	sum := ""
+	if existed {
+		h := sha256.Sum256(content)
+		sum = hex.EncodeToString(h[:])
+	}
+	if content == nil {
+		content = []byte{}
+	}
+
+	tx, err := s.DB.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to save file version: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO file_versions (path, existed, size, sha256, content, tool, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		abs, existed, len(content), sum, content, tool, time.Now().UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save file version: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file version ID: %w", err)
+	}
+	_, err = tx.Exec(
+		`DELETE FROM file_versions WHERE path = ? AND id NOT IN (SELECT id FROM file_versions WHERE path = ? ORDER BY id DESC LIMIT ?)`,
+		abs, abs, MaxVersionsPerFile,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune file versions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to save file version: %w", err)
+	}
+	return id, nil
+}
+
+// List returns the saved versions of a file, newest first, without content.
+func (s *FileVersionStore) List(path string, limit int) ([]FileVersion, error) {
+	abs, err := normalizeVersionPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = MaxVersionsPerFile
+	}
+	rows, err := s.DB.DB.Query(
+		`SELECT id, path, existed, size, sha256, tool, created_at FROM file_versions WHERE path = ? ORDER BY id DESC LIMIT ?`,
+		abs, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []FileVersion{}
+	for rows.Next() {
+		var v FileVersion
+		if err := rows.Scan(&v.ID, &v.Path, &v.Existed, &v.Size, &v.SHA256, &v.Tool, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan file version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Get returns one version of a file including its content. An id of 0
+// selects the newest version.
+func (s *FileVersionStore) Get(path string, id int64) (*FileVersion, error) {
+	abs, err := normalizeVersionPath(path)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id, path, existed, size, sha256, tool, created_at, content FROM file_versions WHERE path = ?`
+	args := []interface{}{abs}
+	if id > 0 {
+		query += " AND id = ?"
+		args = append(args, id)
+	}
+	query += " ORDER BY id DESC LIMIT 1"
+
+	var v FileVersion
+	err = s.DB.DB.QueryRow(query, args...).Scan(&v.ID, &v.Path, &v.Existed, &v.Size, &v.SHA256, &v.Tool, &v.CreatedAt, &v.Content)
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file version: %w", err)
+	}
+	return &v, nil
+}
+
+func normalizeVersionPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	// Symlinked paths share the history of the file they point to.
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	// A file that does not exist yet is keyed under its resolved directory.
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs)), nil
+	}
+	return filepath.Clean(abs), nil
+}
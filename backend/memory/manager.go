@@ -18,6 +18,8 @@ type Manager struct {
     Memories      *MemoryStore
     CurrentConvID int64
     AllowedDirs   *AllowedDirsStore
+    ToolAudit     *ToolAuditStore
+    FileVersions  *FileVersionStore
 }
 
 func NewManager(db *Database) (*Manager, error) {
@@ -27,6 +29,8 @@ func NewManager(db *Database) (*Manager, error) {
     manager := &Manager{
         Conversations: convStore,
         Memories:      memStore,
+        ToolAudit:     NewToolAuditStore(db),
+        FileVersions:  NewFileVersionStore(db),
     }
 
 	currentID, err := convStore.GetCurrentConversation()
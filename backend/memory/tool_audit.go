@@ -0,0 +1,95 @@
+/**
+ * Tool call audit module.
+ *
+ * Persists every tool invocation, whether requested directly by the user
+ * or initiated by the model, together with its arguments, outcome and
+ * timing so past actions can be reviewed per conversation.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: tool_audit.go
+ * Description: Tool call audit log persistence.
+ */
+
+package memory
+
+import (
+	"fmt"
+	"time"
+)
+
+type ToolCallRecord struct {
+	ID             int64  `json:"id"`
+	ConversationID int64  `json:"conversation_id"`
+	ToolName       string `json:"tool_name"`
+	Arguments      string `json:"arguments"`
+	ResultSummary  string `json:"result_summary"`
+	Error          string `json:"error,omitempty"`
+	DurationMs     int64  `json:"duration_ms"`
+	Initiator      string `json:"initiator"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type ToolAuditStore struct {
+	DB *Database
+}
+
+func NewToolAuditStore(db *Database) *ToolAuditStore {
+	return &ToolAuditStore{DB: db}
+}
+
+// Record stores a tool call and returns its ID. CreatedAt is filled in when empty.
+func (s *ToolAuditStore) Record(rec *ToolCallRecord) (int64, error) {
+	if rec.CreatedAt == "" {
+		rec.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	result, err := s.DB.DB.Exec(
+		`INSERT INTO tool_calls (conversation_id, tool_name, arguments, result_summary, error, duration_ms, initiator, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.ConversationID, rec.ToolName, rec.Arguments, rec.ResultSummary, rec.Error, rec.DurationMs, rec.Initiator, rec.CreatedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record tool call: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tool call ID: %w", err)
+	}
+	rec.ID = id
+	return id, nil
+}
+
+// List returns recorded calls for a conversation, newest first. An empty
+// toolName matches every tool.
+func (s *ToolAuditStore) List(conversationID int64, toolName string, limit, offset int) ([]ToolCallRecord, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	query := "SELECT id, conversation_id, tool_name, arguments, result_summary, error, duration_ms, initiator, created_at FROM tool_calls WHERE conversation_id = ?"
+	args := []interface{}{conversationID}
+	if toolName != "" {
+		query += " AND tool_name = ?"
+		args = append(args, toolName)
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := s.DB.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	records := []ToolCallRecord{}
+	for rows.Next() {
+		var rec ToolCallRecord
+		if err := rows.Scan(&rec.ID, &rec.ConversationID, &rec.ToolName, &rec.Arguments, &rec.ResultSummary, &rec.Error, &rec.DurationMs, &rec.Initiator, &rec.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tool call: %w", err)
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,135 @@
+/**
+ * Trash store module.
+ *
+ * Files and directories deleted by NIRA's tools are moved into a trash
+ * directory instead of being removed, and recorded here with their
+ * original location so they can be listed and restored.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: trash.go
+ * Description: Recoverable trash for deleted paths.
+ */
+
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ErrTrashItemNotFound is returned when a trash ID does not exist.
+var ErrTrashItemNotFound = errors.New("trash item not found")
+
+type TrashItem struct {
+	ID           int64  `json:"id"`
+	OriginalPath string `json:"original_path"`
+	TrashPath    string `json:"trash_path"`
+	IsDir        bool   `json:"is_dir"`
+	Size         int64  `json:"size"`
+	Tool         string `json:"tool"`
+	DeletedAt    string `json:"deleted_at"`
+}
+
+type TrashStore struct {
+	DB *Database
+	// Dir is where trashed paths are kept on disk.
+	Dir string
+}
+
+func NewTrashStore(db *Database, dir string) *TrashStore {
+	return &TrashStore{DB: db, Dir: dir}
+}
+
+// Reserve returns a fresh location in the trash directory for an entry
+// named base, creating the directory if needed.
+func (s *TrashStore) Reserve(base string) (string, error) {
+	if s.Dir == "" {
+		return "", errors.New("trash directory is not configured")
+	}
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid trash directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	stamp := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for i := 0; ; i++ {
+		name := stamp + "-" + base
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d-%s", stamp, i, base)
+		}
+		p := filepath.Join(dir, name)
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			return p, nil
+		}
+	}
+}
+
+// Add records an entry moved into the trash.
+func (s *TrashStore) Add(item TrashItem) (int64, error) {
+	if item.DeletedAt == "" {
+		item.DeletedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	result, err := s.DB.DB.Exec(
+		`INSERT INTO trash (original_path, trash_path, is_dir, size, tool, deleted_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		item.OriginalPath, item.TrashPath, item.IsDir, item.Size, item.Tool, item.DeletedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record trash item: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// List returns trash entries, newest first.
+func (s *TrashStore) List(limit int) ([]TrashItem, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.DB.DB.Query(
+		`SELECT id, original_path, trash_path, is_dir, size, tool, deleted_at FROM trash ORDER BY id DESC LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		var it TrashItem
+		if err := rows.Scan(&it.ID, &it.OriginalPath, &it.TrashPath, &it.IsDir, &it.Size, &it.Tool, &it.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// Get returns one trash entry.
+func (s *TrashStore) Get(id int64) (*TrashItem, error) {
+	var it TrashItem
+	err := s.DB.DB.QueryRow(
+		`SELECT id, original_path, trash_path, is_dir, size, tool, deleted_at FROM trash WHERE id = ?`, id,
+	).Scan(&it.ID, &it.OriginalPath, &it.TrashPath, &it.IsDir, &it.Size, &it.Tool, &it.DeletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTrashItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash item: %w", err)
+	}
+	return &it, nil
+}
+
+// Delete forgets a trash entry, typically after it was restored.
+func (s *TrashStore) Delete(id int64) error {
+	if _, err := s.DB.DB.Exec(`DELETE FROM trash WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete trash item: %w", err)
+	}
+	return nil
+}
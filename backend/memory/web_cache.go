@@ -0,0 +1,79 @@
+/**
+ * Web cache store module.
+ *
+ * Keeps the responses web_fetch downloads, keyed by URL, together with
+ * the validators (ETag, Last-Modified) needed to revalidate them with a
+ * conditional request instead of downloading the page again.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: web_cache.go
+ * Description: SQLite cache of fetched web pages.
+ */
+
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type WebCacheEntry struct {
+	URL          string
+	FinalURL     string
+	Status       int
+	ContentType  string
+	ETag         string
+	LastModified string
+	Body         []byte
+	FetchedAt    time.Time
+}
+
+type WebCacheStore struct {
+	DB *Database
+}
+
+func NewWebCacheStore(db *Database) *WebCacheStore {
+	return &WebCacheStore{DB: db}
+}
+
+// Get returns the cached response for url, or nil when there is none.
+func (s *WebCacheStore) Get(url string) (*WebCacheEntry, error) {
+	var e WebCacheEntry
+	var fetched string
+	err := s.DB.DB.QueryRow(
+		`SELECT url, final_url, status, content_type, etag, last_modified, body, fetched_at FROM web_cache WHERE url = ?`, url,
+	).Scan(&e.URL, &e.FinalURL, &e.Status, &e.ContentType, &e.ETag, &e.LastModified, &e.Body, &fetched)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read web cache: %w", err)
+	}
+	e.FetchedAt, _ = time.Parse(time.RFC3339Nano, fetched)
+	return &e, nil
+}
+
+// Put stores or replaces the cached response for e.URL.
+func (s *WebCacheStore) Put(e WebCacheEntry) error {
+	if e.FetchedAt.IsZero() {
+		e.FetchedAt = time.Now()
+	}
+	_, err := s.DB.DB.Exec(
+		`INSERT OR REPLACE INTO web_cache (url, final_url, status, content_type, etag, last_modified, body, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.URL, e.FinalURL, e.Status, e.ContentType, e.ETag, e.LastModified, e.Body, e.FetchedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write web cache: %w", err)
+	}
+	return nil
+}
+
+// Touch marks a cached response as revalidated now.
+func (s *WebCacheStore) Touch(url string) error {
+	if _, err := s.DB.DB.Exec(`UPDATE web_cache SET fetched_at = ? WHERE url = ?`, time.Now().UTC().Format(time.RFC3339Nano), url); err != nil {
+		return fmt.Errorf("failed to update web cache: %w", err)
+	}
+	return nil
+}
@@ -22,6 +22,15 @@ const (
 	MessageTypeSystem    MessageType = "system"
 	MessageTypeError    MessageType = "error"
 	MessageTypeChunk    MessageType = "chunk"
+	// MessageTypeConfirm asks the user to approve a tool call (backend → frontend)
+	// and carries the answer back (frontend → backend, content "approve" or "deny").
+	MessageTypeConfirm MessageType = "confirm"
+	// MessageTypeAudit requests (frontend → backend) and returns (backend → frontend)
+	// the tool-call audit history as JSON.
+	MessageTypeAudit MessageType = "audit"
+	// MessageTypeConfig tells clients (backend → frontend) that a config
+	// reload changed settings; content is a ConfigNotice as JSON.
+	MessageTypeConfig MessageType = "config"
 )
 
 type WSMessage struct {
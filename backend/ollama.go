@@ -18,11 +18,18 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 )
 
 type OllamaClient struct {
 	Endpoint string
 	Model    string
+	// Options are Ollama model options (temperature, num_ctx, ...) sent
+	// with every request.
+	Options map[string]interface{}
+
+	// mu guards Model and Options, which a config reload may replace.
+	mu sync.RWMutex
 }
 
 type ChatMessage struct {
@@ -31,9 +38,10 @@ type ChatMessage struct {
 }
 
 type ChatRequest struct {
-	Model    string        `json:"model"`
-	Messages []ChatMessage `json:"messages"`
-	Stream   bool          `json:"stream"`
+	Model    string                 `json:"model"`
+	Messages []ChatMessage          `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
 }
 
 type ChatResponseChunk struct {
@@ -53,14 +61,24 @@ func NewOllamaClient(endpoint string, model string) *OllamaClient {
 	}
 }
 
+// SetModel switches the model and options used by later requests.
+func (c *OllamaClient) SetModel(model string, options map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Model, c.Options = model, options
+}
+
 func (c *OllamaClient) Chat(messages []ChatMessage, onChunk func(string) error) error {
 	url := fmt.Sprintf("%s/api/chat", c.Endpoint)
 
+	c.mu.RLock()
 	reqBody := ChatRequest{
 		Model:    c.Model,
 		Messages: messages,
 		Stream:   true,
+		Options:  c.Options,
 	}
+	c.mu.RUnlock()
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
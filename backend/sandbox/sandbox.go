@@ -0,0 +1,409 @@
+/**
+ * Filesystem sandbox module.
+ *
+ * Decides whether a path lies inside a set of root directories. Both the
+ * path and the roots are resolved to their real locations first, so a
+ * symlink inside a root cannot lead outside of it and "../" segments cannot
+ * climb out. Paths that do not exist yet (write targets) are resolved
+ * through their nearest existing ancestor. On case-insensitive filesystems
+ * the comparison ignores case.
+ *
+ * Each root carries an access mode (read-only, read-write or index-only)
+ * and an optional deny-list of glob patterns relative to the root.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: sandbox.go
+ * Description: Symlink- and traversal-safe path containment checks.
+ */
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxLinkHops bounds the symlinks followed while resolving one path.
+const maxLinkHops = 40
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// ErrNotAllowed is returned for paths outside every root.
+var ErrNotAllowed = errors.New("not in allowed directories")
+
+// Access is the kind of operation a path is checked for.
+type Access int
+
+const (
+	AccessRead Access = iota
+	AccessWrite
+	// AccessIndex is reading by the indexer.
+	AccessIndex
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessWrite:
+		return "write"
+	case AccessIndex:
+		return "index"
+	}
+	return "read"
+}
+
+// Mode is the access granted under a root.
+type Mode string
+
+const (
+	ModeReadWrite Mode = "read_write"
+	ModeReadOnly  Mode = "read_only"
+	// ModeIndexOnly lets the indexer read files that file tools cannot.
+	ModeIndexOnly Mode = "index_only"
+)
+
+// ParseMode accepts a mode name ("read-only" and "read_only" alike); an
+// empty name means read-write.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")) {
+	case "", ModeReadWrite:
+		return ModeReadWrite, nil
+	case ModeReadOnly:
+		return ModeReadOnly, nil
+	case ModeIndexOnly:
+		return ModeIndexOnly, nil
+	}
+	return "", fmt.Errorf("unknown access mode %q (use read_only, read_write or index_only)", name)
+}
+
+// Permits reports whether the mode allows the access.
+func (m Mode) Permits(a Access) bool {
+	switch m {
+	case ModeReadOnly:
+		return a != AccessWrite
+	case ModeIndexOnly:
+		return a == AccessIndex
+	}
+	return true
+}
+
+// Spec configures one root.
+type Spec struct {
+	Path string
+	Mode Mode
+	// Deny lists glob patterns, relative to the root, that are never
+	// accessible.
+	Deny []string
+}
+
+// Root is a resolved sandbox root.
+type Root struct {
+	// Path is the root as configured (absolute and cleaned).
+	Path string
+	// Real is the root with symlinks resolved.
+	Real string
+	// Fold is set when the root lives on a case-insensitive filesystem.
+	Fold bool
+	Mode Mode
+	Deny []string
+}
+
+// AccessError reports a path inside a root that the root's mode or
+// deny-list does not allow.
+type AccessError struct {
+	Path    string
+	Root    string
+	Access  Access
+	Mode    Mode
+	Pattern string
+}
+
+func (e *AccessError) Error() string {
+	if e.Pattern != "" {
+		return fmt.Sprintf("access to '%s' denied: it matches the deny pattern '%s' of allowed directory '%s'", e.Path, e.Pattern, e.Root)
+	}
+	return fmt.Sprintf("%s access to '%s' denied: allowed directory '%s' is %s", e.Access, e.Path, e.Root, strings.ReplaceAll(string(e.Mode), "_", "-"))
+}
+
+// Sandbox is a set of roots that paths are checked against.
+type Sandbox struct {
+	roots []Root
+}
+
+// New resolves the given root directories as read-write roots. Roots that
+// cannot be made absolute are skipped; roots that do not exist are kept as
+// written.
+func New(roots []string) *Sandbox {
+	specs := make([]Spec, 0, len(roots))
+	for _, r := range roots {
+		specs = append(specs, Spec{Path: r, Mode: ModeReadWrite})
+	}
+	return FromSpecs(specs)
+}
+
+// FromSpecs resolves roots with their modes and deny-lists.
+func FromSpecs(specs []Spec) *Sandbox {
+	s := &Sandbox{}
+	for _, spec := range specs {
+		if spec.Path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(spec.Path)
+		if err != nil {
+			continue
+		}
+		abs = filepath.Clean(abs)
+		real, err := Resolve(abs)
+		if err != nil {
+			real = abs
+		}
+		mode := spec.Mode
+		if mode == "" {
+			mode = ModeReadWrite
+		}
+		s.roots = append(s.roots, Root{Path: abs, Real: real, Fold: IsCaseInsensitive(real), Mode: mode, Deny: spec.Deny})
+	}
+	return s
+}
+
+// Roots returns the resolved roots.
+func (s *Sandbox) Roots() []Root {
+	return append([]Root{}, s.roots...)
+}
+
+// IsAllowed reports whether path lies inside one of the roots and is not
+// deny-listed, whatever the root's mode.
+func (s *Sandbox) IsAllowed(path string) bool {
+	return s.CheckAccess(path, AccessIndex) == nil
+}
+
+// CheckAccess returns nil when the innermost root containing path permits
+// the access and none of its deny patterns match. Otherwise the error wraps
+// ErrNotAllowed or is an *AccessError.
+func (s *Sandbox) CheckAccess(path string, access Access) error {
+	root, rel, ok := s.match(path)
+	if !ok {
+		return fmt.Errorf("path '%s' is %w", path, ErrNotAllowed)
+	}
+	if pattern := denied(root, rel); pattern != "" {
+		return &AccessError{Path: path, Root: root.Path, Access: access, Mode: root.Mode, Pattern: pattern}
+	}
+	if !root.Mode.Permits(access) {
+		return &AccessError{Path: path, Root: root.Path, Access: access, Mode: root.Mode}
+	}
+	return nil
+}
+
+// Match returns the innermost root containing path.
+func (s *Sandbox) Match(path string) (Root, bool) {
+	root, _, ok := s.match(path)
+	return root, ok
+}
+
+// match finds the innermost root containing path and the path relative to
+// it, slash-separated.
+func (s *Sandbox) match(path string) (Root, string, bool) {
+	if s == nil || path == "" || len(s.roots) == 0 {
+		return Root{}, "", false
+	}
+	real, err := Resolve(path)
+	if err != nil {
+		return Root{}, "", false
+	}
+	var best Root
+	var bestRel string
+	found := false
+	for _, r := range s.roots {
+		rel, ok := relWithin(r, real)
+		if ok && (!found || len(r.Real) > len(best.Real)) {
+			best, bestRel, found = r, rel, true
+		}
+	}
+	return best, bestRel, found
+}
+
+// denied returns the first deny pattern matching rel or one of its parent
+// directories.
+func denied(r Root, rel string) string {
+	if rel == "." || len(r.Deny) == 0 {
+		return ""
+	}
+	if r.Fold {
+		rel = strings.ToLower(rel)
+	}
+	parts := strings.Split(rel, "/")
+	for _, pattern := range r.Deny {
+		p := pattern
+		if r.Fold {
+			p = strings.ToLower(p)
+		}
+		for i := 1; i <= len(parts); i++ {
+			if MatchGlob(p, strings.Join(parts[:i], "/")) {
+				return pattern
+			}
+		}
+	}
+	return ""
+}
+
+// MatchGlob matches a slash-separated relative path against a glob. "**"
+// matches any number of directories; a pattern without a slash matches the
+// last path element at any depth, like .gitignore patterns do. A trailing
+// slash is ignored.
+func MatchGlob(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	if pattern == "" {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := pathpkg.Match(pattern, pathpkg.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := pathpkg.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// Within reports whether path lies inside root, both resolved.
+func Within(root, path string) bool {
+	return New([]string{root}).IsAllowed(path)
+}
+
+func relWithin(r Root, real string) (string, bool) {
+	root := r.Real
+	if r.Fold {
+		root, real = strings.ToLower(root), strings.ToLower(real)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || filepath.IsAbs(rel) {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Resolve returns the absolute, symlink-free form of path. Components are
+// resolved one at a time the way the kernel does, so "link/../x" means the
+// parent of the link's target rather than the lexical parent. A missing
+// component is kept as written and resolving goes on after it, since a
+// later ".." can step back onto a symlink; a dangling symlink is followed
+// to where it points, so creating the file cannot land outside the sandbox.
+func Resolve(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("empty path")
+	}
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		// Not filepath.Join: cleaning would drop ".." lexically.
+		path = wd + string(filepath.Separator) + path
+	}
+	vol := filepath.VolumeName(path)
+	real := vol + string(filepath.Separator)
+	pending := splitPath(path[len(vol):])
+	hops := 0
+	for len(pending) > 0 {
+		comp := pending[0]
+		pending = pending[1:]
+		switch comp {
+		case ".":
+			continue
+		case "..":
+			real = filepath.Dir(real)
+			continue
+		}
+		next := filepath.Join(real, comp)
+		info, err := os.Lstat(next)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Keep going: a later ".." climbs out of the missing
+			// component and may land on a symlink again.
+			real = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			real = next
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return "", errTooManyLinks
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			vol = filepath.VolumeName(target)
+			real = vol + string(filepath.Separator)
+			target = target[len(vol):]
+		}
+		pending = append(splitPath(target), pending...)
+	}
+	return real, nil
+}
+
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r < utf8.RuneSelf && os.IsPathSeparator(uint8(r)) })
+}
+
+// IsCaseInsensitive reports whether dir lives on a filesystem that ignores
+// case, by looking the directory up under a case-swapped name. When the
+// name has no letters to swap, the platform default is used.
+func IsCaseInsensitive(dir string) bool {
+	base := filepath.Base(dir)
+	swapped := swapCase(base)
+	if swapped == base {
+		return runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	}
+	orig, err := os.Stat(dir)
+	if err != nil {
+		return runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	}
+	other, err := os.Stat(filepath.Join(filepath.Dir(dir), swapped))
+	return err == nil && os.SameFile(orig, other)
+}
+
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsUpper(r):
+			return unicode.ToLower(r)
+		case unicode.IsLower(r):
+			return unicode.ToUpper(r)
+		}
+		return r
+	}, s)
+}
@@ -15,10 +15,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"nira/memory"
 	"nira/tools"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -31,13 +34,23 @@ var upgrader = websocket.Upgrader{
 }
 
 type Server struct {
-	Port         int
-	Ollama       *OllamaClient
-	ToolRegistry *tools.Registry
-	ToolHandler  *ToolHandler
-	Logger       *Logger
-	Memory       *memory.Manager
+	Port          int
+	Ollama        *OllamaClient
+	ToolRegistry  *tools.Registry
+	ToolHandler   *ToolHandler
+	Logger        *Logger
+	Memory        *memory.Manager
+	Confirmations *ConfirmationBroker
+	// MaxToolIterations caps the model → tools → model rounds per user message.
+	MaxToolIterations int
+	// Offline is set when network tools are disabled.
+	Offline      bool
 	Conversation []ChatMessage
+
+	// clients maps each open connection to its job queue, so notifications
+	// are written by the connection's own worker.
+	clientsMu sync.Mutex
+	clients   map[*websocket.Conn]chan func()
 }
 
 // DirectToolCall represents a tool call directly from the frontend
@@ -47,16 +60,112 @@ type DirectToolCall struct {
     Arguments map[string]interface{} `json:"arguments"`
 }
 
-func NewServer(port int, ollama *OllamaClient, registry *tools.Registry, logger *Logger, mem *memory.Manager) *Server {
-	toolHandler := NewToolHandler(registry, logger)
+func NewServer(config Config, ollama *OllamaClient, registry *tools.Registry, logger *Logger, mem *memory.Manager, policy *tools.Policy) *Server {
+	toolHandler := NewToolHandler(registry, logger, policy)
+	toolHandler.Memory = mem
+	if config.ToolWorkers > 0 {
+		toolHandler.Workers = config.ToolWorkers
+	}
+	toolHandler.PreviewChanges = config.PreviewFileChanges
+	toolHandler.AutoApplyLines = config.PreviewAutoApplyLines
 	return &Server{
-		Port:         port,
-		Ollama:       ollama,
-		ToolRegistry: registry,
-		ToolHandler:  toolHandler,
-		Logger:       logger,
-		Memory:       mem,
-		Conversation: []ChatMessage{},
+		Port:              config.WebSocketPort,
+		Ollama:            ollama,
+		ToolRegistry:      registry,
+		ToolHandler:       toolHandler,
+		Logger:            logger,
+		Memory:            mem,
+		Confirmations:     NewConfirmationBroker(time.Duration(config.ConfirmTimeoutSeconds) * time.Second),
+		MaxToolIterations: config.MaxToolIterations,
+		Offline:           config.Network.Offline,
+		Conversation:      []ChatMessage{},
+		clients:           map[*websocket.Conn]chan func(){},
+	}
+}
+
+// ConfigNotice is the content of a MessageTypeConfig message.
+type ConfigNotice struct {
+	// Applied are the settings now in effect.
+	Applied []string `json:"applied"`
+	// RestartRequired changed in the file but keep their running values
+	// until NIRA is restarted.
+	RestartRequired []string `json:"restart_required,omitempty"`
+	Model           string   `json:"model"`
+}
+
+// ApplyConfig switches the running server to the live settings in applied
+// after a config reload and tells connected clients what changed.
+func (s *Server) ApplyConfig(old, config Config, applied, restartRequired []string) {
+	for _, key := range applied {
+		switch key {
+		case "model", "generation":
+			s.Ollama.SetModel(config.DefaultModel, config.Generation)
+		case "log_level":
+			level, _ := ParseLogLevel(config.LogLevel)
+			s.Logger.SetLevel(level)
+		case "tool_policy":
+			policy, err := tools.NewPolicy(config.ToolPolicy)
+			if err != nil {
+				s.Logger.Error("Config reload: %v", err)
+				continue
+			}
+			s.ToolHandler.Policy.Update(policy)
+		case "allowed_paths":
+			s.applyAllowedPaths(old.AllowedPaths, config.AllowedPaths)
+		}
+	}
+
+	content, err := json.Marshal(ConfigNotice{Applied: applied, RestartRequired: restartRequired, Model: config.DefaultModel})
+	if err != nil {
+		return
+	}
+	s.notifyClients(WSMessage{Type: MessageTypeConfig, Content: string(content)})
+}
+
+// applyAllowedPaths grants directories added to allowed_paths and revokes
+// the ones removed from it, as long as the grant still comes from the config;
+// grants the user made are left alone.
+func (s *Server) applyAllowedPaths(old, paths []string) {
+	store := s.Memory.AllowedDirs
+	if store == nil {
+		return
+	}
+	listed := func(list []string, p string) bool {
+		for _, q := range list {
+			if q == p {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range paths {
+		if !listed(old, p) {
+			if err := store.AddFromConfig(p); err != nil {
+				s.Logger.Warn("Config reload: failed to allow %s: %v", p, err)
+			}
+		}
+	}
+	for _, p := range old {
+		if !listed(paths, p) {
+			if err := store.RemoveFromConfig(p); err != nil {
+				s.Logger.Warn("Config reload: failed to remove %s: %v", p, err)
+			}
+		}
+	}
+}
+
+// notifyClients queues msg for every open connection. It is written after
+// the request the connection is busy with; a connection whose queue is full
+// misses it rather than blocking the caller.
+func (s *Server) notifyClients(msg WSMessage) {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	for conn, jobs := range s.clients {
+		select {
+		case jobs <- func() { conn.WriteJSON(msg) }:
+		default:
+			s.Logger.Warn("Client queue full; dropped %s notification", msg.Type)
+		}
 	}
 }
 
@@ -92,6 +201,29 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		s.Logger.Info("Loaded %d messages from history", len(recentMessages))
 	}
 
+	// Requests are processed one at a time on a worker goroutine so the read
+	// loop stays free to deliver confirmation answers while a tool loop waits.
+	jobs := make(chan func(), 16)
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for job := range jobs {
+			job()
+		}
+	}()
+	s.clientsMu.Lock()
+	s.clients[conn] = jobs
+	s.clientsMu.Unlock()
+	defer func() {
+		// Unregister first so notifyClients never sends on a closed queue.
+		s.clientsMu.Lock()
+		delete(s.clients, conn)
+		s.clientsMu.Unlock()
+		close(jobs)
+		s.Confirmations.Cancel(conn)
+		<-workerDone
+	}()
+
 	for {
 		_, rawMsg, err := conn.ReadMessage()
 		if err != nil {
@@ -106,7 +238,8 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		var directToolCall DirectToolCall
 		if err := json.Unmarshal(rawMsg, &directToolCall); err == nil && directToolCall.Name != "" {
 			s.Logger.Info("✅ Parsed as direct tool call: %s", directToolCall.Name)
-			s.handleDirectToolCall(conn, &directToolCall)
+			call := directToolCall
+			jobs <- func() { s.handleDirectToolCall(conn, &call) }
 			continue
 		}
 
@@ -120,9 +253,24 @@ func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		s.Logger.Info("✅ Parsed WSMessage - Type: '%s', Content: '%s'", msg.Type, msg.Content)
 		s.Logger.Info("🔍 Comparing msg.Type ('%s') with MessageTypeUser ('%s')", msg.Type, MessageTypeUser)
 
+		if msg.Type == MessageTypeConfirm {
+			approved := msg.Content == "approve"
+			if !s.Confirmations.Resolve(conn, msg.ID, approved) {
+				s.Logger.Warn("Confirmation %s is no longer pending", msg.ID)
+			}
+			continue
+		}
+
+		if msg.Type == MessageTypeAudit {
+			req := msg
+			jobs <- func() { s.handleAuditRequest(conn, &req) }
+			continue
+		}
+
 		if msg.Type == MessageTypeUser {
 			s.Logger.Info("✅ Message type matches! Calling handleUserMessage")
-			s.handleUserMessage(conn, msg.Content)
+			content := msg.Content
+			jobs <- func() { s.handleUserMessage(conn, content) }
 		} else {
 			s.Logger.Warn("⚠️ Message type '%s' does not match expected type '%s'", msg.Type, MessageTypeUser)
 		}
@@ -144,8 +292,18 @@ func (s *Server) handleDirectToolCall(conn *websocket.Conn, toolCall *DirectTool
 		return
 	}
 
- // Execute the tool
- result, err := tool.Execute(toolCall.Arguments)
+ // Direct calls are initiated by the user, so "ask" is already satisfied; only "deny" blocks them.
+ if s.ToolHandler.Policy.Decide(tool) == tools.DecisionDeny {
+     conn.WriteJSON(WSMessage{
+         Type:    MessageTypeError,
+         Content: fmt.Sprintf("Tool '%s' is disabled by the tool policy", toolCall.Name),
+         ID:      toolCall.ID,
+     })
+     return
+ }
+
+ // Execute the tool (logged and audited as user-initiated)
+ result, err := s.ToolHandler.Invoke(tool, &tools.Call{Name: toolCall.Name, Arguments: toolCall.Arguments}, InitiatorUser)
  if err != nil {
      s.Logger.Error("Tool execution failed: %v", err)
      // If this was a silent call with an ID, return a single error message tagged with the ID
@@ -257,6 +415,41 @@ func (s *Server) handleDirectToolCall(conn *websocket.Conn, toolCall *DirectTool
 	conn.WriteJSON(doneMsg)
 }
 
+// AuditRequest is the optional JSON content of an audit message.
+type AuditRequest struct {
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Tool           string `json:"tool,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+	Offset         int    `json:"offset,omitempty"`
+}
+
+// handleAuditRequest replies with the tool-call audit history for a
+// conversation (the current one unless conversation_id is given).
+func (s *Server) handleAuditRequest(conn *websocket.Conn, msg *WSMessage) {
+	var req AuditRequest
+	if msg.Content != "" {
+		if err := json.Unmarshal([]byte(msg.Content), &req); err != nil {
+			conn.WriteJSON(WSMessage{Type: MessageTypeError, Content: fmt.Sprintf("Invalid audit request: %v", err), ID: msg.ID})
+			return
+		}
+	}
+	if req.ConversationID == 0 {
+		req.ConversationID = s.Memory.CurrentConvID
+	}
+
+	records, err := s.Memory.ToolAudit.List(req.ConversationID, req.Tool, req.Limit, req.Offset)
+	if err != nil {
+		conn.WriteJSON(WSMessage{Type: MessageTypeError, Content: fmt.Sprintf("Failed to load audit log: %v", err), ID: msg.ID})
+		return
+	}
+	payload, err := json.Marshal(records)
+	if err != nil {
+		conn.WriteJSON(WSMessage{Type: MessageTypeError, Content: fmt.Sprintf("Failed to encode audit log: %v", err), ID: msg.ID})
+		return
+	}
+	conn.WriteJSON(WSMessage{Type: MessageTypeAudit, Content: string(payload), ID: msg.ID})
+}
+
 func (s *Server) streamText(conn *websocket.Conn, text string) {
 	// Stream text in small chunks for better UX
 	chunkSize := 50
@@ -315,7 +508,10 @@ func (s *Server) handleUserMessage(conn *websocket.Conn, content string) {
 	messages := append([]ChatMessage{systemMsg}, s.Conversation...)
 	s.Logger.Info("📨 Total messages to send to Ollama: %d", len(messages))
 
-	maxIterations := 5
+	maxIterations := s.MaxToolIterations
+	if maxIterations < 1 {
+		maxIterations = 1
+	}
 	for i := 0; i < maxIterations; i++ {
 		s.Logger.Info("🔄 Iteration %d/%d", i+1, maxIterations)
 		assistantContent := ""
@@ -353,8 +549,8 @@ func (s *Server) handleUserMessage(conn *websocket.Conn, content string) {
 		s.Logger.Info("✅ Assistant content length: %d chars", len(assistantContent))
 
 		// Check for tool calls in the response (AI-initiated)
-		toolCall, hasToolCall := s.ToolHandler.DetectToolCall(assistantContent)
-		if !hasToolCall {
+		toolCalls := s.ToolHandler.DetectToolCalls(assistantContent)
+		if len(toolCalls) == 0 {
 			// No tool call, final response
 			assistantMsg := ChatMessage{
 				Role:    "assistant",
@@ -374,22 +570,23 @@ func (s *Server) handleUserMessage(conn *websocket.Conn, content string) {
 			return
 		}
 
-		// Execute tool call (AI-initiated)
-		s.Logger.Info("Detected AI tool call: %s", toolCall.Name)
-		toolResult, err := s.ToolHandler.ExecuteTool(toolCall)
-		if err != nil {
-			s.Logger.Error("Tool execution failed: %v", err)
-			errorMsg := WSMessage{
-				Type:    MessageTypeError,
-				Content: fmt.Sprintf("Tool error: %v", err),
+		// Execute tool calls (AI-initiated), subject to the permission policy
+		s.Logger.Info("Detected %d AI tool call(s)", len(toolCalls))
+		outcomes := s.ToolHandler.ExecuteCalls(toolCalls, InitiatorModel, func(call *tools.Call, tool tools.Tool, previews []tools.FilePreview) bool {
+			return s.Confirmations.Request(conn, call, tool.Permission(), previews)
+		})
+		for _, o := range outcomes {
+			if o.Err != nil && !errors.Is(o.Err, ErrToolDenied) {
+				s.Logger.Error("Tool execution failed: %v", o.Err)
+				conn.WriteJSON(WSMessage{
+					Type:    MessageTypeError,
+					Content: fmt.Sprintf("Tool error: %v", o.Err),
+				})
 			}
-			conn.WriteJSON(errorMsg)
-			return
 		}
 
-		// Inject tool result back into conversation
-		toolResultStr := s.ToolHandler.FormatToolResult(toolCall.Name, toolResult)
-
+		// Inject tool results back into conversation
+		toolResultStr := s.ToolHandler.FormatOutcomes(outcomes)
 
 		// 1. Add what the assistant just said (the tool call request)
 		assistantMsg := ChatMessage{
@@ -405,12 +602,24 @@ func (s *Server) handleUserMessage(conn *websocket.Conn, content string) {
 		}
 		s.Conversation = append(s.Conversation, toolMsg)
 
-		// Send tool result to frontend
-		toolResultMsg := WSMessage{
-			Type:    MessageTypeSystem,
-			Content: fmt.Sprintf("Tool %s executed: %s", toolCall.Name, toolResultStr),
+		if err := s.Memory.SaveMessage("assistant", assistantContent, "tool_call"); err != nil {
+			s.Logger.Warn("Failed to save tool call message: %v", err)
+		}
+		if err := s.Memory.SaveMessage("user", toolResultStr, "tool_result"); err != nil {
+			s.Logger.Warn("Failed to save tool result: %v", err)
+		}
+
+		// Send tool results to frontend
+		for _, o := range outcomes {
+			if o.Err != nil {
+				continue
+			}
+			toolResultMsg := WSMessage{
+				Type:    MessageTypeSystem,
+				Content: fmt.Sprintf("Tool %s executed: %s", o.Call.Name, s.ToolHandler.FormatToolResult(o.Call.Name, o.Result)),
+			}
+			conn.WriteJSON(toolResultMsg)
 		}
-		conn.WriteJSON(toolResultMsg)
 
 		messages = append(messages, assistantMsg)
 		messages = append(messages, toolMsg)
@@ -418,7 +627,18 @@ func (s *Server) handleUserMessage(conn *websocket.Conn, content string) {
 		// Loop continues now with updated 'messages'...
 	}
 
-	s.Logger.Warn("Maximum tool call iterations reached")
+	// The model kept asking for tools; stop and tell the user rather than failing silently.
+	s.Logger.Warn("Maximum tool call iterations (%d) reached", maxIterations)
+	notice := fmt.Sprintf("\n\n(Stopped after %d rounds of tool calls for this message. Ask me to continue if the task is not finished.)", maxIterations)
+	s.streamText(conn, notice)
+	s.Conversation = append(s.Conversation, ChatMessage{Role: "assistant", Content: strings.TrimSpace(notice)})
+	if err := s.Memory.SaveMessage("assistant", strings.TrimSpace(notice), "tool_limit"); err != nil {
+		s.Logger.Warn("Failed to save assistant message: %v", err)
+	}
+	conn.WriteJSON(WSMessage{
+		Type:    MessageTypeAssistant,
+		Content: strings.TrimSpace(notice),
+	})
 }
 
 func (s *Server) buildSystemPrompt() string {
@@ -426,30 +646,49 @@ func (s *Server) buildSystemPrompt() string {
     prompt += "Available tools (name: description):\n"
 
     // Iterate and format tool schemas
+    // Tools the policy denies are left out, so disabling one hides it from the model.
     for _, tool := range s.ToolRegistry.ListTools() {
+        if t, ok := s.ToolRegistry.Get(fmt.Sprint(tool["name"])); ok && s.ToolHandler.Policy.Decide(t) == tools.DecisionDeny {
+            continue
+        }
         prompt += fmt.Sprintf("- %s: %s\n", tool["name"], tool["description"])
     }
 
     prompt += "\nGeneral rules for tool use:\n"
     prompt += "- Always emit tool calls as a single JSON object: {\"name\":\"tool_name\",\"arguments\":{...}} with no extra text.\n"
+    prompt += "- You may emit several tool calls in one reply (one JSON object per line) when they do not depend on each other; all results come back together, in order.\n"
     prompt += "- After a tool result is injected back into context, read it and continue the task. If the task requires multiple steps, call additional tools.\n"
     prompt += "- Paths are relative to the project root unless the user provides an absolute path. Prefer ./<folder> style.\n"
     prompt += "- If a file or folder is unclear or not found, ask a brief clarifying question before proceeding.\n"
-    prompt += "- For edits, read the file first, compute the full new content, then write it using write_file (overwrite semantics).\n"
+    prompt += "- To change an existing file, read it first, then call edit_file with search/replace edits copied exactly from the file (include enough surrounding lines to be unique). Use write_file only for new files or full rewrites.\n"
+    prompt += "- Every write or edit keeps the previous version; if a change went wrong, use file_history and file_undo to restore it.\n"
+    prompt += "- To rename or reorganize files use move_path, copy_path, make_directory and delete_path instead of reading and rewriting them. delete_path moves things to the trash; trash_list and trash_restore bring them back.\n"
 
     prompt += "\nAllowed directory system:\n"
     prompt += "- You may only access files within the user's allowed directories.\n"
     prompt += "- If access is denied or a path is outside allowed roots, ask the user to allow the directory, or call allowed_dirs_add with their confirmation.\n"
     prompt += "- You can inspect current permissions using allowed_dirs_list.\n"
+    prompt += "- Each allowed directory has a mode: read_write, read_only (no writing) or index_only (only rag_index_folder may read it), and may deny some paths by glob. Do not retry a denied path; tell the user.\n"
+    prompt += "- When asking for a new directory, prefer a temporary grant (expires_in, e.g. 1h) or scope \"conversation\" unless the user wants lasting access.\n"
+    prompt += "- Tools that write, delete or change permissions may ask the user for confirmation first. If a call is declined, tell the user and do not retry it.\n"
 
     prompt += "\nHow to handle common requests:\n"
     prompt += "1) ‘Tell me what files are in <dir>’ → Call list_directory with {path:\"./<dir>\", recursive:false}.\n"
+    prompt += "   For an overview of a project or folder structure, call list_directory with {path:\"./<dir>\", mode:\"tree\", max_depth:2}.\n"
     prompt += "2) ‘Summarize <file> in <dir>’ → If you don't know the exact path:\n   a) Call search_files_by_name with {root:\"./<dir>\", pattern:\"<file>\"}.\n   b) Pick the best match, then call read_file with {path}.\n   c) Write a concise summary as assistant text (no further tool call).\n"
-    prompt += "3) ‘Make <change> to <file> in <dir>’ →\n   a) search_files_by_name to find the file,\n   b) read_file to load content,\n   c) modify the text deterministically,\n   d) write_file with the full updated content.\n"
+    prompt += "3) ‘Make <change> to <file> in <dir>’ →\n   a) search_files_by_name to find the file,\n   b) read_file to load content,\n   c) call edit_file with {path, edits:[{search:\"<exact old text>\", replace:\"<new text>\"}]},\n   d) check the returned diff; if edit_file reports a missing or ambiguous anchor, re-read the file and retry.\n"
+    prompt += "4) ‘Where is <text> used/defined in <dir>’ → Call search_file_contents with {root:\"./<dir>\", pattern:\"<text>\", context:2} (regex:true for patterns, include:[\"*.go\"] to narrow), then read_file the relevant file if needed.\n"
+    if s.Offline {
+        prompt += "5) NIRA is offline: there are no web tools. Answer from local files and your own knowledge, and say so when a question needs current information from the internet.\n"
+    } else if t, ok := s.ToolRegistry.Get("web_search"); ok && s.ToolHandler.Policy.Decide(t) != tools.DecisionDeny {
+        prompt += "5) ‘Look up <topic> online’ → Call web_search with {query:\"<topic>\"}, then web_fetch with {url} for the most relevant results to read them; cite the URLs you used. Use web_fetch {index:true} to keep a page searchable with rag_search.\n"
+    }
 
     prompt += "\nIndexing and retrieval (basic local RAG):\n"
     prompt += "- To index a folder of text files for faster search, call rag_index_folder with {root, patterns:[\"*.md\",\"*.txt\"], max_size_mb, max_files}.\n"
     prompt += "- To retrieve relevant files/snippets, call rag_search with {query:\"...\", limit, path_prefix}.\n"
+    prompt += "- For zip/tar archives, call archive_list to see members (read:\"<member>\" returns one member's text); archive_extract with {index:true} makes their text searchable without extracting.\n"
+    prompt += "- For questions about a git repository, use git_status, git_log (author/since/grep filters), git_show, git_diff (from/to refs or uncommitted changes) and git_blame (a line range) rather than reading .git; use stat_only first when a diff may be large.\n"
     prompt += "- Always ensure the root/path_prefix is within allowed directories; if not, request permission first.\n"
 
     prompt += "\nRolePlay (RP) data management (backend-owned):\n"
@@ -0,0 +1,316 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"nira/memory"
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAllowedDirs_ModesAndDeny verifies that access modes and deny patterns
+// are enforced by the file tools and the indexer.
+func TestAllowedDirs_ModesAndDeny(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+
+	base := t.TempDir()
+	project := filepath.Join(base, "project")
+	docs := filepath.Join(project, "docs")
+	archive := filepath.Join(base, "archive")
+	for _, d := range []string{filepath.Join(project, ".git"), docs, archive} {
+		os.MkdirAll(d, 0755)
+	}
+	files := map[string]string{
+		filepath.Join(project, "main.go"):        "package main\n",
+		filepath.Join(project, "prod.env"):       "TOKEN=x\n",
+		filepath.Join(project, ".git", "config"): "[core]\n",
+		filepath.Join(docs, "guide.md"):          "guide\n",
+		filepath.Join(archive, "old.md"):         "old notes\n",
+	}
+	for p, c := range files {
+		os.WriteFile(p, []byte(c), 0644)
+	}
+
+	add := tools.NewAllowedDirsAddTool(store)
+	for _, args := range []map[string]interface{}{
+		{"path": project, "deny": []interface{}{".git/**", "*.env"}},
+		{"path": docs, "mode": "read_only"},
+		{"path": archive, "mode": "index_only"},
+	} {
+		if _, err := add.Execute(args); err != nil {
+			t.Fatalf("allowed_dirs_add %v failed: %v", args, err)
+		}
+	}
+	if _, err := add.Execute(map[string]interface{}{"path": project, "mode": "everything"}); err == nil {
+		t.Error("Expected error for an unknown mode")
+	}
+
+	read := tools.NewFileReadToolWithChecker(nil, store)
+	write := tools.NewFileWriteToolWithChecker(nil, store)
+	list := tools.NewListDirectoryToolWithChecker(nil, store)
+
+	cases := []struct {
+		name  string
+		tool  tools.Tool
+		path  string
+		allow bool
+	}{
+		{"read in read-write root", read, filepath.Join(project, "main.go"), true},
+		{"write in read-write root", write, filepath.Join(project, "new.go"), true},
+		{"read denied by basename glob", read, filepath.Join(project, "prod.env"), false},
+		{"read denied by directory glob", read, filepath.Join(project, ".git", "config"), false},
+		{"write denied by glob", write, filepath.Join(project, "local.env"), false},
+		{"read in nested read-only root", read, filepath.Join(docs, "guide.md"), true},
+		{"write in nested read-only root", write, filepath.Join(docs, "guide.md"), false},
+		{"read in index-only root", read, filepath.Join(archive, "old.md"), false},
+		{"list index-only root", list, archive, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.tool.Execute(map[string]interface{}{"path": tc.path, "content": "x"})
+			if tc.allow && err != nil {
+				t.Errorf("Expected access, got %v", err)
+			}
+			var accessErr *sandbox.AccessError
+			if !tc.allow && !errors.As(err, &accessErr) {
+				t.Errorf("Expected access error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("Listing hides denied entries", func(t *testing.T) {
+		result, err := list.Execute(map[string]interface{}{"path": project, "recursive": true})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		for _, item := range result.([]map[string]interface{}) {
+			name := item["name"].(string)
+			if name == ".git" || name == "config" || strings.HasSuffix(name, ".env") {
+				t.Errorf("Denied entry %q should not be listed", item["path"])
+			}
+		}
+	})
+
+	t.Run("Indexer reads index-only roots and skips denied files", func(t *testing.T) {
+		ragIndex := memory.NewRagIndex(db)
+		indexer := tools.NewRagIndexFolderTool(store, ragIndex)
+		for _, root := range []string{archive, project} {
+			if _, err := indexer.Execute(map[string]interface{}{"root": root, "patterns": []interface{}{"*"}}); err != nil {
+				t.Fatalf("Indexing %s failed: %v", root, err)
+			}
+		}
+		if hits, _ := ragIndex.Search("old notes", 10, ""); len(hits) != 1 {
+			t.Errorf("Expected the index-only file to be indexed, got %v", hits)
+		}
+		if hits, _ := ragIndex.Search("TOKEN", 10, ""); len(hits) != 0 {
+			t.Errorf("Deny-listed file must not be indexed, got %v", hits)
+		}
+	})
+
+	t.Run("List reports modes", func(t *testing.T) {
+		result, _ := tools.NewAllowedDirsListTool(store).Execute(nil)
+		entries := result.(map[string]interface{})["allowed"].([]map[string]interface{})
+		modes := map[string]sandbox.Mode{}
+		for _, e := range entries {
+			modes[e["path"].(string)] = e["mode"].(sandbox.Mode)
+		}
+		if modes[project] != sandbox.ModeReadWrite || modes[docs] != sandbox.ModeReadOnly || modes[archive] != sandbox.ModeIndexOnly {
+			t.Errorf("Unexpected modes: %v", entries)
+		}
+	})
+}
+
+// TestAllowedDirs_ExpiryAndScope verifies that temporary grants are revoked
+// when they expire and conversation grants when the conversation changes.
+func TestAllowedDirs_ExpiryAndScope(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	add := tools.NewAllowedDirsAddTool(store)
+	list := tools.NewAllowedDirsListTool(store)
+
+	temp, scoped, permanent := t.TempDir(), t.TempDir(), t.TempDir()
+	if _, err := add.Execute(map[string]interface{}{"path": scoped, "scope": "conversation"}); err == nil {
+		t.Error("Conversation scope should fail without a current conversation")
+	}
+
+	conv := int64(7)
+	store.CurrentConversation = func() int64 { return conv }
+	for _, args := range []map[string]interface{}{
+		{"path": temp, "expires_in": "1s"},
+		{"path": scoped, "scope": "conversation"},
+		{"path": permanent},
+	} {
+		if _, err := add.Execute(args); err != nil {
+			t.Fatalf("allowed_dirs_add %v failed: %v", args, err)
+		}
+	}
+	if _, err := add.Execute(map[string]interface{}{"path": temp, "expires_in": "soon"}); err == nil {
+		t.Error("Expected error for an invalid duration")
+	}
+
+	result, _ := list.Execute(nil)
+	byPath := map[string]map[string]interface{}{}
+	for _, e := range result.(map[string]interface{})["allowed"].([]map[string]interface{}) {
+		byPath[e["path"].(string)] = e
+	}
+	if _, ok := byPath[temp]["expires_in"].(string); !ok {
+		t.Errorf("Temporary grant should report its remaining lifetime: %v", byPath[temp])
+	}
+	if byPath[scoped]["scope"] != "conversation" || byPath[permanent]["scope"] != "global" {
+		t.Errorf("Unexpected scopes: %v", byPath)
+	}
+	if !store.IsAllowed(filepath.Join(temp, "a.txt")) || !store.IsAllowed(filepath.Join(scoped, "a.txt")) {
+		t.Fatal("Fresh grants should be in effect")
+	}
+
+	time.Sleep(1200 * time.Millisecond)
+	if store.IsAllowed(filepath.Join(temp, "a.txt")) {
+		t.Error("Expired grant should be revoked")
+	}
+
+	conv = 8
+	if store.IsAllowed(filepath.Join(scoped, "a.txt")) {
+		t.Error("Conversation grant should be revoked in another conversation")
+	}
+	if !store.IsAllowed(filepath.Join(permanent, "a.txt")) {
+		t.Error("Permanent grant should stay")
+	}
+	if paths := store.List(); len(paths) != 1 || paths[0] != permanent {
+		t.Errorf("Expected only the permanent grant to remain, got %v", paths)
+	}
+}
+
+// TestAllowedDirs_MigratesOldTable verifies that a database created before
+// access modes existed keeps its directories as read-write.
+func TestAllowedDirs_MigratesOldTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = raw.Exec(`CREATE TABLE allowed_directories (id INTEGER PRIMARY KEY AUTOINCREMENT, path TEXT UNIQUE NOT NULL, added_at TEXT NOT NULL);
+		INSERT INTO allowed_directories(path, added_at) VALUES('/srv/notes', '2024-01-01T00:00:00Z');`)
+	raw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := memory.NewDatabase(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	entries := store.Entries()
+	if len(entries) != 1 || entries[0].Mode != sandbox.ModeReadWrite {
+		t.Errorf("Expected migrated read-write entry, got %+v", entries)
+	}
+}
+
+// TestAllowedDirs_TemporaryKeepsPermanent verifies that an expiring or
+// conversation grant never replaces a permanent one, so revoking it cannot
+// take the permanent grant with it.
+func TestAllowedDirs_TemporaryKeepsPermanent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	store.CurrentConversation = func() int64 { return 3 }
+	seeded, upgraded := t.TempDir(), t.TempDir()
+	if err := store.EnsureSeed([]string{seeded}); err != nil {
+		t.Fatalf("Seed failed: %v", err)
+	}
+
+	for _, g := range []memory.DirGrant{
+		{Mode: sandbox.ModeReadOnly, TTL: time.Second},
+		{Mode: sandbox.ModeReadOnly, Conversation: true},
+	} {
+		if err := store.Set(seeded, g); !errors.Is(err, memory.ErrPermanentGrant) {
+			t.Errorf("Expected ErrPermanentGrant for %+v, got %v", g, err)
+		}
+	}
+	entries := store.Entries()
+	if len(entries) != 1 || entries[0].Mode != sandbox.ModeReadWrite || entries[0].ExpiresAt != "" {
+		t.Fatalf("Permanent grant should be unchanged, got %+v", entries)
+	}
+
+	// A permanent grant may replace a temporary one, which then stays.
+	if err := store.Set(upgraded, memory.DirGrant{TTL: time.Second}); err != nil {
+		t.Fatalf("Temporary grant failed: %v", err)
+	}
+	if err := store.Set(upgraded, memory.DirGrant{Mode: sandbox.ModeReadOnly, TTL: time.Second}); err != nil {
+		t.Errorf("Replacing a temporary grant should work: %v", err)
+	}
+	if err := store.Set(upgraded, memory.DirGrant{}); err != nil {
+		t.Fatalf("Permanent grant failed: %v", err)
+	}
+
+	time.Sleep(1200 * time.Millisecond)
+	store.CurrentConversation = func() int64 { return 4 }
+	for _, dir := range []string{seeded, upgraded} {
+		if !store.IsAllowed(filepath.Join(dir, "a.txt")) {
+			t.Errorf("Permanent grant on %s should survive expiry and conversation changes", dir)
+		}
+	}
+}
+
+// TestAllowedDirs_FromConfig verifies that removing a path from allowed_paths
+// revokes only the grant the config made.
+func TestAllowedDirs_FromConfig(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	configured, granted, taken := t.TempDir(), t.TempDir(), t.TempDir()
+
+	if err := store.Set(granted, memory.DirGrant{Mode: sandbox.ModeReadOnly}); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	for _, dir := range []string{configured, granted, taken} {
+		if err := store.AddFromConfig(dir); err != nil {
+			t.Fatalf("AddFromConfig(%s) failed: %v", dir, err)
+		}
+	}
+	for _, d := range store.Entries() {
+		if want := d.Path == configured || d.Path == taken; d.FromConfig != want {
+			t.Errorf("%s: expected FromConfig %v, got %v", d.Path, want, d.FromConfig)
+		}
+		if d.Path == granted && d.Mode != sandbox.ModeReadOnly {
+			t.Errorf("The config should not change the user's grant, got %s", d.Mode)
+		}
+	}
+
+	// Changing a configured grant makes it the user's.
+	if err := store.Set(taken, memory.DirGrant{Deny: []string{"*.env"}}); err != nil {
+		t.Fatalf("Grant failed: %v", err)
+	}
+	for _, dir := range []string{configured, granted, taken} {
+		if err := store.RemoveFromConfig(dir); err != nil {
+			t.Fatalf("RemoveFromConfig(%s) failed: %v", dir, err)
+		}
+	}
+	if got := store.List(); len(got) != 2 || got[0] != granted || got[1] != taken {
+		t.Errorf("Expected only the user's grants to remain, got %v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"nira/memory"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type archiveFile struct {
+	name, body, link string
+	dir              bool
+}
+
+func writeTar(t *testing.T, w io.Writer, files []archiveFile) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		h := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		switch {
+		case f.dir:
+			h.Typeflag, h.Mode, h.Size = tar.TypeDir, 0755, 0
+		case f.link != "":
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, f.link, 0
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, f.body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ragRecorder collects Upsert calls in place of the RAG index.
+type ragRecorder map[string]string
+
+func (r ragRecorder) Upsert(path, name, modTime string, size int64, content string) error {
+	r[path] = content
+	return nil
+}
+
+// TestArchiveTools verifies listing, member reads, extraction with zip-slip
+// protection, size limits and indexing for each supported format.
+func TestArchiveTools(t *testing.T) {
+	root := t.TempDir()
+	files := []archiveFile{
+		{name: "docs/", dir: true},
+		{name: "docs/readme.md", body: "# Shared notes\nhello archive\n"},
+		{name: "data/blob.bin", body: "\x89PNG\x00\x00\x00\x0d\xff\x10"},
+		{name: "../evil.txt", body: "escaped"},
+		{name: "/etc/evil.txt", body: "escaped"},
+		{name: "docs/link", link: "/etc/passwd"},
+	}
+
+	var plain bytes.Buffer
+	writeTar(t, &plain, files)
+	os.WriteFile(filepath.Join(root, "shared.tar"), plain.Bytes(), 0644)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	writeTar(t, gw, files)
+	gw.Close()
+	os.WriteFile(filepath.Join(root, "shared.tgz"), gz.Bytes(), 0644)
+
+	var zst bytes.Buffer
+	zw, _ := zstd.NewWriter(&zst)
+	writeTar(t, zw, files)
+	zw.Close()
+	os.WriteFile(filepath.Join(root, "shared.tar.zst"), zst.Bytes(), 0644)
+
+	var zb bytes.Buffer
+	w := zip.NewWriter(&zb)
+	for _, f := range files {
+		if f.dir || f.link != "" {
+			continue
+		}
+		fw, _ := w.Create(f.name)
+		io.WriteString(fw, f.body)
+	}
+	w.Close()
+	os.WriteFile(filepath.Join(root, "shared.zip"), zb.Bytes(), 0644)
+
+	list := tools.NewArchiveListTool([]string{root})
+	extract := tools.NewArchiveExtractTool([]string{root})
+	index := ragRecorder{}
+	extract.Index = index
+
+	for _, name := range []string{"shared.tar", "shared.tgz", "shared.tar.zst", "shared.zip"} {
+		archive := filepath.Join(root, name)
+		t.Run(name, func(t *testing.T) {
+			result, err := list.Execute(map[string]interface{}{"path": archive})
+			if err != nil {
+				t.Fatalf("archive_list failed: %v", err)
+			}
+			r := result.(map[string]interface{})
+			entries := r["entries"].([]map[string]interface{})
+			unsafe := 0
+			for _, e := range entries {
+				if e["unsafe"] == true {
+					unsafe++
+				}
+			}
+			if len(entries) < 4 || unsafe != 2 {
+				t.Errorf("Unexpected listing (%v): %v", r["format"], entries)
+			}
+
+			result, err = list.Execute(map[string]interface{}{"path": archive, "read": "docs/readme.md"})
+			if err != nil || !strings.Contains(result.(map[string]interface{})["content"].(string), "hello archive") {
+				t.Errorf("Reading a member failed: %v %v", result, err)
+			}
+			if _, err := list.Execute(map[string]interface{}{"path": archive, "read": "data/blob.bin"}); err == nil {
+				t.Error("Expected error reading a binary member")
+			}
+
+			dest := filepath.Join(root, "out-"+name)
+			result, err = extract.Execute(map[string]interface{}{"path": archive, "destination": dest, "index": true})
+			if err != nil {
+				t.Fatalf("archive_extract failed: %v", err)
+			}
+			r = result.(map[string]interface{})
+			if data, _ := os.ReadFile(filepath.Join(dest, "docs", "readme.md")); !strings.Contains(string(data), "hello archive") {
+				t.Error("Member was not extracted")
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil.txt")); err == nil {
+				t.Fatal("Zip-slip member escaped the destination")
+			}
+			if _, err := os.Lstat(filepath.Join(dest, "docs", "link")); err == nil {
+				t.Error("Symlink members should not be extracted")
+			}
+			if r["extracted"] != 2 || r["indexed"] != 1 {
+				t.Errorf("Unexpected extraction result: %v", r)
+			}
+			if !strings.Contains(index[archive+"!/docs/readme.md"], "hello archive") {
+				t.Errorf("Member was not indexed: %v", index)
+			}
+		})
+	}
+
+	t.Run("Limits and sandbox", func(t *testing.T) {
+		big := filepath.Join(root, "big.tar")
+		f, _ := os.Create(big)
+		writeTar(t, f, []archiveFile{{name: "a.txt", body: strings.Repeat("a", 700<<10)}, {name: "b.txt", body: strings.Repeat("b", 700<<10)}})
+		f.Close()
+		_, err := extract.Execute(map[string]interface{}{"path": big, "destination": filepath.Join(root, "big"), "max_total_mb": 1})
+		if err == nil || !strings.Contains(err.Error(), "limit") {
+			t.Errorf("Expected a size limit error, got %v", err)
+		}
+		if left, _ := os.ReadDir(filepath.Join(root, "big")); len(left) != 1 || left[0].Name() != "a.txt" {
+			t.Errorf("Expected only the complete member to remain, got %v", left)
+		}
+		if _, err := extract.Execute(map[string]interface{}{"path": big, "destination": filepath.Join(root, "sel"), "members": []interface{}{"b.txt"}, "max_total_mb": 1}); err != nil {
+			t.Errorf("Selecting one member should fit the limit: %v", err)
+		}
+		if _, err := extract.Execute(map[string]interface{}{"path": big, "destination": t.TempDir()}); err == nil {
+			t.Error("Expected error for a destination outside allowed directories")
+		}
+	})
+
+	t.Run("Overwrite goes to the trash", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+		dest := filepath.Join(root, "over")
+		os.MkdirAll(filepath.Join(dest, "docs"), 0755)
+		readme := filepath.Join(dest, "docs", "readme.md")
+		os.WriteFile(readme, []byte("local edits\n"), 0644)
+		args := map[string]interface{}{"path": filepath.Join(root, "shared.tar"), "destination": dest, "members": []interface{}{"docs/readme.md"}, "overwrite": true}
+
+		noTrash := tools.NewArchiveExtractTool([]string{root})
+		if _, err := noTrash.Execute(args); err != nil {
+			t.Fatalf("archive_extract failed: %v", err)
+		}
+		if data, _ := os.ReadFile(readme); string(data) != "local edits\n" {
+			t.Error("Without a trash the existing file must be kept")
+		}
+
+		trash := memory.NewTrashStore(db, filepath.Join(t.TempDir(), "trash"))
+		extract.Trash = trash
+		defer func() { extract.Trash = nil }()
+		if _, err := extract.Execute(args); err != nil {
+			t.Fatalf("archive_extract failed: %v", err)
+		}
+		if data, _ := os.ReadFile(readme); !strings.Contains(string(data), "hello archive") {
+			t.Error("Member should replace the existing file")
+		}
+		items, _ := trash.List(10)
+		if len(items) != 1 || items[0].OriginalPath != readme {
+			t.Fatalf("Expected the old file in the trash, got %v", items)
+		}
+		if data, _ := os.ReadFile(items[0].TrashPath); string(data) != "local edits\n" {
+			t.Errorf("Trash should hold the old content, got %q", data)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nira/tools"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestEgress verifies domain allow/deny lists, offline mode, private address
+// blocking, checks on redirect hops and request logging of the central
+// HTTP client.
+func TestEgress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://blocked.example/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+	host := strings.Split(strings.TrimPrefix(srv.URL, "http://"), ":")[0]
+
+	if _, err := tools.NewEgress(tools.EgressConfig{AllowDomains: []string{"https://example.com/"}}); err == nil {
+		t.Error("Expected error for a URL in AllowDomains")
+	}
+
+	t.Run("Domain lists", func(t *testing.T) {
+		e, err := tools.NewEgress(tools.EgressConfig{AllowDomains: []string{"*.Example.com", host}, DenyDomains: []string{"ads.example.com"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases := map[string]bool{
+			"example.com":          true,
+			"docs.example.com:443": true,
+			"ads.example.com":      false,
+			"x.ads.example.com":    false,
+			"notexample.com":       false,
+			"evil.com":             false,
+		}
+		for h, want := range cases {
+			if got := e.Check(h) == nil; got != want {
+				t.Errorf("Check(%s) = %v, want %v", h, got, want)
+			}
+		}
+	})
+
+	t.Run("Client and logging", func(t *testing.T) {
+		e, _ := tools.NewEgress(tools.EgressConfig{AllowDomains: []string{host}, LogRequests: true})
+		var logs []string
+		e.Log = func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+		client := e.Client(0)
+		resp, err := client.Get(srv.URL + "/page?q=secret")
+		if err != nil {
+			t.Fatalf("Allowed request failed: %v", err)
+		}
+		resp.Body.Close()
+		if _, err := client.Get(srv.URL + "/away"); err == nil || !strings.Contains(err.Error(), "not in AllowDomains") {
+			t.Errorf("Redirect to a host outside AllowDomains should fail, got %v", err)
+		}
+		if len(logs) != 3 || !strings.Contains(logs[0], "GET "+srv.URL+"/page -> 200") || !strings.Contains(logs[2], "blocked") {
+			t.Errorf("Unexpected log lines: %q", logs)
+		}
+		if strings.Contains(strings.Join(logs, "\n"), "secret") {
+			t.Error("Query strings must not be logged")
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		e, _ := tools.NewEgress(tools.EgressConfig{Offline: true})
+		if !e.Offline() || e.Check(host) != tools.ErrOffline {
+			t.Fatal("Offline egress must refuse every host")
+		}
+		fetch := tools.NewWebFetchTool(nil, nil)
+		fetch.Egress = e
+		if _, err := fetch.Execute(map[string]interface{}{"url": srv.URL}); err == nil {
+			t.Error("web_fetch must fail offline")
+		}
+		providers, _ := tools.NewSearchProviders([]tools.SearchProviderConfig{{Type: "searxng", URL: srv.URL}}, e)
+		if _, err := tools.NewWebSearchTool(providers).Execute(map[string]interface{}{"query": "x"}); err == nil || !strings.Contains(err.Error(), "offline") {
+			t.Errorf("web_search must fail offline, got %v", err)
+		}
+
+		registry := tools.NewRegistry()
+		registry.Register(tools.NewWebFetchTool(nil, nil))
+		registry.Register(tools.NewFileReadTool([]string{"."}))
+		for name, tool := range registry.Tools {
+			if tool.Permission() == tools.PermissionNetwork {
+				registry.Unregister(name)
+			}
+		}
+		if _, ok := registry.Get("web_fetch"); ok || len(registry.Tools) != 1 {
+			t.Errorf("Network tools should be removable: %v", registry.Tools)
+		}
+	})
+
+	t.Run("Private addresses", func(t *testing.T) {
+		e, _ := tools.NewEgress(tools.EgressConfig{})
+		client := e.Client(5 * time.Second)
+		for _, u := range []string{srv.URL, strings.Replace(srv.URL, host, "localhost", 1), "http://169.254.169.254/latest/meta-data/", "http://[::1]:1/"} {
+			resp, err := client.Get(u)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if !errors.Is(err, tools.ErrPrivateAddress) {
+				t.Errorf("Expected %s to be blocked as private, got %v", u, err)
+			}
+		}
+
+		// A configured service may be private; other clients of the same
+		// egress still may not reach it.
+		resp, err := e.ServiceClient(0, srv.URL).Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Configured service should be reachable: %v", err)
+		}
+		resp.Body.Close()
+		if _, err := client.Get(srv.URL); !errors.Is(err, tools.ErrPrivateAddress) {
+			t.Errorf("Other clients must not reuse the service's connection, got %v", err)
+		}
+	})
+
+	var nilEgress *tools.Egress
+	if nilEgress.Check("anything.example") != nil || nilEgress.Client(0) == nil {
+		t.Error("A nil egress must allow everything")
+	}
+}
+
+// TestEgressProxyHelper is not a real test: TestEgress_Proxy re-executes the
+// test binary with a proxy in the environment, which net/http reads only
+// once per process, and this makes the requests.
+func TestEgressProxyHelper(t *testing.T) {
+	if os.Getenv("NIRA_EGRESS_PROXY_HELPER") != "1" {
+		t.Skip("helper process for TestEgress_Proxy")
+	}
+	egress, err := tools.NewEgress(tools.EgressConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := egress.Client(5 * time.Second)
+	if _, err := client.Get("http://169.254.169.254/latest/meta-data/"); !errors.Is(err, tools.ErrPrivateAddress) {
+		t.Errorf("Expected the metadata address to be blocked through the proxy, got %v", err)
+	}
+	resp, err := client.Get("http://public.example/")
+	if err != nil {
+		t.Fatalf("Expected a public host to go through the proxy: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// TestEgress_Proxy verifies that private targets are refused when requests
+// go through a proxy, which is the only host the dialer sees.
+func TestEgress_Proxy(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Host)
+		mu.Unlock()
+		fmt.Fprint(w, "ok")
+	}))
+	defer proxy.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEgressProxyHelper$", "-test.v")
+	cmd.Env = append(os.Environ(), "NIRA_EGRESS_PROXY_HELPER=1", "HTTP_PROXY="+proxy.URL, "http_proxy="+proxy.URL, "NO_PROXY=", "no_proxy=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Helper failed: %v\n%s", err, out)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 1 || seen[0] != "public.example" {
+		t.Errorf("Expected only public.example to reach the proxy, got %v", seen)
+	}
+}
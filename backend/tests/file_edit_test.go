@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileEdit_SearchReplaceAndDiff verifies anchored edits, unified diffs,
+// failure on missing or ambiguous anchors, and the returned diff.
+func TestFileEdit_SearchReplaceAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	original := "package main\n\nfunc a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n"
+	reset := func() {
+		if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	read := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+	tool := tools.NewFileEditTool([]string{dir})
+
+	t.Run("Search and replace", func(t *testing.T) {
+		reset()
+		result, err := tool.Execute(map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": "func a() {\n\treturn", "replace": "func a() {\n\tprintln(\"a\")\n\treturn"}},
+		})
+		if err != nil {
+			t.Fatalf("Edit failed: %v", err)
+		}
+		if !strings.Contains(read(), "println(\"a\")\n\treturn\n}\n\nfunc b()") {
+			t.Errorf("Unexpected file content:\n%s", read())
+		}
+		res := result.(map[string]interface{})
+		diff := res["diff"].(string)
+		if !strings.Contains(diff, "+\tprintln(\"a\")") || res["added"] != 1 || res["removed"] != 0 {
+			t.Errorf("Unexpected diff result: %v", res)
+		}
+	})
+
+	t.Run("Ambiguous anchor", func(t *testing.T) {
+		reset()
+		_, err := tool.Execute(map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": "\treturn\n", "replace": "\treturn nil\n"}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "ambiguous") || !strings.Contains(err.Error(), "lines 4, 8") {
+			t.Errorf("Expected ambiguous anchor error with line numbers, got %v", err)
+		}
+		if read() != original {
+			t.Error("File must not change when an edit fails")
+		}
+		if _, err := tool.Execute(map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": "\treturn\n", "replace": "\treturn nil\n", "replace_all": true}},
+		}); err != nil || strings.Count(read(), "return nil") != 2 {
+			t.Errorf("replace_all should edit every match: %v", err)
+		}
+	})
+
+	t.Run("Missing anchor leaves file untouched", func(t *testing.T) {
+		reset()
+		_, err := tool.Execute(map[string]interface{}{
+			"path": path,
+			"edits": []interface{}{
+				map[string]interface{}{"search": "package main", "replace": "package app"},
+				map[string]interface{}{"search": "func a() {\n    return", "replace": "x"},
+			},
+		})
+		if err == nil || !strings.Contains(err.Error(), "edit 2") || !strings.Contains(err.Error(), "whitespace") {
+			t.Errorf("Expected whitespace hint for edit 2, got %v", err)
+		}
+		if read() != original {
+			t.Error("Earlier edits must not be written when a later one fails")
+		}
+	})
+
+	t.Run("Unified diff", func(t *testing.T) {
+		reset()
+		diff := "--- a/main.go\n+++ b/main.go\n@@ -7,3 +7,4 @@\n func b() {\n+\tprintln(\"b\")\n \treturn\n }\n"
+		if _, err := tool.Execute(map[string]interface{}{"path": path, "diff": diff}); err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+		if !strings.Contains(read(), "func b() {\n\tprintln(\"b\")\n\treturn\n}\n") {
+			t.Errorf("Unexpected file content:\n%s", read())
+		}
+
+		reset()
+		bad := "@@ -1,2 +1,2 @@\n func c() {\n-\treturn\n+\treturn 1\n"
+		if _, err := tool.Execute(map[string]interface{}{"path": path, "diff": bad}); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("Expected not found error for unmatched hunk, got %v", err)
+		}
+	})
+
+	t.Run("SEARCH/REPLACE blocks and dry run", func(t *testing.T) {
+		reset()
+		blocks := "<<<<<<< SEARCH\npackage main\n=======\npackage app\n>>>>>>> REPLACE\n"
+		result, err := tool.Execute(map[string]interface{}{"path": path, "diff": blocks, "dry_run": true})
+		if err != nil {
+			t.Fatalf("Blocks failed: %v", err)
+		}
+		if read() != original {
+			t.Error("Dry run must not write the file")
+		}
+		if !strings.Contains(result.(map[string]interface{})["diff"].(string), "-package main\n+package app\n") {
+			t.Errorf("Unexpected dry run diff: %v", result)
+		}
+	})
+
+	t.Run("Outside allowed paths", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "x.txt")
+		os.WriteFile(other, []byte("a"), 0644)
+		if _, err := tool.Execute(map[string]interface{}{"path": other, "edits": []interface{}{map[string]interface{}{"search": "a", "replace": "b"}}}); err == nil {
+			t.Error("Expected error for path outside allowed directories")
+		}
+	})
+}
+
+// TestFileEdit_DiffCommentLines verifies that a removed "-- " comment line
+// followed by an added line starting with "++ " is not taken for a file
+// header: in the diff they read "--- " and "+++ ".
+func TestFileEdit_DiffCommentLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.sql")
+	original := "-- users table\nCREATE TABLE users (id INTEGER);\n-- TODO: indexes\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	tool := tools.NewFileEditTool([]string{dir})
+
+	diff := "--- a/schema.sql\n+++ b/schema.sql\n@@ -1,3 +1,3 @@\n--- users table\n+++ accounts table ++\n CREATE TABLE users (id INTEGER);\n--- TODO: indexes\n+-- indexes: none\n"
+	if _, err := tool.Execute(map[string]interface{}{"path": path, "diff": diff}); err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "++ accounts table ++\nCREATE TABLE users (id INTEGER);\n-- indexes: none\n"; string(data) != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, data)
+	}
+
+	// Once the counts are used up, a header pair starts another file.
+	two := "--- a/schema.sql\n+++ b/schema.sql\n@@ -1 +1 @@\n-++ accounts table ++\n+-- users table\n--- a/other.sql\n+++ b/other.sql\n@@ -1 +1 @@\n-x\n+y\n"
+	if _, err := tool.Execute(map[string]interface{}{"path": path, "diff": two}); err == nil || !strings.Contains(err.Error(), "more than one file") {
+		t.Errorf("Expected a second file header to be recognized, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"nira/memory"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileHistory_BackupAndUndo verifies that writes keep versioned backups,
+// file_history lists them and file_undo restores or removes files.
+func TestFileHistory_BackupAndUndo(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	dir := t.TempDir()
+	allowed, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	allowed.Add(dir)
+	versions := memory.NewFileVersionStore(db)
+
+	writeTool := tools.NewFileWriteToolWithChecker(nil, allowed)
+	writeTool.Versions = versions
+	editTool := tools.NewFileEditToolWithChecker(nil, allowed)
+	editTool.Versions = versions
+	history := tools.NewFileHistoryTool(versions, allowed)
+	undo := tools.NewFileUndoTool(versions, allowed)
+
+	path := filepath.Join(dir, "notes.txt")
+	read := func() string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	for _, content := range []string{"one\n", "two\n"} {
+		if _, err := writeTool.Execute(map[string]interface{}{"path": path, "content": content}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if _, err := editTool.Execute(map[string]interface{}{"path": path, "edits": []interface{}{map[string]interface{}{"search": "two", "replace": "three"}}}); err != nil {
+		t.Fatalf("Edit failed: %v", err)
+	}
+
+	t.Run("Atomic write leaves no temp files", func(t *testing.T) {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("Expected only the target file in %s, got %d entries", dir, len(entries))
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		result, err := history.Execute(map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatalf("History failed: %v", err)
+		}
+		list := result.(map[string]interface{})["versions"].([]map[string]interface{})
+		if len(list) != 3 {
+			t.Fatalf("Expected 3 versions, got %d", len(list))
+		}
+		if list[0]["tool"] != "edit_file" || list[2]["existed"] != false {
+			t.Errorf("Unexpected version order or fields: %v", list)
+		}
+	})
+
+	t.Run("Undo restores and can be undone", func(t *testing.T) {
+		result, err := undo.Execute(map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatalf("Undo failed: %v", err)
+		}
+		if read() != "two\n" {
+			t.Errorf("Expected content before the edit, got %q", read())
+		}
+		if !strings.Contains(result.(map[string]interface{})["diff"].(string), "+two") {
+			t.Errorf("Expected diff in undo result: %v", result)
+		}
+		if _, err := undo.Execute(map[string]interface{}{"path": path}); err != nil || read() != "three\n" {
+			t.Errorf("Undoing the undo should restore the edit, got %q (%v)", read(), err)
+		}
+	})
+
+	t.Run("Undo creation deletes the file", func(t *testing.T) {
+		list, _ := versions.List(path, 0)
+		first := list[len(list)-1]
+		if _, err := undo.Execute(map[string]interface{}{"path": path, "version_id": first.ID}); err != nil {
+			t.Fatalf("Undo failed: %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("File should be removed when restoring a version where it did not exist")
+		}
+		if _, err := undo.Execute(map[string]interface{}{"path": path}); err != nil || read() != "three\n" {
+			t.Errorf("Deletion should be undoable, got %q (%v)", read(), err)
+		}
+	})
+
+	t.Run("Parent directories are not created silently", func(t *testing.T) {
+		nested := filepath.Join(dir, "a", "b.txt")
+		if _, err := writeTool.Execute(map[string]interface{}{"path": nested, "content": "x"}); err == nil {
+			t.Error("Expected error for missing parent directory")
+		}
+		if _, err := writeTool.Execute(map[string]interface{}{"path": nested, "content": "x", "create_dirs": true}); err != nil {
+			t.Errorf("create_dirs should allow the write: %v", err)
+		}
+	})
+
+	t.Run("Outside allowed paths", func(t *testing.T) {
+		if _, err := undo.Execute(map[string]interface{}{"path": filepath.Join(t.TempDir(), "x")}); err == nil {
+			t.Error("Expected error for path outside allowed directories")
+		}
+	})
+}
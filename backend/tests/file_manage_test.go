@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"nira/memory"
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileManage verifies move, copy, delete-to-trash, restore and mkdir,
+// and that sandbox roots are protected.
+func TestFileManage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	trash := memory.NewTrashStore(db, filepath.Join(t.TempDir(), "trash"))
+	roots := []string{root}
+
+	move := tools.NewMovePathTool(roots)
+	move.Trash = trash
+	copyTool := tools.NewCopyPathTool(roots)
+	copyTool.Trash = trash
+	del := tools.NewDeletePathTool(roots)
+	del.Trash = trash
+	mkdir := tools.NewMakeDirectoryTool(roots)
+	checker := sandbox.New(roots)
+	restore := tools.NewTrashRestoreTool(trash, checker)
+	list := tools.NewTrashListTool(trash, checker)
+
+	run := func(tool tools.Tool, args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("%s %v failed: %v", tool.Name(), args, err)
+		}
+		return result.(map[string]interface{})
+	}
+	p := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	read := func(path string) string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	t.Run("Make directory", func(t *testing.T) {
+		if r := run(mkdir, map[string]interface{}{"path": p("notes/2024")}); r["created"] != true {
+			t.Errorf("Expected created: %v", r)
+		}
+		if r := run(mkdir, map[string]interface{}{"path": p("notes/2024")}); r["created"] != false {
+			t.Errorf("Existing directory should not be reported as created: %v", r)
+		}
+		if _, err := mkdir.Execute(map[string]interface{}{"path": p("a/b"), "parents": false}); err == nil {
+			t.Error("Expected error without parents")
+		}
+	})
+
+	t.Run("Move and copy", func(t *testing.T) {
+		os.WriteFile(p("notes/todo.md"), []byte("buy milk"), 0644)
+		run(move, map[string]interface{}{"source": p("notes/todo.md"), "destination": p("notes/2024/todo.md")})
+		if read(p("notes/2024/todo.md")) != "buy milk" {
+			t.Fatal("File was not moved")
+		}
+		if _, err := os.Stat(p("notes/todo.md")); !os.IsNotExist(err) {
+			t.Error("Source should be gone after move")
+		}
+
+		r := run(copyTool, map[string]interface{}{"source": p("notes"), "destination": p("backup/notes"), "create_dirs": true})
+		if read(p("backup/notes/2024/todo.md")) != "buy milk" || r["files"] != int64(1) {
+			t.Errorf("Directory was not copied: %v", r)
+		}
+		if _, err := copyTool.Execute(map[string]interface{}{"source": p("notes"), "destination": p("notes/2024/copy")}); err == nil {
+			t.Error("Expected error copying a directory into itself")
+		}
+	})
+
+	t.Run("Overwrite goes to the trash", func(t *testing.T) {
+		os.WriteFile(p("draft.md"), []byte("new"), 0644)
+		if _, err := move.Execute(map[string]interface{}{"source": p("draft.md"), "destination": p("notes/2024/todo.md")}); err == nil {
+			t.Fatal("Expected error for an existing destination without overwrite")
+		}
+		r := run(move, map[string]interface{}{"source": p("draft.md"), "destination": p("notes/2024/todo.md"), "overwrite": true})
+		if read(p("notes/2024/todo.md")) != "new" || r["replaced_trash_id"] == nil {
+			t.Errorf("Unexpected overwrite result: %v", r)
+		}
+	})
+
+	t.Run("Delete and restore", func(t *testing.T) {
+		r := run(del, map[string]interface{}{"path": p("backup")})
+		if _, err := os.Stat(p("backup")); !os.IsNotExist(err) {
+			t.Fatal("Deleted directory should be gone")
+		}
+		items := run(list, map[string]interface{}{})["items"].([]memory.TrashItem)
+		if len(items) != 2 || items[0].ID != r["trash_id"] || !items[0].IsDir {
+			t.Fatalf("Unexpected trash listing: %+v", items)
+		}
+		run(restore, map[string]interface{}{"id": r["trash_id"]})
+		if read(p("backup/notes/2024/todo.md")) != "buy milk" {
+			t.Error("Directory was not restored")
+		}
+		if _, err := restore.Execute(map[string]interface{}{"id": r["trash_id"]}); err == nil {
+			t.Error("Expected error restoring the same entry twice")
+		}
+	})
+
+	t.Run("Sandbox", func(t *testing.T) {
+		os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("x"), 0644)
+		cases := []struct {
+			name string
+			tool tools.Tool
+			args map[string]interface{}
+			want string
+		}{
+			{"delete root", del, map[string]interface{}{"path": root}, "protected"},
+			{"move root", move, map[string]interface{}{"source": root, "destination": p("moved")}, "protected"},
+			{"move out", move, map[string]interface{}{"source": p("notes"), "destination": filepath.Join(outside, "notes")}, "not in allowed"},
+			{"copy in", copyTool, map[string]interface{}{"source": filepath.Join(outside, "secret.txt"), "destination": p("secret.txt")}, "not in allowed"},
+			{"delete outside", del, map[string]interface{}{"path": filepath.Join(outside, "secret.txt")}, "not in allowed"},
+			{"mkdir outside", mkdir, map[string]interface{}{"path": filepath.Join(outside, "x")}, "not in allowed"},
+		}
+		for _, c := range cases {
+			_, err := c.tool.Execute(c.args)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("%s: expected error containing %q, got %v", c.name, c.want, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(outside, "secret.txt")); err != nil {
+			t.Error("File outside the sandbox was touched")
+		}
+	})
+}
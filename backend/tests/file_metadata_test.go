@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"nira/tools"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileMetadata_Options verifies the optional metadata fields.
+func TestFileMetadata_Options(t *testing.T) {
+	dir := t.TempDir()
+	tool := tools.NewFileMetadataTool([]string{dir})
+	meta := func(args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("file_metadata %v failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("one\ntwo\nthree"), 0640)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "blob.bin"), []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 13}, 0644)
+
+	t.Run("File details", func(t *testing.T) {
+		r := meta(map[string]interface{}{"path": notes, "all": true})
+		if r["mime_type"] != "text/plain; charset=utf-8" || r["line_count"] != 3 || r["encoding"] != "utf-8" {
+			t.Errorf("Unexpected text details: %v", r)
+		}
+		if r["sha256"] != "058053d87c818d699cde0f00d670bca0e1c6ad857caa9758ea6a556d7c64fcee" {
+			t.Errorf("Unexpected sha256: %v", r["sha256"])
+		}
+		if r["permissions"] != "0640" || r["mode"] != "-rw-r-----" {
+			t.Errorf("Unexpected permissions: %v %v", r["permissions"], r["mode"])
+		}
+		if r := meta(map[string]interface{}{"path": notes}); r["line_count"] != nil || r["sha256"] != nil {
+			t.Errorf("Options should be off by default: %v", r)
+		}
+		if r := meta(map[string]interface{}{"path": filepath.Join(dir, "sub", "blob.bin"), "line_count": true}); r["binary"] != true || r["line_count"] != nil {
+			t.Errorf("Binary files should not get a line count: %v", r)
+		}
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		link := filepath.Join(dir, "link.txt")
+		if err := os.Symlink("notes.txt", link); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+		r := meta(map[string]interface{}{"path": link})
+		if r["is_symlink"] != true || r["symlink_target"] != "notes.txt" || r["size"] != int64(13) {
+			t.Errorf("Unexpected symlink metadata: %v", r)
+		}
+		os.Remove(link)
+	})
+
+	t.Run("Directory summary", func(t *testing.T) {
+		r := meta(map[string]interface{}{"path": dir, "dir_summary": true})
+		children := r["children"].(map[string]int)
+		if children["files"] != 1 || children["dirs"] != 1 || r["total_files"] != 2 || r["total_size"] != int64(21) {
+			t.Errorf("Unexpected summary: %v", r)
+		}
+	})
+
+	t.Run("Git status", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not installed")
+		}
+		git := func(args ...string) {
+			cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v: %s", args, err, out)
+			}
+		}
+		if r := meta(map[string]interface{}{"path": notes, "git_status": true}); r["git"].(map[string]interface{})["in_repo"] != false {
+			t.Errorf("Expected not in repo: %v", r["git"])
+		}
+		git("init", "-q")
+		os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.bin\n"), 0644)
+		git("add", "notes.txt", ".gitignore")
+		git("commit", "-q", "-m", "init")
+
+		status := func(p string) map[string]interface{} {
+			return meta(map[string]interface{}{"path": p, "git_status": true})["git"].(map[string]interface{})
+		}
+		if g := status(notes); g["status"] != "clean" || g["tracked"] != true {
+			t.Errorf("Expected clean tracked file: %v", g)
+		}
+		os.WriteFile(notes, []byte("changed\n"), 0640)
+		if g := status(notes); g["status"] != "modified" {
+			t.Errorf("Expected modified: %v", g)
+		}
+		os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0644)
+		if g := status(filepath.Join(dir, "new.txt")); g["status"] != "untracked" || g["tracked"] != false {
+			t.Errorf("Expected untracked: %v", g)
+		}
+		if g := status(filepath.Join(dir, "sub", "blob.bin")); g["status"] != "ignored" {
+			t.Errorf("Expected ignored: %v", g)
+		}
+		if g := status(dir); g["status"] != "dirty" || g["modified"] != 1 || g["untracked"] != 1 {
+			t.Errorf("Unexpected directory status: %v", g)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"bytes"
+	"nira/memory"
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFilePreview_DiffAndPinning verifies that mutating file tools report
+// their change as a diff and refuse to apply it if the file changed after
+// the preview was approved.
+func TestFilePreview_DiffAndPinning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.txt")
+	os.WriteFile(path, []byte("milk\neggs\n"), 0644)
+
+	writeTool := tools.NewFileWriteTool([]string{dir})
+	editTool := tools.NewFileEditTool([]string{dir})
+	var _ tools.Previewer = writeTool
+	var _ tools.Previewer = editTool
+
+	t.Run("Write preview", func(t *testing.T) {
+		previews, err := writeTool.Preview(map[string]interface{}{"path": path, "content": "milk\nbread\n"})
+		if err != nil || len(previews) != 1 {
+			t.Fatalf("Preview failed: %v %v", previews, err)
+		}
+		p := previews[0]
+		if p.Action != "modify" || p.Added != 1 || p.Removed != 1 || !strings.Contains(p.Diff, "-eggs\n+bread\n") {
+			t.Errorf("Unexpected preview: %+v", p)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "milk\neggs\n" {
+			t.Error("Preview must not write the file")
+		}
+
+		created, err := writeTool.Preview(map[string]interface{}{"path": filepath.Join(dir, "new.txt"), "content": "x\n"})
+		if err != nil || created[0].Action != "create" || created[0].BaseSHA256 != "" {
+			t.Errorf("Expected create preview, got %+v (%v)", created, err)
+		}
+	})
+
+	t.Run("Edit preview", func(t *testing.T) {
+		previews, err := editTool.Preview(map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": "eggs", "replace": "butter"}},
+		})
+		if err != nil || !strings.Contains(previews[0].Diff, "+butter") {
+			t.Errorf("Unexpected edit preview: %v (%v)", previews, err)
+		}
+		if _, err := editTool.Preview(map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": "cheese", "replace": "x"}},
+		}); err == nil {
+			t.Error("Preview should report an edit that cannot apply")
+		}
+	})
+
+	t.Run("Approved preview is pinned to the previewed content", func(t *testing.T) {
+		args := map[string]interface{}{"path": path, "content": "milk\nbread\n"}
+		previews, _ := writeTool.Preview(args)
+		args[tools.PreviewBaseArg] = map[string]interface{}{path: previews[0].BaseSHA256}
+
+		// Someone else changes the file between approval and execution.
+		os.WriteFile(path, []byte("milk\neggs\nflour\n"), 0644)
+		if _, err := writeTool.Execute(args); err == nil || !strings.Contains(err.Error(), "changed after the preview") {
+			t.Errorf("Expected stale preview error, got %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != "milk\neggs\nflour\n" {
+			t.Error("Stale preview must not overwrite the newer content")
+		}
+
+		previews, _ = writeTool.Preview(args)
+		args[tools.PreviewBaseArg] = map[string]interface{}{path: previews[0].BaseSHA256}
+		if _, err := writeTool.Execute(args); err != nil {
+			t.Errorf("Write with current preview should succeed: %v", err)
+		}
+	})
+}
+
+// TestFilePreview_FileManagement verifies the summaries of moves, copies,
+// deletions, new directories, restores and extractions, and that they are
+// pinned to what was previewed.
+func TestFilePreview_FileManagement(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	root := t.TempDir()
+	roots := []string{root}
+	trash := memory.NewTrashStore(db, filepath.Join(t.TempDir(), "trash"))
+	p := func(rel string) string { return filepath.Join(root, filepath.FromSlash(rel)) }
+	os.MkdirAll(p("src/sub"), 0755)
+	os.WriteFile(p("src/a.txt"), []byte("alpha\n"), 0644)
+	os.WriteFile(p("src/sub/b.txt"), []byte("beta\n"), 0644)
+	os.WriteFile(p("old.txt"), []byte("old\n"), 0644)
+
+	move := tools.NewMovePathTool(roots)
+	move.Trash = trash
+	copyTool := tools.NewCopyPathTool(roots)
+	copyTool.Trash = trash
+	del := tools.NewDeletePathTool(roots)
+	del.Trash = trash
+	mkdir := tools.NewMakeDirectoryTool(roots)
+	restore := tools.NewTrashRestoreTool(trash, sandbox.New(roots))
+	extract := tools.NewArchiveExtractTool(roots)
+	extract.Trash = trash
+	for _, tool := range []tools.Tool{move, copyTool, del, mkdir, restore, extract} {
+		if _, ok := tool.(tools.Previewer); !ok {
+			t.Errorf("%s does not implement Previewer", tool.Name())
+		}
+	}
+
+	preview := func(tool tools.Previewer, args map[string]interface{}) []tools.FilePreview {
+		t.Helper()
+		previews, err := tool.Preview(args)
+		if err != nil {
+			t.Fatalf("Preview %v failed: %v", args, err)
+		}
+		return previews
+	}
+	pin := func(args map[string]interface{}, previews []tools.FilePreview) {
+		bases := map[string]interface{}{}
+		for _, pr := range previews {
+			bases[pr.Path] = pr.BaseSHA256
+		}
+		args[tools.PreviewBaseArg] = bases
+	}
+
+	t.Run("Move", func(t *testing.T) {
+		args := map[string]interface{}{"source": p("src"), "destination": p("dst/src"), "create_dirs": true}
+		previews := preview(move, args)
+		if len(previews) != 2 || previews[0].Action != "move" || previews[1].Action != "create" {
+			t.Fatalf("Unexpected move preview: %+v", previews)
+		}
+		if !strings.Contains(previews[0].Summary, "directory (2 files") || !strings.Contains(previews[1].Summary, "creates "+p("dst")) {
+			t.Errorf("Unexpected summaries: %q / %q", previews[0].Summary, previews[1].Summary)
+		}
+		if _, err := os.Stat(p("dst")); err == nil {
+			t.Error("Preview must not create directories")
+		}
+
+		pin(args, previews)
+		os.WriteFile(p("src/new.txt"), []byte("late\n"), 0644)
+		if _, err := move.Execute(args); err == nil || !strings.Contains(err.Error(), "changed after the preview") {
+			t.Errorf("Expected stale preview error, got %v", err)
+		}
+		if _, err := os.Stat(p("src/a.txt")); err != nil {
+			t.Error("Stale preview must not move anything")
+		}
+		os.Remove(p("src/new.txt"))
+	})
+
+	t.Run("Copy over an existing file", func(t *testing.T) {
+		args := map[string]interface{}{"source": p("src/a.txt"), "destination": p("old.txt"), "overwrite": true}
+		previews := preview(copyTool, args)
+		if len(previews) != 1 || previews[0].Action != "replace" || !strings.Contains(previews[0].Summary, "goes to the trash") {
+			t.Fatalf("Unexpected copy preview: %+v", previews)
+		}
+		if _, err := copyTool.Preview(map[string]interface{}{"source": p("src/a.txt"), "destination": p("old.txt")}); err == nil {
+			t.Error("Expected an error previewing a copy onto an existing file without overwrite")
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		file := preview(del, map[string]interface{}{"path": p("old.txt")})
+		if file[0].Action != "delete" || !strings.Contains(file[0].Diff, "-old") || file[0].Summary == "" {
+			t.Errorf("Expected a file deletion with a diff, got %+v", file)
+		}
+		dir := preview(del, map[string]interface{}{"path": p("src")})
+		if dir[0].Action != "delete" || !strings.Contains(dir[0].Summary, "directory (2 files") {
+			t.Errorf("Expected a directory summary, got %+v", dir)
+		}
+		if _, err := del.Preview(map[string]interface{}{"path": root}); err == nil {
+			t.Error("Expected the protected root to be refused")
+		}
+	})
+
+	t.Run("Make directory", func(t *testing.T) {
+		args := map[string]interface{}{"path": p("x/y/z")}
+		previews := preview(mkdir, args)
+		if previews[0].Action != "create" || !strings.Contains(previews[0].Summary, p("x")+", "+p("x/y")) {
+			t.Errorf("Unexpected mkdir preview: %+v", previews)
+		}
+		if _, err := mkdir.Preview(map[string]interface{}{"path": p("x/y/z"), "parents": false}); err == nil {
+			t.Error("Expected an error without parents")
+		}
+
+		pin(args, previews)
+		os.MkdirAll(p("x/y/z/w"), 0755)
+		if _, err := mkdir.Execute(args); err == nil || !strings.Contains(err.Error(), "changed after the preview") {
+			t.Errorf("Expected stale preview error, got %v", err)
+		}
+		os.RemoveAll(p("x"))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		result, err := del.Execute(map[string]interface{}{"path": p("src/a.txt")})
+		if err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		id := result.(map[string]interface{})["trash_id"].(int64)
+		previews := preview(restore, map[string]interface{}{"id": id})
+		if previews[0].Action != "create" || !strings.Contains(previews[0].Diff, "+alpha") || !strings.Contains(previews[0].Summary, "delete_path") {
+			t.Errorf("Unexpected restore preview: %+v", previews)
+		}
+	})
+
+	t.Run("Extract", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeTar(t, &buf, []archiveFile{
+			{name: "pkg/", dir: true},
+			{name: "pkg/old.txt", body: "new\n"},
+			{name: "pkg/fresh.txt", body: "fresh\n"},
+			{name: "../evil.txt", body: "x"},
+		})
+		os.WriteFile(p("bundle.tar"), buf.Bytes(), 0644)
+		os.MkdirAll(p("out/pkg"), 0755)
+		os.WriteFile(p("out/pkg/old.txt"), []byte("old\n"), 0644)
+
+		args := map[string]interface{}{"path": p("bundle.tar"), "destination": p("out"), "overwrite": true}
+		previews := preview(extract, args)
+		summary := previews[0].Summary
+		if previews[0].Action != "modify" || !strings.Contains(summary, "extracts 2 files") || !strings.Contains(summary, "1 existing file replaced (the old versions go to the trash): pkg/old.txt") || !strings.Contains(summary, "1 unsafe name") {
+			t.Errorf("Unexpected extract preview: %+v", previews)
+		}
+		if indexOnly := preview(extract, map[string]interface{}{"path": p("bundle.tar"), "index": true}); len(indexOnly) != 0 {
+			t.Errorf("Index-only extraction changes no files, got %+v", indexOnly)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"fmt"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// TestFileRead_RangesAndLimits verifies line ranges, head/tail, byte
+// offsets, truncation and encoding detection.
+func TestFileRead_RangesAndLimits(t *testing.T) {
+	dir := t.TempDir()
+	tool := tools.NewFileReadTool([]string{dir})
+
+	var lines strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&lines, "line %03d\n", i)
+	}
+	logPath := filepath.Join(dir, "app.log")
+	os.WriteFile(logPath, []byte(lines.String()), 0644)
+
+	read := func(args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("read_file %v failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+
+	t.Run("Whole small file", func(t *testing.T) {
+		r := read(map[string]interface{}{"path": logPath})
+		if r["content"] != lines.String() || r["total_lines"] != 100 || r["truncated"] != false {
+			t.Errorf("Unexpected result: total=%v truncated=%v", r["total_lines"], r["truncated"])
+		}
+	})
+
+	t.Run("Line range", func(t *testing.T) {
+		r := read(map[string]interface{}{"path": logPath, "start_line": 10, "end_line": 12})
+		if r["content"] != "line 010\nline 011\nline 012\n" || r["start_line"] != 10 || r["end_line"] != 12 || r["total_lines"] != 100 {
+			t.Errorf("Unexpected range result: %v", r)
+		}
+	})
+
+	t.Run("Head and tail", func(t *testing.T) {
+		if r := read(map[string]interface{}{"path": logPath, "head": 2}); r["content"] != "line 001\nline 002\n" {
+			t.Errorf("Unexpected head: %q", r["content"])
+		}
+		r := read(map[string]interface{}{"path": logPath, "tail": 2})
+		if r["content"] != "line 099\nline 100\n" || r["start_line"] != 99 {
+			t.Errorf("Unexpected tail: %v", r)
+		}
+	})
+
+	t.Run("Max bytes truncates with a marker", func(t *testing.T) {
+		r := read(map[string]interface{}{"path": logPath, "max_bytes": 30})
+		content := r["content"].(string)
+		if !strings.HasPrefix(content, "line 001\nline 002\nline 003\n[... truncated") || r["end_line"] != 3 || r["truncated"] != true {
+			t.Errorf("Unexpected truncated read: %q", content)
+		}
+		if !strings.Contains(content, "start_line=4") {
+			t.Errorf("Marker should say where to continue: %q", content)
+		}
+		r = read(map[string]interface{}{"path": logPath, "tail": 50, "max_bytes": 20})
+		if !strings.HasPrefix(r["content"].(string), "[... truncated") || !strings.HasSuffix(r["content"].(string), "line 099\nline 100\n") {
+			t.Errorf("Unexpected truncated tail: %q", r["content"])
+		}
+	})
+
+	t.Run("Byte offsets", func(t *testing.T) {
+		r := read(map[string]interface{}{"path": logPath, "offset": 9, "length": 9})
+		if r["content"] != "line 002\n" || r["next_offset"] != int64(18) || r["total_lines"] != 100 {
+			t.Errorf("Unexpected byte range: %v", r)
+		}
+		if _, err := tool.Execute(map[string]interface{}{"path": logPath, "offset": 9, "head": 2}); err == nil {
+			t.Error("Expected error mixing byte and line arguments")
+		}
+	})
+
+	t.Run("Long single line", func(t *testing.T) {
+		p := filepath.Join(dir, "minified.js")
+		os.WriteFile(p, []byte(strings.Repeat("é", 1000)), 0644)
+		r := read(map[string]interface{}{"path": p, "max_bytes": 101})
+		content := r["content"].(string)
+		if !strings.HasPrefix(content, strings.Repeat("é", 50)+"\n[... truncated") || r["total_lines"] != 1 {
+			t.Errorf("Long line should be cut on a character boundary: %q", content[:min(len(content), 120)])
+		}
+	})
+
+	t.Run("Encodings", func(t *testing.T) {
+		utf16Path := filepath.Join(dir, "win.txt")
+		units := utf16.Encode([]rune("héllo\r\nwörld\r\n"))
+		data := []byte{0xFF, 0xFE}
+		for _, u := range units {
+			data = append(data, byte(u), byte(u>>8))
+		}
+		os.WriteFile(utf16Path, data, 0644)
+		r := read(map[string]interface{}{"path": utf16Path})
+		if r["encoding"] != "utf-16le" || r["content"] != "héllo\r\nwörld\r\n" || r["total_lines"] != 2 {
+			t.Errorf("Unexpected UTF-16 read: %v", r)
+		}
+
+		latin1Path := filepath.Join(dir, "old.txt")
+		os.WriteFile(latin1Path, []byte("caf\xe9\n"), 0644)
+		r = read(map[string]interface{}{"path": latin1Path})
+		if r["encoding"] != "latin-1" || r["content"] != "café\n" {
+			t.Errorf("Unexpected Latin-1 read: %v", r)
+		}
+
+		binPath := filepath.Join(dir, "image.bin")
+		os.WriteFile(binPath, []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 13, 0xFF, 0x10}, 0644)
+		if _, err := tool.Execute(map[string]interface{}{"path": binPath}); err == nil {
+			t.Error("Expected error for binary file")
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo creates a repository in a temp dir and returns a helper that
+// runs git in it.
+func gitRepo(t *testing.T) (string, func(args ...string) string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Tester", "-c", "user.email=tester@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "-b", "main")
+	return dir, run
+}
+
+// TestGitTools verifies status, log filters, show, diff and blame against a
+// temporary repository, including sandbox filtering and output limits.
+func TestGitTools(t *testing.T) {
+	repo, git := gitRepo(t)
+	write := func(name, content string) {
+		p := filepath.Join(repo, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+	}
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("secret.env", "TOKEN=1\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "Initial commit")
+	write("main.go", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n")
+	write("secret.env", "TOKEN=2\n")
+	git("add", ".")
+	git("-c", "user.name=Other", "commit", "-q", "-m", "Print a greeting", "-m", "Longer body.")
+	first := git("rev-parse", "HEAD~1")
+
+	checker := sandbox.FromSpecs([]sandbox.Spec{{Path: repo, Mode: sandbox.ModeReadWrite, Deny: []string{"*.env"}}})
+	allowed := []string{repo}
+
+	t.Run("Status", func(t *testing.T) {
+		status := tools.NewGitStatusToolWithChecker(allowed, checker)
+		result, err := status.Execute(map[string]interface{}{"path": repo})
+		if err != nil {
+			t.Fatalf("git_status failed: %v", err)
+		}
+		r := result.(map[string]interface{})
+		if r["branch"] != "main" || r["clean"] != true {
+			t.Errorf("Unexpected status of a clean repo: %v", r)
+		}
+
+		write("main.go", "package main\n")
+		write("notes.md", "new\n")
+		write("secret.env", "TOKEN=3\n")
+		defer git("checkout", "--", "main.go", "secret.env")
+		defer os.Remove(filepath.Join(repo, "notes.md"))
+		result, _ = status.Execute(map[string]interface{}{"path": repo})
+		files := result.(map[string]interface{})["files"].([]map[string]interface{})
+		got := map[string]string{}
+		for _, f := range files {
+			got[f["path"].(string)] = f["status"].(string)
+		}
+		if got["main.go"] != "modified" || got["notes.md"] != "untracked" || len(got) != 2 {
+			t.Errorf("Unexpected files (denied file must be hidden): %v", got)
+		}
+
+		result, _ = status.Execute(map[string]interface{}{"path": filepath.Join(repo, "notes.md")})
+		if files := result.(map[string]interface{})["files"].([]map[string]interface{}); len(files) != 1 {
+			t.Errorf("Status of one path should only report it: %v", files)
+		}
+	})
+
+	t.Run("Log", func(t *testing.T) {
+		log := tools.NewGitLogToolWithChecker(allowed, checker)
+		result, err := log.Execute(map[string]interface{}{"path": repo, "name_status": true})
+		if err != nil {
+			t.Fatalf("git_log failed: %v", err)
+		}
+		commits := result.(map[string]interface{})["commits"].([]map[string]interface{})
+		if len(commits) != 2 || commits[0]["subject"] != "Print a greeting" || commits[1]["hash"] != first {
+			t.Fatalf("Unexpected commits: %v", commits)
+		}
+		if files := commits[0]["files"].([]map[string]string); len(files) != 1 || files[0]["path"] != "main.go" {
+			t.Errorf("Denied files must not be listed: %v", files)
+		}
+
+		for _, args := range []map[string]interface{}{
+			{"path": repo, "author": "other"},
+			{"path": repo, "grep": "GREETING"},
+			{"path": repo, "max_count": 1},
+		} {
+			result, _ := log.Execute(args)
+			if commits := result.(map[string]interface{})["commits"].([]map[string]interface{}); len(commits) != 1 {
+				t.Errorf("Filter %v returned %d commits", args, len(commits))
+			}
+		}
+		if _, err := log.Execute(map[string]interface{}{"path": repo, "ref": "--output=/tmp/x"}); err == nil {
+			t.Error("Expected error for a ref that looks like an option")
+		}
+	})
+
+	t.Run("Show and diff", func(t *testing.T) {
+		show := tools.NewGitShowToolWithChecker(allowed, checker)
+		result, err := show.Execute(map[string]interface{}{"path": repo, "ref": "HEAD"})
+		if err != nil {
+			t.Fatalf("git_show failed: %v", err)
+		}
+		r := result.(map[string]interface{})
+		diff := r["diff"].(string)
+		if r["body"] != "Longer body." || r["author"] != "Other" || !strings.Contains(diff, "+import \"fmt\"") {
+			t.Errorf("Unexpected commit: %v", r)
+		}
+		if strings.Contains(diff, "TOKEN") {
+			t.Error("Diff of a denied file leaked")
+		}
+
+		d := tools.NewGitDiffToolWithChecker(allowed, checker)
+		result, err = d.Execute(map[string]interface{}{"path": repo, "from": first, "to": "HEAD", "max_bytes": 60})
+		if err != nil {
+			t.Fatalf("git_diff failed: %v", err)
+		}
+		r = result.(map[string]interface{})
+		files := r["files"].([]map[string]interface{})
+		if len(files) != 1 || files[0]["added"] != 3 || r["truncated"] != true {
+			t.Errorf("Unexpected diff result: %v", r)
+		}
+
+		write("main.go", "package main\n")
+		defer git("checkout", "--", "main.go")
+		result, _ = d.Execute(map[string]interface{}{"path": repo})
+		if !strings.Contains(result.(map[string]interface{})["diff"].(string), "-import \"fmt\"") {
+			t.Errorf("Expected the unstaged change: %v", result)
+		}
+		result, _ = d.Execute(map[string]interface{}{"path": repo, "staged": true})
+		if files := result.(map[string]interface{})["files"].([]map[string]interface{}); len(files) != 0 {
+			t.Errorf("Nothing is staged, got %v", files)
+		}
+	})
+
+	t.Run("Blame", func(t *testing.T) {
+		blame := tools.NewGitBlameToolWithChecker(allowed, checker)
+		result, err := blame.Execute(map[string]interface{}{"path": filepath.Join(repo, "main.go"), "start_line": 1, "end_line": 50})
+		if err != nil {
+			t.Fatalf("git_blame failed: %v", err)
+		}
+		lines := result.(map[string]interface{})["lines"].([]map[string]interface{})
+		if len(lines) != 5 || lines[0]["text"] != "package main" || lines[0]["author"] != "Tester" || lines[2]["author"] != "Other" {
+			t.Errorf("Unexpected blame: %v", lines)
+		}
+		if _, err := blame.Execute(map[string]interface{}{"path": filepath.Join(repo, "secret.env")}); err == nil {
+			t.Error("Expected error blaming a denied file")
+		}
+		if _, err := blame.Execute(map[string]interface{}{"path": t.TempDir()}); err == nil {
+			t.Error("Expected error outside allowed directories")
+		}
+	})
+}
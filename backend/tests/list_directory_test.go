@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestListDirectory_TreeAndIgnores verifies .gitignore-aware listing and
+// the tree rendering with depth limits and aggregated sizes.
+func TestListDirectory_TreeAndIgnores(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, size int) {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(strings.Repeat("x", size)), 0644)
+	}
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("build/\n"), 0644)
+	write("README.md", 100)
+	write("src/main.go", 1000)
+	write("src/util/strings.go", 2048)
+	write("src/util/deep/more/x.go", 10)
+	write("build/out.bin", 5000)
+	write("node_modules/lib/index.js", 5000)
+	write(".git/HEAD", 20)
+	write("app.log", 50)
+
+	tool := tools.NewListDirectoryTool([]string{root})
+	run := func(args map[string]interface{}) interface{} {
+		t.Helper()
+		args["path"] = root
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("list_directory %v failed: %v", args, err)
+		}
+		return result
+	}
+	names := func(result interface{}) string {
+		var out []string
+		for _, e := range result.([]map[string]interface{}) {
+			rel, _ := filepath.Rel(root, e["path"].(string))
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return strings.Join(out, ",")
+	}
+
+	t.Run("List skips ignored entries", func(t *testing.T) {
+		got := names(run(map[string]interface{}{"recursive": true, "ignore": []interface{}{"*.log"}}))
+		for _, hidden := range []string{"build", "node_modules", ".git", "app.log"} {
+			if strings.Contains(","+got+",", ","+hidden+",") || strings.Contains(","+got, ","+hidden+"/") {
+				t.Errorf("%s should be hidden: %s", hidden, got)
+			}
+		}
+		if !strings.Contains(got, "src/util/strings.go") {
+			t.Errorf("Expected nested files: %s", got)
+		}
+		if got := names(run(map[string]interface{}{"ignore_gitignore": true})); !strings.Contains(got, "node_modules") || !strings.Contains(got, "build") {
+			t.Errorf("ignore_gitignore should list everything: %s", got)
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		r := run(map[string]interface{}{"mode": "tree", "max_depth": 2}).(map[string]interface{})
+		tree := r["tree"].(string)
+		want := []string{
+			"├── src/ (3 files, 3.0 KB)",
+			"│   ├── util/ (2 files, 2.0 KB) …",
+			"│   └── main.go (1000 B)",
+			"├── README.md (100 B)",
+		}
+		for _, line := range want {
+			if !strings.Contains(tree, line+"\n") {
+				t.Errorf("Tree is missing %q:\n%s", line, tree)
+			}
+		}
+		if strings.Contains(tree, "strings.go") || strings.Contains(tree, "node_modules") {
+			t.Errorf("Tree should collapse deep directories and skip ignored ones:\n%s", tree)
+		}
+		if r["total_files"] != 6 || r["total_size"] != int64(3215) {
+			t.Errorf("Unexpected totals: %v %v", r["total_files"], r["total_size"])
+		}
+
+		r = run(map[string]interface{}{"mode": "tree", "max_items": 2}).(map[string]interface{})
+		if r["truncated"] != true || !strings.Contains(r["tree"].(string), "… ") {
+			t.Errorf("Expected a truncated tree:\n%s", r["tree"])
+		}
+	})
+}
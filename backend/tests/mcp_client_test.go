@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMCPFakeServer is not a real test: when NIRA_FAKE_MCP is set, the test
+// binary re-executed by TestMCPClient_ProxyAndRestart acts as an MCP server.
+func TestMCPFakeServer(t *testing.T) {
+	if os.Getenv("NIRA_FAKE_MCP") != "1" {
+		t.Skip("helper process for MCP client tests")
+	}
+	// NIRA_FAKE_MCP_SLOW_RESTART delays the handshake of every start but the first.
+	var initDelay time.Duration
+	if log := os.Getenv("NIRA_FAKE_MCP_LOG"); log != "" {
+		if data, _ := os.ReadFile(log); len(data) > 0 {
+			initDelay, _ = time.ParseDuration(os.Getenv("NIRA_FAKE_MCP_SLOW_RESTART"))
+		}
+		f, _ := os.OpenFile(log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		fmt.Fprintln(f, "start")
+		f.Close()
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	reply := func(id json.RawMessage, result interface{}) {
+		out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+	}
+	text := func(s string, isErr bool) map[string]interface{} {
+		return map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": s}}, "isError": isErr}
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+				Cursor    string                 `json:"cursor"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(in.Bytes(), &msg); err != nil {
+			os.Exit(2)
+		}
+		switch msg.Method {
+		case "initialize":
+			time.Sleep(initDelay)
+			reply(msg.ID, map[string]interface{}{
+				"protocolVersion": tools.MCPProtocolVersion,
+				"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+				"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+			})
+		case "tools/list":
+			// Two pages to exercise cursor handling.
+			if msg.Params.Cursor == "" {
+				reply(msg.ID, map[string]interface{}{
+					"tools": []interface{}{map[string]interface{}{
+						"name":        "echo",
+						"description": "Echoes text",
+						"inputSchema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}}},
+					}},
+					"nextCursor": "2",
+				})
+			} else {
+				reply(msg.ID, map[string]interface{}{"tools": []interface{}{
+					map[string]interface{}{"name": "fail", "inputSchema": map[string]interface{}{"type": "object"}},
+					map[string]interface{}{"name": "crash", "inputSchema": map[string]interface{}{"type": "object"}},
+				}})
+			}
+		case "tools/call":
+			switch msg.Params.Name {
+			case "echo":
+				reply(msg.ID, text(fmt.Sprint(msg.Params.Arguments["text"]), false))
+			case "fail":
+				reply(msg.ID, text("something broke", true))
+			case "crash":
+				os.Exit(1)
+			default:
+				out.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "error": map[string]interface{}{"code": -32602, "message": "unknown tool"}})
+			}
+		}
+	}
+	os.Exit(0)
+}
+
+// TestMCPClient_ProxyAndRestart verifies tool listing, namespaced proxies,
+// call forwarding, tool errors and restart after the server exits.
+func TestMCPClient_ProxyAndRestart(t *testing.T) {
+	startLog := filepath.Join(t.TempDir(), "starts")
+	client, err := tools.NewMCPClient(tools.MCPServerConfig{
+		Name:    "fake",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestMCPFakeServer$"},
+		Env:     map[string]string{"NIRA_FAKE_MCP": "1", "NIRA_FAKE_MCP_LOG": startLog},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MCP client: %v", err)
+	}
+	client.Logf = t.Logf
+	if err := client.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+	defer client.Close()
+
+	proxies := map[string]tools.Tool{}
+	for _, p := range client.Tools() {
+		proxies[p.Name()] = p
+	}
+	if len(proxies) != 3 {
+		t.Fatalf("Expected 3 tools across both pages, got %d", len(proxies))
+	}
+	echo, ok := proxies["fake__echo"]
+	if !ok {
+		t.Fatalf("Expected namespaced tool fake__echo, got %v", proxies)
+	}
+
+	t.Run("Schema and permission", func(t *testing.T) {
+		schema := echo.Schema()
+		params := schema["parameters"].(map[string]interface{})
+		if _, ok := params["properties"].(map[string]interface{})["text"]; !ok {
+			t.Errorf("Input schema not forwarded: %v", params)
+		}
+		if !strings.Contains(echo.Description(), "Echoes text") {
+			t.Errorf("Description not forwarded: %s", echo.Description())
+		}
+		if echo.Permission() != tools.PermissionWrite {
+			t.Errorf("MCP tools should default to write, got %s", echo.Permission())
+		}
+	})
+
+	t.Run("Call forwarding", func(t *testing.T) {
+		result, err := echo.Execute(map[string]interface{}{"text": "hello"})
+		if err != nil || result != "hello" {
+			t.Errorf("Expected echo result, got %v, %v", result, err)
+		}
+		if _, err := proxies["fake__fail"].Execute(nil); err == nil || !strings.Contains(err.Error(), "something broke") {
+			t.Errorf("Expected tool error, got %v", err)
+		}
+	})
+
+	t.Run("Restart after exit", func(t *testing.T) {
+		if _, err := proxies["fake__crash"].Execute(nil); err == nil {
+			t.Fatal("Expected error when the server exits mid-call")
+		}
+		result, err := echo.Execute(map[string]interface{}{"text": "again"})
+		if err != nil || result != "again" {
+			t.Fatalf("Expected call to succeed after restart, got %v, %v", result, err)
+		}
+		data, _ := os.ReadFile(startLog)
+		if n := strings.Count(string(data), "start"); n != 2 {
+			t.Errorf("Expected server to be started twice, got %d", n)
+		}
+	})
+}
+
+// TestMCPClient_InfoDuringRestart verifies that tool descriptions are served
+// from the last listing while the server restarts, and that concurrent calls
+// share one restart.
+func TestMCPClient_InfoDuringRestart(t *testing.T) {
+	startLog := filepath.Join(t.TempDir(), "starts")
+	client, err := tools.NewMCPClient(tools.MCPServerConfig{
+		Name:    "fake",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^TestMCPFakeServer$"},
+		Env:     map[string]string{"NIRA_FAKE_MCP": "1", "NIRA_FAKE_MCP_LOG": startLog, "NIRA_FAKE_MCP_SLOW_RESTART": "1s"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MCP client: %v", err)
+	}
+	client.Logf = t.Logf
+	if err := client.Start(); err != nil {
+		t.Fatalf("Failed to start MCP server: %v", err)
+	}
+	defer client.Close()
+	proxies := map[string]tools.Tool{}
+	for _, p := range client.Tools() {
+		proxies[p.Name()] = p
+	}
+	echo := proxies["fake__echo"]
+	proxies["fake__crash"].Execute(nil)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = echo.Execute(map[string]interface{}{"text": "x"})
+		}(i)
+	}
+	time.Sleep(200 * time.Millisecond)
+	start := time.Now()
+	if !strings.Contains(echo.Description(), "Echoes text") || echo.Schema()["parameters"] == nil {
+		t.Errorf("Expected the cached description, got %q", echo.Description())
+	}
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Errorf("Description waited %v for the restart", d)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Call %d failed after restart: %v", i, err)
+		}
+	}
+	data, _ := os.ReadFile(startLog)
+	if n := strings.Count(string(data), "start"); n != 2 {
+		t.Errorf("Expected one restart shared by both calls, got %d starts", n)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"nira/memory"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMCPServer_Protocol drives the server over pipes and verifies tool
+// listing, exposure filtering and that file tools keep their sandbox.
+func TestMCPServer_Protocol(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	allowedDir := t.TempDir()
+	outsideDir := t.TempDir()
+	os.WriteFile(filepath.Join(allowedDir, "note.txt"), []byte("inside"), 0644)
+	os.WriteFile(filepath.Join(outsideDir, "secret.txt"), []byte("outside"), 0644)
+
+	allowed, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	if err := allowed.Add(allowedDir); err != nil {
+		t.Fatalf("Failed to allow directory: %v", err)
+	}
+
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewFileReadToolWithChecker(nil, allowed))
+	registry.Register(tools.NewAllowedDirsAddTool(allowed))
+
+	server := tools.NewMCPServer(registry)
+	server.Expose = func(tool tools.Tool) bool { return tool.Permission() != tools.PermissionGrant }
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	responses := bufio.NewScanner(clientIn)
+	responses.Buffer(make([]byte, 1<<20), 1<<20)
+
+	roundTrip := func(id int, method string, params interface{}) map[string]interface{} {
+		t.Helper()
+		req, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		if _, err := clientOut.Write(append(req, '\n')); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+		if !responses.Scan() {
+			t.Fatalf("No response to %s", method)
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(responses.Bytes(), &resp); err != nil {
+			t.Fatalf("Invalid response JSON: %v", err)
+		}
+		if resp["id"] != float64(id) {
+			t.Fatalf("Response id mismatch: %v", resp)
+		}
+		return resp
+	}
+	callText := func(id int, name string, args map[string]interface{}) (string, bool) {
+		t.Helper()
+		resp := roundTrip(id, "tools/call", map[string]interface{}{"name": name, "arguments": args})
+		result, ok := resp["result"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected result for %s, got %v", name, resp)
+		}
+		content := result["content"].([]interface{})[0].(map[string]interface{})
+		isErr, _ := result["isError"].(bool)
+		return content["text"].(string), isErr
+	}
+
+	t.Run("Initialize", func(t *testing.T) {
+		resp := roundTrip(1, "initialize", map[string]interface{}{"protocolVersion": tools.MCPProtocolVersion, "capabilities": map[string]interface{}{}})
+		result := resp["result"].(map[string]interface{})
+		if result["protocolVersion"] != tools.MCPProtocolVersion {
+			t.Errorf("Unexpected protocol version: %v", result)
+		}
+		// The initialized notification gets no response; the next request must still line up.
+		clientOut.Write([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"))
+	})
+
+	t.Run("List hides unexposed tools", func(t *testing.T) {
+		resp := roundTrip(2, "tools/list", map[string]interface{}{})
+		list := resp["result"].(map[string]interface{})["tools"].([]interface{})
+		if len(list) != 1 || list[0].(map[string]interface{})["name"] != "read_file" {
+			t.Errorf("Expected only read_file, got %v", list)
+		}
+		if _, ok := list[0].(map[string]interface{})["inputSchema"].(map[string]interface{}); !ok {
+			t.Errorf("Expected inputSchema for read_file")
+		}
+	})
+
+	t.Run("Sandboxed calls", func(t *testing.T) {
+		text, isErr := callText(3, "read_file", map[string]interface{}{"path": filepath.Join(allowedDir, "note.txt")})
+		if isErr || !strings.Contains(text, "inside") {
+			t.Errorf("Expected file content, got %q (error=%v)", text, isErr)
+		}
+		text, isErr = callText(4, "read_file", map[string]interface{}{"path": filepath.Join(outsideDir, "secret.txt")})
+		if !isErr || strings.Contains(text, "outside") {
+			t.Errorf("Expected sandbox error, got %q", text)
+		}
+	})
+
+	t.Run("Unexposed and unknown methods", func(t *testing.T) {
+		resp := roundTrip(5, "tools/call", map[string]interface{}{"name": "allowed_dirs_add", "arguments": map[string]interface{}{"path": outsideDir}})
+		if resp["error"] == nil {
+			t.Errorf("Expected error calling unexposed tool, got %v", resp)
+		}
+		if allowed.IsAllowed(outsideDir) {
+			t.Errorf("Unexposed tool must not run")
+		}
+		resp = roundTrip(6, "resources/list", nil)
+		if resp["error"] == nil {
+			t.Errorf("Expected method not found, got %v", resp)
+		}
+	})
+
+	clientOut.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, pluginsDir, name, manifest, script string) {
+	t.Helper()
+	dir := filepath.Join(pluginsDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create plugin dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "plugin.json"), []byte(manifest), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write plugin script: %v", err)
+	}
+}
+
+// TestPlugins_LoadAndExecute verifies manifest loading and the stdin/stdout protocol.
+func TestPlugins_LoadAndExecute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin test uses shell scripts")
+	}
+	root := t.TempDir()
+	pluginsDir := filepath.Join(root, "plugins")
+	workDir := filepath.Join(root, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("Failed to create work dir: %v", err)
+	}
+	checker := sandbox.New([]string{workDir})
+
+	writePlugin(t, pluginsDir, "echo", `{
+		"name": "echo_args",
+		"description": "Echoes its input",
+		"command": "run.sh",
+		"permission": "read",
+		"working_dir": "`+workDir+`",
+		"parameters": {"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}
+	}`, "#!/bin/sh\nprintf '{\"result\":'\ncat\nprintf '}'\n")
+
+	writePlugin(t, pluginsDir, "slow", `{
+		"name": "slow_tool",
+		"description": "Never answers in time",
+		"command": "run.sh",
+		"working_dir": "`+workDir+`",
+		"timeout_seconds": 1
+	}`, "#!/bin/sh\nsleep 5\n")
+
+	writePlugin(t, pluginsDir, "outside", `{
+		"name": "outside_tool",
+		"description": "Runs outside allowed dirs",
+		"command": "run.sh",
+		"working_dir": "`+root+`"
+	}`, "#!/bin/sh\necho '{\"result\":1}'\n")
+
+	writePlugin(t, pluginsDir, "broken", `{"name": "bad name!", "command": "run.sh"}`, "#!/bin/sh\n")
+
+	plugins, errs := tools.LoadPlugins(pluginsDir, checker)
+	if len(errs) != 1 {
+		t.Errorf("Expected 1 load error for the broken manifest, got %v", errs)
+	}
+	byName := map[string]*tools.PluginTool{}
+	for _, p := range plugins {
+		byName[p.Name()] = p
+	}
+	if len(byName) != 3 {
+		t.Fatalf("Expected 3 plugins, got %d", len(byName))
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		p := byName["echo_args"]
+		if p.Permission() != tools.PermissionRead {
+			t.Errorf("Expected read permission, got %s", p.Permission())
+		}
+		result, err := p.Execute(map[string]interface{}{"text": "hi"})
+		if err != nil {
+			t.Fatalf("Plugin call failed: %v", err)
+		}
+		req, ok := result.(map[string]interface{})
+		if !ok || req["name"] != "echo_args" {
+			t.Fatalf("Unexpected plugin result: %v", result)
+		}
+		args := req["arguments"].(map[string]interface{})
+		if args["text"] != "hi" {
+			t.Errorf("Arguments not forwarded: %v", args)
+		}
+		if _, err := p.Execute(map[string]interface{}{}); err == nil {
+			t.Error("Expected error for missing required argument")
+		}
+	})
+
+	t.Run("Default permission", func(t *testing.T) {
+		if byName["slow_tool"].Permission() != tools.PermissionWrite {
+			t.Errorf("Plugins without a permission should default to write")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := byName["slow_tool"].Execute(nil)
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("Expected timeout error, got %v", err)
+		}
+	})
+
+	t.Run("Working directory outside allowed paths", func(t *testing.T) {
+		if _, err := byName["outside_tool"].Execute(nil); err == nil {
+			t.Error("Expected error for working directory outside allowed paths")
+		}
+	})
+}
+
+// TestPlugins_PathAccess verifies path arguments and the working directory are
+// checked for the manifest's path_access, with working_dir relative to the
+// plugin directory.
+func TestPlugins_PathAccess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin test uses shell scripts")
+	}
+	root := t.TempDir()
+	pluginsDir := filepath.Join(root, "plugins")
+	workDir := filepath.Join(root, "work")
+	readOnly := filepath.Join(root, "docs")
+	dataDir := filepath.Join(pluginsDir, "relative", "data")
+	for _, d := range []string{workDir, readOnly, dataDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", d, err)
+		}
+	}
+	checker := sandbox.FromSpecs([]sandbox.Spec{
+		{Path: workDir, Mode: sandbox.ModeReadWrite},
+		{Path: readOnly, Mode: sandbox.ModeReadOnly},
+		{Path: dataDir, Mode: sandbox.ModeReadWrite},
+	})
+	pwdScript := "#!/bin/sh\nprintf '{\"result\":\"%s\"}' \"$(pwd)\"\n"
+
+	writePlugin(t, pluginsDir, "writer", `{
+		"name": "writer_tool",
+		"command": "run.sh",
+		"working_dir": "`+workDir+`",
+		"path_arguments": ["path"]
+	}`, pwdScript)
+	writePlugin(t, pluginsDir, "reader", `{
+		"name": "reader_tool",
+		"command": "run.sh",
+		"permission": "read",
+		"path_access": "read",
+		"working_dir": "`+readOnly+`",
+		"path_arguments": ["path"]
+	}`, pwdScript)
+	writePlugin(t, pluginsDir, "relative", `{
+		"name": "relative_tool",
+		"command": "run.sh",
+		"working_dir": "data"
+	}`, pwdScript)
+	writePlugin(t, pluginsDir, "echo", `{
+		"name": "echo_tool",
+		"command": "run.sh",
+		"working_dir": "`+workDir+`",
+		"path_arguments": ["path"]
+	}`, "#!/bin/sh\nprintf '{\"result\":%s}' \"$(cat)\"\n")
+	writePlugin(t, pluginsDir, "badaccess", `{
+		"name": "bad_access",
+		"command": "run.sh",
+		"path_access": "index"
+	}`, pwdScript)
+
+	plugins, errs := tools.LoadPlugins(pluginsDir, checker)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "path_access") {
+		t.Errorf("Expected a path_access load error, got %v", errs)
+	}
+	byName := map[string]*tools.PluginTool{}
+	for _, p := range plugins {
+		byName[p.Name()] = p
+	}
+
+	t.Run("Write access by default", func(t *testing.T) {
+		p := byName["writer_tool"]
+		if _, err := p.Execute(map[string]interface{}{"path": filepath.Join(workDir, "out.txt")}); err != nil {
+			t.Errorf("Expected a read-write path to be accepted: %v", err)
+		}
+		_, err := p.Execute(map[string]interface{}{"path": filepath.Join(readOnly, "a.txt")})
+		if err == nil || !strings.Contains(err.Error(), "read-only") {
+			t.Errorf("Expected a read-only path to be refused, got %v", err)
+		}
+	})
+
+	t.Run("Declared read access", func(t *testing.T) {
+		if _, err := byName["reader_tool"].Execute(map[string]interface{}{"path": filepath.Join(readOnly, "a.txt")}); err != nil {
+			t.Errorf("Expected read access to a read-only directory: %v", err)
+		}
+	})
+
+	t.Run("Working directory relative to the plugin", func(t *testing.T) {
+		result, err := byName["relative_tool"].Execute(nil)
+		if err != nil {
+			t.Fatalf("Plugin call failed: %v", err)
+		}
+		want, _ := filepath.EvalSymlinks(dataDir)
+		got, _ := filepath.EvalSymlinks(result.(string))
+		if got != want {
+			t.Errorf("Expected the plugin to run in %s, got %v", want, result)
+		}
+	})
+
+	t.Run("Relative paths from the working directory", func(t *testing.T) {
+		// The test runs in another directory than the plugin.
+		p := byName["echo_tool"]
+		result, err := p.Execute(map[string]interface{}{"path": "out.txt"})
+		if err != nil {
+			t.Fatalf("Expected a path relative to the working directory to be accepted: %v", err)
+		}
+		args, _ := result.(map[string]interface{})["arguments"].(map[string]interface{})
+		want, _ := filepath.EvalSymlinks(workDir)
+		if got := args["path"]; got != filepath.Join(want, "out.txt") {
+			t.Errorf("Expected the plugin to get %s, got %v", filepath.Join(want, "out.txt"), got)
+		}
+		_, err = p.Execute(map[string]interface{}{"path": "../docs/a.txt"})
+		if err == nil || !strings.Contains(err.Error(), "read-only") {
+			t.Errorf("Expected ../docs to resolve to the read-only directory, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"nira/memory"
+	"nira/sandbox"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSandbox_Escapes runs the same escape attempts against the sandbox,
+// the allowed directories store and a file tool using static roots.
+func TestSandbox_Escapes(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "Root")
+	outside := filepath.Join(base, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), outside, filepath.Join(outside, "deep")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(root+"-evil", 0755)
+	os.WriteFile(filepath.Join(root, "file.txt"), []byte("in"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("out"), 0644)
+
+	link := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	link(filepath.Join(outside, "secret.txt"), "secret-link")
+	link(outside, "out-dir")
+	link(filepath.Join(outside, "deep"), "deep-dir")
+	link(filepath.Join(outside, "missing.txt"), "dangling")
+	link("sub", "inner")
+	link("../outside", "relative-out")
+
+	folds := sandbox.IsCaseInsensitive(root)
+
+	cases := []struct {
+		name    string
+		path    string
+		allowed bool
+	}{
+		{"root itself", root, true},
+		{"file inside", filepath.Join(root, "file.txt"), true},
+		{"new file inside", filepath.Join(root, "sub", "new.txt"), true},
+		{"new nested path inside", filepath.Join(root, "a", "b", "c.txt"), true},
+		{"dot segments staying inside", root + "/sub/../file.txt", true},
+		{"symlink staying inside", filepath.Join(root, "inner", "x.txt"), true},
+		{"parent of root", filepath.Dir(root), false},
+		{"dot-dot escape", root + "/../outside/secret.txt", false},
+		{"deep dot-dot escape", root + "/sub/../../outside", false},
+		{"sibling with shared prefix", root + "-evil/x", false},
+		{"symlinked file pointing out", filepath.Join(root, "secret-link"), false},
+		{"symlinked dir pointing out", filepath.Join(root, "out-dir", "secret.txt"), false},
+		{"new file under escaping symlink", filepath.Join(root, "out-dir", "new.txt"), false},
+		{"relative symlink pointing out", filepath.Join(root, "relative-out", "secret.txt"), false},
+		{"dangling symlink pointing out", filepath.Join(root, "dangling"), false},
+		{"dot-dot after symlink", root + "/deep-dir/../secret.txt", false},
+		{"dot-dot from missing dir onto symlink", root + "/newdir/../out-dir/sub", false},
+		{"dot-dot from missing dir staying inside", root + "/newdir/../sub/new.txt", true},
+		{"other case", strings.Replace(root, "Root", "ROOT", 1) + "/file.txt", folds},
+		{"empty path", "", false},
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+	store, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	store.Add(root)
+
+	readTool := tools.NewFileReadTool([]string{root})
+	checkers := map[string]func(string) bool{
+		"sandbox":      sandbox.New([]string{root}).IsAllowed,
+		"allowed_dirs": store.IsAllowed,
+		"static_roots": func(path string) bool {
+			_, err := readTool.Execute(map[string]interface{}{"path": path})
+			return err == nil || !strings.Contains(err.Error(), "not in allowed directories")
+		},
+	}
+
+	for checkerName, allowed := range checkers {
+		for _, tc := range cases {
+			t.Run(checkerName+"/"+tc.name, func(t *testing.T) {
+				if got := allowed(tc.path); got != tc.allowed {
+					t.Errorf("IsAllowed(%q) = %v, want %v", tc.path, got, tc.allowed)
+				}
+			})
+		}
+	}
+}
+
+// TestSandbox_Resolve verifies that resolution follows symlinks component
+// by component instead of cleaning ".." lexically.
+func TestSandbox_Resolve(t *testing.T) {
+	base, _ := filepath.EvalSymlinks(t.TempDir())
+	os.MkdirAll(filepath.Join(base, "a", "b"), 0755)
+	if err := os.Symlink(filepath.Join(base, "a", "b"), filepath.Join(base, "l")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	got, err := sandbox.Resolve(base + "/l/../x")
+	if err != nil || got != filepath.Join(base, "a", "x") {
+		t.Errorf("Resolve = %q (%v), want %q", got, err, filepath.Join(base, "a", "x"))
+	}
+
+	os.Symlink("loop2", filepath.Join(base, "loop1"))
+	os.Symlink("loop1", filepath.Join(base, "loop2"))
+	if _, err := sandbox.Resolve(filepath.Join(base, "loop1")); err == nil {
+		t.Error("Expected error for a symlink loop")
+	}
+	if sandbox.New([]string{base}).IsAllowed(filepath.Join(base, "loop1")) {
+		t.Error("Unresolvable paths must not be allowed")
+	}
+}
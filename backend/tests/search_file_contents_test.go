@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSearchFileContents verifies matching, context lines, filters,
+// .gitignore handling, binary skipping and result caps.
+func TestSearchFileContents(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+	}
+	write(".gitignore", "build/\n*.log\n!keep.log\n")
+	write("main.go", "package main\n\nfunc main() {\n\tStartServer(8080)\n}\n")
+	write("server/server.go", "package server\n\n// StartServer listens on port.\nfunc StartServer(port int) {}\n")
+	write("README.md", "Call startserver to begin.\n")
+	write("build/out.go", "StartServer generated\n")
+	write("debug.log", "StartServer crashed\n")
+	write("keep.log", "StartServer ok\n")
+	write("blob.bin", "StartServer\x00\x01\x02")
+	write(".git/HEAD", "StartServer\n")
+	var many strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&many, "needle %d\n", i)
+	}
+	write("many.txt", many.String())
+
+	tool := tools.NewSearchFileContentsTool([]string{root})
+	search := func(args map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		args["root"] = root
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("Search %v failed: %v", args, err)
+		}
+		return result.(map[string]interface{})
+	}
+	files := func(result map[string]interface{}) []string {
+		var out []string
+		for _, m := range result["matches"].([]tools.ContentMatch) {
+			rel, _ := filepath.Rel(root, m.File)
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return out
+	}
+
+	t.Run("Literal, case-insensitive, gitignore and binary aware", func(t *testing.T) {
+		result := search(map[string]interface{}{"pattern": "startserver"})
+		got := strings.Join(files(result), ",")
+		want := "README.md,keep.log,main.go,server/server.go,server/server.go"
+		if got != want {
+			t.Errorf("Matched files = %s, want %s", got, want)
+		}
+		if result["skipped_binary"].(int64) != 1 {
+			t.Errorf("Expected one binary file skipped, got %v", result["skipped_binary"])
+		}
+	})
+
+	t.Run("Regex with include glob and context", func(t *testing.T) {
+		result := search(map[string]interface{}{"pattern": `^func \w+\(`, "regex": true, "case_sensitive": true, "include": []interface{}{"**/*.go"}, "context": 1})
+		matches := result["matches"].([]tools.ContentMatch)
+		if len(matches) != 2 {
+			t.Fatalf("Expected 2 matches, got %+v", matches)
+		}
+		m := matches[1]
+		if m.Line != 4 || m.Column != 1 || m.Before[0] != "// StartServer listens on port." || len(m.After) != 0 {
+			t.Errorf("Unexpected match: %+v", m)
+		}
+	})
+
+	t.Run("Exclude and ignore_gitignore", func(t *testing.T) {
+		result := search(map[string]interface{}{"pattern": "StartServer", "case_sensitive": true, "ignore_gitignore": true, "exclude": []interface{}{"server", "*.bin"}})
+		got := strings.Join(files(result), ",")
+		if !strings.Contains(got, "build/out.go") || !strings.Contains(got, "debug.log") || strings.Contains(got, "server/") {
+			t.Errorf("Unexpected files: %s", got)
+		}
+	})
+
+	t.Run("Caps", func(t *testing.T) {
+		result := search(map[string]interface{}{"pattern": "needle", "max_per_file": 5})
+		if n := len(result["matches"].([]tools.ContentMatch)); n != 5 || result["truncated"] != true {
+			t.Errorf("Expected 5 matches and truncation, got %d (%v)", n, result["truncated"])
+		}
+		result = search(map[string]interface{}{"pattern": "needle", "max_per_file": 0, "max_results": 10})
+		if n := len(result["matches"].([]tools.ContentMatch)); n != 10 || result["truncated"] != true {
+			t.Errorf("Expected 10 matches and truncation, got %d", n)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := tool.Execute(map[string]interface{}{"root": root, "pattern": "(", "regex": true}); err == nil {
+			t.Error("Expected error for invalid regex")
+		}
+		if _, err := tool.Execute(map[string]interface{}{"root": filepath.Dir(root), "pattern": "x"}); err == nil {
+			t.Error("Expected error for root outside allowed directories")
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"nira/memory"
+	"testing"
+)
+
+// TestToolAuditStore_RecordAndList verifies audit records are persisted and
+// filtered per conversation and tool, newest first.
+func TestToolAuditStore_RecordAndList(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := memory.NewToolAuditStore(db)
+
+	records := []memory.ToolCallRecord{
+		{ConversationID: 1, ToolName: "read_file", Arguments: `{"path":"a.txt"}`, ResultSummary: "ok", DurationMs: 3, Initiator: "model"},
+		{ConversationID: 1, ToolName: "write_file", Arguments: `{"path":"b.txt"}`, Error: "denied", Initiator: "model"},
+		{ConversationID: 2, ToolName: "read_file", Arguments: `{"path":"c.txt"}`, ResultSummary: "ok", Initiator: "user"},
+	}
+	for i := range records {
+		id, err := store.Record(&records[i])
+		if err != nil {
+			t.Fatalf("Failed to record tool call: %v", err)
+		}
+		if id == 0 || records[i].CreatedAt == "" {
+			t.Errorf("Record should assign ID and timestamp, got %+v", records[i])
+		}
+	}
+
+	t.Run("Per conversation", func(t *testing.T) {
+		got, err := store.List(1, "", 10, 0)
+		if err != nil {
+			t.Fatalf("Failed to list tool calls: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Expected 2 records for conversation 1, got %d", len(got))
+		}
+		if got[0].ToolName != "write_file" || got[0].Error != "denied" {
+			t.Errorf("Expected newest record first with error, got %+v", got[0])
+		}
+		if got[1].DurationMs != 3 || got[1].Initiator != "model" {
+			t.Errorf("Record fields not preserved: %+v", got[1])
+		}
+	})
+
+	t.Run("Tool filter and paging", func(t *testing.T) {
+		got, err := store.List(1, "read_file", 10, 0)
+		if err != nil {
+			t.Fatalf("Failed to list tool calls: %v", err)
+		}
+		if len(got) != 1 || got[0].Arguments != `{"path":"a.txt"}` {
+			t.Errorf("Unexpected filtered records: %+v", got)
+		}
+
+		page, err := store.List(1, "", 1, 1)
+		if err != nil {
+			t.Fatalf("Failed to list tool calls: %v", err)
+		}
+		if len(page) != 1 || page[0].ToolName != "read_file" {
+			t.Errorf("Unexpected page: %+v", page)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"nira/memory"
+	"nira/tools"
+	"testing"
+)
+
+// TestToolPolicy_Decisions verifies tier defaults, configured overrides and validation.
+func TestToolPolicy_Decisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	allowed, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	rpStore := memory.NewRPStore(db)
+
+	readTool := tools.NewFileReadToolWithChecker(nil, allowed)
+	writeTool := tools.NewFileWriteToolWithChecker(nil, allowed)
+	grantTool := tools.NewAllowedDirsAddTool(allowed)
+	deleteTool := tools.NewRPCharacterDeleteTool(rpStore)
+	webTool := &tools.WebSearchTool{}
+
+	t.Run("Default tiers", func(t *testing.T) {
+		p := tools.DefaultPolicy()
+		cases := []struct {
+			tool tools.Tool
+			want tools.Decision
+		}{
+			{readTool, tools.DecisionAllow},
+			{webTool, tools.DecisionAllow},
+			{writeTool, tools.DecisionAsk},
+			{grantTool, tools.DecisionAsk},
+			{deleteTool, tools.DecisionAsk},
+		}
+		for _, c := range cases {
+			if got := p.Decide(c.tool); got != c.want {
+				t.Errorf("%s (%s): expected %s, got %s", c.tool.Name(), c.tool.Permission(), c.want, got)
+			}
+		}
+	})
+
+	t.Run("Configured overrides", func(t *testing.T) {
+		p, err := tools.NewPolicy(map[string]string{
+			"network":    "deny",
+			"write_file": "allow",
+		})
+		if err != nil {
+			t.Fatalf("Failed to build policy: %v", err)
+		}
+		if p.Decide(webTool) != tools.DecisionDeny {
+			t.Errorf("Network tier should be denied")
+		}
+		if p.Decide(writeTool) != tools.DecisionAllow {
+			t.Errorf("Tool override should take precedence over tier")
+		}
+		if p.Decide(tools.NewRPCharacterSaveTool(rpStore)) != tools.DecisionAsk {
+			t.Errorf("Other write tools should keep the default")
+		}
+	})
+
+	t.Run("Update in place", func(t *testing.T) {
+		p := tools.DefaultPolicy()
+		held := p
+		next, err := tools.NewPolicy(map[string]string{"write_file": "deny"})
+		if err != nil {
+			t.Fatalf("Failed to build policy: %v", err)
+		}
+		p.Update(next)
+		if held.Decide(writeTool) != tools.DecisionDeny {
+			t.Errorf("Holders of the policy should see the update")
+		}
+		next.Overrides["write_file"] = tools.DecisionAllow
+		if held.Decide(writeTool) != tools.DecisionDeny {
+			t.Errorf("Update should copy the decisions, not share them")
+		}
+	})
+
+	t.Run("Invalid decision", func(t *testing.T) {
+		if _, err := tools.NewPolicy(map[string]string{"write": "sometimes"}); err == nil {
+			t.Error("Expected error for invalid decision")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"nira/memory"
+	"nira/tools"
+	"reflect"
+	"testing"
+)
+
+type sampleArgs struct {
+	Path      string   `json:"path" desc:"Target path" required:"true"`
+	Recursive bool     `json:"recursive"`
+	MaxItems  int      `json:"max_items" default:"25"`
+	Patterns  []string `json:"patterns"`
+	Mode      string   `json:"mode" enum:"fast,slow"`
+	internal  string
+}
+
+// TestToolSchema_Generation verifies that schemas are derived from argument structs.
+func TestToolSchema_Generation(t *testing.T) {
+	schema := tools.BuildSchema("sample", "A sample tool", sampleArgs{})
+	if schema["name"] != "sample" || schema["description"] != "A sample tool" {
+		t.Fatalf("Unexpected name/description: %v", schema)
+	}
+	params, ok := schema["parameters"].(map[string]interface{})
+	if !ok || params["type"] != "object" {
+		t.Fatalf("Parameters should be an object schema, got %v", schema["parameters"])
+	}
+	props := params["properties"].(map[string]interface{})
+	if len(props) != 5 {
+		t.Errorf("Expected 5 properties (unexported fields skipped), got %d", len(props))
+	}
+	expectTypes := map[string]string{
+		"path":      "string",
+		"recursive": "boolean",
+		"max_items": "integer",
+		"patterns":  "array",
+		"mode":      "string",
+	}
+	for name, typ := range expectTypes {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("Missing property %s", name)
+			continue
+		}
+		if prop["type"] != typ {
+			t.Errorf("Property %s: expected type %s, got %v", name, typ, prop["type"])
+		}
+	}
+	if props["path"].(map[string]interface{})["description"] != "Target path" {
+		t.Errorf("Description tag not propagated")
+	}
+	if !reflect.DeepEqual(params["required"], []string{"path"}) {
+		t.Errorf("Expected required [path], got %v", params["required"])
+	}
+}
+
+// TestToolSchema_Decode verifies decoding, defaults, coercion and validation.
+func TestToolSchema_Decode(t *testing.T) {
+	t.Run("Defaults and coercion", func(t *testing.T) {
+		var a sampleArgs
+		err := tools.DecodeArgs(map[string]interface{}{
+			"path":      "./Docs",
+			"recursive": "true",
+			"patterns":  []interface{}{"*.md", "*.txt"},
+		}, &a)
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if a.Path != "./Docs" || !a.Recursive || a.MaxItems != 25 || len(a.Patterns) != 2 {
+			t.Errorf("Unexpected decoded args: %+v", a)
+		}
+	})
+
+	t.Run("Numeric strings", func(t *testing.T) {
+		var a sampleArgs
+		if err := tools.DecodeArgs(map[string]interface{}{"path": "x", "max_items": "10"}, &a); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if a.MaxItems != 10 {
+			t.Errorf("Expected max_items 10, got %d", a.MaxItems)
+		}
+	})
+
+	t.Run("Validation errors", func(t *testing.T) {
+		cases := []map[string]interface{}{
+			{},
+			{"path": 5},
+			{"path": "x", "recursive": "maybe"},
+			{"path": "x", "mode": "medium"},
+		}
+		for _, args := range cases {
+			var a sampleArgs
+			if err := tools.DecodeArgs(args, &a); err == nil {
+				t.Errorf("Expected error for args %v", args)
+			}
+		}
+	})
+
+	t.Run("Fractional integers rejected", func(t *testing.T) {
+		var a sampleArgs
+		err := tools.DecodeArgs(map[string]interface{}{"path": "x", "max_items": 3.7}, &a)
+		if err == nil || err.Error() != "max_items argument must be an integer" {
+			t.Errorf("Expected integer error, got %v", err)
+		}
+		if err := tools.DecodeArgs(map[string]interface{}{"path": "x", "max_items": 3.0}, &a); err != nil || a.MaxItems != 3 {
+			t.Errorf("Expected 3.0 to decode as 3, got %d (%v)", a.MaxItems, err)
+		}
+	})
+}
+
+// TestToolSchema_RegisteredToolsUniform verifies every built-in tool exposes the same schema shape.
+func TestToolSchema_RegisteredToolsUniform(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	allowed, err := memory.NewAllowedDirsStore(db)
+	if err != nil {
+		t.Fatalf("Failed to create allowed dirs store: %v", err)
+	}
+	rpStore := memory.NewRPStore(db)
+	ragIndex := memory.NewRagIndex(db)
+
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewFileReadToolWithChecker(nil, allowed))
+	registry.Register(tools.NewFileWriteToolWithChecker(nil, allowed))
+	registry.Register(tools.NewListDirectoryToolWithChecker(nil, allowed))
+	registry.Register(tools.NewSearchFilesByNameToolWithChecker(nil, allowed))
+	registry.Register(tools.NewFileMetadataToolWithChecker(nil, allowed))
+	registry.Register(tools.NewAllowedDirsListTool(allowed))
+	registry.Register(tools.NewAllowedDirsAddTool(allowed))
+	registry.Register(tools.NewAllowedDirsRemoveTool(allowed))
+	registry.Register(tools.NewRagIndexFolderTool(allowed, ragIndex))
+	registry.Register(tools.NewRagSearchTool(ragIndex, allowed))
+	registry.Register(tools.NewRPCharacterListTool(rpStore))
+	registry.Register(tools.NewRPCharacterSaveTool(rpStore))
+	registry.Register(tools.NewRPStoryCardSaveTool(rpStore))
+	tools.RegisterWebSearchTool(registry.Tools)
+
+	for _, schema := range registry.ListTools() {
+		if len(schema) != 3 {
+			t.Errorf("Tool %v: expected exactly name/description/parameters, got %v", schema["name"], schema)
+		}
+		params, ok := schema["parameters"].(map[string]interface{})
+		if !ok || params["type"] != "object" {
+			t.Errorf("Tool %v: parameters must be an object schema", schema["name"])
+			continue
+		}
+		if _, ok := params["properties"].(map[string]interface{}); !ok {
+			t.Errorf("Tool %v: missing properties", schema["name"])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nira/memory"
+	"nira/tools"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+)
+
+const articlePage = `<!DOCTYPE html>
+<html><head><title>Gardening notes</title><script>var tracking = 1;</script></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="cookie-banner">We use cookies</div>
+<article>
+  <header><h1>Growing tomatoes</h1></header>
+  <p>Tomatoes need <strong>full sun</strong> and <a href="/soil">rich soil</a>.</p>
+  <h2>Steps</h2>
+  <ol><li>Start seeds indoors</li><li>Transplant after frost<ul><li>harden off first</li></ul></li></ol>
+  <pre><code class="language-sh">water --daily</code></pre>
+  <table><tr><th>Variety</th><th>Days</th></tr><tr><td>Roma</td><td>75</td></tr></table>
+</article>
+<footer>Copyright 2024</footer>
+</body></html>`
+
+// TestWebFetch verifies main-content extraction, robots.txt, size limits,
+// ETag revalidation through the SQLite cache and RAG indexing.
+func TestWebFetch(t *testing.T) {
+	var pageHits, notModified atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\nAllow: /private/ok\n\nUser-agent: otherbot\nDisallow: /\n")
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		pageHits.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, articlePage)
+	})
+	mux.HandleFunc("/private/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "private text")
+	})
+	mux.HandleFunc("/big.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, strings.Repeat("line of text\n", 10000))
+	})
+	mux.HandleFunc("/greek.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, strings.Repeat("αβγδε\n", 100))
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/secret", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	db, err := memory.NewDatabase(filepath.Join(t.TempDir(), "web.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	index := ragRecorder{}
+	fetch := tools.NewWebFetchTool(memory.NewWebCacheStore(db), index)
+
+	t.Run("Main content as markdown", func(t *testing.T) {
+		result, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/article#top", "index": true})
+		if err != nil {
+			t.Fatalf("web_fetch failed: %v", err)
+		}
+		r := result.(map[string]interface{})
+		content := r["content"].(string)
+		for _, want := range []string{
+			"# Growing tomatoes",
+			"**full sun**",
+			"[rich soil](" + srv.URL + "/soil)",
+			"## Steps",
+			"1. Start seeds indoors",
+			"2. Transplant after frost\n   - harden off first",
+			"```sh\nwater --daily\n```",
+			"| Variety | Days |\n| --- | --- |\n| Roma | 75 |",
+		} {
+			if !strings.Contains(content, want) {
+				t.Errorf("Content missing %q:\n%s", want, content)
+			}
+		}
+		for _, unwanted := range []string{"Home", "cookies", "Copyright", "tracking"} {
+			if strings.Contains(content, unwanted) {
+				t.Errorf("Content should not contain %q:\n%s", unwanted, content)
+			}
+		}
+		if r["title"] != "Gardening notes" || r["cached"] != false || r["indexed"] != true {
+			t.Errorf("Unexpected result: %v", r)
+		}
+		if !strings.Contains(index[srv.URL+"/article"], "Growing tomatoes") {
+			t.Errorf("Page was not indexed: %v", index)
+		}
+	})
+
+	t.Run("Cache and revalidation", func(t *testing.T) {
+		before := pageHits.Load()
+		result, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/article"})
+		if err != nil || result.(map[string]interface{})["cached"] != true || pageHits.Load() != before {
+			t.Fatalf("Expected a cache hit without a request: %v %v", result, err)
+		}
+
+		fetch.MaxAge = 0
+		defer func() { fetch.MaxAge = tools.NewWebFetchTool(nil, nil).MaxAge }()
+		result, err = fetch.Execute(map[string]interface{}{"url": srv.URL + "/article"})
+		if err != nil || notModified.Load() != 1 {
+			t.Fatalf("Expected a conditional request answered with 304: %v", err)
+		}
+		r := result.(map[string]interface{})
+		if r["cached"] != true || !strings.Contains(r["content"].(string), "Growing tomatoes") {
+			t.Errorf("Revalidated page should come from the cache: %v", r)
+		}
+	})
+
+	t.Run("Robots and limits", func(t *testing.T) {
+		if _, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/private/secret"}); err == nil || !strings.Contains(err.Error(), "robots.txt") {
+			t.Errorf("Expected robots.txt to disallow the page, got %v", err)
+		}
+		if _, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/private/ok"}); err != nil {
+			t.Errorf("Allow rule should win over the shorter disallow: %v", err)
+		}
+		if _, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/moved"}); err == nil || !strings.Contains(err.Error(), "robots.txt") {
+			t.Errorf("Redirect targets must be checked against robots.txt, got %v", err)
+		}
+
+		result, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/big.txt", "max_bytes": 1000, "max_chars": 200})
+		if err != nil {
+			t.Fatalf("web_fetch failed: %v", err)
+		}
+		r := result.(map[string]interface{})
+		if r["truncated"] != true || len(r["content"].(string)) > 300 || r["chars"].(int) > 1000 {
+			t.Errorf("Limits not applied: %v", r)
+		}
+
+		// max_chars and chars count characters, not bytes.
+		result, err = fetch.Execute(map[string]interface{}{"url": srv.URL + "/greek.txt", "max_chars": 60})
+		if err != nil {
+			t.Fatalf("web_fetch failed: %v", err)
+		}
+		r = result.(map[string]interface{})
+		shown, _, _ := strings.Cut(r["content"].(string), "\n\n[...")
+		if n := utf8.RuneCountInString(shown); r["truncated"] != true || n != 59 {
+			t.Errorf("Expected 59 characters (10 lines but the last newline), got %d: %q", n, shown)
+		}
+		if r["chars"] != 599 {
+			t.Errorf("Expected chars to count 599 characters (the final newline is trimmed), got %v", r["chars"])
+		}
+
+		if _, err := fetch.Execute(map[string]interface{}{"url": srv.URL + "/file.pdf"}); err == nil || !strings.Contains(err.Error(), "content type") {
+			t.Errorf("Expected an unsupported content type error, got %v", err)
+		}
+		if _, err := fetch.Execute(map[string]interface{}{"url": "file:///etc/passwd"}); err == nil {
+			t.Error("Expected error for a non-http URL")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nira/tools"
+	"strings"
+	"testing"
+)
+
+// TestWebSearchProviders verifies the SearXNG, Brave and generic JSON
+// providers, result normalization and fallback between providers.
+func TestWebSearchProviders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/searxng/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || r.URL.Query().Get("q") != "go sqlite" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"results":[
+			{"title":"mattn/go-sqlite3","url":"https://github.com/mattn/go-sqlite3","content":"sqlite3 driver for <b>go</b> &amp; database/sql","engine":"google"},
+			{"title":"duplicate","url":"https://github.com/mattn/go-sqlite3","content":"again"},
+			{"title":"no scheme","url":"javascript:alert(1)","content":"x"},
+			{"title":"  Spaced   title ","url":"https://pkg.go.dev/database/sql","content":""}]}`)
+	})
+	mux.HandleFunc("/brave", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Subscription-Token") != "key" {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"web":{"results":[{"title":"Brave hit","url":"https://example.com/brave","description":"from <strong>brave</strong>"}]}}`)
+	})
+	mux.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":{"items":[{"name":"Custom hit","link":{"href":"https://example.com/%s"},"summary":"n=%s"}]}}`,
+			r.URL.Query().Get("term"), r.URL.Query().Get("n"))
+	})
+	mux.HandleFunc("/broken/search", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/empty/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results":[]}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	build := func(cfgs ...tools.SearchProviderConfig) *tools.WebSearchTool {
+		providers, errs := tools.NewSearchProviders(cfgs, nil)
+		if len(errs) > 0 {
+			t.Fatalf("Unexpected provider errors: %v", errs)
+		}
+		return tools.NewWebSearchTool(providers)
+	}
+	search := func(tool *tools.WebSearchTool, args map[string]interface{}) []tools.WebSearchResult {
+		t.Helper()
+		result, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("web_search failed: %v", err)
+		}
+		return result.([]tools.WebSearchResult)
+	}
+	searxng := tools.SearchProviderConfig{Type: "searxng", Name: "local", URL: srv.URL + "/searxng/"}
+
+	t.Run("SearXNG normalization", func(t *testing.T) {
+		results := search(build(searxng), map[string]interface{}{"query": "go sqlite"})
+		if len(results) != 2 {
+			t.Fatalf("Expected duplicates and non-http URLs to be dropped, got %v", results)
+		}
+		if results[0].Snippet != "sqlite3 driver for go & database/sql" || results[0].Source != "local" {
+			t.Errorf("Snippet not normalized: %+v", results[0])
+		}
+		if results[1].Title != "Spaced title" {
+			t.Errorf("Title not normalized: %q", results[1].Title)
+		}
+		if results := search(build(searxng), map[string]interface{}{"query": "go sqlite", "limit": 1}); len(results) != 1 {
+			t.Errorf("limit not applied: %v", results)
+		}
+	})
+
+	t.Run("Brave and generic JSON", func(t *testing.T) {
+		results := search(build(tools.SearchProviderConfig{Type: "brave", URL: srv.URL + "/brave", APIKey: "key"}), map[string]interface{}{"query": "x"})
+		if len(results) != 1 || results[0].Snippet != "from brave" || results[0].Source != "brave" {
+			t.Errorf("Unexpected Brave results: %v", results)
+		}
+
+		custom := tools.SearchProviderConfig{Type: "json", URL: srv.URL + "/custom?term={query}&n={limit}",
+			ResultsPath: "data.items", TitleField: "name", URLField: "link.href", SnippetField: "summary"}
+		results = search(build(custom), map[string]interface{}{"query": "abc"})
+		if len(results) != 1 || results[0].URL != "https://example.com/abc" || results[0].Snippet != "n=5" {
+			t.Errorf("Unexpected JSON results: %v", results)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		broken := tools.SearchProviderConfig{Type: "searxng", Name: "broken", URL: srv.URL + "/broken"}
+		empty := tools.SearchProviderConfig{Type: "searxng", Name: "empty", URL: srv.URL + "/empty"}
+		brave := tools.SearchProviderConfig{Type: "brave", URL: srv.URL + "/brave", APIKey: "wrong"}
+
+		results := search(build(broken, empty, searxng), map[string]interface{}{"query": "go sqlite"})
+		if len(results) == 0 || results[0].Source != "local" {
+			t.Errorf("Expected results from the last provider, got %v", results)
+		}
+
+		_, err := build(broken, brave).Execute(map[string]interface{}{"query": "go sqlite"})
+		if err == nil || !strings.Contains(err.Error(), "broken: HTTP 503") || !strings.Contains(err.Error(), "brave: HTTP 401") {
+			t.Errorf("Expected every provider's error, got %v", err)
+		}
+		_, err = build(empty).Execute(map[string]interface{}{"query": "go sqlite"})
+		if err == nil || !strings.Contains(err.Error(), "no results") {
+			t.Errorf("Expected a no results error, got %v", err)
+		}
+	})
+
+	t.Run("Config validation", func(t *testing.T) {
+		providers, errs := tools.NewSearchProviders([]tools.SearchProviderConfig{
+			{Type: "searxng"},
+			{Type: "brave"},
+			{Type: "json", URL: "https://example.com/search"},
+			{Type: "bing"},
+			{Type: "duckduckgo"},
+		}, nil)
+		if len(errs) != 4 || len(providers) != 1 || providers[0].Name() != "duckduckgo" {
+			t.Errorf("Unexpected validation: %v %v", providers, errs)
+		}
+	})
+}
@@ -14,62 +14,172 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"nira/memory"
 	"nira/tools"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type ToolHandler struct {
 	Registry *tools.Registry
 	Logger   *Logger
+	Policy   *tools.Policy
+	// Memory, when set, receives an audit record for every tool call.
+	Memory *memory.Manager
+	// Workers bounds how many independent tool calls run at once.
+	Workers int
+	// PreviewChanges makes every previewable file change wait for the user's
+	// approval of its diff, even when the policy would allow the tool.
+	PreviewChanges bool
+	// AutoApplyLines lets previewed changes of at most this many changed
+	// lines run without asking; 0 always asks.
+	AutoApplyLines int
 }
 
-func NewToolHandler(registry *tools.Registry, logger *Logger) *ToolHandler {
+// ToolOutcome is the result of one call from a model turn.
+type ToolOutcome struct {
+	Call   *tools.Call
+	Result interface{}
+	Err    error
+}
+
+// Who asked for a tool call, as stored in the audit log.
+const (
+	InitiatorUser  = "user"
+	InitiatorModel = "model"
+	InitiatorMCP   = "mcp"
+)
+
+// maxAuditSummary bounds the result text kept per audit record.
+const maxAuditSummary = 500
+
+// ErrToolDenied is returned by Authorize when a call may not run.
+var ErrToolDenied = errors.New("tool call denied")
+
+func NewToolHandler(registry *tools.Registry, logger *Logger, policy *tools.Policy) *ToolHandler {
+	if policy == nil {
+		policy = tools.DefaultPolicy()
+	}
 	return &ToolHandler{
 		Registry: registry,
 		Logger:   logger,
+		Policy:   policy,
+		Workers:  4,
 	}
 }
 
+var (
+	xmlToolCallPattern    = regexp.MustCompile(`(?s)<tool_call[^>]*>(.*?)</tool_call>`)
+	simpleToolCallPattern = regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
+)
+
+// DetectToolCall returns the first tool call in a model reply.
 func (th *ToolHandler) DetectToolCall(content string) (*tools.Call, bool) {
-	// Look for JSON tool call patterns in the response
-	// Common patterns: {"tool": "...", "args": {...}} or <tool_call>...</tool_call>
+	calls := th.DetectToolCalls(content)
+	if len(calls) == 0 {
+		return nil, false
+	}
+	return calls[0], true
+}
 
-	// Pattern 1: JSON object with tool/function fields
-	jsonPattern := regexp.MustCompile(`\{[^{}]*"(?:tool|function|name)"[^{}]*\}`)
-	matches := jsonPattern.FindString(content)
-	if matches != "" {
-		var call tools.Call
-		if err := json.Unmarshal([]byte(matches), &call); err == nil && call.Name != "" {
-			return &call, true
-		}
+// DetectToolCalls extracts every tool call in a model reply, in the order
+// they appear. Recognized forms:
+//   - JSON objects {"name": "...", "arguments": {...}} (also "tool"/"args"),
+//     including nested argument objects
+//   - <tool_call>{...}</tool_call> tags
+//   - tool_name(arg1="value1", arg2="value2"), only for registered tools and
+//     only when no JSON/XML calls are present
+func (th *ToolHandler) DetectToolCalls(content string) []*tools.Call {
+	type found struct {
+		pos  int
+		call *tools.Call
 	}
+	var hits []found
 
-	// Pattern 2: XML-like tool call tags
-	xmlPattern := regexp.MustCompile(`<tool_call[^>]*>([^<]+)</tool_call>`)
-	xmlMatches := xmlPattern.FindStringSubmatch(content)
-	if len(xmlMatches) > 1 {
-		var call tools.Call
-		if err := json.Unmarshal([]byte(xmlMatches[1]), &call); err == nil && call.Name != "" {
-			return &call, true
+	// Pattern 1: XML-like tool call tags; their spans are masked so the JSON
+	// scan below does not pick up the same call twice.
+	masked := []byte(content)
+	for _, loc := range xmlToolCallPattern.FindAllStringSubmatchIndex(content, -1) {
+		if call, ok := parseCallObject([]byte(content[loc[2]:loc[3]])); ok {
+			hits = append(hits, found{loc[0], call})
+		}
+		for i := loc[0]; i < loc[1]; i++ {
+			masked[i] = ' '
 		}
 	}
 
-	// Pattern 3: Simple function call format: tool_name(arg1="value1", arg2="value2")
-	simplePattern := regexp.MustCompile(`(\w+)\s*\(([^)]*)\)`)
-	simpleMatches := simplePattern.FindStringSubmatch(content)
-	if len(simpleMatches) > 1 {
-		call := &tools.Call{
-			Name:      simpleMatches[1],
-			Arguments: th.parseSimpleArgs(simpleMatches[2]),
+	// Pattern 2: balanced JSON objects carrying a tool name
+	text := string(masked)
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			continue
+		}
+		if call, ok := parseCallObject(raw); ok {
+			hits = append(hits, found{i, call})
 		}
-		if call.Name != "" {
-			return call, true
+		// Skip the whole object so nested argument objects are not rescanned.
+		i += int(dec.InputOffset()) - 1
+	}
+
+	// Pattern 3: Simple function call format, restricted to known tools since
+	// ordinary prose often contains parentheses.
+	if len(hits) == 0 {
+		for _, m := range simpleToolCallPattern.FindAllStringSubmatchIndex(content, -1) {
+			name := content[m[2]:m[3]]
+			if _, exists := th.Registry.Get(name); !exists {
+				continue
+			}
+			hits = append(hits, found{m[0], &tools.Call{
+				Name:      name,
+				Arguments: th.parseSimpleArgs(content[m[4]:m[5]]),
+			}})
 		}
 	}
 
-	return nil, false
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].pos < hits[b].pos })
+	calls := make([]*tools.Call, 0, len(hits))
+	for _, h := range hits {
+		calls = append(calls, h.call)
+	}
+	return calls
+}
+
+// parseCallObject interprets a JSON object as a tool call if it names a tool.
+func parseCallObject(data []byte) (*tools.Call, bool) {
+	var obj struct {
+		Name      string                 `json:"name"`
+		Tool      string                 `json:"tool"`
+		Arguments map[string]interface{} `json:"arguments"`
+		Args      map[string]interface{} `json:"args"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, false
+	}
+	call := &tools.Call{Name: obj.Name, Arguments: obj.Arguments}
+	if call.Name == "" {
+		call.Name = obj.Tool
+	}
+	if call.Arguments == nil {
+		call.Arguments = obj.Args
+	}
+	if call.Name == "" {
+		return nil, false
+	}
+	if call.Arguments == nil {
+		call.Arguments = map[string]interface{}{}
+	}
+	return call, true
 }
 
 func (th *ToolHandler) parseSimpleArgs(argsStr string) map[string]interface{} {
@@ -87,22 +197,249 @@ func (th *ToolHandler) parseSimpleArgs(argsStr string) map[string]interface{} {
 	return args
 }
 
-func (th *ToolHandler) ExecuteTool(call *tools.Call) (interface{}, error) {
+// Authorize applies the permission policy to a model-initiated call. When the
+// policy says ask, confirm is called and must return true for the call to run.
+// Tools that can preview their file changes hand confirm the diffs; with
+// PreviewChanges set those changes are always confirmed unless they are within
+// AutoApplyLines. An approved preview pins the call to the previewed content.
+func (th *ToolHandler) Authorize(call *tools.Call, confirm func(tool tools.Tool, previews []tools.FilePreview) bool) error {
+	tool, exists := th.Registry.Get(call.Name)
+	if !exists {
+		return fmt.Errorf("tool '%s' not found", call.Name)
+	}
+
+	decision := th.Policy.Decide(tool)
+	var previews []tools.FilePreview
+	if decision != tools.DecisionDeny {
+		previews = th.preview(tool, call)
+	}
+	if len(previews) > 0 && th.PreviewChanges {
+		if th.autoApplies(previews) {
+			th.Logger.Info("Auto-applying small change from %s", call.Name)
+			decision = tools.DecisionAllow
+		} else {
+			decision = tools.DecisionAsk
+		}
+	}
+
+	switch decision {
+	case tools.DecisionAllow:
+		pinPreviews(call, previews)
+		return nil
+	case tools.DecisionAsk:
+		th.Logger.Info("Tool %s (%s) requires confirmation", call.Name, tool.Permission())
+		if confirm != nil && confirm(tool, previews) {
+			pinPreviews(call, previews)
+			return nil
+		}
+		err := fmt.Errorf("%w: the user declined %s", ErrToolDenied, call.Name)
+		th.record(call, InitiatorModel, nil, err, 0)
+		return err
+	default:
+		err := fmt.Errorf("%w: %s tools are disabled by policy", ErrToolDenied, tool.Permission())
+		th.record(call, InitiatorModel, nil, err, 0)
+		return err
+	}
+}
+
+// preview asks a previewable tool for its file changes. Failures are left for
+// the actual call to report.
+func (th *ToolHandler) preview(tool tools.Tool, call *tools.Call) []tools.FilePreview {
+	previewer, ok := tool.(tools.Previewer)
+	if !ok {
+		return nil
+	}
+	previews, err := previewer.Preview(call.Arguments)
+	if err != nil {
+		th.Logger.Debug("No preview for %s: %v", call.Name, err)
+		return nil
+	}
+	return previews
+}
+
+func (th *ToolHandler) autoApplies(previews []tools.FilePreview) bool {
+	if th.AutoApplyLines <= 0 {
+		return false
+	}
+	lines := 0
+	for _, p := range previews {
+		// Deletions, omitted diffs and structural changes always ask.
+		if p.Action == "delete" || p.Omitted != "" || p.Summary != "" {
+			return false
+		}
+		lines += p.Lines()
+	}
+	return lines <= th.AutoApplyLines
+}
+
+// pinPreviews records the content hashes the previews were computed from, so
+// the tool refuses to write if a file changed after approval.
+func pinPreviews(call *tools.Call, previews []tools.FilePreview) {
+	if len(previews) == 0 {
+		return
+	}
+	bases := make(map[string]interface{}, len(previews))
+	for _, p := range previews {
+		bases[p.Path] = p.BaseSHA256
+	}
+	if call.Arguments == nil {
+		call.Arguments = map[string]interface{}{}
+	}
+	call.Arguments[tools.PreviewBaseArg] = bases
+}
+
+func (th *ToolHandler) ExecuteTool(call *tools.Call, initiator string) (interface{}, error) {
 	tool, exists := th.Registry.Get(call.Name)
 	if !exists {
 		return nil, fmt.Errorf("tool '%s' not found", call.Name)
 	}
 
+	result, err := th.Invoke(tool, call, initiator)
+	if err != nil {
+		return nil, fmt.Errorf("tool execution failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// ExecuteCalls authorizes and runs the calls from one model turn, returning
+// outcomes in the same order as calls. Calls are authorized in order, so
+// confirmation prompts reach the user one at a time. Consecutive read and
+// network calls run concurrently (at most Workers at once); calls that
+// change state run alone, preserving the model's ordering of side effects.
+// Such a call is authorized only once the calls before it have finished, so
+// its preview shows, and pins, the content it will actually change.
+func (th *ToolHandler) ExecuteCalls(calls []*tools.Call, initiator string, confirm func(call *tools.Call, tool tools.Tool, previews []tools.FilePreview) bool) []ToolOutcome {
+	outcomes := make([]ToolOutcome, len(calls))
+	workers := th.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		outcomes[i].Call = call
+		tool, exists := th.Registry.Get(call.Name)
+		barrier := exists && !isIndependent(tool)
+		if barrier {
+			// Finish everything before this call, preview and run it, then continue.
+			wg.Wait()
+		}
+		err := th.Authorize(call, func(tool tools.Tool, previews []tools.FilePreview) bool {
+			return confirm != nil && confirm(call, tool, previews)
+		})
+		if err != nil {
+			outcomes[i].Err = err
+			continue
+		}
+		if barrier {
+			outcomes[i].Result, outcomes[i].Err = th.ExecuteTool(call, initiator)
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call *tools.Call) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			outcomes[i].Result, outcomes[i].Err = th.ExecuteTool(call, initiator)
+		}(i, call)
+	}
+	wg.Wait()
+
+	return outcomes
+}
+
+// isIndependent reports whether a tool can safely run alongside others.
+func isIndependent(tool tools.Tool) bool {
+	switch tool.Permission() {
+	case tools.PermissionRead, tools.PermissionNetwork:
+		return true
+	}
+	return false
+}
+
+// FormatOutcomes renders the results of a turn for injection into the
+// conversation, one block per call in order.
+func (th *ToolHandler) FormatOutcomes(outcomes []ToolOutcome) string {
+	parts := make([]string, 0, len(outcomes))
+	for _, o := range outcomes {
+		switch {
+		case errors.Is(o.Err, ErrToolDenied):
+			// Let the model know so it can answer without the tool instead of retrying.
+			parts = append(parts, fmt.Sprintf("Tool %s was not executed: %v. Do not retry it; continue without it.", o.Call.Name, o.Err))
+		case o.Err != nil:
+			parts = append(parts, fmt.Sprintf("Tool %s failed: %v", o.Call.Name, o.Err))
+		default:
+			parts = append(parts, th.FormatToolResult(o.Call.Name, o.Result))
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Invoke runs an already resolved tool, logging and auditing the call. Errors
+// from the tool are returned unwrapped.
+func (th *ToolHandler) Invoke(tool tools.Tool, call *tools.Call, initiator string) (interface{}, error) {
 	th.Logger.LogToolCall(call.Name, call.Arguments)
 
+	start := time.Now()
 	result, err := tool.Execute(call.Arguments)
+	duration := time.Since(start)
 	th.Logger.LogToolResult(call.Name, result, err)
+	th.record(call, initiator, result, err, duration)
 
-	if err != nil {
-		return nil, fmt.Errorf("tool execution failed: %w", err)
+	return result, err
+}
+
+// record writes an audit entry; failures are logged and never block the call.
+func (th *ToolHandler) record(call *tools.Call, initiator string, result interface{}, callErr error, duration time.Duration) {
+	if th.Memory == nil || th.Memory.ToolAudit == nil {
+		return
 	}
 
-	return result, nil
+	// Transport flags such as _silent are not part of the call itself.
+	args := make(map[string]interface{}, len(call.Arguments))
+	for k, v := range call.Arguments {
+		if !strings.HasPrefix(k, "_") {
+			args[k] = v
+		}
+	}
+	argsJSON, _ := json.Marshal(args)
+
+	rec := &memory.ToolCallRecord{
+		ConversationID: th.Memory.CurrentConvID,
+		ToolName:       call.Name,
+		Arguments:      string(argsJSON),
+		DurationMs:     duration.Milliseconds(),
+		Initiator:      initiator,
+	}
+	if callErr != nil {
+		rec.Error = callErr.Error()
+	} else {
+		rec.ResultSummary = summarizeResult(result)
+	}
+	if _, err := th.Memory.ToolAudit.Record(rec); err != nil {
+		th.Logger.Warn("Failed to record tool call %s: %v", call.Name, err)
+	}
+}
+
+func summarizeResult(result interface{}) string {
+	var text string
+	if s, ok := result.(string); ok {
+		text = s
+	} else if b, err := json.Marshal(result); err == nil {
+		text = string(b)
+	} else {
+		text = fmt.Sprintf("%v", result)
+	}
+	if len(text) > maxAuditSummary {
+		// Back up to a character boundary so the record stays valid UTF-8.
+		n := maxAuditSummary
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n] + "..."
+	}
+	return text
 }
 
 func (th *ToolHandler) FormatToolResult(toolName string, result interface{}) string {
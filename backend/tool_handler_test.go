@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"nira/tools"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// fakeTool records when each call starts and ends in a shared event log.
+type fakeTool struct {
+	name string
+	perm tools.Permission
+	log  *eventLog
+}
+
+func (t *fakeTool) Name() string                   { return t.name }
+func (t *fakeTool) Description() string            { return "test tool" }
+func (t *fakeTool) Schema() map[string]interface{} { return map[string]interface{}{"name": t.name} }
+func (t *fakeTool) Permission() tools.Permission   { return t.perm }
+func (t *fakeTool) Execute(args map[string]interface{}) (interface{}, error) {
+	id := fmt.Sprint(args["id"])
+	t.log.add("start " + id)
+	time.Sleep(20 * time.Millisecond)
+	t.log.add("end " + id)
+	return "result " + id, nil
+}
+
+type eventLog struct {
+	mu      sync.Mutex
+	events  []string
+	running int
+	peak    int
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	if event[:5] == "start" {
+		l.running++
+		if l.running > l.peak {
+			l.peak = l.running
+		}
+	} else {
+		l.running--
+	}
+}
+
+func (l *eventLog) index(event string) int {
+	for i, e := range l.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func newTestToolHandler() (*ToolHandler, *eventLog) {
+	log := &eventLog{}
+	registry := tools.NewRegistry()
+	registry.Register(&fakeTool{name: "read_file", perm: tools.PermissionRead, log: log})
+	registry.Register(&fakeTool{name: "web_search", perm: tools.PermissionNetwork, log: log})
+	registry.Register(&fakeTool{name: "write_file", perm: tools.PermissionWrite, log: log})
+	policy, err := tools.NewPolicy(map[string]string{"write": "allow"})
+	if err != nil {
+		panic(err)
+	}
+	return NewToolHandler(registry, NewLogger(LogLevelError), policy), log
+}
+
+func callNames(calls []*tools.Call) []string {
+	names := make([]string, len(calls))
+	for i, c := range calls {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// TestToolHandler_DetectToolCalls covers the reply formats the model uses.
+func TestToolHandler_DetectToolCalls(t *testing.T) {
+	th, _ := newTestToolHandler()
+
+	t.Run("Several calls in order", func(t *testing.T) {
+		reply := `First {"name": "read_file", "arguments": {"path": "a.txt"}} then
+<tool_call>{"tool": "web_search", "args": {"query": "go"}}</tool_call> and
+{"name": "write_file", "arguments": {"path": "b.txt", "content": "x"}}`
+		calls := th.DetectToolCalls(reply)
+		if got, want := callNames(calls), []string{"read_file", "web_search", "write_file"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+		if calls[1].Arguments["query"] != "go" {
+			t.Errorf("Expected args from the tool/args form, got %v", calls[1].Arguments)
+		}
+	})
+
+	t.Run("XML call not also detected as JSON", func(t *testing.T) {
+		calls := th.DetectToolCalls(`<tool_call>{"name": "read_file", "arguments": {"path": "a.txt"}}</tool_call>`)
+		if len(calls) != 1 || calls[0].Name != "read_file" {
+			t.Fatalf("Expected one read_file call, got %v", callNames(calls))
+		}
+	})
+
+	t.Run("Nested JSON arguments", func(t *testing.T) {
+		calls := th.DetectToolCalls(`{"name": "write_file", "arguments": {"path": "c.json", "options": {"name": "inner", "arguments": {}}}}`)
+		if len(calls) != 1 {
+			t.Fatalf("Expected the nested object to stay inside the call, got %v", callNames(calls))
+		}
+		options, ok := calls[0].Arguments["options"].(map[string]interface{})
+		if !ok || options["name"] != "inner" {
+			t.Errorf("Expected nested options to be kept, got %v", calls[0].Arguments)
+		}
+	})
+
+	t.Run("Simple form only for registered tools", func(t *testing.T) {
+		calls := th.DetectToolCalls(`I would call print(x) or summarize(text="hi"), but read_file(path="notes.md") is the one.`)
+		if len(calls) != 1 || calls[0].Name != "read_file" || calls[0].Arguments["path"] != "notes.md" {
+			t.Fatalf("Expected only read_file(path=notes.md), got %v", calls)
+		}
+		if calls := th.DetectToolCalls("The formula f(x) = max(a, b) has no tools in it."); len(calls) != 0 {
+			t.Errorf("Expected no calls from prose, got %v", callNames(calls))
+		}
+	})
+}
+
+// TestToolHandler_ExecuteCalls verifies result order and write barriers.
+func TestToolHandler_ExecuteCalls(t *testing.T) {
+	th, log := newTestToolHandler()
+	calls := []*tools.Call{
+		{Name: "read_file", Arguments: map[string]interface{}{"id": "r1"}},
+		{Name: "web_search", Arguments: map[string]interface{}{"id": "r2"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"id": "w"}},
+		{Name: "read_file", Arguments: map[string]interface{}{"id": "r3"}},
+		{Name: "missing_tool", Arguments: map[string]interface{}{"id": "m"}},
+		{Name: "read_file", Arguments: map[string]interface{}{"id": "r4"}},
+	}
+	outcomes := th.ExecuteCalls(calls, InitiatorModel, nil)
+
+	if len(outcomes) != len(calls) {
+		t.Fatalf("Expected %d outcomes, got %d", len(calls), len(outcomes))
+	}
+	for i, o := range outcomes {
+		if o.Call != calls[i] {
+			t.Errorf("Outcome %d belongs to %s", i, o.Call.Name)
+		}
+		if i == 4 {
+			if o.Err == nil {
+				t.Errorf("Expected an error for the unknown tool")
+			}
+			continue
+		}
+		if want := "result " + calls[i].Arguments["id"].(string); o.Err != nil || o.Result != want {
+			t.Errorf("Outcome %d: expected %q, got %v (%v)", i, want, o.Result, o.Err)
+		}
+	}
+
+	if log.peak < 2 {
+		t.Errorf("Expected independent reads to overlap, peak concurrency was %d", log.peak)
+	}
+	start, end := log.index("start w"), log.index("end w")
+	for _, id := range []string{"r1", "r2"} {
+		if log.index("end "+id) > start {
+			t.Errorf("Write started before %s finished: %v", id, log.events)
+		}
+	}
+	for _, id := range []string{"r3", "r4"} {
+		if log.index("start "+id) < end {
+			t.Errorf("%s started before the write finished: %v", id, log.events)
+		}
+	}
+}
+
+// TestToolHandler_EditsSameFileTwice verifies that a second edit of a file in
+// the same turn is previewed and pinned against the first edit's result.
+func TestToolHandler_EditsSameFileTwice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry := tools.NewRegistry()
+	registry.Register(tools.NewFileEditTool([]string{dir}))
+	policy, err := tools.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := NewToolHandler(registry, NewLogger(LogLevelError), policy)
+	th.PreviewChanges = true
+
+	edit := func(search, replace string) *tools.Call {
+		return &tools.Call{Name: "edit_file", Arguments: map[string]interface{}{
+			"path":  path,
+			"edits": []interface{}{map[string]interface{}{"search": search, "replace": replace}},
+		}}
+	}
+	var diffs []string
+	outcomes := th.ExecuteCalls([]*tools.Call{edit("one", "1"), edit("two", "2")}, InitiatorModel,
+		func(call *tools.Call, tool tools.Tool, previews []tools.FilePreview) bool {
+			for _, p := range previews {
+				diffs = append(diffs, p.Diff)
+			}
+			return true
+		})
+
+	for i, o := range outcomes {
+		if o.Err != nil {
+			t.Errorf("Edit %d failed: %v", i+1, o.Err)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "1\n2\n" {
+		t.Errorf("Expected both edits to apply, got %q", data)
+	}
+	if len(diffs) != 2 || !strings.Contains(diffs[1], " 1\n-two\n+2") {
+		t.Errorf("Expected the second preview to start from the first edit, got %q", diffs)
+	}
+}
+
+// TestSummarizeResult_UTF8 verifies that long results are cut between characters.
+func TestSummarizeResult_UTF8(t *testing.T) {
+	prefix := strings.Repeat("a", maxAuditSummary-1)
+	got := summarizeResult(prefix + "é and more")
+	if !utf8.ValidString(got) {
+		t.Fatalf("Expected valid UTF-8, got %q", got[len(got)-8:])
+	}
+	if want := prefix + "..."; got != want {
+		t.Errorf("Expected the cut before é, got %q", got[len(got)-8:])
+	}
+	if got := summarizeResult("short é"); got != "short é" {
+		t.Errorf("Expected short results unchanged, got %q", got)
+	}
+}
@@ -2,34 +2,74 @@ package tools
 
 import (
     "fmt"
+    "nira/memory"
+    "nira/sandbox"
     "os"
+    "path"
+    "time"
 )
 
 // AllowedDirsProvider provides access to allowed directories list management.
 type AllowedDirsProvider interface {
-    List() []string
-    Add(path string) error
+    Entries() []memory.AllowedDir
+    Set(path string, grant memory.DirGrant) error
     Remove(path string) error
 }
 
+// allowedDirArgs is used by allowed_dirs_remove.
+type allowedDirArgs struct {
+    Path string `json:"path" desc:"Directory path" required:"true"`
+}
+
+type allowedDirAddArgs struct {
+    Path string   `json:"path" desc:"Directory path" required:"true"`
+    Mode string   `json:"mode" desc:"Access mode: read_write, read_only (no writes) or index_only (only rag_index_folder may read it)" enum:"read_write,read_only,index_only" default:"read_write"`
+    Deny []string `json:"deny" desc:"Glob patterns relative to the directory that stay inaccessible, e.g. .git/** or *.env"`
+    ExpiresIn string `json:"expires_in" desc:"Revoke the grant after this long, e.g. 30m or 2h (default: never)"`
+    Scope     string `json:"scope" desc:"global, or conversation to revoke the grant when another conversation starts" enum:"global,conversation" default:"global"`
+}
+
+// allowedEntries renders the allowed directories with their scope and
+// remaining lifetime.
+func allowedEntries(store AllowedDirsProvider) []map[string]interface{} {
+    now := time.Now()
+    entries := store.Entries()
+    list := make([]map[string]interface{}, 0, len(entries))
+    for _, d := range entries {
+        entry := map[string]interface{}{
+            "path":     d.Path,
+            "mode":     d.Mode,
+            "added_at": d.AddedAt,
+            "scope":    "global",
+        }
+        if len(d.Deny) > 0 {
+            entry["deny"] = d.Deny
+        }
+        if d.ConversationID != 0 {
+            entry["scope"] = "conversation"
+            entry["conversation_id"] = d.ConversationID
+        }
+        if d.ExpiresAt != "" {
+            entry["expires_at"] = d.ExpiresAt
+            entry["expires_in"] = d.Remaining(now).Round(time.Second).String()
+        }
+        list = append(list, entry)
+    }
+    return list
+}
+
 // allowed_dirs_list
 type AllowedDirsListTool struct{ store AllowedDirsProvider }
 
 func NewAllowedDirsListTool(store AllowedDirsProvider) *AllowedDirsListTool { return &AllowedDirsListTool{store: store} }
 func (t *AllowedDirsListTool) Name() string        { return "allowed_dirs_list" }
-func (t *AllowedDirsListTool) Description() string { return "Lists directories NIRA is allowed to access. Args: none." }
+func (t *AllowedDirsListTool) Permission() Permission { return PermissionRead }
+func (t *AllowedDirsListTool) Description() string { return "Lists directories NIRA is allowed to access with their access mode (read_write, read_only, index_only), deny patterns, scope (global or conversation) and, for temporary grants, the remaining lifetime (expires_in). Args: none." }
 func (t *AllowedDirsListTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type":       "object",
-            "properties": map[string]interface{}{},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), NoArgs{})
 }
 func (t *AllowedDirsListTool) Execute(args map[string]interface{}) (interface{}, error) {
-    return map[string]interface{}{"allowed": t.store.List()}, nil
+    return map[string]interface{}{"allowed": allowedEntries(t.store)}, nil
 }
 
 // allowed_dirs_add
@@ -37,34 +77,51 @@ type AllowedDirsAddTool struct{ store AllowedDirsProvider }
 
 func NewAllowedDirsAddTool(store AllowedDirsProvider) *AllowedDirsAddTool { return &AllowedDirsAddTool{store: store} }
 func (t *AllowedDirsAddTool) Name() string        { return "allowed_dirs_add" }
-func (t *AllowedDirsAddTool) Description() string { return "Adds a directory to the allowed list. Args: path (string)." }
+func (t *AllowedDirsAddTool) Permission() Permission { return PermissionGrant }
+func (t *AllowedDirsAddTool) Description() string { return "Adds a directory to the allowed list, or replaces the grant of one already allowed. Prefer a temporary or conversation-scoped grant unless the user wants permanent access. Args: path (string), mode (read_write|read_only|index_only), deny ([string], optional), expires_in (duration like 30m or 2h, optional), scope (global|conversation)." }
 func (t *AllowedDirsAddTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "path": map[string]interface{}{"type": "string", "description": "Directory path to allow"},
-            },
-            "required": []string{"path"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), allowedDirAddArgs{})
 }
 func (t *AllowedDirsAddTool) Execute(args map[string]interface{}) (interface{}, error) {
-    p, ok := args["path"].(string)
-    if !ok || p == "" {
-        return nil, fmt.Errorf("path argument is required and must be a string")
+    var a allowedDirAddArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
+    }
+    p := a.Path
+    if p == "" {
+        return nil, fmt.Errorf("path argument must not be empty")
     }
     // Simple existence check
     info, err := os.Stat(p)
     if err != nil || !info.IsDir() {
         return nil, fmt.Errorf("path must be an existing directory")
     }
-    if err := t.store.Add(p); err != nil {
+    mode, err := sandbox.ParseMode(a.Mode)
+    if err != nil {
         return nil, err
     }
-    return map[string]interface{}{"allowed": t.store.List()}, nil
+    var deny []string
+    for _, pattern := range a.Deny {
+        if pattern == "" {
+            continue
+        }
+        if _, err := path.Match(pattern, ""); err != nil {
+            return nil, fmt.Errorf("invalid deny pattern %q: %w", pattern, err)
+        }
+        deny = append(deny, pattern)
+    }
+    grant := memory.DirGrant{Mode: mode, Deny: deny, Conversation: a.Scope == "conversation"}
+    if a.ExpiresIn != "" {
+        ttl, err := time.ParseDuration(a.ExpiresIn)
+        if err != nil || ttl <= 0 {
+            return nil, fmt.Errorf("expires_in must be a positive duration such as 30m or 2h")
+        }
+        grant.TTL = ttl
+    }
+    if err := t.store.Set(p, grant); err != nil {
+        return nil, err
+    }
+    return map[string]interface{}{"allowed": allowedEntries(t.store)}, nil
 }
 
 // allowed_dirs_remove
@@ -72,27 +129,22 @@ type AllowedDirsRemoveTool struct{ store AllowedDirsProvider }
 
 func NewAllowedDirsRemoveTool(store AllowedDirsProvider) *AllowedDirsRemoveTool { return &AllowedDirsRemoveTool{store: store} }
 func (t *AllowedDirsRemoveTool) Name() string        { return "allowed_dirs_remove" }
+func (t *AllowedDirsRemoveTool) Permission() Permission { return PermissionGrant }
 func (t *AllowedDirsRemoveTool) Description() string { return "Removes a directory from the allowed list. Args: path (string)." }
 func (t *AllowedDirsRemoveTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "path": map[string]interface{}{"type": "string", "description": "Directory path to remove"},
-            },
-            "required": []string{"path"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), allowedDirArgs{})
 }
 func (t *AllowedDirsRemoveTool) Execute(args map[string]interface{}) (interface{}, error) {
-    p, ok := args["path"].(string)
-    if !ok || p == "" {
-        return nil, fmt.Errorf("path argument is required and must be a string")
+    var a allowedDirArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
+    }
+    p := a.Path
+    if p == "" {
+        return nil, fmt.Errorf("path argument must not be empty")
     }
     if err := t.store.Remove(p); err != nil {
         return nil, err
     }
-    return map[string]interface{}{"allowed": t.store.List()}, nil
+    return map[string]interface{}{"allowed": allowedEntries(t.store)}, nil
 }
@@ -0,0 +1,224 @@
+/**
+ * Archive reading module.
+ *
+ * Detects zip, tar, tar.gz and tar.zst archives from their leading bytes
+ * and walks their members through one callback, so the archive tools do
+ * not care which format they are reading. Member names are sanitized
+ * before they are ever joined onto a destination directory.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: archive.go
+ * Description: Format detection, member iteration and zip-slip checks.
+ */
+
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	archiveZip    = "zip"
+	archiveTar    = "tar"
+	archiveTarGz  = "tar.gz"
+	archiveTarZst = "tar.zst"
+)
+
+// maxZstdWindow bounds the memory a zstd stream may ask the decoder for.
+const maxZstdWindow = 64 << 20
+
+// errStopArchive ends an archive walk early without reporting an error.
+var errStopArchive = errors.New("stop archive walk")
+
+// archiveMember describes one entry of an archive.
+type archiveMember struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	// Type is file, dir, symlink, hardlink or other.
+	Type string
+	Link string
+}
+
+// detectArchive returns the format of the archive at path from its first
+// bytes, falling back to the extension for old tar files without the
+// ustar magic.
+func detectArchive(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return archiveZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return archiveTarGz, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return archiveTarZst, nil
+	case n >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar, nil
+	case strings.HasSuffix(strings.ToLower(p), ".tar"):
+		return archiveTar, nil
+	}
+	return "", fmt.Errorf("'%s' is not a zip, tar, tar.gz or tar.zst archive", p)
+}
+
+// walkArchive calls fn for each member of the archive at p, in archive
+// order. open returns the member's content and is only valid during the
+// call. fn may return errStopArchive to stop early.
+func walkArchive(p string, fn func(m archiveMember, open func() (io.ReadCloser, error)) error) (string, error) {
+	format, err := detectArchive(p)
+	if err != nil {
+		return "", err
+	}
+	if format == archiveZip {
+		return format, walkZip(p, fn)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return format, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch format {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return format, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	case archiveTarZst:
+		zr, err := zstd.NewReader(f, zstd.WithDecoderMaxWindow(maxZstdWindow), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return format, fmt.Errorf("invalid zstd stream: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return format, walkTar(r, fn)
+}
+
+func walkZip(p string, fn func(archiveMember, func() (io.ReadCloser, error)) error) error {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		m := archiveMember{Name: f.Name, Size: int64(f.UncompressedSize64), Mode: info.Mode(), ModTime: f.Modified, Type: "file"}
+		switch {
+		case info.IsDir():
+			m.Type, m.Size = "dir", 0
+		case info.Mode()&fs.ModeSymlink != 0:
+			m.Type = "symlink"
+		case !info.Mode().IsRegular():
+			m.Type = "other"
+		}
+		if err := fn(m, f.Open); err != nil {
+			if err == errStopArchive {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(r io.Reader, fn func(archiveMember, func() (io.ReadCloser, error)) error) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+		m := archiveMember{Name: h.Name, Size: h.Size, Mode: h.FileInfo().Mode(), ModTime: h.ModTime, Type: "other", Link: h.Linkname}
+		switch h.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			m.Type = "file"
+		case tar.TypeDir:
+			m.Type, m.Size = "dir", 0
+		case tar.TypeSymlink:
+			m.Type = "symlink"
+		case tar.TypeLink:
+			m.Type = "hardlink"
+		}
+		if m.Type == "other" || m.Type == "symlink" || m.Type == "hardlink" {
+			m.Size = 0
+		}
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := fn(m, open); err != nil {
+			if err == errStopArchive {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// safeMemberPath returns the cleaned, slash-separated form of an archive
+// member name, rejecting names that would land outside the extraction
+// directory: absolute paths, drive letters and ".." components.
+func safeMemberPath(name string) (string, error) {
+	n := strings.ReplaceAll(name, `\`, "/")
+	if n == "" || strings.HasPrefix(n, "/") || (len(n) >= 2 && n[1] == ':') || strings.ContainsRune(n, 0) {
+		return "", fmt.Errorf("unsafe member name %q", name)
+	}
+	for _, part := range strings.Split(n, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("unsafe member name %q", name)
+		}
+	}
+	clean := path.Clean(n)
+	if clean == "." {
+		return "", fmt.Errorf("unsafe member name %q", name)
+	}
+	return clean, nil
+}
+
+// memberMatches reports whether a member is selected by the given names or
+// globs; no selectors select everything. A directory selects what is
+// under it.
+func memberMatches(selectors []string, name string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	name = strings.TrimSuffix(name, "/")
+	for _, s := range selectors {
+		s = strings.Trim(strings.ReplaceAll(s, `\`, "/"), "/")
+		if s == "" {
+			continue
+		}
+		if name == s || strings.HasPrefix(name, s+"/") || matchesAnyGlob([]string{s}, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,550 @@
+/**
+ * Archive tools module.
+ *
+ * archive_list shows the members of a zip, tar, tar.gz or tar.zst archive
+ * and can read one text member; archive_extract writes members below a
+ * destination directory and/or adds their text to the RAG index. Member
+ * names that would escape the destination are refused, and extraction
+ * stops at entry-count and byte limits that count the bytes actually
+ * decompressed rather than what the headers claim.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: archive_tools.go
+ * Description: Archive listing, member reading and safe extraction.
+ */
+
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"nira/memory"
+	"nira/sandbox"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxIndexedMember bounds the size of members added to the RAG index.
+const maxIndexedMember = 2 << 20
+
+// errArchiveLimit reports that extraction stopped at a size or count limit.
+var errArchiveLimit = errors.New("archive limit reached")
+
+type archiveListArgs struct {
+	Path       string `json:"path" desc:"Archive file (.zip, .tar, .tar.gz/.tgz, .tar.zst)" required:"true"`
+	Read       string `json:"read" desc:"Name of a member whose text content to return instead of the listing"`
+	MaxEntries int    `json:"max_entries" desc:"Maximum entries to list" default:"1000"`
+	MaxBytes   int    `json:"max_bytes" desc:"Maximum bytes of member content to return" default:"131072"`
+}
+
+type archiveExtractArgs struct {
+	Path          string   `json:"path" desc:"Archive file" required:"true"`
+	Destination   string   `json:"destination" desc:"Directory to extract into (created if missing); omit to only index"`
+	Members       []string `json:"members" desc:"Member names, directories or globs to extract (default: all)"`
+	Overwrite     bool     `json:"overwrite" desc:"Replace files that already exist in the destination"`
+	Index         bool     `json:"index" desc:"Add text members to the RAG index (searchable with rag_search)"`
+	IndexPatterns []string `json:"index_patterns" desc:"Glob patterns of members to index" default:"*.md,*.txt,*.json,*.yaml,*.yml"`
+	MaxTotalMB    int      `json:"max_total_mb" desc:"Stop after extracting this many MB in total" default:"512"`
+	MaxFileMB     int      `json:"max_file_mb" desc:"Refuse members larger than this" default:"100"`
+	MaxEntries    int      `json:"max_entries" desc:"Stop after this many members" default:"10000"`
+}
+
+// ArchiveListTool lists the members of an archive or reads one of them.
+type ArchiveListTool struct {
+	AllowedPaths []string
+	checker      PathChecker
+}
+
+func NewArchiveListTool(allowedPaths []string) *ArchiveListTool {
+	return &ArchiveListTool{AllowedPaths: allowedPaths}
+}
+
+func NewArchiveListToolWithChecker(allowedPaths []string, checker PathChecker) *ArchiveListTool {
+	return &ArchiveListTool{AllowedPaths: allowedPaths, checker: checker}
+}
+
+func (t *ArchiveListTool) Name() string           { return "archive_list" }
+func (t *ArchiveListTool) Permission() Permission { return PermissionRead }
+func (t *ArchiveListTool) Description() string {
+	return "Lists the members of a zip, tar, tar.gz or tar.zst archive (name, type, size, mod_time), or returns the text of one member with read. Args: path (string), read (string, member name), max_entries (int), max_bytes (int)."
+}
+func (t *ArchiveListTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), archiveListArgs{})
+}
+
+func (t *ArchiveListTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a archiveListArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	if a.Read != "" {
+		return readArchiveMember(a.Path, a.Read, a.MaxBytes)
+	}
+	if a.MaxEntries <= 0 {
+		a.MaxEntries = 1000
+	}
+
+	entries := []map[string]interface{}{}
+	var total int
+	var totalSize int64
+	format, err := walkArchive(a.Path, func(m archiveMember, _ func() (io.ReadCloser, error)) error {
+		total++
+		totalSize += m.Size
+		if len(entries) < a.MaxEntries {
+			e := map[string]interface{}{
+				"name":     m.Name,
+				"type":     m.Type,
+				"size":     m.Size,
+				"mod_time": m.ModTime.UTC().Format(time.RFC3339),
+			}
+			if m.Link != "" {
+				e["link"] = m.Link
+			}
+			if _, err := safeMemberPath(m.Name); err != nil {
+				e["unsafe"] = true
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"path":          a.Path,
+		"format":        format,
+		"entries":       entries,
+		"total_entries": total,
+		"total_size":    totalSize,
+		"truncated":     total > len(entries),
+	}, nil
+}
+
+// readArchiveMember returns up to maxBytes of a text member.
+func readArchiveMember(archive, name string, maxBytes int) (interface{}, error) {
+	if maxBytes <= 0 {
+		maxBytes = 131072
+	}
+	var result map[string]interface{}
+	format, err := walkArchive(archive, func(m archiveMember, open func() (io.ReadCloser, error)) error {
+		if m.Name != name && path.Clean(m.Name) != path.Clean(name) {
+			return nil
+		}
+		if m.Type != "file" {
+			return fmt.Errorf("member '%s' is a %s, not a file", name, m.Type)
+		}
+		rc, err := open()
+		if err != nil {
+			return fmt.Errorf("failed to read member: %w", err)
+		}
+		defer rc.Close()
+		raw, err := io.ReadAll(io.LimitReader(rc, int64(maxBytes)+1))
+		if err != nil {
+			return fmt.Errorf("failed to read member: %w", err)
+		}
+		truncated := len(raw) > maxBytes
+		if truncated {
+			raw = cutUTF8(raw, maxBytes)
+		}
+		content, encoding, err := decodeText(raw, truncated)
+		if err != nil {
+			return fmt.Errorf("member '%s' looks like a binary file", name)
+		}
+		result = map[string]interface{}{
+			"path":      archive,
+			"member":    m.Name,
+			"size":      m.Size,
+			"encoding":  encoding,
+			"content":   content,
+			"truncated": truncated,
+		}
+		return errStopArchive
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, fmt.Errorf("member '%s' not found in %s archive; use archive_list to see member names", name, format)
+	}
+	return result, nil
+}
+
+// decodeText decodes a member's bytes to UTF-8, failing for binary data.
+// more tells whether the member continues past raw.
+func decodeText(raw []byte, more bool) (string, string, error) {
+	encoding, bom := detectEncoding(raw[:min(len(raw), encodingSniffLen)], more || len(raw) > encodingSniffLen)
+	if encoding == encodingBinary {
+		return "", encoding, errors.New("binary content")
+	}
+	content, err := io.ReadAll(decodingReader(bytes.NewReader(raw[bom:]), encoding))
+	if err != nil {
+		return "", encoding, err
+	}
+	return string(content), encoding, nil
+}
+
+// ArchiveExtractTool extracts archive members and optionally indexes them.
+type ArchiveExtractTool struct {
+	AllowedPaths []string
+	checker      PathChecker
+	// Index receives text members when index is requested; nil disables it.
+	Index RagIndexWriter
+	// Trash receives files replaced with overwrite; nil keeps existing files.
+	Trash *memory.TrashStore
+}
+
+func NewArchiveExtractTool(allowedPaths []string) *ArchiveExtractTool {
+	return &ArchiveExtractTool{AllowedPaths: allowedPaths}
+}
+
+func NewArchiveExtractToolWithChecker(allowedPaths []string, checker PathChecker) *ArchiveExtractTool {
+	return &ArchiveExtractTool{AllowedPaths: allowedPaths, checker: checker}
+}
+
+func (t *ArchiveExtractTool) Name() string           { return "archive_extract" }
+func (t *ArchiveExtractTool) Permission() Permission { return PermissionWrite }
+func (t *ArchiveExtractTool) Description() string {
+	return "Extracts members of a zip, tar, tar.gz or tar.zst archive into a destination directory and/or adds their text to the RAG index. Members with unsafe names (absolute, ..) and links are skipped. Args: path (string), destination (string), members ([name or glob]), overwrite (bool), index (bool), index_patterns ([glob]), max_total_mb (int), max_file_mb (int), max_entries (int)."
+}
+func (t *ArchiveExtractTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), archiveExtractArgs{})
+}
+
+// archiveExtraction is the running state of one archive_extract call.
+type archiveExtraction struct {
+	extracted, indexed, dirs int
+	skipped                  []map[string]string
+	written                  int64
+}
+
+func (x *archiveExtraction) skip(name, reason string) {
+	if len(x.skipped) < 100 {
+		x.skipped = append(x.skipped, map[string]string{"name": name, "reason": reason})
+	}
+}
+
+func (t *ArchiveExtractTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a archiveExtractArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Destination == "" && !a.Index {
+		return nil, fmt.Errorf("set destination, index, or both")
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	if a.Index {
+		if t.Index == nil {
+			return nil, fmt.Errorf("the RAG index is not available")
+		}
+		if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessIndex); err != nil {
+			return nil, err
+		}
+	}
+	if a.Destination != "" {
+		if err := checkPathAccess(t.checker, t.AllowedPaths, a.Destination, sandbox.AccessWrite); err != nil {
+			return nil, err
+		}
+		if err := checkPreviewBase(args, a.Destination); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(a.Destination, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create destination: %w", err)
+		}
+	}
+	patterns := nonEmpty(a.IndexPatterns)
+	if len(patterns) == 0 {
+		patterns = []string{"*.md", "*.txt", "*.json", "*.yaml", "*.yml"}
+	}
+	maxTotal := int64(a.MaxTotalMB) << 20
+	maxFile := int64(a.MaxFileMB) << 20
+	archiveAbs, _ := filepath.Abs(a.Path)
+
+	x := &archiveExtraction{}
+	entries := 0
+	format, err := walkArchive(a.Path, func(m archiveMember, open func() (io.ReadCloser, error)) error {
+		if !memberMatches(a.Members, m.Name) {
+			return nil
+		}
+		if entries++; a.MaxEntries > 0 && entries > a.MaxEntries {
+			return fmt.Errorf("%w: more than %d members", errArchiveLimit, a.MaxEntries)
+		}
+		rel, err := safeMemberPath(m.Name)
+		if err != nil {
+			x.skip(m.Name, "unsafe name")
+			return nil
+		}
+		switch m.Type {
+		case "dir":
+			if a.Destination != "" {
+				if err := t.makeDir(a.Destination, rel); err != nil {
+					return err
+				}
+				x.dirs++
+			}
+			return nil
+		case "file":
+		default:
+			x.skip(m.Name, m.Type+" entries are not extracted")
+			return nil
+		}
+		if maxFile > 0 && m.Size > maxFile {
+			x.skip(m.Name, fmt.Sprintf("larger than max_file_mb=%d", a.MaxFileMB))
+			return nil
+		}
+
+		rc, err := open()
+		if err != nil {
+			return fmt.Errorf("failed to read member '%s': %w", m.Name, err)
+		}
+		defer rc.Close()
+		// Headers can lie about sizes; count what is actually decompressed.
+		budget := int64(-1)
+		if maxFile > 0 {
+			budget = maxFile
+		}
+		if maxTotal > 0 {
+			remaining := maxTotal - x.written
+			if remaining <= 0 {
+				return fmt.Errorf("%w: max_total_mb=%d", errArchiveLimit, a.MaxTotalMB)
+			}
+			if budget < 0 || remaining < budget {
+				budget = remaining
+			}
+		}
+		var src io.Reader = rc
+		if budget >= 0 {
+			src = io.LimitReader(rc, budget+1)
+		}
+
+		want := a.Index && matchesAnyGlob(patterns, path.Base(rel)) && m.Size <= maxIndexedMember
+		keep := &limitedBuffer{limit: maxIndexedMember}
+		if want {
+			src = io.TeeReader(src, keep)
+		}
+
+		var n int64
+		if a.Destination != "" {
+			n, err = t.writeMember(a.Destination, rel, src, m, a.Overwrite, budget, x)
+		} else {
+			n, err = io.Copy(io.Discard, src)
+			if err == nil && budget >= 0 && n > budget {
+				err = fmt.Errorf("%w: '%s' decompresses past the size limit", errArchiveLimit, m.Name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		x.written += n
+		if want && !keep.overflow {
+			t.indexMember(archiveAbs, rel, m, keep.Bytes(), x)
+		}
+		return nil
+	})
+
+	result := map[string]interface{}{
+		"path":        a.Path,
+		"format":      format,
+		"extracted":   x.extracted,
+		"directories": x.dirs,
+		"indexed":     x.indexed,
+		"bytes":       x.written,
+		"skipped":     x.skipped,
+	}
+	if a.Destination != "" {
+		result["destination"] = a.Destination
+	}
+	if err != nil {
+		if errors.Is(err, errArchiveLimit) {
+			return nil, fmt.Errorf("%v (stopped after extracting %d files; raise max_total_mb, max_file_mb or max_entries, or pick members)", err, x.extracted)
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// Preview summarizes what extracting into the destination would create and
+// overwrite, from the member headers. Index-only calls change no files.
+func (t *ArchiveExtractTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a archiveExtractArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Destination == "" {
+		return nil, nil
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Destination, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	files, dirs, unsafe := 0, 0, 0
+	var size int64
+	var existing []string
+	_, err := walkArchive(a.Path, func(m archiveMember, _ func() (io.ReadCloser, error)) error {
+		if !memberMatches(a.Members, m.Name) {
+			return nil
+		}
+		rel, err := safeMemberPath(m.Name)
+		if err != nil {
+			unsafe++
+			return nil
+		}
+		switch m.Type {
+		case "dir":
+			dirs++
+		case "file":
+			files++
+			size += m.Size
+			if info, err := os.Lstat(filepath.Join(a.Destination, filepath.FromSlash(rel))); err == nil && info.Mode().IsRegular() {
+				existing = append(existing, rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("extracts %d %s (%s) and %d %s from %s", files, plural(files, "file", "files"), formatBytes(size), dirs, plural(dirs, "directory", "directories"), a.Path)
+	if len(existing) > 0 {
+		verb := "kept (overwrite is off)"
+		switch {
+		case a.Overwrite && t.Trash == nil:
+			verb = "kept (no trash is configured)"
+		case a.Overwrite:
+			verb = "replaced (the old versions go to the trash)"
+		}
+		names := existing
+		if len(names) > 10 {
+			names = append(names[:10:10], fmt.Sprintf("%d more", len(existing)-10))
+		}
+		summary += fmt.Sprintf("; %d existing %s %s: %s", len(existing), plural(len(existing), "file", "files"), verb, strings.Join(names, ", "))
+	}
+	if unsafe > 0 {
+		summary += fmt.Sprintf("; %d unsafe %s skipped", unsafe, plural(unsafe, "name", "names"))
+	}
+	action := "modify"
+	if _, err := os.Stat(a.Destination); err != nil {
+		action = "create"
+	}
+	return []FilePreview{previewPath(a.Destination, action, summary)}, nil
+}
+
+// makeDir creates dest/rel after checking it stays inside dest.
+func (t *ArchiveExtractTool) makeDir(dest, rel string) error {
+	target := filepath.Join(dest, filepath.FromSlash(rel))
+	if err := t.checkTarget(dest, target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", target, err)
+	}
+	return nil
+}
+
+// checkTarget refuses targets that resolve outside dest, for example
+// through a symlink already present in the destination, or that the
+// sandbox does not allow writing.
+func (t *ArchiveExtractTool) checkTarget(dest, target string) error {
+	if !sandbox.Within(dest, target) {
+		return fmt.Errorf("member path '%s' escapes the destination", target)
+	}
+	return checkPathAccess(t.checker, t.AllowedPaths, target, sandbox.AccessWrite)
+}
+
+// writeMember writes one file member and returns the bytes read. The member
+// goes to a temporary file first, so one that fails or passes budget (-1
+// for none) leaves nothing behind; an existing file it replaces goes to
+// the trash.
+func (t *ArchiveExtractTool) writeMember(dest, rel string, src io.Reader, m archiveMember, overwrite bool, budget int64, x *archiveExtraction) (int64, error) {
+	target := filepath.Join(dest, filepath.FromSlash(rel))
+	if err := t.makeDir(dest, path.Dir(rel)); err != nil {
+		return 0, err
+	}
+	if err := t.checkTarget(dest, target); err != nil {
+		return 0, err
+	}
+	replace := false
+	if info, err := os.Lstat(target); err == nil {
+		switch {
+		case !overwrite || !info.Mode().IsRegular():
+			x.skip(m.Name, "already exists")
+			return io.Copy(io.Discard, src)
+		case t.Trash == nil:
+			x.skip(m.Name, "already exists and no trash is configured to keep it")
+			return io.Copy(io.Discard, src)
+		}
+		replace = true
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".nira-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create '%s': %w", target, err)
+	}
+	n, err := io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && budget >= 0 && n > budget {
+		err = fmt.Errorf("%w: '%s' decompresses past the size limit", errArchiveLimit, m.Name)
+	}
+	if err == nil {
+		// Keep permission bits only; never setuid/setgid, always owner-writable.
+		err = os.Chmod(tmp.Name(), m.Mode.Perm()&0755|0600)
+	}
+	if err == nil && replace {
+		_, err = (&fileManager{Trash: t.Trash}).trash(target, t.Name())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		if errors.Is(err, errArchiveLimit) {
+			return n, err
+		}
+		return n, fmt.Errorf("failed to write '%s': %w", target, err)
+	}
+	if !m.ModTime.IsZero() {
+		os.Chtimes(target, m.ModTime, m.ModTime)
+	}
+	x.extracted++
+	return n, nil
+}
+
+// indexMember adds a text member to the RAG index under
+// "<archive>!/<member>".
+func (t *ArchiveExtractTool) indexMember(archive, rel string, m archiveMember, data []byte, x *archiveExtraction) {
+	content, _, err := decodeText(data, false)
+	if err != nil {
+		x.skip(m.Name, "binary content not indexed")
+		return
+	}
+	mod := m.ModTime.UTC().Format(time.RFC3339)
+	if err := t.Index.Upsert(archive+"!/"+rel, path.Base(rel), mod, int64(len(data)), content); err != nil {
+		x.skip(m.Name, "index failed: "+err.Error())
+		return
+	}
+	x.indexed++
+}
+
+func nonEmpty(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
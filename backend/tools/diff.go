@@ -0,0 +1,206 @@
+/**
+ * Text diff module.
+ *
+ * Produces unified diffs between two versions of a text file so edits can
+ * be reported back to the model and shown to the user. Lines are compared
+ * with their terminators, so a change to the final newline shows up as
+ * "\ No newline at end of file" like in diff(1).
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: diff.go
+ * Description: Line-based unified diff generation.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffCells bounds the LCS table; larger changed regions are reported as
+// a full replacement rather than a minimal diff.
+const maxDiffCells = 4 << 20
+
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// UnifiedDiff returns the unified diff turning before into after, or "" when
+// they are equal.
+func UnifiedDiff(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(splitLinesKeepEnds(before), splitLinesKeepEnds(after))
+
+	// aPos[k]/bPos[k] count old/new lines preceding op k.
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", path, path)
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk while the next change is close enough to share context.
+		last := k
+		for j := k + 1; j < len(ops) && j-last-1 <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		stop := last + 1 + diffContext
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		aCount, bCount := aPos[stop]-aPos[start], bPos[stop]-bPos[start]
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aCount), hunkRange(bPos[start], bCount))
+		for _, op := range ops[start:stop] {
+			sb.WriteByte(op.kind)
+			if strings.HasSuffix(op.text, "\n") {
+				sb.WriteString(op.text)
+			} else {
+				sb.WriteString(op.text)
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = stop
+	}
+	return sb.String()
+}
+
+// DiffStats counts added and removed lines in a unified diff.
+func DiffStats(diff string) (added, removed int) {
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+			// File headers ("--- a/x", "+++ b/x") precede the first hunk.
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLinesKeepEnds splits text after each "\n"; the last line may lack one.
+func splitLinesKeepEnds(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes an edit script using the longest common subsequence of
+// the region between the common prefix and suffix.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffLine{' ', line})
+	}
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffLine{' ', line})
+	}
+	return ops
+}
+
+func diffMiddle(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	ops := make([]diffLine, 0, n+m)
+	if n == 0 || m == 0 || n*m > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, diffLine{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffLine{'+', line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:], stored row-major.
+	w := m + 1
+	lcs := make([]int32, (n+1)*w)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+				lcs[i*w+j] = lcs[(i+1)*w+j]
+			} else {
+				lcs[i*w+j] = lcs[i*w+j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			ops = append(ops, diffLine{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffLine{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffLine{'+', b[j]})
+	}
+	return ops
+}
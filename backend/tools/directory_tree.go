@@ -0,0 +1,223 @@
+/**
+ * Directory tree module.
+ *
+ * Builds the tree view of list_directory: a compact, indented rendering of
+ * a directory with per-directory file counts and total sizes. Directories
+ * below the depth limit are summarized on one line instead of expanded,
+ * and ignored entries (.gitignore, default noise folders, custom globs)
+ * are left out of both the listing and the totals.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: directory_tree.go
+ * Description: Depth-limited, gitignore-aware directory tree summaries.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxTreeEntries bounds how many entries a tree walk visits in total.
+const maxTreeEntries = 200000
+
+// defaultIgnoredDirs are skipped like .gitignore entries even when no
+// .gitignore mentions them.
+var defaultIgnoredDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"__pycache__":  true,
+}
+
+// listFilter decides which entries a listing skips.
+type listFilter struct {
+	root      string
+	globs     []string
+	gitignore *gitignore // nil when .gitignore is not honored
+	readable  func(string) bool
+}
+
+// newListFilter returns a filter for a walk rooted at root.
+func newListFilter(root string, globs []string, useGitignore bool, readable func(string) bool) *listFilter {
+	f := &listFilter{root: root, globs: globs, readable: readable}
+	if useGitignore {
+		f.gitignore = &gitignore{}
+		f.gitignore.load(root, ".")
+	}
+	return f
+}
+
+// skip reports whether the entry at p should be left out.
+func (f *listFilter) skip(p string, isDir bool) bool {
+	rel, err := filepath.Rel(f.root, p)
+	if err != nil {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	if f.gitignore != nil {
+		if isDir && defaultIgnoredDirs[filepath.Base(p)] {
+			return true
+		}
+		if f.gitignore.ignored(rel, isDir) {
+			return true
+		}
+	}
+	if matchesAnyGlob(f.globs, rel) {
+		return true
+	}
+	return !f.readable(p)
+}
+
+// enter loads the .gitignore of a directory the walk descends into.
+func (f *listFilter) enter(dir string) {
+	if f.gitignore == nil || dir == f.root {
+		return
+	}
+	if rel, err := filepath.Rel(f.root, dir); err == nil {
+		f.gitignore.load(dir, filepath.ToSlash(rel))
+	}
+}
+
+// treeNode is a directory entry with the totals of everything below it.
+type treeNode struct {
+	name     string
+	isDir    bool
+	link     string // symlink target, if the entry is a link
+	size     int64
+	files    int
+	children []*treeNode
+	// collapsed is set for directories below the depth limit.
+	collapsed bool
+}
+
+// dirTree walks a directory into treeNodes.
+type dirTree struct {
+	filter   *listFilter
+	maxDepth int
+	visited  int
+	// partial is set when the walk hit maxTreeEntries or unreadable
+	// directories, so totals are lower bounds.
+	partial bool
+}
+
+// build returns the node for dir; depth is dir's depth below the root.
+func (w *dirTree) build(dir, name string, depth int) *treeNode {
+	node := &treeNode{name: name, isDir: true, collapsed: depth >= w.maxDepth && depth > 0}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.partial = true
+		return node
+	}
+	w.filter.enter(dir)
+	for _, e := range entries {
+		if w.visited >= maxTreeEntries {
+			w.partial = true
+			break
+		}
+		w.visited++
+		p := filepath.Join(dir, e.Name())
+		if w.filter.skip(p, e.IsDir()) {
+			continue
+		}
+		var child *treeNode
+		switch {
+		case e.IsDir():
+			child = w.build(p, e.Name(), depth+1)
+		case e.Type()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			child = &treeNode{name: e.Name(), link: target}
+		default:
+			child = &treeNode{name: e.Name(), files: 1}
+			if info, err := e.Info(); err == nil {
+				child.size = info.Size()
+			}
+		}
+		node.size += child.size
+		node.files += child.files
+		if !node.collapsed {
+			node.children = append(node.children, child)
+		}
+	}
+	// Directories first, then names.
+	sort.Slice(node.children, func(i, j int) bool {
+		a, b := node.children[i], node.children[j]
+		if a.isDir != b.isDir {
+			return a.isDir
+		}
+		return a.name < b.name
+	})
+	return node
+}
+
+// renderTree draws node as an indented tree, stopping after maxLines
+// entries. It returns the text and whether entries were left out.
+func renderTree(node *treeNode, maxLines int) (string, bool) {
+	var b strings.Builder
+	b.WriteString(node.label())
+	b.WriteByte('\n')
+	lines := 0
+	cut := false
+	var draw func(n *treeNode, prefix string)
+	draw = func(n *treeNode, prefix string) {
+		for i, c := range n.children {
+			if lines >= maxLines {
+				fmt.Fprintf(&b, "%s└── … %d more\n", prefix, len(n.children)-i)
+				cut = true
+				return
+			}
+			last := i == len(n.children)-1
+			branch, indent := "├── ", "│   "
+			if last {
+				branch, indent = "└── ", "    "
+			}
+			b.WriteString(prefix + branch + c.label())
+			b.WriteByte('\n')
+			lines++
+			if c.isDir {
+				draw(c, prefix+indent)
+			}
+		}
+	}
+	draw(node, "")
+	return b.String(), cut
+}
+
+func (n *treeNode) label() string {
+	switch {
+	case n.link != "":
+		return n.name + " -> " + n.link
+	case !n.isDir:
+		return fmt.Sprintf("%s (%s)", n.name, formatBytes(n.size))
+	}
+	label := fmt.Sprintf("%s/ (%d %s, %s)", n.name, n.files, plural(n.files, "file", "files"), formatBytes(n.size))
+	if n.collapsed && n.files > 0 {
+		label += " …"
+	}
+	return label
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// formatBytes renders a size with a binary unit, e.g. "1.5 KB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
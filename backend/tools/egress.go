@@ -0,0 +1,295 @@
+/**
+ * Network egress module.
+ *
+ * Every outbound HTTP request made by NIRA's tools goes through one
+ * Egress: it applies a default timeout, refuses hosts outside the
+ * configured allow list or on the deny list (redirect hops included),
+ * refuses hosts that resolve to loopback, private or link-local
+ * addresses unless they are allow-listed, refuses everything in offline
+ * mode, and logs each request. A nil *Egress allows everything, so tools
+ * work unchanged in tests.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: egress.go
+ * Description: Central outbound HTTP client with domain policy and logging.
+ */
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultEgressTimeout = 30 * time.Second
+
+// ErrOffline is returned for any outbound request in offline mode.
+var ErrOffline = errors.New("network access is disabled (offline mode)")
+
+// ErrPrivateAddress is returned when a host that is not allow-listed
+// resolves to an address on the local machine or network.
+var ErrPrivateAddress = errors.New("resolves to a loopback, private or link-local address")
+
+// sharedAddressSpace is 100.64.0.0/10 (carrier-grade NAT), which some
+// clouds use for metadata services; net.IP.IsPrivate does not cover it.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// EgressConfig controls outbound network access from tools.
+type EgressConfig struct {
+	// Offline refuses every outbound request; main also unregisters the
+	// network tools so the model never sees them.
+	Offline bool `toml:"offline"`
+	// AllowDomains, when not empty, lists the only hosts tools may reach.
+	// An entry matches the domain and its subdomains ("example.com" also
+	// allows "docs.example.com"); IP addresses match exactly.
+	AllowDomains []string `toml:"allow_domains"`
+	// DenyDomains are never reached, even when allowed above.
+	DenyDomains []string `toml:"deny_domains"`
+	// TimeoutSeconds bounds a request whose tool sets no timeout of its own.
+	TimeoutSeconds int `toml:"timeout_seconds"`
+	// LogRequests logs every outbound request with its status and duration.
+	LogRequests bool `toml:"log_requests"`
+}
+
+// Egress hands out HTTP clients that enforce an EgressConfig.
+type Egress struct {
+	cfg   EgressConfig
+	allow []string
+	deny  []string
+	base  http.RoundTripper
+	// proxies are the hosts of the proxy environment variables, which the
+	// operator chose and which may live on the local network.
+	proxies []string
+	// Log receives one line per outbound request when LogRequests is set.
+	Log func(format string, args ...interface{})
+}
+
+// NewEgress validates cfg and returns the policy it describes.
+func NewEgress(cfg EgressConfig) (*Egress, error) {
+	e := &Egress{cfg: cfg}
+	e.base = e.newTransport()
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if u, err := url.Parse(os.Getenv(name)); err == nil && u.Hostname() != "" {
+			e.proxies = append(e.proxies, strings.ToLower(u.Hostname()))
+		}
+	}
+	var err error
+	if e.allow, err = normalizeDomains(cfg.AllowDomains); err != nil {
+		return nil, fmt.Errorf("invalid AllowDomains: %w", err)
+	}
+	if e.deny, err = normalizeDomains(cfg.DenyDomains); err != nil {
+		return nil, fmt.Errorf("invalid DenyDomains: %w", err)
+	}
+	if cfg.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("invalid TimeoutSeconds %d", cfg.TimeoutSeconds)
+	}
+	return e, nil
+}
+
+// normalizeDomains lowercases entries and strips "*." and "." prefixes.
+func normalizeDomains(list []string) ([]string, error) {
+	var out []string
+	for _, d := range list {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "*."), ".")
+		if d == "" {
+			continue
+		}
+		if strings.ContainsAny(d, "/:@ ") && net.ParseIP(d) == nil {
+			return nil, fmt.Errorf("'%s' is not a host name (use example.com, not a URL)", d)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// Offline reports whether all outbound requests are refused.
+func (e *Egress) Offline() bool {
+	return e != nil && e.cfg.Offline
+}
+
+// Check returns an error when host may not be reached.
+func (e *Egress) Check(host string) error {
+	if e == nil {
+		return nil
+	}
+	if e.cfg.Offline {
+		return ErrOffline
+	}
+	h := strings.ToLower(strings.TrimSuffix(host, "."))
+	if split, _, err := net.SplitHostPort(h); err == nil {
+		h = split
+	}
+	h = strings.Trim(h, "[]")
+	if domainListed(e.deny, h) {
+		return fmt.Errorf("network access to %s is blocked by DenyDomains", h)
+	}
+	if len(e.allow) > 0 && !domainListed(e.allow, h) {
+		return fmt.Errorf("network access to %s is not in AllowDomains", h)
+	}
+	return nil
+}
+
+func domainListed(list []string, host string) bool {
+	for _, d := range list {
+		if host == d || (net.ParseIP(d) == nil && strings.HasSuffix(host, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTransport returns a transport that dials through dialContext.
+func (e *Egress) newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = e.dialContext
+	return transport
+}
+
+// privateOKKey marks a request context whose host may resolve to a private
+// address.
+type privateOKKey struct{}
+
+// dialContext resolves the host itself and refuses loopback, private and
+// link-local addresses unless the request was marked as allowed to reach
+// them. It connects to the address it checked, so a second DNS answer
+// cannot swap in another one.
+func (e *Egress) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := ctx.Value(privateOKKey{}).(bool); ok || domainListed(e.proxies, strings.ToLower(host)) {
+		return d.DialContext(ctx, network, addr)
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPublic(host, ips); err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses for %s", host)
+	}
+	return nil, lastErr
+}
+
+// checkPublic refuses a host when any of its addresses is private.
+func checkPublic(host string, ips []net.IPAddr) error {
+	for _, ip := range ips {
+		if isPrivateAddress(ip.IP) {
+			return fmt.Errorf("network access to %s is blocked: it %w (%s); add it to AllowDomains to permit it", host, ErrPrivateAddress, ip.IP)
+		}
+	}
+	return nil
+}
+
+// isPrivateAddress reports whether ip is on this machine or a local
+// network: loopback, private, link-local (169.254.169.254 included),
+// shared, unspecified or multicast.
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// Client returns an HTTP client that checks and logs every request,
+// redirects included. A zero timeout uses the configured default.
+func (e *Egress) Client(timeout time.Duration) *http.Client {
+	return e.ServiceClient(timeout, "")
+}
+
+// ServiceClient is Client for a service the operator configured at
+// serviceURL, such as a self-hosted search engine: requests to its host
+// may reach a private address, as if it were in AllowDomains. Its host
+// still has to pass the domain lists.
+func (e *Egress) ServiceClient(timeout time.Duration, serviceURL string) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultEgressTimeout
+		if e != nil && e.cfg.TimeoutSeconds > 0 {
+			timeout = time.Duration(e.cfg.TimeoutSeconds) * time.Second
+		}
+	}
+	if e == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	t := &egressTransport{e: e, base: e.base}
+	if u, err := url.Parse(serviceURL); err == nil && u.Hostname() != "" {
+		// Its own connection pool, so other clients never reuse a
+		// connection that was allowed to reach a private address.
+		t.service = strings.ToLower(u.Hostname())
+		t.base = e.newTransport()
+	}
+	return &http.Client{Timeout: timeout, Transport: t}
+}
+
+// egressTransport applies an Egress to each round trip.
+type egressTransport struct {
+	e    *Egress
+	base http.RoundTripper
+	// service is a configured service host that may be private.
+	service string
+}
+
+func (t *egressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	if err := t.e.Check(req.URL.Host); err != nil {
+		t.log("outbound %s %s blocked: %v", req.Method, target, err)
+		return nil, err
+	}
+	host := strings.ToLower(strings.TrimSuffix(req.URL.Hostname(), "."))
+	if (t.service != "" && host == t.service) || domainListed(t.e.allow, host) {
+		req = req.WithContext(context.WithValue(req.Context(), privateOKKey{}, true))
+	} else if t.proxied(req) {
+		// dialContext only sees the proxy, so check the target here. A
+		// host that does not resolve locally is left to the proxy.
+		if ips, err := net.DefaultResolver.LookupIPAddr(req.Context(), host); err == nil {
+			if err := checkPublic(host, ips); err != nil {
+				t.log("outbound %s %s blocked: %v", req.Method, target, err)
+				return nil, err
+			}
+		}
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		t.log("outbound %s %s failed after %v: %v", req.Method, target, elapsed, err)
+		return nil, err
+	}
+	t.log("outbound %s %s -> %d in %v", req.Method, target, resp.StatusCode, elapsed)
+	return resp, nil
+}
+
+// proxied reports whether the base transport sends req through a proxy.
+func (t *egressTransport) proxied(req *http.Request) bool {
+	transport, ok := t.base.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return false
+	}
+	u, err := transport.Proxy(req)
+	return err == nil && u != nil
+}
+
+func (t *egressTransport) log(format string, args ...interface{}) {
+	if t.e.cfg.LogRequests && t.e.Log != nil {
+		t.e.Log(format, args...)
+	}
+}
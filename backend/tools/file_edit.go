@@ -0,0 +1,450 @@
+/**
+ * File edit tool module.
+ *
+ * Applies targeted changes to an existing file instead of rewriting it:
+ * search/replace blocks or a unified diff. Every anchor must match exactly
+ * once (or be explicitly replace_all); otherwise nothing is written and the
+ * error says which edit failed and why. Returns the resulting diff.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: file_edit.go
+ * Description: Patch-based file editing tool.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"nira/memory"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type editBlock struct {
+	Search     string `json:"search" desc:"Exact text to find, including indentation"`
+	Replace    string `json:"replace" desc:"Text to put in its place"`
+	ReplaceAll bool   `json:"replace_all" desc:"Replace every occurrence instead of requiring exactly one"`
+}
+
+type editFileArgs struct {
+	Path   string      `json:"path" desc:"The file to edit" required:"true"`
+	Edits  []editBlock `json:"edits" desc:"Search/replace blocks applied in order"`
+	Diff   string      `json:"diff" desc:"Unified diff, or <<<<<<< SEARCH / ======= / >>>>>>> REPLACE blocks, to apply instead of edits"`
+	DryRun bool        `json:"dry_run" desc:"Return the diff without writing the file"`
+}
+
+type FileEditTool struct {
+	AllowedPaths []string
+	checker      PathChecker
+	// Versions keeps the content before each edit; nil disables backups.
+	Versions *memory.FileVersionStore
+}
+
+func NewFileEditTool(allowedPaths []string) *FileEditTool {
+	return &FileEditTool{AllowedPaths: allowedPaths}
+}
+
+func NewFileEditToolWithChecker(allowedPaths []string, checker PathChecker) *FileEditTool {
+	return &FileEditTool{AllowedPaths: allowedPaths, checker: checker}
+}
+
+func (t *FileEditTool) Name() string {
+	return "edit_file"
+}
+
+func (t *FileEditTool) Description() string {
+	return "Edits an existing file in place without rewriting it. Arguments: 'path' (string), and either 'edits' (list of {search, replace, replace_all}) or 'diff' (unified diff or SEARCH/REPLACE blocks), optional 'dry_run' (bool). Each search text or diff hunk must match exactly once. Returns the resulting unified diff."
+}
+
+func (t *FileEditTool) Permission() Permission {
+	return PermissionWrite
+}
+
+func (t *FileEditTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), editFileArgs{})
+}
+
+func (t *FileEditTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a editFileArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Edits) == 0 && strings.TrimSpace(a.Diff) == "" {
+		return nil, fmt.Errorf("edits or diff argument is required")
+	}
+	if len(a.Edits) > 0 && a.Diff != "" {
+		return nil, fmt.Errorf("use either edits or diff, not both")
+	}
+	original, updated, applied, err := t.apply(a)
+	if err != nil {
+		return nil, err
+	}
+	diff := UnifiedDiff(filepath.Base(a.Path), normalizeNewlines(original), updated)
+	added, removed := DiffStats(diff)
+	if err := checkPreviewBase(args, a.Path); err != nil {
+		return nil, err
+	}
+
+	var versionID int64
+	if !a.DryRun && diff != "" {
+		if versionID, err = safeWriteFile(t.Versions, a.Path, []byte(restoreLineEndings(original, updated)), t.Name()); err != nil {
+			return nil, err
+		}
+	}
+
+	result := map[string]interface{}{
+		"path":    a.Path,
+		"applied": applied,
+		"added":   added,
+		"removed": removed,
+		"changed": diff != "",
+		"dry_run": a.DryRun,
+		"diff":    diff,
+	}
+	if versionID > 0 {
+		result["backup_version"] = versionID
+	}
+	return result, nil
+}
+
+// apply returns the file's current content and the edited content, which
+// uses "\n" line endings regardless of the file's.
+func (t *FileEditTool) apply(a editFileArgs) (original, updated string, applied int, err error) {
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessWrite); err != nil {
+		return "", "", 0, err
+	}
+
+	info, err := os.Stat(a.Path)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	if info.IsDir() {
+		return "", "", 0, fmt.Errorf("path '%s' is a directory", a.Path)
+	}
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	original = string(data)
+	content := normalizeNewlines(original)
+
+	switch {
+	case len(a.Edits) > 0:
+		updated, applied, err = applyEdits(content, a.Edits)
+	case isSearchReplaceText(a.Diff):
+		var edits []editBlock
+		edits, err = parseSearchReplaceBlocks(a.Diff)
+		if err == nil {
+			updated, applied, err = applyEdits(content, edits)
+		}
+	default:
+		updated, applied, err = applyUnifiedDiff(content, a.Diff)
+	}
+	if err != nil {
+		return "", "", 0, fmt.Errorf("%s: %w; file not modified", a.Path, err)
+	}
+	return original, updated, applied, nil
+}
+
+// Preview reports the change the edits would make without writing it.
+func (t *FileEditTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a editFileArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Edits) == 0 && strings.TrimSpace(a.Diff) == "" {
+		return nil, fmt.Errorf("edits or diff argument is required")
+	}
+	if a.DryRun {
+		return nil, nil
+	}
+	original, updated, _, err := t.apply(a)
+	if err != nil {
+		return nil, err
+	}
+	p, err := previewChange(a.Path, []byte(restoreLineEndings(original, updated)))
+	if err != nil {
+		return nil, err
+	}
+	return []FilePreview{p}, nil
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// restoreLineEndings converts updated back to CRLF when the original file used it.
+func restoreLineEndings(original, updated string) string {
+	if strings.Contains(original, "\r\n") {
+		return strings.ReplaceAll(updated, "\n", "\r\n")
+	}
+	return updated
+}
+
+// applyEdits applies search/replace blocks in order; each later block sees
+// the result of the earlier ones.
+func applyEdits(content string, edits []editBlock) (string, int, error) {
+	for i, e := range edits {
+		search := strings.ReplaceAll(e.Search, "\r\n", "\n")
+		replace := strings.ReplaceAll(e.Replace, "\r\n", "\n")
+		if search == "" {
+			return "", i, fmt.Errorf("edit %d: search text is empty", i+1)
+		}
+		switch n := strings.Count(content, search); {
+		case n == 0:
+			return "", i, fmt.Errorf("edit %d: %s", i+1, notFoundDetail(content, search))
+		case n > 1 && !e.ReplaceAll:
+			return "", i, fmt.Errorf("edit %d: search text is ambiguous, it matches %d times (lines %s); add surrounding lines to make it unique or set replace_all", i+1, n, matchLines(content, search))
+		}
+		content = strings.ReplaceAll(content, search, replace)
+	}
+	return content, len(edits), nil
+}
+
+// notFoundDetail explains a missing anchor, pointing at a near match that
+// differs only in whitespace when there is one.
+func notFoundDetail(content, search string) string {
+	want := strings.Split(strings.Trim(search, "\n"), "\n")
+	lines := strings.Split(content, "\n")
+	for i := 0; i+len(want) <= len(lines); i++ {
+		match := true
+		for j, w := range want {
+			if strings.TrimSpace(lines[i+j]) != strings.TrimSpace(w) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return fmt.Sprintf("search text not found; lines %d-%d match except for whitespace/indentation, copy them exactly", i+1, i+len(want))
+		}
+	}
+	return fmt.Sprintf("search text not found (first line: %q); read the file again and copy the text exactly", truncate(want[0], 80))
+}
+
+func matchLines(content, search string) string {
+	var nums []string
+	offset := 0
+	for len(nums) < 10 {
+		idx := strings.Index(content[offset:], search)
+		if idx < 0 {
+			break
+		}
+		nums = append(nums, strconv.Itoa(strings.Count(content[:offset+idx], "\n")+1))
+		offset += idx + len(search)
+	}
+	return strings.Join(nums, ", ")
+}
+
+func isSearchReplaceText(s string) bool {
+	return strings.Contains(s, "<<<<<<< SEARCH")
+}
+
+// parseSearchReplaceBlocks reads blocks of the form
+//
+//	<<<<<<< SEARCH
+//	old lines
+//	=======
+//	new lines
+//	>>>>>>> REPLACE
+func parseSearchReplaceBlocks(text string) ([]editBlock, error) {
+	const (
+		outside = iota
+		inSearch
+		inReplace
+	)
+	var blocks []editBlock
+	var search, replace []string
+	state := outside
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		marker := strings.TrimSpace(line)
+		switch {
+		case state == outside && strings.HasPrefix(marker, "<<<<<<<"):
+			state, search, replace = inSearch, nil, nil
+		case state == inSearch && strings.HasPrefix(marker, "=======") && strings.Trim(marker, "=") == "":
+			state = inReplace
+		case state == inReplace && strings.HasPrefix(marker, ">>>>>>>"):
+			blocks = append(blocks, editBlock{Search: strings.Join(search, "\n"), Replace: strings.Join(replace, "\n")})
+			state = outside
+		case state == inSearch:
+			search = append(search, line)
+		case state == inReplace:
+			replace = append(replace, line)
+		}
+	}
+	if state != outside {
+		return nil, fmt.Errorf("SEARCH/REPLACE block %d is not terminated", len(blocks)+1)
+	}
+	return blocks, nil
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+type diffHunk struct {
+	header   string
+	oldStart int
+	old      []string
+	new      []string
+}
+
+// parseUnifiedDiff reads the hunks of a single-file unified diff. Line counts
+// in hunk headers are not trusted to end a hunk, which runs to the next
+// header. They only tell a file header from a removed line starting with
+// "-- " (a SQL or Lua comment) followed by an added one starting with "++ ":
+// within the counts the pair is a change.
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	var hunks []diffHunk
+	var cur *diffHunk
+	files := 0
+	oldLeft, newLeft := 0, 0 // lines the current hunk's header still promises
+	for i, line := range lines {
+		inCounts := cur != nil && (oldLeft > 0 || newLeft > 0)
+		switch {
+		case !inCounts && strings.HasPrefix(line, "+++ ") && i > 0 && strings.HasPrefix(lines[i-1], "--- "):
+			files++
+			if files > 1 {
+				return nil, fmt.Errorf("diff touches more than one file; send one edit_file call per file")
+			}
+			cur = nil
+		case !inCounts && strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "),
+			strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+			cur = nil
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			oldLeft, newLeft = hunkCount(m[2]), hunkCount(m[4])
+			hunks = append(hunks, diffHunk{header: line, oldStart: start})
+			cur = &hunks[len(hunks)-1]
+		case cur == nil:
+			// Text outside hunks (commit messages, headers) is ignored.
+		case strings.HasPrefix(line, "\\"):
+			// "\ No newline at end of file"
+		case strings.HasPrefix(line, "-"):
+			cur.old = append(cur.old, line[1:])
+			oldLeft--
+		case strings.HasPrefix(line, "+"):
+			cur.new = append(cur.new, line[1:])
+			newLeft--
+		case strings.HasPrefix(line, " "):
+			cur.old = append(cur.old, line[1:])
+			cur.new = append(cur.new, line[1:])
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		case line == "":
+			// Editors often strip the space from empty context lines.
+			cur.old = append(cur.old, "")
+			cur.new = append(cur.new, "")
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		default:
+			return nil, fmt.Errorf("unexpected line in hunk %s: %q", cur.header, truncate(line, 80))
+		}
+	}
+	// The trailing empty context lines added for the diff's final newline are not real.
+	for i := range hunks {
+		h := &hunks[i]
+		for len(h.old) > 0 && len(h.new) > 0 && h.old[len(h.old)-1] == "" && h.new[len(h.new)-1] == "" {
+			h.old, h.new = h.old[:len(h.old)-1], h.new[:len(h.new)-1]
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("diff contains no hunks (@@ ... @@)")
+	}
+	return hunks, nil
+}
+
+// hunkCount reads a line count from a hunk header; an omitted count is 1.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// applyUnifiedDiff locates each hunk by its context and removed lines. The
+// header's line number breaks ties; a hunk that matches several places
+// elsewhere, or nowhere, fails the whole patch.
+func applyUnifiedDiff(content, diff string) (string, int, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", 0, err
+	}
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	var out []string
+	next := 0 // first line not yet copied to out
+	for i, h := range hunks {
+		pos, err := locateHunk(lines, h, next)
+		if err != nil {
+			return "", i, fmt.Errorf("hunk %d (%s): %w", i+1, h.header, err)
+		}
+		out = append(out, lines[next:pos]...)
+		out = append(out, h.new...)
+		next = pos + len(h.old)
+	}
+	out = append(out, lines[next:]...)
+
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	return result, len(hunks), nil
+}
+
+func locateHunk(lines []string, h diffHunk, from int) (int, error) {
+	hint := h.oldStart - 1
+	if len(h.old) == 0 {
+		// Pure insertion: "@@ -N,0" inserts after line N.
+		pos := h.oldStart
+		if pos < from || pos > len(lines) {
+			return 0, fmt.Errorf("insertion point line %d is out of range", h.oldStart)
+		}
+		return pos, nil
+	}
+
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		var matches []int
+		for i := from; i+len(h.old) <= len(lines); i++ {
+			ok := true
+			for j, want := range h.old {
+				if !equal(lines[i+j], want) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				matches = append(matches, i)
+			}
+		}
+		switch {
+		case len(matches) == 1:
+			return matches[0], nil
+		case len(matches) > 1:
+			for _, m := range matches {
+				if m == hint {
+					return m, nil
+				}
+			}
+			nums := make([]string, 0, len(matches))
+			for _, m := range matches {
+				nums = append(nums, strconv.Itoa(m+1))
+			}
+			return 0, fmt.Errorf("context is ambiguous, it matches at lines %s; add more context lines", strings.Join(nums, ", "))
+		}
+	}
+	return 0, fmt.Errorf("context and removed lines not found (first line: %q); read the file again and regenerate the diff", truncate(h.old[0], 80))
+}
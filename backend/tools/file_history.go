@@ -0,0 +1,211 @@
+/**
+ * File history tools module.
+ *
+ * file_history lists the saved versions of a file that NIRA changed;
+ * file_undo restores one of them. Restoring is itself backed up, so an
+ * undo can be undone too.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: file_history.go
+ * Description: File version listing and restore tools.
+ */
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"nira/memory"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// maxUndoDiff bounds the content size for which file_undo reports a diff.
+const maxUndoDiff = 1 << 20
+
+type fileHistoryArgs struct {
+	Path  string `json:"path" desc:"The file whose versions to list" required:"true"`
+	Limit int    `json:"limit" desc:"Max versions" default:"20"`
+}
+
+type fileUndoArgs struct {
+	Path      string `json:"path" desc:"The file to restore" required:"true"`
+	VersionID int64  `json:"version_id" desc:"Version to restore (default: the most recent one)"`
+}
+
+// FileHistoryTool lists earlier versions of a file.
+type FileHistoryTool struct {
+	versions *memory.FileVersionStore
+	checker  PathChecker
+}
+
+func NewFileHistoryTool(versions *memory.FileVersionStore, checker PathChecker) *FileHistoryTool {
+	return &FileHistoryTool{versions: versions, checker: checker}
+}
+
+func (t *FileHistoryTool) Name() string           { return "file_history" }
+func (t *FileHistoryTool) Permission() Permission { return PermissionRead }
+func (t *FileHistoryTool) Description() string {
+	return "Lists saved earlier versions of a file changed by NIRA (newest first): id, created_at, tool, size, sha256, existed, and whether it matches the current content. Args: path (string), limit (int)."
+}
+func (t *FileHistoryTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), fileHistoryArgs{})
+}
+func (t *FileHistoryTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a fileHistoryArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkHistoryAccess(t.checker, a.Path, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	versions, err := t.versions.List(a.Path, a.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	currentSum, exists := fileSHA256(a.Path)
+	list := make([]map[string]interface{}, 0, len(versions))
+	for _, v := range versions {
+		list = append(list, map[string]interface{}{
+			"id":              v.ID,
+			"created_at":      v.CreatedAt,
+			"tool":            v.Tool,
+			"existed":         v.Existed,
+			"size":            v.Size,
+			"sha256":          v.SHA256,
+			"matches_current": v.Existed == exists && (!exists || v.SHA256 == currentSum),
+		})
+	}
+	return map[string]interface{}{
+		"path":     a.Path,
+		"exists":   exists,
+		"versions": list,
+	}, nil
+}
+
+// FileUndoTool restores a saved version of a file.
+type FileUndoTool struct {
+	versions *memory.FileVersionStore
+	checker  PathChecker
+}
+
+func NewFileUndoTool(versions *memory.FileVersionStore, checker PathChecker) *FileUndoTool {
+	return &FileUndoTool{versions: versions, checker: checker}
+}
+
+func (t *FileUndoTool) Name() string           { return "file_undo" }
+func (t *FileUndoTool) Permission() Permission { return PermissionWrite }
+func (t *FileUndoTool) Description() string {
+	return "Restores a file to a version saved before NIRA changed it (default: the most recent). A file that did not exist in that version is deleted. The current content is saved first, so the undo can itself be undone. Args: path (string), version_id (int, optional)."
+}
+func (t *FileUndoTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), fileUndoArgs{})
+}
+func (t *FileUndoTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a fileUndoArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkHistoryAccess(t.checker, a.Path, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	version, err := t.versions.Get(a.Path, a.VersionID)
+	if errors.Is(err, memory.ErrVersionNotFound) {
+		if a.VersionID > 0 {
+			return nil, fmt.Errorf("version %d not found for '%s'; use file_history to list versions", a.VersionID, a.Path)
+		}
+		return nil, fmt.Errorf("no saved versions for '%s'", a.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPreviewBase(args, a.Path); err != nil {
+		return nil, err
+	}
+
+	current, readErr := os.ReadFile(a.Path)
+	exists := readErr == nil
+	if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read current file: %w", readErr)
+	}
+
+	result := map[string]interface{}{
+		"path":             a.Path,
+		"restored_version": version.ID,
+	}
+	var backupID int64
+	switch {
+	case !version.Existed && !exists:
+		result["action"] = "unchanged"
+		return result, nil
+	case !version.Existed:
+		if backupID, err = safeRemoveFile(t.versions, a.Path, t.Name()); err != nil {
+			return nil, err
+		}
+		result["action"] = "deleted"
+	default:
+		if !exists {
+			if err := os.MkdirAll(filepath.Dir(a.Path), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directories: %w", err)
+			}
+		}
+		if backupID, err = safeWriteFile(t.versions, a.Path, version.Content, t.Name()); err != nil {
+			return nil, err
+		}
+		result["action"] = "restored"
+	}
+	result["backup_version"] = backupID
+
+	if len(current) <= maxUndoDiff && len(version.Content) <= maxUndoDiff && utf8.Valid(current) && utf8.Valid(version.Content) {
+		result["diff"] = UnifiedDiff(filepath.Base(a.Path), string(current), string(version.Content))
+	}
+	return result, nil
+}
+
+// Preview reports the change restoring the version would make.
+func (t *FileUndoTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a fileUndoArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkHistoryAccess(t.checker, a.Path, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	version, err := t.versions.Get(a.Path, a.VersionID)
+	if err != nil {
+		return nil, err
+	}
+	after := version.Content
+	if !version.Existed {
+		after = nil
+	} else if after == nil {
+		after = []byte{}
+	}
+	p, err := previewChange(a.Path, after)
+	if err != nil {
+		return nil, err
+	}
+	return []FilePreview{p}, nil
+}
+
+// checkHistoryAccess requires a checker: the history tools have no static
+// roots to fall back to.
+func checkHistoryAccess(checker PathChecker, path string, access sandbox.Access) error {
+	if checker == nil {
+		return fmt.Errorf("path '%s' is %w", path, sandbox.ErrNotAllowed)
+	}
+	return checkPathAccess(checker, nil, path, access)
+}
+
+func fileSHA256(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return contentSHA256(data), true
+}
@@ -0,0 +1,610 @@
+/**
+ * File management tools module.
+ *
+ * move_path, copy_path, delete_path and make_directory let the model
+ * reorganize files inside the allowed directories. Sources and
+ * destinations are both checked against the sandbox, allowed roots
+ * themselves can never be moved or deleted, and anything deleted or
+ * overwritten is moved to the trash so it can be restored.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: file_manage.go
+ * Description: Move, copy, delete and mkdir tools.
+ */
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"nira/memory"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+type movePathArgs struct {
+	Source      string `json:"source" desc:"File or directory to move" required:"true"`
+	Destination string `json:"destination" desc:"New path, including the name" required:"true"`
+	Overwrite   bool   `json:"overwrite" desc:"Replace an existing destination (the old one goes to the trash)"`
+	CreateDirs  bool   `json:"create_dirs" desc:"Create missing parent directories of the destination"`
+}
+
+type copyPathArgs struct {
+	Source      string `json:"source" desc:"File or directory to copy" required:"true"`
+	Destination string `json:"destination" desc:"Path of the copy, including the name" required:"true"`
+	Overwrite   bool   `json:"overwrite" desc:"Replace an existing destination (the old one goes to the trash)"`
+	CreateDirs  bool   `json:"create_dirs" desc:"Create missing parent directories of the destination"`
+}
+
+type deletePathArgs struct {
+	Path string `json:"path" desc:"File or directory to move to the trash" required:"true"`
+}
+
+type makeDirectoryArgs struct {
+	Path    string `json:"path" desc:"Directory to create" required:"true"`
+	Parents bool   `json:"parents" desc:"Create missing parent directories too" default:"true"`
+}
+
+// fileManager holds what the file management tools share.
+type fileManager struct {
+	AllowedPaths []string
+	checker      PathChecker
+	// Trash receives deleted and overwritten paths; nil refuses to delete.
+	Trash *memory.TrashStore
+}
+
+// rootLister is implemented by checkers that can list their roots, such as
+// memory.AllowedDirsStore.
+type rootLister interface {
+	List() []string
+}
+
+// protectedRoot returns the allowed root that path is, or contains. Such
+// paths must not be moved or deleted.
+func (m *fileManager) protectedRoot(path string) (string, bool) {
+	roots := m.AllowedPaths
+	if l, ok := m.checker.(rootLister); ok {
+		roots = l.List()
+	}
+	for _, r := range roots {
+		if sandbox.Within(path, r) {
+			return r, true
+		}
+	}
+	if m.Trash != nil && m.Trash.Dir != "" && sandbox.Within(path, m.Trash.Dir) {
+		return m.Trash.Dir, true
+	}
+	return "", false
+}
+
+// checkRemovable verifies that path may be taken away from where it is.
+func (m *fileManager) checkRemovable(path string) error {
+	if err := checkPathAccess(m.checker, m.AllowedPaths, path, sandbox.AccessWrite); err != nil {
+		return err
+	}
+	if root, ok := m.protectedRoot(path); ok {
+		return fmt.Errorf("refusing to move or delete '%s': it is or contains the protected directory '%s'", path, root)
+	}
+	return nil
+}
+
+// prepareDestination checks dst for writing and clears the way for it: an
+// existing destination is trashed when overwrite is set, and missing
+// parents are created when createDirs is set. It returns the trash ID of
+// a replaced destination, or 0.
+func (m *fileManager) prepareDestination(src, dst string, overwrite, createDirs bool, tool string) (int64, error) {
+	if err := checkPathAccess(m.checker, m.AllowedPaths, dst, sandbox.AccessWrite); err != nil {
+		return 0, err
+	}
+	dstInfo, err := os.Lstat(dst)
+	exists := err == nil
+	if srcInfo, err := os.Lstat(src); err == nil && exists && os.SameFile(srcInfo, dstInfo) {
+		if tool == "move_path" {
+			return 0, nil // a rename that only changes case
+		}
+		return 0, fmt.Errorf("source and destination are the same file")
+	}
+	if sandbox.Within(src, dst) {
+		return 0, fmt.Errorf("destination '%s' is inside the source '%s'", dst, src)
+	}
+	parent := filepath.Dir(dst)
+	if info, err := os.Stat(parent); err != nil {
+		if !os.IsNotExist(err) || !createDirs {
+			return 0, fmt.Errorf("destination directory '%s' does not exist (set create_dirs to create it)", parent)
+		}
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create destination directory: %w", err)
+		}
+	} else if !info.IsDir() {
+		return 0, fmt.Errorf("destination parent '%s' is not a directory", parent)
+	}
+	if !exists {
+		return 0, nil
+	}
+	if !overwrite {
+		return 0, fmt.Errorf("destination '%s' already exists (set overwrite to replace it)", dst)
+	}
+	if err := m.checkRemovable(dst); err != nil {
+		return 0, err
+	}
+	return m.trash(dst, tool)
+}
+
+// previewDestination describes what happens at dst when incoming is moved
+// or copied there, applying the checks of prepareDestination without
+// changing anything. It reports false when dst needs no preview (a rename
+// that only changes case).
+func (m *fileManager) previewDestination(src, dst string, overwrite, createDirs bool, incoming, tool string) (FilePreview, bool, error) {
+	if err := checkPathAccess(m.checker, m.AllowedPaths, dst, sandbox.AccessWrite); err != nil {
+		return FilePreview{}, false, err
+	}
+	dstInfo, err := os.Lstat(dst)
+	exists := err == nil
+	if srcInfo, err := os.Lstat(src); err == nil && exists && os.SameFile(srcInfo, dstInfo) {
+		if tool == "move_path" {
+			return FilePreview{}, false, nil
+		}
+		return FilePreview{}, false, fmt.Errorf("source and destination are the same file")
+	}
+	if sandbox.Within(src, dst) {
+		return FilePreview{}, false, fmt.Errorf("destination '%s' is inside the source '%s'", dst, src)
+	}
+	if exists {
+		if !overwrite {
+			return FilePreview{}, false, fmt.Errorf("destination '%s' already exists (set overwrite to replace it)", dst)
+		}
+		if err := m.checkRemovable(dst); err != nil {
+			return FilePreview{}, false, err
+		}
+		old, err := describePath(dst)
+		if err != nil {
+			return FilePreview{}, false, err
+		}
+		return previewPath(dst, "replace", fmt.Sprintf("replaced by %s; the existing %s goes to the trash", incoming, old)), true, nil
+	}
+	summary := "receives " + incoming
+	if missing := missingParents(dst); len(missing) > 0 {
+		if !createDirs {
+			return FilePreview{}, false, fmt.Errorf("destination directory '%s' does not exist (set create_dirs to create it)", filepath.Dir(dst))
+		}
+		summary += "; creates " + strings.Join(missing, ", ")
+	}
+	return previewPath(dst, "create", summary), true, nil
+}
+
+// missingParents lists the directories above path that do not exist yet,
+// outermost first.
+func missingParents(path string) []string {
+	var missing []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil || dir == filepath.Dir(dir) {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+	}
+	return missing
+}
+
+// trash moves path into the trash and records it.
+func (m *fileManager) trash(path, tool string) (int64, error) {
+	if m.Trash == nil {
+		return 0, errors.New("no trash is configured; refusing to delete")
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat path: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return 0, fmt.Errorf("invalid path: %w", err)
+	}
+	dest, err := m.Trash.Reserve(filepath.Base(abs))
+	if err != nil {
+		return 0, err
+	}
+	size := treeSize(path)
+	if err := movePath(path, dest); err != nil {
+		return 0, fmt.Errorf("failed to move '%s' to the trash: %w", path, err)
+	}
+	id, err := m.Trash.Add(memory.TrashItem{OriginalPath: filepath.Clean(abs), TrashPath: dest, IsDir: info.IsDir(), Size: size, Tool: tool})
+	if err != nil {
+		// Put it back rather than lose track of it.
+		if rerr := movePath(dest, path); rerr != nil {
+			return 0, fmt.Errorf("%v; the entry remains at %s", err, dest)
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// MovePathTool moves or renames a file or directory.
+type MovePathTool struct{ fileManager }
+
+func NewMovePathTool(allowedPaths []string) *MovePathTool {
+	return &MovePathTool{fileManager{AllowedPaths: allowedPaths}}
+}
+
+func NewMovePathToolWithChecker(allowedPaths []string, checker PathChecker) *MovePathTool {
+	return &MovePathTool{fileManager{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *MovePathTool) Name() string           { return "move_path" }
+func (t *MovePathTool) Permission() Permission { return PermissionWrite }
+func (t *MovePathTool) Description() string {
+	return "Moves or renames a file or directory. Both paths must be writable allowed paths; allowed roots cannot be moved. Args: source (string), destination (string, the full new path), overwrite (bool), create_dirs (bool)."
+}
+func (t *MovePathTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), movePathArgs{})
+}
+
+func (t *MovePathTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a movePathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := t.checkRemovable(a.Source); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewBases(args, a.Source, a.Destination); err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(a.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	replaced, err := t.prepareDestination(a.Source, a.Destination, a.Overwrite, a.CreateDirs, t.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := movePath(a.Source, a.Destination); err != nil {
+		return nil, fmt.Errorf("failed to move '%s': %w", a.Source, err)
+	}
+	res := map[string]interface{}{
+		"source":      a.Source,
+		"destination": a.Destination,
+		"is_dir":      info.IsDir(),
+	}
+	if replaced > 0 {
+		res["replaced_trash_id"] = replaced
+	}
+	return res, nil
+}
+
+// Preview reports what would be moved and what it would replace.
+func (t *MovePathTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a movePathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := t.checkRemovable(a.Source); err != nil {
+		return nil, err
+	}
+	what, err := describePath(a.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	previews := []FilePreview{previewPath(a.Source, "move", fmt.Sprintf("moves this %s to %s", what, a.Destination))}
+	dst, ok, err := t.previewDestination(a.Source, a.Destination, a.Overwrite, a.CreateDirs, fmt.Sprintf("%s (%s)", a.Source, what), t.Name())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		previews = append(previews, dst)
+	}
+	return previews, nil
+}
+
+// CopyPathTool copies a file or directory tree.
+type CopyPathTool struct{ fileManager }
+
+func NewCopyPathTool(allowedPaths []string) *CopyPathTool {
+	return &CopyPathTool{fileManager{AllowedPaths: allowedPaths}}
+}
+
+func NewCopyPathToolWithChecker(allowedPaths []string, checker PathChecker) *CopyPathTool {
+	return &CopyPathTool{fileManager{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *CopyPathTool) Name() string           { return "copy_path" }
+func (t *CopyPathTool) Permission() Permission { return PermissionWrite }
+func (t *CopyPathTool) Description() string {
+	return "Copies a file or a directory tree. The source must be readable and the destination writable; entries the sandbox hides are skipped. Args: source (string), destination (string, the full path of the copy), overwrite (bool), create_dirs (bool)."
+}
+func (t *CopyPathTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), copyPathArgs{})
+}
+
+func (t *CopyPathTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a copyPathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Source, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewBase(args, a.Destination); err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(a.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	replaced, err := t.prepareDestination(a.Source, a.Destination, a.Overwrite, a.CreateDirs, t.Name())
+	if err != nil {
+		return nil, err
+	}
+	readable := func(p string) bool {
+		return checkPathAccess(t.checker, t.AllowedPaths, p, sandbox.AccessRead) == nil
+	}
+	stats, err := copyTree(a.Source, a.Destination, readable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy '%s': %w", a.Source, err)
+	}
+	res := map[string]interface{}{
+		"source":      a.Source,
+		"destination": a.Destination,
+		"is_dir":      info.IsDir(),
+		"files":       stats.files,
+		"bytes":       stats.bytes,
+		"skipped":     stats.skipped,
+	}
+	if replaced > 0 {
+		res["replaced_trash_id"] = replaced
+	}
+	return res, nil
+}
+
+// Preview reports what the copy would create or replace.
+func (t *CopyPathTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a copyPathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Source, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	what, err := describePath(a.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source: %w", err)
+	}
+	dst, _, err := t.previewDestination(a.Source, a.Destination, a.Overwrite, a.CreateDirs, fmt.Sprintf("a copy of %s (%s)", a.Source, what), t.Name())
+	if err != nil {
+		return nil, err
+	}
+	return []FilePreview{dst}, nil
+}
+
+// DeletePathTool moves a file or directory to the trash.
+type DeletePathTool struct{ fileManager }
+
+func NewDeletePathTool(allowedPaths []string) *DeletePathTool {
+	return &DeletePathTool{fileManager{AllowedPaths: allowedPaths}}
+}
+
+func NewDeletePathToolWithChecker(allowedPaths []string, checker PathChecker) *DeletePathTool {
+	return &DeletePathTool{fileManager{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *DeletePathTool) Name() string           { return "delete_path" }
+func (t *DeletePathTool) Permission() Permission { return PermissionDestructive }
+func (t *DeletePathTool) Description() string {
+	return "Deletes a file or directory by moving it to the trash; trash_restore brings it back. Allowed roots cannot be deleted. Args: path (string)."
+}
+func (t *DeletePathTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), deletePathArgs{})
+}
+
+func (t *DeletePathTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a deletePathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := t.checkRemovable(a.Path); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewBase(args, a.Path); err != nil {
+		return nil, err
+	}
+	id, err := t.trash(a.Path, t.Name())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": a.Path, "trash_id": id}, nil
+}
+
+// Preview reports what would go to the trash, with a diff for a file.
+func (t *DeletePathTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a deletePathArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := t.checkRemovable(a.Path); err != nil {
+		return nil, err
+	}
+	what, err := describePath(a.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	summary := fmt.Sprintf("moves this %s to the trash; trash_restore brings it back", what)
+	if info, err := os.Lstat(a.Path); err == nil && info.Mode().IsRegular() {
+		p, err := previewChange(a.Path, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.Summary = summary
+		return []FilePreview{p}, nil
+	}
+	return []FilePreview{previewPath(a.Path, "delete", summary)}, nil
+}
+
+// MakeDirectoryTool creates a directory.
+type MakeDirectoryTool struct{ fileManager }
+
+func NewMakeDirectoryTool(allowedPaths []string) *MakeDirectoryTool {
+	return &MakeDirectoryTool{fileManager{AllowedPaths: allowedPaths}}
+}
+
+func NewMakeDirectoryToolWithChecker(allowedPaths []string, checker PathChecker) *MakeDirectoryTool {
+	return &MakeDirectoryTool{fileManager{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *MakeDirectoryTool) Name() string           { return "make_directory" }
+func (t *MakeDirectoryTool) Permission() Permission { return PermissionWrite }
+func (t *MakeDirectoryTool) Description() string {
+	return "Creates a directory inside a writable allowed directory. Args: path (string), parents (bool, default true: create missing parents too)."
+}
+func (t *MakeDirectoryTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), makeDirectoryArgs{})
+}
+
+func (t *MakeDirectoryTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a makeDirectoryArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewBase(args, a.Path); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(a.Path); err == nil {
+		if !info.IsDir() {
+			return nil, fmt.Errorf("'%s' exists and is not a directory", a.Path)
+		}
+		return map[string]interface{}{"path": a.Path, "created": false}, nil
+	}
+	mkdir := os.Mkdir
+	if a.Parents {
+		mkdir = os.MkdirAll
+	}
+	if err := mkdir(a.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return map[string]interface{}{"path": a.Path, "created": true}, nil
+}
+
+// Preview reports the directories that would be created.
+func (t *MakeDirectoryTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a makeDirectoryArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(a.Path); err == nil {
+		if !info.IsDir() {
+			return nil, fmt.Errorf("'%s' exists and is not a directory", a.Path)
+		}
+		return []FilePreview{previewPath(a.Path, "unchanged", "directory already exists")}, nil
+	}
+	summary := "new directory"
+	if missing := missingParents(a.Path); len(missing) > 0 {
+		if !a.Parents {
+			return nil, fmt.Errorf("parent directory '%s' does not exist (set parents to create it)", filepath.Dir(a.Path))
+		}
+		summary += "; also creates " + strings.Join(missing, ", ")
+	}
+	return []FilePreview{previewPath(a.Path, "create", summary)}, nil
+}
+
+// movePath renames src to dst, copying and removing when they are on
+// different filesystems.
+func movePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if _, err := copyTree(src, dst, nil); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+type copyStats struct {
+	files, bytes int64
+	skipped      int
+}
+
+// copyTree copies src to dst, preserving permissions and recreating
+// symlinks as links. Entries for which include returns false are skipped;
+// a nil include copies everything.
+func copyTree(src, dst string, include func(string) bool) (copyStats, error) {
+	var stats copyStats
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != src && include != nil && !include(p) {
+			stats.skipped++
+			return skipEntry(d)
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			n, err := copyFile(p, target, info.Mode().Perm())
+			stats.files++
+			stats.bytes += n
+			return err
+		default:
+			stats.skipped++ // devices, sockets and pipes
+			return nil
+		}
+	})
+	return stats, err
+}
+
+func copyFile(src, dst string, perm fs.FileMode) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// treeSize totals the regular files at or below path.
+func treeSize(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
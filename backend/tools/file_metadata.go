@@ -1,12 +1,34 @@
 package tools
 
 import (
+    "crypto/sha256"
+    "encoding/hex"
     "fmt"
+    "io"
+    "io/fs"
+    "mime"
+    "net/http"
+    "nira/sandbox"
     "os"
     "path/filepath"
+    "strings"
     "time"
 )
 
+// maxSummaryEntries bounds the recursive walk behind dir_summary.
+const maxSummaryEntries = 100000
+
+type fileMetadataArgs struct {
+    Path       string `json:"path" desc:"Path to file or directory" required:"true"`
+    MIME       bool   `json:"mime" desc:"Detect the MIME type"`
+    LineCount  bool   `json:"line_count" desc:"Count lines (text files only)"`
+    SHA256     bool   `json:"sha256" desc:"Compute the SHA-256 of the file contents"`
+    Owner      bool   `json:"owner" desc:"Include Unix permissions, owner and group"`
+    DirSummary bool   `json:"dir_summary" desc:"For directories, count children and total the size of everything below"`
+    GitStatus  bool   `json:"git_status" desc:"Report git tracking status when the path is inside a repository"`
+    All        bool   `json:"all" desc:"Enable every option above"`
+}
+
 // FileMetadataTool returns basic metadata for a given path.
 type FileMetadataTool struct {
     AllowedPaths []string
@@ -19,66 +41,287 @@ func NewFileMetadataToolWithChecker(allowedPaths []string, checker PathChecker)
 }
 
 func (t *FileMetadataTool) Name() string { return "file_metadata" }
+func (t *FileMetadataTool) Permission() Permission { return PermissionRead }
 
 func (t *FileMetadataTool) Description() string {
-    return "Returns basic metadata for a file or directory. Args: path (string)."
+    return "Returns metadata for a file or directory: name, size, modification time and symlink target, plus optional details. Args: path (string), mime, line_count, sha256, owner, dir_summary, git_status, all (bool)."
 }
 
 func (t *FileMetadataTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "path": map[string]interface{}{
-                    "type":        "string",
-                    "description": "Path to file or directory",
-                },
-            },
-            "required": []string{"path"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), fileMetadataArgs{})
 }
 
 func (t *FileMetadataTool) Execute(args map[string]interface{}) (interface{}, error) {
-    path, ok := args["path"].(string)
-    if !ok {
-        return nil, fmt.Errorf("path argument is required and must be a string")
+    var a fileMetadataArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
     }
-    if !t.isPathAllowed(path) {
-        return nil, fmt.Errorf("path '%s' is not in allowed directories", path)
+    if a.All {
+        a.MIME, a.LineCount, a.SHA256, a.Owner, a.DirSummary, a.GitStatus = true, true, true, true, true, true
     }
-    info, err := os.Stat(path)
+    path := a.Path
+    if err := checkPathAccess(t.checker, t.AllowedPaths, path, sandbox.AccessRead); err != nil {
+        return nil, err
+    }
+    linfo, err := os.Lstat(path)
     if err != nil {
         return nil, fmt.Errorf("failed to stat path: %w", err)
     }
+    info := linfo
+    res := map[string]interface{}{"is_symlink": false}
+    if linfo.Mode()&os.ModeSymlink != 0 {
+        target, _ := os.Readlink(path)
+        res["is_symlink"] = true
+        res["symlink_target"] = target
+        if info, err = os.Stat(path); err != nil {
+            // A dangling link: describe the link itself.
+            info = linfo
+            res["symlink_broken"] = true
+        }
+    }
     abs, _ := filepath.Abs(path)
-    mod := info.ModTime().UTC().Format(time.RFC3339)
-    res := map[string]interface{}{
-        "name":     info.Name(),
-        "path":     path,
-        "abs_path": abs,
-        "is_dir":   info.IsDir(),
-        "size":     func() int64 { if info.IsDir() { return 0 }; return info.Size() }(),
-        "mod_time": mod,
+    res["name"] = filepath.Base(abs)
+    res["path"] = path
+    res["abs_path"] = abs
+    res["is_dir"] = info.IsDir()
+    res["size"] = func() int64 { if info.IsDir() { return 0 }; return info.Size() }()
+    res["mod_time"] = info.ModTime().UTC().Format(time.RFC3339)
+
+    regular := info.Mode().IsRegular()
+    if a.MIME {
+        res["mime_type"] = detectMIME(path, info)
+    }
+    if a.LineCount && regular {
+        if enc, lines, err := countFileLines(path, info.Size()); err != nil {
+            res["line_count_error"] = err.Error()
+        } else if enc == encodingBinary {
+            res["binary"] = true
+        } else {
+            res["encoding"] = enc
+            res["line_count"] = lines
+        }
+    }
+    if a.SHA256 && regular {
+        if sum, err := streamSHA256(path); err != nil {
+            res["sha256_error"] = err.Error()
+        } else {
+            res["sha256"] = sum
+        }
+    }
+    if a.Owner {
+        res["mode"] = info.Mode().String()
+        res["permissions"] = fmt.Sprintf("%04o", info.Mode().Perm())
+        addOwner(res, info)
+    }
+    if a.DirSummary && info.IsDir() {
+        t.summarizeDir(path, res)
+    }
+    if a.GitStatus {
+        res["git"] = gitPathStatus(abs, info.IsDir())
     }
     return res, nil
 }
 
-func (t *FileMetadataTool) isPathAllowed(path string) bool {
-    if t.checker != nil { return t.checker.IsAllowed(path) }
-    if len(t.AllowedPaths) == 0 { return false }
-    absPath, err := filepath.Abs(path)
-    if err != nil { return false }
-    for _, allowed := range t.AllowedPaths {
-        absAllowed, err := filepath.Abs(allowed)
-        if err != nil { continue }
-        rel, err := filepath.Rel(absAllowed, absPath)
-        if err != nil { continue }
-        if rel != ".." && !filepath.IsAbs(rel) {
-            return true
+// detectMIME guesses a MIME type from the extension, falling back to
+// sniffing the first 512 bytes.
+func detectMIME(path string, info os.FileInfo) string {
+    if info.IsDir() {
+        return "inode/directory"
+    }
+    if !info.Mode().IsRegular() {
+        return "application/octet-stream"
+    }
+    if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+        return t
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return "application/octet-stream"
+    }
+    defer f.Close()
+    head := make([]byte, 512)
+    n, _ := io.ReadFull(f, head)
+    return http.DetectContentType(head[:n])
+}
+
+// countFileLines detects the encoding of a file and counts its lines,
+// returning encodingBinary without counting for binary files.
+func countFileLines(path string, size int64) (string, int, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", 0, err
+    }
+    defer f.Close()
+    head := make([]byte, encodingSniffLen)
+    n, err := io.ReadFull(f, head)
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+        return "", 0, err
+    }
+    enc, bom := detectEncoding(head[:n], int64(n) < size)
+    if enc == encodingBinary {
+        return enc, 0, nil
+    }
+    if _, err := f.Seek(int64(bom), io.SeekStart); err != nil {
+        return "", 0, err
+    }
+    lines, err := countLines(decodingReader(f, enc))
+    return enc, lines, err
+}
+
+// streamSHA256 hashes a file without reading it into memory.
+func streamSHA256(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// summarizeDir counts the direct children of dir and totals the files
+// below it, skipping entries the sandbox would not let the caller read.
+func (t *FileMetadataTool) summarizeDir(dir string, res map[string]interface{}) {
+    var files, dirs, other int
+    if entries, err := os.ReadDir(dir); err == nil {
+        for _, e := range entries {
+            if checkPathAccess(t.checker, t.AllowedPaths, filepath.Join(dir, e.Name()), sandbox.AccessRead) != nil {
+                continue
+            }
+            switch {
+            case e.IsDir():
+                dirs++
+            case e.Type().IsRegular():
+                files++
+            default:
+                other++
+            }
+        }
+    }
+    res["children"] = map[string]int{"files": files, "dirs": dirs, "other": other}
+
+    var totalSize int64
+    var totalFiles, seen int
+    truncated := false
+    filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+        if err != nil || p == dir {
+            return nil
+        }
+        if seen++; seen > maxSummaryEntries {
+            truncated = true
+            return filepath.SkipAll
+        }
+        if checkPathAccess(t.checker, t.AllowedPaths, p, sandbox.AccessRead) != nil {
+            return skipEntry(d)
         }
+        if d.Type().IsRegular() {
+            if fi, err := d.Info(); err == nil {
+                totalSize += fi.Size()
+                totalFiles++
+            }
+        }
+        return nil
+    })
+    res["total_size"] = totalSize
+    res["total_files"] = totalFiles
+    res["summary_truncated"] = truncated
+}
+
+// gitPathStatus reports whether path is tracked by the repository around
+// it and how it differs from HEAD. Problems are reported in the result
+// rather than failing the whole metadata call.
+func gitPathStatus(abs string, isDir bool) map[string]interface{} {
+    dir := abs
+    if !isDir {
+        dir = filepath.Dir(abs)
+    }
+    realDir, err := filepath.EvalSymlinks(dir)
+    if err != nil {
+        return map[string]interface{}{"in_repo": false, "error": err.Error()}
+    }
+    root, err := gitRepoRoot(realDir)
+    if err == errGitNotFound {
+        return map[string]interface{}{"in_repo": false, "error": err.Error()}
+    }
+    if err != nil {
+        return map[string]interface{}{"in_repo": false}
+    }
+    target := realDir
+    if !isDir {
+        target = filepath.Join(realDir, filepath.Base(abs))
+    }
+    rel, err := filepath.Rel(root, target)
+    if err != nil {
+        return map[string]interface{}{"in_repo": false, "error": err.Error()}
+    }
+    rel = filepath.ToSlash(rel)
+    out := map[string]interface{}{"in_repo": true, "repo_root": root}
+    if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+        out["status"] = "git_internal"
+        out["tracked"] = false
+        return out
+    }
+
+    spec := ":(literal)" + rel
+    if rel == "." {
+        spec = "."
+    }
+    status, _, err := runGit(root, "status", "--porcelain=v1", "--ignored", "--untracked-files=all", "-z", "--", spec)
+    if err != nil {
+        out["error"] = err.Error()
+        return out
+    }
+    var staged, modified, untracked, ignored int
+    for _, rec := range strings.Split(status, "\x00") {
+        if len(rec) < 3 || rec[2] != ' ' {
+            continue // empty, or the source path of a rename
+        }
+        x, y := rec[0], rec[1]
+        switch {
+        case x == '?' && y == '?':
+            untracked++
+        case x == '!' && y == '!':
+            ignored++
+        default:
+            if x != ' ' {
+                staged++
+            }
+            if y != ' ' {
+                modified++
+            }
+        }
+    }
+
+    tracked, _, err := runGit(root, "ls-files", "-z", "--", spec)
+    out["tracked"] = err == nil && tracked != ""
+    if isDir {
+        out["staged"] = staged
+        out["modified"] = modified
+        out["untracked"] = untracked
+        out["ignored"] = ignored
+        if staged+modified+untracked == 0 {
+            out["status"] = "clean"
+        } else {
+            out["status"] = "dirty"
+        }
+        return out
+    }
+    switch {
+    case untracked > 0:
+        out["status"] = "untracked"
+    case ignored > 0:
+        out["status"] = "ignored"
+    case staged > 0 && modified > 0:
+        out["status"] = "staged_and_modified"
+    case staged > 0:
+        out["status"] = "staged"
+    case modified > 0:
+        out["status"] = "modified"
+    default:
+        out["status"] = "clean"
     }
-    return false
+    return out
 }
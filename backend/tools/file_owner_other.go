@@ -0,0 +1,8 @@
+//go:build !unix
+
+package tools
+
+import "os"
+
+// addOwner is a no-op where files have no Unix owner.
+func addOwner(res map[string]interface{}, info os.FileInfo) {}
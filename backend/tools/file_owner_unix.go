@@ -0,0 +1,29 @@
+//go:build unix
+
+package tools
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// addOwner adds the owning user and group of a file to res.
+func addOwner(res map[string]interface{}, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	uid, gid := strconv.FormatUint(uint64(st.Uid), 10), strconv.FormatUint(uint64(st.Gid), 10)
+	res["uid"] = st.Uid
+	res["gid"] = st.Gid
+	res["owner"] = uid
+	if u, err := user.LookupId(uid); err == nil {
+		res["owner"] = u.Username
+	}
+	res["group"] = gid
+	if g, err := user.LookupGroupId(gid); err == nil {
+		res["group"] = g.Name
+	}
+}
@@ -13,12 +13,34 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"nira/sandbox"
 	"os"
-	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
+const (
+	// defaultReadMaxBytes bounds the content returned when max_bytes is unset.
+	defaultReadMaxBytes = 128 * 1024
+	// maxTailLines bounds the tail argument.
+	maxTailLines = 10000
+)
+
+type readFileArgs struct {
+	Path      string `json:"path" desc:"The file path to read" required:"true"`
+	StartLine int    `json:"start_line" desc:"First line to return (1-based)"`
+	EndLine   int    `json:"end_line" desc:"Last line to return, inclusive (default: through the end)"`
+	Head      int    `json:"head" desc:"Return only the first N lines"`
+	Tail      int    `json:"tail" desc:"Return only the last N lines"`
+	Offset    int64  `json:"offset" desc:"Byte offset to start reading at; selects byte mode"`
+	Length    int64  `json:"length" desc:"Number of bytes to read from offset (byte mode)"`
+	MaxBytes  int    `json:"max_bytes" desc:"Maximum bytes of content to return; longer output is cut with a truncation marker" default:"131072"`
+}
+
 type FileReadTool struct {
     AllowedPaths []string
     checker      PathChecker
@@ -38,81 +60,333 @@ func (t *FileReadTool) Name() string {
 }
 
 func (t *FileReadTool) Description() string {
-	return "Reads the contents of a text file. Requires a file path as input."
+	return "Reads a text file. Large files are cut at max_bytes with a truncation marker; the result has total_lines, start_line and end_line so you can page with start_line/end_line, head or tail, or offset/length for bytes. UTF-16 and Latin-1 files are decoded. Args: path (string), start_line, end_line, head, tail, offset, length, max_bytes (int, optional)."
+}
+
+func (t *FileReadTool) Permission() Permission {
+	return PermissionRead
 }
 
 func (t *FileReadTool) Execute(args map[string]interface{}) (interface{}, error) {
-	path, ok := args["path"].(string)
-	if !ok {
-		return nil, fmt.Errorf("path argument is required and must be a string")
+	var a readFileArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	path := a.Path
+
+	if err := checkPathAccess(t.checker, t.AllowedPaths, path, sandbox.AccessRead); err != nil {
+		return nil, err
 	}
 
- if !t.isPathAllowed(path) {
-        return nil, fmt.Errorf("path not in allowed directories")
-    }
+	byteMode := a.Offset > 0 || a.Length > 0
+	lineMode := a.StartLine != 0 || a.EndLine != 0 || a.Head != 0 || a.Tail != 0
+	switch {
+	case byteMode && lineMode:
+		return nil, fmt.Errorf("use either offset/length or line arguments, not both")
+	case a.Head != 0 && a.Tail != 0:
+		return nil, fmt.Errorf("use either head or tail, not both")
+	case a.Offset < 0 || a.Length < 0 || a.StartLine < 0 || a.EndLine < 0 || a.Head < 0 || a.Tail < 0:
+		return nil, fmt.Errorf("line and byte arguments must not be negative")
+	case a.EndLine != 0 && a.EndLine < a.StartLine:
+		return nil, fmt.Errorf("end_line must not be before start_line")
+	case a.Tail > maxTailLines:
+		return nil, fmt.Errorf("tail is limited to %d lines", maxTailLines)
+	}
+	if a.MaxBytes <= 0 {
+		a.MaxBytes = defaultReadMaxBytes
+	}
 
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
-
-	content, err := io.ReadAll(file)
+	info, err := file.Stat()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("'%s' is a directory; use list_directory", path)
+	}
+
+	head := make([]byte, encodingSniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	encoding, bom := detectEncoding(head[:n], int64(n) < info.Size())
+	if encoding == encodingBinary {
+		return nil, fmt.Errorf("'%s' looks like a binary file; use file_metadata to inspect it", path)
+	}
 
-	return map[string]interface{}{
-		"content": string(content),
-		"path":    path,
-	}, nil
+	result := map[string]interface{}{
+		"path":     path,
+		"encoding": encoding,
+		"size":     info.Size(),
+	}
+	if byteMode {
+		err = readByteRange(file, info.Size(), encoding, int64(bom), a, result)
+	} else {
+		err = readLineRange(file, int64(bom), encoding, a, result)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return result, nil
 }
 
-func (t *FileReadTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"path": map[string]interface{}{
-					"type":        "string",
-					"description": "The file path to read",
-				},
-			},
-			"required": []string{"path"},
-		},
+// readLineRange fills result with the requested lines, counting all lines
+// of the file on the way.
+func readLineRange(file *os.File, bom int64, encoding string, a readFileArgs, result map[string]interface{}) error {
+	if _, err := file.Seek(bom, io.SeekStart); err != nil {
+		return err
+	}
+	start, end := a.StartLine, a.EndLine
+	if start == 0 {
+		start = 1
+	}
+	if a.Head > 0 {
+		end = a.Head
+	}
+
+	r := bufio.NewReaderSize(decodingReader(file, encoding), 64*1024)
+	var (
+		out       bytes.Buffer
+		tail      [][]byte
+		tailBytes int
+		total     int
+		first     int
+		last      int
+		truncated bool
+	)
+	for {
+		limit := 0
+		collecting := a.Tail > 0 || (!truncated && (end == 0 || total < end))
+		if collecting {
+			// One byte past the budget shows that a line does not fit.
+			limit = a.MaxBytes + 1
+		}
+		line, cut, err := readCappedLine(r, limit)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == nil && err == io.EOF {
+			break
+		}
+		total++
+		switch {
+		case a.Tail > 0:
+			tail = append(tail, line)
+			tailBytes += len(line)
+			// Keep only the newest lines that can still be returned.
+			for len(tail) > a.Tail || (len(tail) > 1 && tailBytes-len(tail[0]) >= a.MaxBytes) {
+				tailBytes -= len(tail[0])
+				tail = tail[1:]
+			}
+		case collecting && total >= start:
+			if out.Len()+len(line) > a.MaxBytes || cut {
+				truncated = true
+				if out.Len() > 0 {
+					break
+				}
+				// A single line larger than the budget is returned in part.
+				out.Write(cutUTF8(line, a.MaxBytes))
+			} else {
+				out.Write(line)
+			}
+			if first == 0 {
+				first = total
+			}
+			last = total
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if a.Tail > 0 {
+		first = total - len(tail) + 1
+		last = total
+		if len(tail) > 0 && tailBytes > a.MaxBytes {
+			tail[0] = cutUTF8Start(tail[0], tailBytes-a.MaxBytes)
+			truncated = true
+		}
+		if len(tail) < a.Tail && first > 1 {
+			truncated = true
+		}
+		if truncated {
+			fmt.Fprintf(&out, "[... truncated: showing lines %d-%d of %d; earlier lines omitted ...]\n", first, last, total)
+		}
+		for _, l := range tail {
+			out.Write(l)
+		}
+		if len(tail) == 0 {
+			first = 0
+		}
+	} else if truncated {
+		if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+			out.WriteByte('\n')
+		}
+		fmt.Fprintf(&out, "[... truncated at max_bytes=%d: showing lines %d-%d of %d; continue with start_line=%d ...]\n", a.MaxBytes, first, last, total, last+1)
 	}
+
+	result["content"] = strings.ToValidUTF8(out.String(), "\uFFFD")
+	result["total_lines"] = total
+	result["start_line"] = first
+	result["end_line"] = last
+	result["truncated"] = truncated
+	return nil
 }
 
-func (t *FileReadTool) isPathAllowed(path string) bool {
-    if t.checker != nil {
-        return t.checker.IsAllowed(path)
-    }
-    if len(t.AllowedPaths) == 0 {
-        return false
-    }
+// readByteRange fills result with the bytes from the requested offset.
+func readByteRange(file *os.File, size int64, encoding string, bom int64, a readFileArgs, result map[string]interface{}) error {
+	offset := a.Offset
+	if offset < bom {
+		offset = bom
+	}
+	if (encoding == encodingUTF16LE || encoding == encodingUTF16BE) && (offset-bom)%2 != 0 {
+		offset--
+	}
+	if offset > size {
+		offset = size
+	}
+	wanted := size - offset
+	if a.Length > 0 && a.Length < wanted {
+		wanted = a.Length
+	}
+	length := wanted
+	if length > int64(a.MaxBytes) {
+		length = int64(a.MaxBytes)
+	}
+	truncated := length < wanted
 
-	absPath, err := filepath.Abs(path)
+	raw := make([]byte, length)
+	if _, err := file.ReadAt(raw, offset); err != nil && err != io.EOF {
+		return err
+	}
+	if encoding == encodingUTF8 {
+		// Do not start or end inside a multi-byte character.
+		for len(raw) > 0 && !utf8.RuneStart(raw[0]) {
+			raw = raw[1:]
+			offset++
+		}
+		if r, n := utf8.DecodeLastRune(raw); r == utf8.RuneError && n <= 1 && len(raw) > 0 && offset+int64(len(raw)) < size {
+			for i := len(raw) - 1; i >= 0 && len(raw)-i <= utf8.UTFMax; i-- {
+				if utf8.RuneStart(raw[i]) {
+					if !utf8.FullRune(raw[i:]) {
+						raw = raw[:i]
+					}
+					break
+				}
+			}
+		}
+	}
+	content, err := io.ReadAll(decodingReader(bytes.NewReader(raw), encoding))
 	if err != nil {
-    return false
+		return err
+	}
+
+	if _, err := file.Seek(bom, io.SeekStart); err != nil {
+		return err
+	}
+	total, err := countLines(decodingReader(file, encoding))
+	if err != nil {
+		return err
+	}
+
+	next := offset + int64(len(raw))
+	if truncated {
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			content = append(content, '\n')
+		}
+		content = append(content, fmt.Sprintf("[... truncated at max_bytes=%d; continue with offset=%d ...]\n", a.MaxBytes, next)...)
+	}
+	result["content"] = strings.ToValidUTF8(string(content), "\uFFFD")
+	result["offset"] = offset
+	result["bytes_read"] = len(raw)
+	result["total_lines"] = total
+	result["truncated"] = truncated
+	if next < size {
+		result["next_offset"] = next
+	}
+	return nil
 }
 
-	for _, allowed := range t.AllowedPaths {
-		absAllowed, err := filepath.Abs(allowed)
-		if err != nil {
+// readCappedLine reads one line including its newline, keeping at most
+// limit bytes; cut reports that the line was longer. It returns a nil line
+// at the end of input.
+func readCappedLine(r *bufio.Reader, limit int) (line []byte, cut bool, err error) {
+	started := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(chunk) > 0 {
+			started = true
+		}
+		if room := limit - len(line); room > 0 {
+			if len(chunk) > room {
+				line = append(line, chunk[:room]...)
+				cut = true
+			} else {
+				line = append(line, chunk...)
+			}
+		} else if len(chunk) > 0 {
+			cut = cut || limit > 0
+		}
+		if err == bufio.ErrBufferFull {
 			continue
 		}
+		if started && line == nil {
+			line = []byte{}
+		}
+		return line, cut, err
+	}
+}
 
-		rel, err := filepath.Rel(absAllowed, absPath)
+// countLines counts lines, including a last line without a newline.
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	total := 0
+	var last byte = '\n'
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			total += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			if last != '\n' {
+				total++
+			}
+			return total, nil
+		}
 		if err != nil {
-			continue
+			return total, err
 		}
+	}
+}
 
-		if rel != ".." && !filepath.IsAbs(rel) {
-			return true
-		}
+// cutUTF8 returns at most n bytes of b without splitting a character.
+func cutUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
 	}
+	return b[:n]
+}
 
-	return false
+// cutUTF8Start drops at least n leading bytes of b without splitting a character.
+func cutUTF8Start(b []byte, n int) []byte {
+	if n >= len(b) {
+		return nil
+	}
+	for n < len(b) && !utf8.RuneStart(b[n]) {
+		n++
+	}
+	return b[n:]
+}
+
+func (t *FileReadTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), readFileArgs{})
 }
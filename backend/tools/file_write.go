@@ -1,14 +1,25 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
+	"nira/memory"
+	"nira/sandbox"
 	"os"
 	"path/filepath"
 )
 
+type writeFileArgs struct {
+	Path    string `json:"path" desc:"The file path to write to" required:"true"`
+	Content    string `json:"content" desc:"The text content to write" required:"true"`
+	CreateDirs bool   `json:"create_dirs" desc:"Create missing parent directories"`
+}
+
 type FileWriteTool struct {
     AllowedPaths []string
     checker      PathChecker
+    // Versions keeps the previous content of overwritten files; nil disables backups.
+    Versions *memory.FileVersionStore
 }
 
 func NewFileWriteTool(allowedPaths []string) *FileWriteTool {
@@ -24,74 +35,65 @@ func (t *FileWriteTool) Name() string {
 }
 
 func (t *FileWriteTool) Description() string {
-	return "Writes text content to a file. Arguments: 'path' (string), 'content' (string)."
+	return "Writes text content to a file, replacing it atomically and keeping the previous version for file_undo. Arguments: 'path' (string), 'content' (string), optional 'create_dirs' (bool) to create missing parent directories."
+}
+
+func (t *FileWriteTool) Permission() Permission {
+	return PermissionWrite
 }
 
 func (t *FileWriteTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"path": map[string]interface{}{
-					"type":        "string",
-					"description": "The file path to write to",
-				},
-				"content": map[string]interface{}{
-					"type":        "string",
-					"description": "The text content to write",
-				},
-			},
-			"required": []string{"path", "content"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), writeFileArgs{})
 }
 
 func (t *FileWriteTool) Execute(args map[string]interface{}) (interface{}, error) {
-	path, ok := args["path"].(string)
-	if !ok {
-		return nil, fmt.Errorf("path argument is required and must be a string")
+	var a writeFileArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
 	}
-	content, ok := args["content"].(string)
-	if !ok {
-		return nil, fmt.Errorf("content argument is required and must be a string")
+	path, content := a.Path, a.Content
+
+	if err := checkPathAccess(t.checker, t.AllowedPaths, path, sandbox.AccessWrite); err != nil {
+		return nil, err
 	}
 
- if !t.isPathAllowed(path) {
-        return nil, fmt.Errorf("path '%s' is not in allowed directories", path)
-    }
+	if err := checkPreviewBase(args, path); err != nil {
+		return nil, err
+	}
 
-	// Ensure directory exists
 	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create directories: %w", err)
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if !a.CreateDirs {
+			return nil, fmt.Errorf("parent directory '%s' does not exist; set create_dirs to create it", dir)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directories: %w", err)
+		}
 	}
 
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write file: %w", err)
+	versionID, err := safeWriteFile(t.Versions, path, []byte(content), t.Name())
+	if err != nil {
+		return nil, err
 	}
 
+	if versionID > 0 {
+		return fmt.Sprintf("Successfully wrote to %s (previous version saved as #%d; file_undo restores it)", path, versionID), nil
+	}
 	return fmt.Sprintf("Successfully wrote to %s", path), nil
 }
 
-func (t *FileWriteTool) isPathAllowed(path string) bool {
-    if t.checker != nil {
-        return t.checker.IsAllowed(path)
-    }
-    if len(t.AllowedPaths) == 0 {
-        return false
-    }
-    absPath, err := filepath.Abs(path)
-    if err != nil { return false }
-    for _, allowed := range t.AllowedPaths {
-        absAllowed, err := filepath.Abs(allowed)
-        if err != nil { continue }
-        rel, err := filepath.Rel(absAllowed, absPath)
-        if err != nil { continue }
-        if rel != ".." && !filepath.IsAbs(rel) {
-            return true
-        }
-    }
-    return false
+// Preview reports the diff between the file's current content and the new content.
+func (t *FileWriteTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a writeFileArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Path, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	p, err := previewChange(a.Path, []byte(a.Content))
+	if err != nil {
+		return nil, err
+	}
+	return []FilePreview{p}, nil
 }
@@ -0,0 +1,79 @@
+/**
+ * Git command helper module.
+ *
+ * Runs read-only git commands for tools that report repository state.
+ * Repository configuration that would make git start other programs is
+ * overridden, and optional index locks are disabled so a query never
+ * blocks a concurrent commit.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: git_exec.go
+ * Description: Bounded, read-only git invocation.
+ */
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// gitTimeout bounds a single git command.
+	gitTimeout = 15 * time.Second
+	// maxGitOutput bounds the output kept from a git command.
+	maxGitOutput = 4 << 20
+)
+
+// errGitNotFound is returned when no git executable is installed.
+var errGitNotFound = errors.New("git is not installed")
+
+// runGit runs git with args in dir and returns its standard output. The
+// output is cut at maxGitOutput; truncated reports whether that happened.
+func runGit(dir string, args ...string) (out string, truncated bool, err error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", false, errGitNotFound
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	base := []string{"-C", dir, "--no-pager",
+		"-c", "core.fsmonitor=false", "-c", "core.quotePath=false",
+		"-c", "color.ui=false", "-c", "log.showSignature=false",
+	}
+	cmd := exec.CommandContext(ctx, "git", append(base, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0", "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	var stdout, stderr limitedBuffer
+	stdout.limit, stderr.limit = maxGitOutput, 16<<10
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = 2 * time.Second
+
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", false, fmt.Errorf("git %s timed out after %v", args[0], gitTimeout)
+	}
+	if runErr != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		return "", false, fmt.Errorf("git %s: %s", args[0], truncate(msg, 500))
+	}
+	return stdout.String(), stdout.overflow, nil
+}
+
+// gitRepoRoot returns the top-level directory of the work tree containing
+// dir, or an error when dir is not inside one.
+func gitRepoRoot(dir string) (string, error) {
+	out, _, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
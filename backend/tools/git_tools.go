@@ -0,0 +1,765 @@
+/**
+ * Git repository tools module.
+ *
+ * Read-only views of repositories inside allowed directories: git_status,
+ * git_log, git_show, git_diff and git_blame. Every command is limited to
+ * the path the caller asked about, files the sandbox hides are filtered
+ * out of the results, and diffs are cut to a byte budget so they fit in
+ * the prompt. Repository settings that would run external programs
+ * (external diff drivers, textconv) are disabled.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: git_tools.go
+ * Description: Read-only git status, log, show, diff and blame tools.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultGitDiffBytes = 20000
+	maxGitLogCount      = 200
+	maxBlameLines       = 500
+)
+
+// diffFlags keep diff output plain and stop repository configuration from
+// running external programs.
+var diffFlags = []string{"--no-ext-diff", "--no-textconv", "--no-color", "--src-prefix=a/", "--dst-prefix=b/"}
+
+// gitTool holds what the git tools share.
+type gitTool struct {
+	AllowedPaths []string
+	checker      PathChecker
+}
+
+// gitTarget is a path resolved against the repository containing it.
+type gitTarget struct {
+	root string // repository top level
+	rel  string // slash-separated path below root, "." for the root
+	spec string // pathspec limiting commands to rel
+}
+
+// resolve checks path for reading and locates its repository.
+func (g *gitTool) resolve(path string) (*gitTarget, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path argument must not be empty")
+	}
+	if err := checkPathAccess(g.checker, g.AllowedPaths, path, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	real, _ = filepath.Abs(real)
+	dir := real
+	if !info.IsDir() {
+		dir = filepath.Dir(real)
+	}
+	root, err := gitRepoRoot(dir)
+	if err == errGitNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not inside a git repository", path)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("'%s' is not inside the repository at %s", path, root)
+	}
+	rel = filepath.ToSlash(rel)
+	t := &gitTarget{root: root, rel: rel, spec: ":(literal)" + rel}
+	if rel == "." {
+		t.spec = "."
+	}
+	return t, nil
+}
+
+// readable reports whether a repository-relative file may be shown.
+func (g *gitTool) readable(t *gitTarget, rel string) bool {
+	p := filepath.Join(t.root, filepath.FromSlash(rel))
+	return checkPathAccess(g.checker, g.AllowedPaths, p, sandbox.AccessRead) == nil
+}
+
+// checkRef refuses revisions that git could parse as options, and other
+// obviously malformed input.
+func checkRef(name, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	if strings.HasPrefix(ref, "-") || len(ref) > 256 {
+		return fmt.Errorf("invalid %s '%s'", name, ref)
+	}
+	for _, r := range ref {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("invalid %s '%s'", name, ref)
+		}
+	}
+	return nil
+}
+
+// GitStatusTool reports the working tree status of a repository.
+type GitStatusTool struct{ gitTool }
+
+type gitStatusArgs struct {
+	Path string `json:"path" desc:"Repository directory, or a file or folder inside it to limit the status to" required:"true"`
+}
+
+func NewGitStatusTool(allowedPaths []string) *GitStatusTool {
+	return &GitStatusTool{gitTool{AllowedPaths: allowedPaths}}
+}
+
+func NewGitStatusToolWithChecker(allowedPaths []string, checker PathChecker) *GitStatusTool {
+	return &GitStatusTool{gitTool{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *GitStatusTool) Name() string           { return "git_status" }
+func (t *GitStatusTool) Permission() Permission { return PermissionRead }
+func (t *GitStatusTool) Description() string {
+	return "Shows the branch, upstream (ahead/behind) and changed files of a git repository in an allowed directory. Args: path (string)."
+}
+func (t *GitStatusTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), gitStatusArgs{})
+}
+
+func (t *GitStatusTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a gitStatusArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	target, err := t.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := runGit(target.root, "status", "--porcelain=v1", "--branch", "-z", "--", target.spec)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{"repo_root": target.root}
+	files := []map[string]interface{}{}
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		if strings.HasPrefix(rec, "## ") {
+			parseBranchLine(rec[3:], result)
+			continue
+		}
+		if len(rec) < 4 {
+			continue
+		}
+		f := map[string]interface{}{"path": rec[3:], "index": string(rec[0]), "worktree": string(rec[1]), "status": statusLabel(rec[0], rec[1])}
+		if rec[0] == 'R' || rec[0] == 'C' {
+			// Renames and copies are followed by the source path.
+			if i+1 < len(records) {
+				i++
+				f["orig_path"] = records[i]
+			}
+		}
+		if t.readable(target, rec[3:]) {
+			files = append(files, f)
+		}
+	}
+	result["files"] = files
+	result["clean"] = len(files) == 0
+	return result, nil
+}
+
+// parseBranchLine reads "main...origin/main [ahead 1, behind 2]".
+func parseBranchLine(line string, result map[string]interface{}) {
+	if i := strings.Index(line, " ["); i >= 0 && strings.HasSuffix(line, "]") {
+		for _, part := range strings.Split(line[i+2:len(line)-1], ", ") {
+			if n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(part, "ahead "), "behind ")); err == nil {
+				if strings.HasPrefix(part, "ahead") {
+					result["ahead"] = n
+				} else {
+					result["behind"] = n
+				}
+			}
+		}
+		line = line[:i]
+	}
+	if branch, upstream, ok := strings.Cut(line, "..."); ok {
+		result["branch"], result["upstream"] = branch, upstream
+	} else {
+		result["branch"] = strings.TrimPrefix(line, "No commits yet on ")
+	}
+}
+
+func statusLabel(x, y byte) string {
+	switch {
+	case x == '?' && y == '?':
+		return "untracked"
+	case x == 'U' || y == 'U' || (x == 'A' && y == 'A') || (x == 'D' && y == 'D'):
+		return "conflicted"
+	case x == 'R':
+		return "renamed"
+	case x == 'A':
+		return "added"
+	case x == 'D' || y == 'D':
+		return "deleted"
+	case x != ' ' && y != ' ':
+		return "staged_and_modified"
+	case x != ' ':
+		return "staged"
+	}
+	return "modified"
+}
+
+// GitLogTool lists commits.
+type GitLogTool struct{ gitTool }
+
+type gitLogArgs struct {
+	Path       string `json:"path" desc:"Repository directory, or a file or folder inside it to limit history to" required:"true"`
+	Ref        string `json:"ref" desc:"Branch, tag, commit or range (e.g. main..feature)" default:"HEAD"`
+	MaxCount   int    `json:"max_count" desc:"Maximum commits (at most 200)" default:"20"`
+	Author     string `json:"author" desc:"Only commits whose author name or email contains this text (case-insensitive)"`
+	Since      string `json:"since" desc:"Only commits after this date (e.g. 2024-01-31 or \"2 weeks ago\")"`
+	Until      string `json:"until" desc:"Only commits before this date"`
+	Grep       string `json:"grep" desc:"Only commits whose message contains this text (case-insensitive)"`
+	NameStatus bool   `json:"name_status" desc:"Include the files each commit changed"`
+}
+
+func NewGitLogTool(allowedPaths []string) *GitLogTool {
+	return &GitLogTool{gitTool{AllowedPaths: allowedPaths}}
+}
+
+func NewGitLogToolWithChecker(allowedPaths []string, checker PathChecker) *GitLogTool {
+	return &GitLogTool{gitTool{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *GitLogTool) Name() string           { return "git_log" }
+func (t *GitLogTool) Permission() Permission { return PermissionRead }
+func (t *GitLogTool) Description() string {
+	return "Lists commits (newest first) touching a path in a git repository, with optional author, date and message filters. Args: path (string), ref (string), max_count (int), author (string), since (string), until (string), grep (string), name_status (bool)."
+}
+func (t *GitLogTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), gitLogArgs{})
+}
+
+// logFormat separates commits with \x1e and fields with \x1f.
+const logFormat = "--format=%x1e%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%s"
+
+func (t *GitLogTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a gitLogArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	target, err := t.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if a.Ref == "" {
+		a.Ref = "HEAD"
+	}
+	if err := checkRef("ref", a.Ref); err != nil {
+		return nil, err
+	}
+	if a.MaxCount <= 0 {
+		a.MaxCount = 20
+	}
+	a.MaxCount = min(a.MaxCount, maxGitLogCount)
+
+	cmd := []string{"log", logFormat, "--max-count=" + strconv.Itoa(a.MaxCount)}
+	if a.Author != "" {
+		cmd = append(cmd, "--author="+a.Author)
+	}
+	if a.Since != "" {
+		cmd = append(cmd, "--since="+a.Since)
+	}
+	if a.Until != "" {
+		cmd = append(cmd, "--until="+a.Until)
+	}
+	if a.Grep != "" {
+		cmd = append(cmd, "--grep="+a.Grep)
+	}
+	if a.Author != "" || a.Grep != "" {
+		cmd = append(cmd, "--regexp-ignore-case", "--fixed-strings")
+	}
+	if a.NameStatus {
+		cmd = append(cmd, "--name-status")
+	}
+	cmd = append(cmd, "--end-of-options", a.Ref, "--", target.spec)
+	out, truncated, err := runGit(target.root, cmd...)
+	if err != nil {
+		return nil, err
+	}
+	commits := parseLog(out, func(rel string) bool { return t.readable(target, rel) })
+	return map[string]interface{}{
+		"repo_root": target.root,
+		"commits":   commits,
+		"truncated": truncated,
+	}, nil
+}
+
+// parseLog parses logFormat output, with file lists when --name-status
+// was given. Files the filter rejects are left out.
+func parseLog(out string, show func(string) bool) []map[string]interface{} {
+	commits := []map[string]interface{}{}
+	for _, chunk := range strings.Split(out, "\x1e") {
+		if strings.TrimSpace(chunk) == "" {
+			continue
+		}
+		header, rest, _ := strings.Cut(chunk, "\n")
+		f := strings.Split(header, "\x1f")
+		if len(f) < 6 {
+			continue
+		}
+		c := map[string]interface{}{"hash": f[0], "short": f[1], "author": f[2], "email": f[3], "date": f[4], "subject": f[5]}
+		if files := parseNameStatus(rest, show); files != nil {
+			c["files"] = files
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// parseNameStatus parses "M\tpath" and "R100\told\tnew" lines.
+func parseNameStatus(out string, show func(string) bool) []map[string]string {
+	var files []map[string]string
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) < 2 || parts[0] == "" {
+			continue
+		}
+		f := map[string]string{"status": parts[0][:1], "path": parts[len(parts)-1]}
+		if len(parts) == 3 {
+			f["orig_path"] = parts[1]
+		}
+		if show(f["path"]) {
+			files = append(files, f)
+		}
+	}
+	if files == nil && strings.TrimSpace(out) != "" {
+		return []map[string]string{}
+	}
+	return files
+}
+
+// GitShowTool shows one commit.
+type GitShowTool struct{ gitTool }
+
+type gitShowArgs struct {
+	Path     string `json:"path" desc:"Repository directory, or a file or folder inside it to limit the diff to" required:"true"`
+	Ref      string `json:"ref" desc:"Commit, branch or tag" required:"true"`
+	StatOnly bool   `json:"stat_only" desc:"Only list changed files with line counts, no patch"`
+	MaxBytes int    `json:"max_bytes" desc:"Maximum bytes of diff to return" default:"20000"`
+}
+
+func NewGitShowTool(allowedPaths []string) *GitShowTool {
+	return &GitShowTool{gitTool{AllowedPaths: allowedPaths}}
+}
+
+func NewGitShowToolWithChecker(allowedPaths []string, checker PathChecker) *GitShowTool {
+	return &GitShowTool{gitTool{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *GitShowTool) Name() string           { return "git_show" }
+func (t *GitShowTool) Permission() Permission { return PermissionRead }
+func (t *GitShowTool) Description() string {
+	return "Shows a commit: author, date, full message, changed files with line counts and the patch (cut at max_bytes). Args: path (string), ref (string), stat_only (bool), max_bytes (int)."
+}
+func (t *GitShowTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), gitShowArgs{})
+}
+
+func (t *GitShowTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a gitShowArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	target, err := t.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if a.Ref == "" {
+		return nil, fmt.Errorf("ref argument must not be empty")
+	}
+	if err := checkRef("ref", a.Ref); err != nil {
+		return nil, err
+	}
+	meta, _, err := runGit(target.root, "show", "--no-patch", "--format=%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%P%x1f%B", "--end-of-options", a.Ref)
+	if err != nil {
+		return nil, err
+	}
+	f := strings.SplitN(meta, "\x1f", 7)
+	if len(f) < 7 {
+		return nil, fmt.Errorf("'%s' is not a commit", a.Ref)
+	}
+	message := strings.TrimSpace(f[6])
+	subject, body, _ := strings.Cut(message, "\n")
+	result := map[string]interface{}{
+		"repo_root": target.root,
+		"hash":      f[0],
+		"short":     f[1],
+		"author":    f[2],
+		"email":     f[3],
+		"date":      f[4],
+		"parents":   strings.Fields(f[5]),
+		"subject":   subject,
+		"body":      strings.TrimSpace(body),
+	}
+	show := func(rel string) bool { return t.readable(target, rel) }
+
+	revs := []string{"--end-of-options", a.Ref, "--", target.spec}
+	numstat, _, err := runGit(target.root, append([]string{"show", "--format=", "--numstat", "--no-renames"}, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+	result["files"] = parseNumstat(numstat, show)
+	if a.StatOnly {
+		return result, nil
+	}
+	cmd := append([]string{"show", "--format=", "--patch"}, diffFlags...)
+	patch, cut, err := runGit(target.root, append(cmd, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+	diff, truncated := capDiff(filterDiff(patch, show), a.MaxBytes)
+	result["diff"] = diff
+	result["truncated"] = truncated || cut
+	return result, nil
+}
+
+// GitDiffTool compares refs, the index and the working tree.
+type GitDiffTool struct{ gitTool }
+
+type gitDiffArgs struct {
+	Path     string `json:"path" desc:"Repository directory, or a file or folder inside it to limit the diff to" required:"true"`
+	From     string `json:"from" desc:"Base commit/branch; omit to diff uncommitted changes"`
+	To       string `json:"to" desc:"Target commit/branch; omit to compare from against the working tree"`
+	Staged   bool   `json:"staged" desc:"Without from/to: show staged changes instead of unstaged ones"`
+	StatOnly bool   `json:"stat_only" desc:"Only list changed files with line counts, no patch"`
+	Context  int    `json:"context" desc:"Context lines around changes" default:"3"`
+	MaxBytes int    `json:"max_bytes" desc:"Maximum bytes of diff to return" default:"20000"`
+}
+
+func NewGitDiffTool(allowedPaths []string) *GitDiffTool {
+	return &GitDiffTool{gitTool{AllowedPaths: allowedPaths}}
+}
+
+func NewGitDiffToolWithChecker(allowedPaths []string, checker PathChecker) *GitDiffTool {
+	return &GitDiffTool{gitTool{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *GitDiffTool) Name() string           { return "git_diff" }
+func (t *GitDiffTool) Permission() Permission { return PermissionRead }
+func (t *GitDiffTool) Description() string {
+	return "Shows a unified diff between two refs, a ref and the working tree, or uncommitted (staged or unstaged) changes, with per-file line counts; cut at max_bytes. Args: path (string), from (string), to (string), staged (bool), stat_only (bool), context (int), max_bytes (int)."
+}
+func (t *GitDiffTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), gitDiffArgs{})
+}
+
+func (t *GitDiffTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a gitDiffArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	target, err := t.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRef("from", a.From); err != nil {
+		return nil, err
+	}
+	if err := checkRef("to", a.To); err != nil {
+		return nil, err
+	}
+	if a.To != "" && a.From == "" {
+		return nil, fmt.Errorf("to requires from")
+	}
+	if a.Context < 0 {
+		a.Context = 0
+	}
+
+	var revs []string
+	if a.Staged && a.From == "" {
+		revs = append(revs, "--cached")
+	}
+	revs = append(revs, "--end-of-options")
+	for _, r := range []string{a.From, a.To} {
+		if r != "" {
+			revs = append(revs, r)
+		}
+	}
+	revs = append(revs, "--", target.spec)
+	show := func(rel string) bool { return t.readable(target, rel) }
+
+	numstat, _, err := runGit(target.root, append([]string{"diff", "--numstat", "--no-renames"}, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"repo_root": target.root,
+		"files":     parseNumstat(numstat, show),
+	}
+	if a.StatOnly {
+		return result, nil
+	}
+	cmd := append([]string{"diff", "-U" + strconv.Itoa(a.Context)}, diffFlags...)
+	patch, cut, err := runGit(target.root, append(cmd, revs...)...)
+	if err != nil {
+		return nil, err
+	}
+	diff, truncated := capDiff(filterDiff(patch, show), a.MaxBytes)
+	result["diff"] = diff
+	result["truncated"] = truncated || cut
+	return result, nil
+}
+
+// parseNumstat parses "added\tremoved\tpath" lines; binary files have "-".
+func parseNumstat(out string, show func(string) bool) []map[string]interface{} {
+	files := []map[string]interface{}{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) < 3 || !show(parts[2]) {
+			continue
+		}
+		f := map[string]interface{}{"path": parts[2]}
+		if parts[0] == "-" {
+			f["binary"] = true
+		} else {
+			f["added"], _ = strconv.Atoi(parts[0])
+			f["removed"], _ = strconv.Atoi(parts[1])
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// filterDiff drops the per-file sections of a unified diff whose files
+// the filter rejects.
+func filterDiff(diff string, show func(string) bool) string {
+	var b strings.Builder
+	keep := true
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			keep = true
+			for _, p := range diffHeaderPaths(line) {
+				if !show(p) {
+					keep = false
+				}
+			}
+		}
+		if keep {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// diffHeaderPaths returns the paths of "diff --git a/<old> b/<new>". When
+// names contain " b/" the split is ambiguous; the split with equal halves
+// is preferred, which covers everything but renames of such names.
+func diffHeaderPaths(line string) []string {
+	rest := strings.TrimSuffix(strings.TrimPrefix(line, "diff --git "), "\n")
+	if !strings.HasPrefix(rest, "a/") {
+		return nil
+	}
+	var first []string
+	for i := 0; i < len(rest); i++ {
+		if !strings.HasPrefix(rest[i:], " b/") {
+			continue
+		}
+		old, new := rest[2:i], rest[i+3:]
+		if old == new {
+			return []string{old}
+		}
+		if first == nil {
+			first = []string{old, new}
+		}
+	}
+	return first
+}
+
+// capDiff cuts diff at a line boundary within maxBytes and marks the cut.
+func capDiff(diff string, maxBytes int) (string, bool) {
+	if maxBytes <= 0 {
+		maxBytes = defaultGitDiffBytes
+	}
+	if len(diff) <= maxBytes {
+		return diff, false
+	}
+	cut := strings.LastIndexByte(diff[:maxBytes], '\n') + 1
+	if cut == 0 {
+		cut = len(cutUTF8([]byte(diff), maxBytes))
+	}
+	return diff[:cut] + fmt.Sprintf("[... diff truncated at max_bytes=%d; narrow path or use stat_only ...]\n", maxBytes), true
+}
+
+// GitBlameTool shows who last changed each line of a file range.
+type GitBlameTool struct{ gitTool }
+
+type gitBlameArgs struct {
+	Path      string `json:"path" desc:"File to blame" required:"true"`
+	StartLine int    `json:"start_line" desc:"First line (1-based)" default:"1"`
+	EndLine   int    `json:"end_line" desc:"Last line (default: start_line + 99; at most 500 lines)"`
+	Ref       string `json:"ref" desc:"Blame the file as of this commit instead of the working tree"`
+}
+
+func NewGitBlameTool(allowedPaths []string) *GitBlameTool {
+	return &GitBlameTool{gitTool{AllowedPaths: allowedPaths}}
+}
+
+func NewGitBlameToolWithChecker(allowedPaths []string, checker PathChecker) *GitBlameTool {
+	return &GitBlameTool{gitTool{AllowedPaths: allowedPaths, checker: checker}}
+}
+
+func (t *GitBlameTool) Name() string           { return "git_blame" }
+func (t *GitBlameTool) Permission() Permission { return PermissionRead }
+func (t *GitBlameTool) Description() string {
+	return "Shows, for each line in a range of a file, the commit, author, date and summary that last changed it. Args: path (string), start_line (int), end_line (int), ref (string)."
+}
+func (t *GitBlameTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), gitBlameArgs{})
+}
+
+func (t *GitBlameTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a gitBlameArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	target, err := t.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(a.Path); err == nil && info.IsDir() {
+		return nil, fmt.Errorf("git_blame needs a file, not a directory")
+	}
+	if err := checkRef("ref", a.Ref); err != nil {
+		return nil, err
+	}
+	if a.StartLine <= 0 {
+		a.StartLine = 1
+	}
+	if a.EndLine <= 0 {
+		a.EndLine = a.StartLine + 99
+	}
+	if a.EndLine < a.StartLine {
+		return nil, fmt.Errorf("end_line (%d) is before start_line (%d)", a.EndLine, a.StartLine)
+	}
+	truncated := false
+	if a.EndLine-a.StartLine+1 > maxBlameLines {
+		a.EndLine = a.StartLine + maxBlameLines - 1
+		truncated = true
+	}
+
+	cmd := []string{"blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", a.StartLine, a.EndLine)}
+	if a.Ref != "" {
+		cmd = append(cmd, "--end-of-options", a.Ref)
+	}
+	cmd = append(cmd, "--", target.rel)
+	out, _, err := runGit(target.root, cmd...)
+	if err != nil {
+		// Ranges past the end of the file are an error in git; retry up to the end.
+		if !strings.Contains(err.Error(), "has only") {
+			return nil, err
+		}
+		total := blameLineCount(err.Error())
+		if total < a.StartLine {
+			return nil, fmt.Errorf("start_line %d is past the end of the file (%d lines)", a.StartLine, total)
+		}
+		a.EndLine = total
+		cmd[3] = fmt.Sprintf("%d,%d", a.StartLine, a.EndLine)
+		if out, _, err = runGit(target.root, cmd...); err != nil {
+			return nil, err
+		}
+	}
+	lines := parseBlame(out)
+	return map[string]interface{}{
+		"repo_root":  target.root,
+		"path":       target.rel,
+		"start_line": a.StartLine,
+		"end_line":   a.StartLine + len(lines) - 1,
+		"lines":      lines,
+		"truncated":  truncated,
+	}, nil
+}
+
+// blameLineCount reads N from git's "file ... has only N lines" error.
+func blameLineCount(msg string) int {
+	i := strings.Index(msg, "has only ")
+	if i < 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.Fields(msg[i+len("has only "):])[0])
+	return n
+}
+
+type blameCommit struct {
+	author, summary string
+	time            int64
+}
+
+// parseBlame parses git blame --porcelain output.
+func parseBlame(out string) []map[string]interface{} {
+	lines := []map[string]interface{}{}
+	commits := map[string]*blameCommit{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), maxGitOutput)
+	var hash string
+	var final int
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "\t") {
+			c := commits[hash]
+			entry := map[string]interface{}{"line": final, "hash": hash[:min(len(hash), 12)], "text": truncate(line[1:], maxMatchLineLen)}
+			if c != nil {
+				entry["author"] = c.author
+				entry["summary"] = c.summary
+				entry["date"] = time.Unix(c.time, 0).UTC().Format(time.RFC3339)
+			}
+			lines = append(lines, entry)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && len(fields[0]) >= 40 && isHex(fields[0]) {
+			hash = fields[0]
+			final, _ = strconv.Atoi(fields[2])
+			if commits[hash] == nil {
+				commits[hash] = &blameCommit{}
+			}
+			continue
+		}
+		c := commits[hash]
+		if c == nil {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			c.author = value
+		case "author-time":
+			c.time, _ = strconv.ParseInt(value, 10, 64)
+		case "summary":
+			c.summary = value
+		}
+	}
+	return lines
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+/**
+ * .gitignore matching module.
+ *
+ * Collects .gitignore rules while walking a directory tree so walkers can
+ * skip ignored files the way git does: patterns apply below the directory
+ * of their .gitignore, a trailing slash matches only directories, "!"
+ * re-includes, and the last matching rule wins.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: gitignore.go
+ * Description: .gitignore rule loading and matching for tree walkers.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"nira/sandbox"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type ignoreRule struct {
+	// base is the slash-separated directory of the .gitignore relative to
+	// the walk root ("" for the root itself).
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+	// anchored is set for patterns with a leading slash, which match
+	// only directly below base.
+	anchored bool
+}
+
+// gitignore accumulates rules from the .gitignore files of visited directories.
+type gitignore struct {
+	rules []ignoreRule
+}
+
+// load reads dir/.gitignore, where dir is rel (slash-separated) below the
+// walk root. A missing file is not an error.
+func (g *gitignore) load(dir, rel string) {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if rel == "." {
+		rel = ""
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: rel}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.HasPrefix(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		g.rules = append(g.rules, r)
+	}
+}
+
+// ignored reports whether rel (slash-separated, relative to the walk root)
+// is ignored.
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+		matched := false
+		if r.anchored && !strings.Contains(r.pattern, "/") {
+			matched, _ = path.Match(r.pattern, sub)
+		} else {
+			matched = sandbox.MatchGlob(r.pattern, sub)
+		}
+		if matched {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
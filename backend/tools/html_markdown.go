@@ -0,0 +1,516 @@
+/**
+ * HTML to markdown module.
+ *
+ * Reduces a web page to its readable main content as markdown: the
+ * article (or main, or the element holding the most paragraph text) with
+ * navigation, scripts, forms and other page furniture removed. Headings,
+ * lists, links, emphasis, code, quotes and simple tables are kept.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: html_markdown.go
+ * Description: Main-content extraction and markdown rendering for HTML.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// minMainContent is the paragraph text a candidate needs before it is
+// preferred over the whole body.
+const minMainContent = 250
+
+// skippedElements never contribute content.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Input: true, atom.Textarea: true,
+	atom.Svg: true, atom.Canvas: true, atom.Iframe: true, atom.Object: true, atom.Embed: true,
+	atom.Head: true, atom.Dialog: true,
+}
+
+// boilerplateTokens in a class or id mark page furniture.
+var boilerplateTokens = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true,
+	"footer": true, "header": true, "masthead": true, "cookie": true, "cookies": true,
+	"banner": true, "advert": true, "ads": true, "ad": true, "share": true, "social": true,
+	"comments": true, "related": true, "breadcrumb": true, "breadcrumbs": true,
+	"popup": true, "modal": true, "newsletter": true, "subscribe": true, "skip": true,
+}
+
+var classTokenSplit = regexp.MustCompile(`[\s_-]+`)
+
+var listMarker = regexp.MustCompile(`^(- |\d+\. )`)
+
+// htmlToMarkdown parses an HTML document and returns its title and the
+// markdown of its main content, or of the whole body when full is set.
+// base resolves relative links.
+func htmlToMarkdown(r io.Reader, base *url.URL, full bool) (title, md string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	title = documentTitle(doc)
+	root := findElement(doc, atom.Body)
+	if root == nil {
+		root = doc
+	}
+	if !full {
+		root = mainContent(root)
+	}
+	w := &mdWriter{base: base}
+	w.children(root)
+	md = w.String()
+	if title == "" {
+		if h1 := findElement(root, atom.H1); h1 != nil {
+			title = collapseSpace(textContent(h1))
+		}
+	}
+	return title, md, nil
+}
+
+func documentTitle(doc *html.Node) string {
+	if t := findElement(doc, atom.Title); t != nil {
+		return collapseSpace(textContent(t))
+	}
+	return ""
+}
+
+// findElement returns the first element of type a below n, depth first.
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// mainContent picks the element holding the page's main text: a lone
+// <article>, <main> or role="main", otherwise the element whose direct
+// paragraphs hold the most text, otherwise body itself.
+func mainContent(body *html.Node) *html.Node {
+	var articles, mains []*html.Node
+	var best *html.Node
+	bestScore := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if isSkipped(n) {
+				return
+			}
+			switch {
+			case n.DataAtom == atom.Article:
+				articles = append(articles, n)
+			case n.DataAtom == atom.Main || attr(n, "role") == "main":
+				mains = append(mains, n)
+			}
+			score := 0
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.DataAtom == atom.P || c.DataAtom == atom.Pre || c.DataAtom == atom.Blockquote) {
+					score += len(collapseSpace(textContent(c)))
+				}
+			}
+			if score > bestScore {
+				best, bestScore = n, score
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(body)
+	switch {
+	case len(articles) == 1:
+		return articles[0]
+	case len(mains) > 0:
+		return mains[0]
+	case best != nil && bestScore >= minMainContent:
+		return best
+	}
+	return body
+}
+
+// isSkipped reports whether an element is page furniture or hidden.
+func isSkipped(n *html.Node) bool {
+	if skippedElements[n.DataAtom] {
+		return true
+	}
+	// A page header is furniture; an article's header holds its title.
+	if n.DataAtom == atom.Header {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.DataAtom == atom.Article || p.DataAtom == atom.Main {
+				return false
+			}
+		}
+		return true
+	}
+	if hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if role := attr(n, "role"); role == "navigation" || role == "banner" || role == "contentinfo" || role == "complementary" {
+		return true
+	}
+	if n.DataAtom == atom.Div || n.DataAtom == atom.Section || n.DataAtom == atom.Ul || n.DataAtom == atom.Span {
+		for _, tok := range classTokenSplit.Split(strings.ToLower(attr(n, "class")+" "+attr(n, "id")), -1) {
+			if boilerplateTokens[tok] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// mdWriter renders nodes as markdown. Nested blocks (list items, quotes)
+// are rendered by a fresh writer and then indented or prefixed.
+type mdWriter struct {
+	b    strings.Builder
+	base *url.URL
+}
+
+func (w *mdWriter) String() string {
+	return normalizeMarkdown(w.b.String())
+}
+
+func (w *mdWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+// block ends the current line and leaves one blank line before what follows.
+func (w *mdWriter) block() {
+	s := w.b.String()
+	switch {
+	case s == "" || strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		w.b.WriteString("\n")
+	default:
+		w.b.WriteString("\n\n")
+	}
+}
+
+func (w *mdWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	lead := s[0] == ' ' || s[0] == '\t' || s[0] == '\n' || s[0] == '\r'
+	trail := strings.TrimRight(s, " \t\r\n") != s
+	s = collapseSpace(s)
+	if lead && !w.atLineStart() && !strings.HasSuffix(w.b.String(), " ") {
+		w.b.WriteString(" ")
+	}
+	w.b.WriteString(s)
+	if trail && s != "" {
+		w.b.WriteString(" ")
+	}
+}
+
+// inner renders n's children with a fresh writer.
+func (w *mdWriter) inner(n *html.Node) string {
+	sub := &mdWriter{base: w.base}
+	sub.children(n)
+	return sub.String()
+}
+
+func (w *mdWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+}
+
+func (w *mdWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+	if isSkipped(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		if t := collapseSpace(w.inner(n)); t != "" {
+			w.block()
+			w.b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " " + t)
+			w.block()
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Figure, atom.Figcaption,
+		atom.Dl, atom.Dt, atom.Dd, atom.Address, atom.Details, atom.Summary, atom.Center:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.b.WriteString("\n")
+	case atom.Hr:
+		w.block()
+		w.b.WriteString("---")
+		w.block()
+	case atom.Pre:
+		w.block()
+		lang := codeLanguage(n)
+		code := strings.Trim(textContent(n), "\n")
+		w.b.WriteString("```" + lang + "\n" + code + "\n```")
+		w.block()
+	case atom.Code, atom.Kbd, atom.Samp:
+		if t := collapseSpace(textContent(n)); t != "" {
+			w.text(" ")
+			w.b.WriteString("`" + strings.ReplaceAll(t, "`", "'") + "`")
+		}
+	case atom.Strong, atom.B:
+		w.wrap(n, "**")
+	case atom.Em, atom.I:
+		w.wrap(n, "*")
+	case atom.Del, atom.S:
+		w.wrap(n, "~~")
+	case atom.A:
+		label := collapseSpace(w.inner(n))
+		href := w.resolve(attr(n, "href"))
+		switch {
+		case label == "":
+		case href == "":
+			w.text(label)
+		default:
+			w.text(" ")
+			w.b.WriteString("[" + label + "](" + href + ")")
+		}
+	case atom.Img:
+		alt := collapseSpace(attr(n, "alt"))
+		if src := w.resolve(attr(n, "src")); alt != "" && src != "" {
+			w.text(" ")
+			w.b.WriteString("![" + alt + "](" + src + ")")
+		}
+	case atom.Ul, atom.Ol:
+		w.list(n)
+	case atom.Blockquote:
+		if t := w.inner(n); t != "" {
+			w.block()
+			for i, line := range strings.Split(t, "\n") {
+				if i > 0 {
+					w.b.WriteString("\n")
+				}
+				w.b.WriteString(strings.TrimRight("> "+line, " "))
+			}
+			w.block()
+		}
+	case atom.Table:
+		w.table(n)
+	default:
+		w.children(n)
+	}
+}
+
+// wrap renders inline content between markers, e.g. **bold**.
+func (w *mdWriter) wrap(n *html.Node, marker string) {
+	t := collapseSpace(w.inner(n))
+	if t == "" {
+		return
+	}
+	w.text(" ")
+	w.b.WriteString(marker + t + marker)
+}
+
+// resolve makes href absolute and drops links that lead nowhere useful.
+func (w *mdWriter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if w.base != nil {
+		u = w.base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto" {
+		return ""
+	}
+	return u.String()
+}
+
+func (w *mdWriter) list(n *html.Node) {
+	w.block()
+	num := 1
+	first := true
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li || isSkipped(c) {
+			continue
+		}
+		item := w.inner(c)
+		if item == "" {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", num)
+			num++
+		}
+		if !first {
+			w.b.WriteString("\n")
+		}
+		first = false
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(item, "\n")
+		for i, line := range lines {
+			switch {
+			case i == 0:
+				w.b.WriteString(marker + line)
+			case line == "" && i+1 < len(lines) && listMarker.MatchString(lines[i+1]):
+				// Keep nested lists tight under their item.
+			case line == "":
+				w.b.WriteString("\n")
+			default:
+				w.b.WriteString("\n" + indent + line)
+			}
+		}
+	}
+	w.block()
+}
+
+// table renders rows as a markdown table; the first row is the header.
+func (w *mdWriter) table(n *html.Node) {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Tr {
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						cells = append(cells, strings.ReplaceAll(collapseSpace(w.inner(cell)), "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+				continue
+			}
+			if c.DataAtom != atom.Table {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return
+	}
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	w.block()
+	for i, r := range rows {
+		for len(r) < cols {
+			r = append(r, "")
+		}
+		w.b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		if i == 0 {
+			w.b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	w.block()
+}
+
+// codeLanguage reads "language-go" style classes from a pre or its code.
+func codeLanguage(pre *html.Node) string {
+	nodes := []*html.Node{pre}
+	if code := findElement(pre, atom.Code); code != nil {
+		nodes = append(nodes, code)
+	}
+	for _, n := range nodes {
+		for _, cls := range strings.Fields(attr(n, "class")) {
+			if lang, ok := strings.CutPrefix(cls, "language-"); ok {
+				return lang
+			}
+			if lang, ok := strings.CutPrefix(cls, "lang-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeMarkdown trims trailing spaces and collapses runs of blank
+// lines outside code fences.
+func normalizeMarkdown(s string) string {
+	var out []string
+	fence, blank := false, false
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fence = !fence
+		}
+		if !fence {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" && !fence {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -2,11 +2,24 @@ package tools
 
 import (
     "fmt"
+    "nira/sandbox"
     "os"
     "path/filepath"
     "time"
 )
 
+type listDirectoryArgs struct {
+    Path            string   `json:"path" desc:"Directory path to list" required:"true"`
+    Recursive       bool     `json:"recursive" desc:"Recursively list contents"`
+    IncludeFiles    bool     `json:"include_files" desc:"Include files in results" default:"true"`
+    IncludeDirs     bool     `json:"include_dirs" desc:"Include directories in results" default:"true"`
+    MaxItems        int      `json:"max_items" desc:"Maximum number of items to return" default:"1000"`
+    Mode            string   `json:"mode" desc:"list returns entries; tree returns an indented text tree with per-directory file counts and sizes" enum:"list,tree" default:"list"`
+    MaxDepth        int      `json:"max_depth" desc:"Tree mode: directories deeper than this are summarized instead of expanded" default:"3"`
+    Ignore          []string `json:"ignore" desc:"Skip entries matching these globs, e.g. *.log or dist"`
+    IgnoreGitignore bool     `json:"ignore_gitignore" desc:"Also list entries excluded by .gitignore and the default ignores (.git, node_modules, __pycache__)"`
+}
+
 // ListDirectoryTool lists directory entries with optional recursion and filters.
 type ListDirectoryTool struct {
     AllowedPaths []string
@@ -19,75 +32,39 @@ func NewListDirectoryToolWithChecker(allowedPaths []string, checker PathChecker)
 }
 
 func (t *ListDirectoryTool) Name() string { return "list_directory" }
+func (t *ListDirectoryTool) Permission() Permission { return PermissionRead }
 
 func (t *ListDirectoryTool) Description() string {
-    return "Lists files and folders under a directory. Args: path (string), recursive (bool, optional), include_files (bool), include_dirs (bool), max_items (int)."
+    return "Lists files and folders under a directory, skipping .gitignore'd entries, .git and node_modules. mode \"tree\" returns a compact text tree with file counts and sizes per directory, expanded to max_depth. Args: path (string), recursive (bool, optional), include_files (bool), include_dirs (bool), max_items (int), mode (list|tree), max_depth (int), ignore ([glob]), ignore_gitignore (bool)."
 }
 
 func (t *ListDirectoryTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "path": map[string]interface{}{
-                    "type":        "string",
-                    "description": "Directory path to list",
-                },
-                "recursive": map[string]interface{}{
-                    "type":        "boolean",
-                    "description": "Recursively list contents",
-                },
-                "include_files": map[string]interface{}{
-                    "type":        "boolean",
-                    "description": "Include files in results (default true)",
-                },
-                "include_dirs": map[string]interface{}{
-                    "type":        "boolean",
-                    "description": "Include directories in results (default true)",
-                },
-                "max_items": map[string]interface{}{
-                    "type":        "integer",
-                    "description": "Maximum number of items to return (default 1000)",
-                },
-            },
-            "required": []string{"path"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), listDirectoryArgs{})
 }
 
 func (t *ListDirectoryTool) Execute(args map[string]interface{}) (interface{}, error) {
-    path, ok := args["path"].(string)
-    if !ok {
-        return nil, fmt.Errorf("path argument is required and must be a string")
-    }
-    if !t.isPathAllowed(path) {
-        return nil, fmt.Errorf("path '%s' is not in allowed directories", path)
+    var a listDirectoryArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
     }
-
-    recursive := false
-    if v, ok := args["recursive"].(bool); ok {
-        recursive = v
-    }
-    includeFiles := true
-    if v, ok := args["include_files"].(bool); ok {
-        includeFiles = v
+    path := a.Path
+    if err := checkPathAccess(t.checker, t.AllowedPaths, path, sandbox.AccessRead); err != nil {
+        return nil, err
     }
-    includeDirs := true
-    if v, ok := args["include_dirs"].(bool); ok {
-        includeDirs = v
+    // Entries hidden by a deny pattern are left out of the listing.
+    readable := func(p string) bool {
+        return checkPathAccess(t.checker, t.AllowedPaths, p, sandbox.AccessRead) == nil
     }
-    maxItems := 1000
-    if v, ok := args["max_items"]; ok {
-        switch n := v.(type) {
-        case float64:
-            maxItems = int(n)
-        case int:
-            maxItems = n
-        }
+    filter := newListFilter(path, a.Ignore, !a.IgnoreGitignore, readable)
+    if a.Mode == "tree" {
+        return t.tree(a, filter)
     }
 
+    recursive := a.Recursive
+    includeFiles := a.IncludeFiles
+    includeDirs := a.IncludeDirs
+    maxItems := a.MaxItems
+
     results := make([]map[string]interface{}, 0, 64)
     count := 0
 
@@ -126,6 +103,15 @@ func (t *ListDirectoryTool) Execute(args map[string]interface{}) (interface{}, e
             if count >= maxItems {
                 return filepath.SkipDir
             }
+            if filter.skip(p, fi.IsDir()) {
+                if fi.IsDir() {
+                    return filepath.SkipDir
+                }
+                return nil
+            }
+            if fi.IsDir() {
+                filter.enter(p)
+            }
             push(p, fi)
             return nil
         })
@@ -145,26 +131,47 @@ func (t *ListDirectoryTool) Execute(args map[string]interface{}) (interface{}, e
             if err != nil {
                 continue
             }
-            push(filepath.Join(path, e.Name()), info)
+            p := filepath.Join(path, e.Name())
+            if filter.skip(p, e.IsDir()) {
+                continue
+            }
+            push(p, info)
         }
     }
 
     return results, nil
 }
 
-func (t *ListDirectoryTool) isPathAllowed(path string) bool {
-    if t.checker != nil { return t.checker.IsAllowed(path) }
-    if len(t.AllowedPaths) == 0 { return false }
-    absPath, err := filepath.Abs(path)
-    if err != nil { return false }
-    for _, allowed := range t.AllowedPaths {
-        absAllowed, err := filepath.Abs(allowed)
-        if err != nil { continue }
-        rel, err := filepath.Rel(absAllowed, absPath)
-        if err != nil { continue }
-        if rel != ".." && !filepath.IsAbs(rel) {
-            return true
-        }
+// tree renders path as a text tree with aggregated counts and sizes.
+func (t *ListDirectoryTool) tree(a listDirectoryArgs, filter *listFilter) (interface{}, error) {
+    info, err := os.Stat(a.Path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read directory: %w", err)
     }
-    return false
+    if !info.IsDir() {
+        return nil, fmt.Errorf("'%s' is not a directory", a.Path)
+    }
+    maxDepth := a.MaxDepth
+    if maxDepth < 1 {
+        maxDepth = 1
+    }
+    maxItems := a.MaxItems
+    if maxItems <= 0 {
+        maxItems = 1000
+    }
+    w := &dirTree{filter: filter, maxDepth: maxDepth}
+    name := filepath.Base(a.Path)
+    if abs, err := filepath.Abs(a.Path); err == nil {
+        name = filepath.Base(abs)
+    }
+    root := w.build(a.Path, name, 0)
+    text, cut := renderTree(root, maxItems)
+    return map[string]interface{}{
+        "path":        a.Path,
+        "tree":        text,
+        "total_files": root.files,
+        "total_size":  root.size,
+        "truncated":   cut,
+        "partial":     w.partial,
+    }, nil
 }
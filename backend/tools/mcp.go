@@ -0,0 +1,115 @@
+/**
+ * Model Context Protocol module.
+ *
+ * Shared JSON-RPC 2.0 message types for the Model Context Protocol as
+ * spoken over stdio: one JSON message per line. Used by the MCP client
+ * that proxies external tool servers into the registry.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: mcp.go
+ * Description: MCP wire types.
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// MCPProtocolVersion is the protocol revision NIRA speaks.
+const MCPProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes used by MCP.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcMessage covers requests, notifications and responses.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// MCPToolInfo is a tool as advertised by tools/list.
+type MCPToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+type mcpImplementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type mcpInitializeParams struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ClientInfo      mcpImplementation      `json:"clientInfo"`
+}
+
+type mcpInitializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      mcpImplementation      `json:"serverInfo"`
+	Instructions    string                 `json:"instructions,omitempty"`
+}
+
+type mcpListToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type mcpListToolsResult struct {
+	Tools      []MCPToolInfo `json:"tools"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type mcpCallToolParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// mcpContent is one item of a tools/call result. Only text is interpreted;
+// other kinds (image, resource) are passed through as-is.
+type mcpContent struct {
+	Type     string      `json:"type"`
+	Text     string      `json:"text,omitempty"`
+	MimeType string      `json:"mimeType,omitempty"`
+	Data     string      `json:"data,omitempty"`
+	Resource interface{} `json:"resource,omitempty"`
+}
+
+type mcpCallToolResult struct {
+	Content           []mcpContent `json:"content"`
+	StructuredContent interface{}  `json:"structuredContent,omitempty"`
+	IsError           bool         `json:"isError,omitempty"`
+}
+
+var mcpNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// MCPToolName namespaces a server's tool so tools from different servers,
+// and from NIRA itself, cannot collide: "<server>__<tool>".
+func MCPToolName(server, tool string) string {
+	return mcpNameUnsafe.ReplaceAllString(server, "_") + "__" + mcpNameUnsafe.ReplaceAllString(tool, "_")
+}
@@ -0,0 +1,600 @@
+/**
+ * MCP client module.
+ *
+ * Connects to stdio-based Model Context Protocol servers, lists their
+ * tools and exposes each one as a proxy Tool with a namespaced name.
+ * A server that exits is restarted on the next call; servers that keep
+ * crashing right after start are left down for a cooldown period.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: mcp_client.go
+ * Description: MCP stdio client and proxy tools.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMCPTimeout  = 60 * time.Second
+	mcpStartTimeout    = 30 * time.Second
+	mcpCrashWindow     = 10 * time.Second
+	mcpMaxCrashes      = 3
+	mcpCrashCooldown   = time.Minute
+	mcpShutdownTimeout = 2 * time.Second
+	maxMCPMessage      = 16 << 20
+)
+
+var mcpServerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// MCPServerConfig describes one stdio MCP server.
+type MCPServerConfig struct {
+	// Name prefixes the server's tools: "<name>__<tool>".
+	Name       string            `toml:"name,omitempty"`
+	Command    string            `toml:"command,omitempty"`
+	Args       []string          `toml:"args,omitempty"`
+	Env        map[string]string `toml:"env,omitempty"`
+	WorkingDir string            `toml:"working_dir,omitempty"`
+	// Permission is the tier of every tool from this server. Defaults to
+	// write so that model-initiated calls are confirmed; override single
+	// tools through ToolPolicy using their namespaced names.
+	Permission     Permission `toml:"permission,omitempty"`
+	TimeoutSeconds int        `toml:"timeout_seconds,omitzero"`
+}
+
+// MCPClient manages the connection to one MCP server process.
+type MCPClient struct {
+	Config MCPServerConfig
+	// Logf receives lifecycle messages and the server's stderr. Defaults to log.Printf.
+	Logf func(format string, args ...interface{})
+
+	mu   sync.Mutex
+	conn *mcpConn
+	// starting is the launch in progress; tools keeps serving the last
+	// listing until it finishes.
+	starting  *mcpStart
+	tools     []MCPToolInfo
+	crashes   int
+	lastCrash time.Time
+	closed    bool
+}
+
+// mcpStart is one launch of the server that concurrent callers wait for.
+type mcpStart struct {
+	done chan struct{}
+	conn *mcpConn
+	err  error
+}
+
+// NewMCPClient validates the config; call Start to launch the server.
+func NewMCPClient(cfg MCPServerConfig) (*MCPClient, error) {
+	if !mcpServerNamePattern.MatchString(cfg.Name) {
+		return nil, fmt.Errorf("invalid MCP server name %q (letters, digits, '-' and '_' only)", cfg.Name)
+	}
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("MCP server %s: command is required", cfg.Name)
+	}
+	if cfg.Permission == "" {
+		cfg.Permission = PermissionWrite
+	}
+	if !IsPermission(string(cfg.Permission)) {
+		return nil, fmt.Errorf("MCP server %s: unknown permission %q", cfg.Name, cfg.Permission)
+	}
+	return &MCPClient{Config: cfg, Logf: log.Printf}, nil
+}
+
+// Start launches the server, performs the initialize handshake and lists its tools.
+func (c *MCPClient) Start() error {
+	_, err := c.connection()
+	return err
+}
+
+// Close shuts the server down. The client cannot be restarted afterwards.
+func (c *MCPClient) Close() {
+	c.mu.Lock()
+	c.closed = true
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn != nil {
+		conn.close()
+	}
+}
+
+// Tools returns a proxy for every tool the server advertised at start.
+func (c *MCPClient) Tools() []*MCPTool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	proxies := make([]*MCPTool, 0, len(c.tools))
+	for _, info := range c.tools {
+		proxies = append(proxies, &MCPTool{client: c, remote: info.Name, name: MCPToolName(c.Config.Name, info.Name)})
+	}
+	return proxies
+}
+
+// CallTool forwards a call to the server, restarting it first if it has exited.
+func (c *MCPClient) CallTool(name string, args map[string]interface{}) (interface{}, error) {
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+	var result mcpCallToolResult
+	if err := conn.request(c.timeout(), "tools/call", mcpCallToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, fmt.Errorf("MCP server %s: %w", c.Config.Name, err)
+	}
+	return decodeToolResult(result)
+}
+
+func (c *MCPClient) info(remote string) (MCPToolInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, info := range c.tools {
+		if info.Name == remote {
+			return info, true
+		}
+	}
+	return MCPToolInfo{}, false
+}
+
+func (c *MCPClient) timeout() time.Duration {
+	if c.Config.TimeoutSeconds > 0 {
+		return time.Duration(c.Config.TimeoutSeconds) * time.Second
+	}
+	return defaultMCPTimeout
+}
+
+// connection returns the live connection, starting or restarting the server
+// as needed. The handshake runs without c.mu held, so tool descriptions stay
+// available meanwhile; callers arriving during it wait for the same launch.
+func (c *MCPClient) connection() (*mcpConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("MCP server %s: client closed", c.Config.Name)
+	}
+	if c.conn != nil {
+		if !c.conn.exited() {
+			conn := c.conn
+			c.mu.Unlock()
+			return conn, nil
+		}
+		if time.Since(c.conn.started) < mcpCrashWindow {
+			c.crashes++
+		} else {
+			c.crashes = 0
+		}
+		c.lastCrash = time.Now()
+		c.Logf("MCP server %s exited: %v", c.Config.Name, c.conn.exitErr())
+		c.conn = nil
+	}
+	if start := c.starting; start != nil {
+		c.mu.Unlock()
+		<-start.done
+		return start.conn, start.err
+	}
+	if c.crashes >= mcpMaxCrashes {
+		if time.Since(c.lastCrash) < mcpCrashCooldown {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("MCP server %s keeps exiting right after start; not restarting for now", c.Config.Name)
+		}
+		c.crashes = 0
+	}
+	start := &mcpStart{done: make(chan struct{})}
+	c.starting = start
+	c.mu.Unlock()
+
+	conn, listed, err := c.launch()
+
+	c.mu.Lock()
+	c.starting = nil
+	switch {
+	case err != nil:
+		c.crashes++
+		c.lastCrash = time.Now()
+	case c.closed:
+		err = fmt.Errorf("MCP server %s: client closed", c.Config.Name)
+	default:
+		if c.tools != nil {
+			c.logToolChanges(listed)
+		}
+		c.tools = listed
+		c.conn = conn
+	}
+	c.mu.Unlock()
+	if err != nil && conn != nil {
+		// Closed while starting.
+		conn.close()
+		conn = nil
+	}
+	start.conn, start.err = conn, err
+	close(start.done)
+	return conn, err
+}
+
+// launch starts the process, runs the initialize handshake and lists the
+// server's tools. Called without c.mu held.
+func (c *MCPClient) launch() (*mcpConn, []MCPToolInfo, error) {
+	cmd := exec.Command(c.Config.Command, c.Config.Args...)
+	cmd.Dir = c.Config.WorkingDir
+	cmd.Env = os.Environ()
+	for k, v := range c.Config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	conn, err := startMCPConn(cmd, c.onNotification, func(line string) {
+		c.Logf("MCP server %s: %s", c.Config.Name, line)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("MCP server %s: failed to start: %w", c.Config.Name, err)
+	}
+
+	var init mcpInitializeResult
+	err = conn.request(mcpStartTimeout, "initialize", mcpInitializeParams{
+		ProtocolVersion: MCPProtocolVersion,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      mcpImplementation{Name: "nira", Version: "0.1.0"},
+	}, &init)
+	if err == nil {
+		err = conn.notify("notifications/initialized", nil)
+	}
+	var listed []MCPToolInfo
+	if err == nil {
+		listed, err = conn.listTools(mcpStartTimeout)
+	}
+	if err != nil {
+		conn.close()
+		return nil, nil, fmt.Errorf("MCP server %s: initialization failed: %w", c.Config.Name, err)
+	}
+
+	c.Logf("MCP server %s (%s %s) started with %d tools", c.Config.Name, init.ServerInfo.Name, init.ServerInfo.Version, len(listed))
+	return conn, listed, nil
+}
+
+func (c *MCPClient) onNotification(conn *mcpConn, msg *rpcMessage) {
+	if msg.Method != "notifications/tools/list_changed" {
+		return
+	}
+	go func() {
+		listed, err := conn.listTools(c.timeout())
+		if err != nil {
+			c.Logf("MCP server %s: failed to refresh tools: %v", c.Config.Name, err)
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.conn == conn {
+			c.logToolChanges(listed)
+			c.tools = listed
+		}
+	}()
+}
+
+// logToolChanges reports tools that were added, which only become callable
+// after NIRA restarts, and tools that vanished. Called with c.mu held.
+func (c *MCPClient) logToolChanges(listed []MCPToolInfo) {
+	old := map[string]bool{}
+	for _, info := range c.tools {
+		old[info.Name] = true
+	}
+	for _, info := range listed {
+		if !old[info.Name] {
+			c.Logf("MCP server %s added tool %s; restart NIRA to register it", c.Config.Name, info.Name)
+		}
+		delete(old, info.Name)
+	}
+	for name := range old {
+		c.Logf("MCP server %s no longer provides tool %s", c.Config.Name, name)
+	}
+}
+
+// decodeToolResult prefers structured content, then text, then raw content items.
+func decodeToolResult(result mcpCallToolResult) (interface{}, error) {
+	var texts []string
+	allText := true
+	for _, item := range result.Content {
+		if item.Type == "text" {
+			texts = append(texts, item.Text)
+		} else {
+			allText = false
+		}
+	}
+	if result.IsError {
+		if len(texts) == 0 {
+			return nil, errors.New("tool reported an error")
+		}
+		return nil, errors.New(strings.Join(texts, "\n"))
+	}
+	if result.StructuredContent != nil {
+		return result.StructuredContent, nil
+	}
+	if allText {
+		return strings.Join(texts, "\n"), nil
+	}
+	return result.Content, nil
+}
+
+// MCPTool proxies one remote tool.
+type MCPTool struct {
+	client *MCPClient
+	remote string
+	name   string
+}
+
+func (t *MCPTool) Name() string           { return t.name }
+func (t *MCPTool) Permission() Permission { return t.client.Config.Permission }
+func (t *MCPTool) Description() string {
+	info, _ := t.client.info(t.remote)
+	return fmt.Sprintf("[MCP %s] %s", t.client.Config.Name, info.Description)
+}
+func (t *MCPTool) Schema() map[string]interface{} {
+	info, _ := t.client.info(t.remote)
+	params := info.InputSchema
+	if params == nil {
+		params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return map[string]interface{}{
+		"name":        t.Name(),
+		"description": t.Description(),
+		"parameters":  params,
+	}
+}
+func (t *MCPTool) Execute(args map[string]interface{}) (interface{}, error) {
+	return t.client.CallTool(t.remote, args)
+}
+
+// mcpConn is one running server process and its JSON-RPC session.
+type mcpConn struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	started time.Time
+	nextID  int64
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *rpcMessage
+
+	stderrDone chan struct{}
+	done       chan struct{}
+	err        error
+}
+
+func startMCPConn(cmd *exec.Cmd, onNotify func(*mcpConn, *rpcMessage), onStderr func(string)) (*mcpConn, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &mcpConn{
+		cmd:     cmd,
+		stdin:   stdin,
+		started: time.Now(),
+		pending: map[int64]chan *rpcMessage{},
+		done:    make(chan struct{}),
+
+		stderrDone: make(chan struct{}),
+	}
+	go func() {
+		defer close(c.stderrDone)
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			onStderr(s.Text())
+		}
+	}()
+	go c.readLoop(stdout, onNotify)
+	return c, nil
+}
+
+func (c *mcpConn) readLoop(stdout io.Reader, onNotify func(*mcpConn, *rpcMessage)) {
+	r := bufio.NewReaderSize(stdout, 64<<10)
+	var readErr error
+	for {
+		line, err := readLine(r, maxMCPMessage)
+		if len(line) > 0 {
+			c.dispatch(line, onNotify)
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	if readErr != io.EOF {
+		c.cmd.Process.Kill()
+	}
+	// Wait closes the pipes, so let the stderr reader drain first.
+	select {
+	case <-c.stderrDone:
+	case <-time.After(mcpShutdownTimeout):
+	}
+	waitErr := c.cmd.Wait()
+	if waitErr != nil {
+		c.err = waitErr
+	} else if readErr != io.EOF {
+		c.err = readErr
+	} else {
+		c.err = errors.New("server closed its output")
+	}
+	close(c.done)
+}
+
+func (c *mcpConn) dispatch(line []byte, onNotify func(*mcpConn, *rpcMessage)) {
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Method == "" && len(msg.ID) > 0:
+		var id int64
+		if err := json.Unmarshal(msg.ID, &id); err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	case msg.Method != "" && len(msg.ID) > 0:
+		// NIRA offers no client capabilities; answer pings and refuse the rest.
+		reply := rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+		if msg.Method == "ping" {
+			reply.Result = json.RawMessage("{}")
+		} else {
+			reply.Error = &rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + msg.Method}
+		}
+		c.write(reply)
+	case msg.Method != "":
+		onNotify(c, &msg)
+	}
+}
+
+// request sends a JSON-RPC request and decodes the result into out.
+func (c *mcpConn) request(timeout time.Duration, method string, params, out interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+	ch := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(rpcMessage{JSONRPC: "2.0", ID: json.RawMessage(fmt.Sprint(id)), Method: method, Params: raw}); err != nil {
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if out == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, out); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-c.done:
+		return fmt.Errorf("server exited during %s: %v", method, c.err)
+	case <-ctx.Done():
+		c.notify("notifications/cancelled", map[string]interface{}{"requestId": id, "reason": "timeout"})
+		return fmt.Errorf("%s timed out after %v", method, timeout)
+	}
+}
+
+func (c *mcpConn) notify(method string, params interface{}) error {
+	msg := rpcMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+func (c *mcpConn) write(msg rpcMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// listTools follows nextCursor until every page has been read.
+func (c *mcpConn) listTools(timeout time.Duration) ([]MCPToolInfo, error) {
+	var all []MCPToolInfo
+	cursor := ""
+	for page := 0; page < 100; page++ {
+		var res mcpListToolsResult
+		if err := c.request(timeout, "tools/list", mcpListToolsParams{Cursor: cursor}, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" {
+			return all, nil
+		}
+		cursor = res.NextCursor
+	}
+	return nil, errors.New("tools/list returned too many pages")
+}
+
+func (c *mcpConn) exited() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *mcpConn) exitErr() error {
+	if c.exited() {
+		return c.err
+	}
+	return nil
+}
+
+// close asks the server to exit by closing stdin, killing it if it lingers.
+func (c *mcpConn) close() {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(mcpShutdownTimeout):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+}
+
+// readLine reads one newline-terminated message, rejecting lines over max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		line = append(line, chunk...)
+		if len(line) > max {
+			return nil, fmt.Errorf("message exceeds %d bytes", max)
+		}
+		if err != nil || !isPrefix {
+			return line, err
+		}
+	}
+}
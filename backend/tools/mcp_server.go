@@ -0,0 +1,183 @@
+/**
+ * MCP server module.
+ *
+ * Serves tools from a Registry to Model Context Protocol clients over
+ * stdio so other local agents and editors can use NIRA's file, RAG and
+ * RP tools. Tools keep their own sandboxing (AllowedDirsStore); which
+ * tools are offered at all is decided by the caller.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: mcp_server.go
+ * Description: MCP stdio server over the tool registry.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// MCPServer answers MCP requests using tools from a Registry.
+type MCPServer struct {
+	Registry *Registry
+	// Expose decides which tools are listed and callable. Nil exposes every tool.
+	Expose func(tool Tool) bool
+	// Invoke runs a call so the caller can log and audit it. Nil calls Execute directly.
+	Invoke       func(tool Tool, args map[string]interface{}) (interface{}, error)
+	Name         string
+	Version      string
+	Instructions string
+
+	writeMu sync.Mutex
+	out     io.Writer
+}
+
+func NewMCPServer(registry *Registry) *MCPServer {
+	return &MCPServer{Registry: registry, Name: "nira", Version: "0.1.0"}
+}
+
+// Serve handles messages from in until it is closed. Tool calls run
+// concurrently; Serve waits for them before returning.
+func (s *MCPServer) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	r := bufio.NewReaderSize(in, 64<<10)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		line, err := readLine(r, maxMCPMessage)
+		if len(line) > 0 {
+			var msg rpcMessage
+			if jerr := json.Unmarshal(line, &msg); jerr != nil {
+				s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: "invalid JSON"})
+			} else if msg.Method == "tools/call" && len(msg.ID) > 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.handle(&msg)
+				}()
+			} else {
+				s.handle(&msg)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *MCPServer) handle(msg *rpcMessage) {
+	if len(msg.ID) == 0 {
+		// Notifications (initialized, cancelled) need no answer.
+		return
+	}
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg.ID, mcpInitializeResult{
+			ProtocolVersion: MCPProtocolVersion,
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+			ServerInfo:      mcpImplementation{Name: s.Name, Version: s.Version},
+			Instructions:    s.Instructions,
+		}, nil)
+	case "ping":
+		s.reply(msg.ID, map[string]interface{}{}, nil)
+	case "tools/list":
+		s.reply(msg.ID, mcpListToolsResult{Tools: s.listTools()}, nil)
+	case "tools/call":
+		var params mcpCallToolParams
+		if len(msg.Params) > 0 {
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				s.reply(msg.ID, nil, &rpcError{Code: rpcInvalidParams, Message: "invalid tools/call params"})
+				return
+			}
+		}
+		tool, ok := s.lookup(params.Name)
+		if !ok {
+			s.reply(msg.ID, nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name})
+			return
+		}
+		s.reply(msg.ID, s.call(tool, params.Arguments), nil)
+	case "":
+		s.reply(msg.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "missing method"})
+	default:
+		s.reply(msg.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + msg.Method})
+	}
+}
+
+func (s *MCPServer) listTools() []MCPToolInfo {
+	list := []MCPToolInfo{}
+	for _, tool := range s.Registry.Tools {
+		if s.Expose != nil && !s.Expose(tool) {
+			continue
+		}
+		schema, _ := tool.Schema()["parameters"].(map[string]interface{})
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		list = append(list, MCPToolInfo{Name: tool.Name(), Description: tool.Description(), InputSchema: schema})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func (s *MCPServer) lookup(name string) (Tool, bool) {
+	tool, ok := s.Registry.Get(name)
+	if !ok || (s.Expose != nil && !s.Expose(tool)) {
+		return nil, false
+	}
+	return tool, true
+}
+
+// call runs the tool; tool failures are results with isError, not protocol errors.
+func (s *MCPServer) call(tool Tool, args map[string]interface{}) mcpCallToolResult {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result interface{}
+	var err error
+	if s.Invoke != nil {
+		result, err = s.Invoke(tool, args)
+	} else {
+		result, err = tool.Execute(args)
+	}
+	if err != nil {
+		return mcpCallToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+	text, ok := result.(string)
+	if !ok {
+		data, merr := json.MarshalIndent(result, "", "  ")
+		if merr != nil {
+			text = fmt.Sprint(result)
+		} else {
+			text = string(data)
+		}
+	}
+	return mcpCallToolResult{Content: []mcpContent{{Type: "text", Text: text}}}
+}
+
+func (s *MCPServer) reply(id json.RawMessage, result interface{}, rerr *rpcError) {
+	msg := rpcMessage{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		} else {
+			msg.Result = data
+		}
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
@@ -1,7 +1,36 @@
 package tools
 
+import (
+    "fmt"
+    "nira/sandbox"
+)
+
 // PathChecker abstracts permission checks for filesystem paths.
 // Implemented by memory.AllowedDirsStore.
 type PathChecker interface {
     IsAllowed(path string) bool
 }
+
+// AccessChecker is a PathChecker that also knows per-directory access
+// modes and deny-lists. Implemented by memory.AllowedDirsStore.
+type AccessChecker interface {
+    PathChecker
+    CheckAccess(path string, access sandbox.Access) error
+}
+
+// checkPathAccess checks path for the given access against the central
+// checker when one is set, otherwise against a read-write sandbox built
+// from the tool's static roots.
+func checkPathAccess(checker PathChecker, roots []string, path string, access sandbox.Access) error {
+    switch c := checker.(type) {
+    case nil:
+        return sandbox.New(roots).CheckAccess(path, access)
+    case AccessChecker:
+        return c.CheckAccess(path, access)
+    default:
+        if c.IsAllowed(path) {
+            return nil
+        }
+        return fmt.Errorf("path '%s' is %w", path, sandbox.ErrNotAllowed)
+    }
+}
@@ -0,0 +1,134 @@
+/**
+ * Tool permission module.
+ *
+ * Classifies tools into permission tiers and decides, per tier, whether a
+ * model-initiated call runs automatically, needs the user's confirmation,
+ * or is refused outright.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: permission.go
+ * Description: Tool permission tiers and execution policy.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Permission is the risk tier a tool belongs to.
+type Permission string
+
+const (
+	PermissionRead        Permission = "read"
+	PermissionWrite       Permission = "write"
+	PermissionDestructive Permission = "destructive"
+	PermissionNetwork     Permission = "network"
+	PermissionGrant       Permission = "permission"
+)
+
+// Decision is what the policy says to do with a tool call.
+type Decision string
+
+const (
+	DecisionAllow Decision = "allow"
+	DecisionAsk   Decision = "ask"
+	DecisionDeny  Decision = "deny"
+)
+
+// Policy maps permission tiers (and optionally individual tools) to decisions.
+type Policy struct {
+	Tiers     map[Permission]Decision
+	Overrides map[string]Decision
+
+	// mu lets Update replace the decisions while calls are being decided.
+	mu sync.RWMutex
+}
+
+// DefaultPolicy auto-runs reads and network lookups and asks before anything
+// that changes files, deletes data or widens NIRA's own permissions.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Tiers: map[Permission]Decision{
+			PermissionRead:        DecisionAllow,
+			PermissionNetwork:     DecisionAllow,
+			PermissionWrite:       DecisionAsk,
+			PermissionDestructive: DecisionAsk,
+			PermissionGrant:       DecisionAsk,
+		},
+		Overrides: map[string]Decision{},
+	}
+}
+
+// NewPolicy builds a policy from string settings, starting from DefaultPolicy.
+// Keys are tier names ("write") or tool names ("write_file"); values are
+// allow, ask or deny.
+func NewPolicy(settings map[string]string) (*Policy, error) {
+	p := DefaultPolicy()
+	for key, value := range settings {
+		d, err := ParseDecision(value)
+		if err != nil {
+			return nil, fmt.Errorf("tool policy %q: %w", key, err)
+		}
+		if IsPermission(key) {
+			p.Tiers[Permission(key)] = d
+		} else {
+			p.Overrides[key] = d
+		}
+	}
+	return p, nil
+}
+
+// Update replaces p's decisions with those of other, so every holder of p
+// sees the new policy.
+func (p *Policy) Update(other *Policy) {
+	other.mu.RLock()
+	tiers := make(map[Permission]Decision, len(other.Tiers))
+	for k, v := range other.Tiers {
+		tiers[k] = v
+	}
+	overrides := make(map[string]Decision, len(other.Overrides))
+	for k, v := range other.Overrides {
+		overrides[k] = v
+	}
+	other.mu.RUnlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Tiers, p.Overrides = tiers, overrides
+}
+
+// Decide returns the decision for a tool; unknown tiers fall back to ask.
+func (p *Policy) Decide(tool Tool) Decision {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if d, ok := p.Overrides[tool.Name()]; ok {
+		return d
+	}
+	if d, ok := p.Tiers[tool.Permission()]; ok {
+		return d
+	}
+	return DecisionAsk
+}
+
+// ParseDecision validates a decision string.
+func ParseDecision(s string) (Decision, error) {
+	switch d := Decision(strings.ToLower(strings.TrimSpace(s))); d {
+	case DecisionAllow, DecisionAsk, DecisionDeny:
+		return d, nil
+	default:
+		return "", fmt.Errorf("invalid decision %q (expected allow, ask or deny)", s)
+	}
+}
+
+// IsPermission reports whether s names a known permission tier.
+func IsPermission(s string) bool {
+	switch Permission(s) {
+	case PermissionRead, PermissionWrite, PermissionDestructive, PermissionNetwork, PermissionGrant:
+		return true
+	}
+	return false
+}
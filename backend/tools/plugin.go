@@ -0,0 +1,321 @@
+/**
+ * Plugin tool module.
+ *
+ * Loads team-specific tools from external executables without recompiling
+ * NIRA. Each plugin lives in its own subdirectory of the plugins directory
+ * with a plugin.json manifest declaring its name, description, schema and
+ * command. Calls are sent as JSON on stdin and answered as JSON on stdout.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: plugin.go
+ * Description: External executable tools loaded from manifests.
+ */
+
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nira/sandbox"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// PluginManifestFile is the manifest file name expected in each plugin directory.
+const PluginManifestFile = "plugin.json"
+
+const (
+	defaultPluginTimeout = 30 * time.Second
+	maxPluginOutput      = 4 << 20
+)
+
+var pluginNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// PluginManifest describes an external tool.
+type PluginManifest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Command is a path relative to the plugin directory, an absolute path,
+	// or a program name looked up on PATH (e.g. "python3").
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Permission is the tool's tier; plugins default to "write" so that
+	// model-initiated calls are confirmed unless the manifest says otherwise.
+	Permission Permission `json:"permission"`
+	// Parameters is the JSON schema object for the tool's arguments.
+	Parameters map[string]interface{} `json:"parameters"`
+	// PathArguments names string arguments that are filesystem paths; each
+	// must be inside an allowed directory. Relative paths are taken from the
+	// working directory, and the plugin receives them absolute.
+	PathArguments []string `json:"path_arguments"`
+	// PathAccess is the access path arguments and the working directory are
+	// checked for: "read" or "write" (the default, since NIRA cannot tell
+	// what the plugin does with them).
+	PathAccess string `json:"path_access"`
+	// WorkingDir is where the command runs, relative to the plugin
+	// directory; it must be inside an allowed directory. Defaults to the
+	// plugin directory.
+	WorkingDir     string `json:"working_dir"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// PluginRequest is written to the plugin's stdin.
+type PluginRequest struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// PluginResponse is read from the plugin's stdout.
+type PluginResponse struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// PluginTool proxies tool calls to an external executable.
+type PluginTool struct {
+	Manifest PluginManifest
+	dir      string
+	command  string
+	workDir  string
+	access   sandbox.Access
+	checker  PathChecker
+}
+
+// LoadPluginManifest reads and validates the manifest in a plugin directory.
+func LoadPluginManifest(dir string, checker PathChecker) (*PluginTool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, PluginManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m PluginManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if !pluginNamePattern.MatchString(m.Name) {
+		return nil, fmt.Errorf("invalid plugin name %q (letters, digits and underscores only)", m.Name)
+	}
+	if m.Command == "" {
+		return nil, fmt.Errorf("plugin %s: command is required", m.Name)
+	}
+	if m.Permission == "" {
+		m.Permission = PermissionWrite
+	}
+	if !IsPermission(string(m.Permission)) {
+		return nil, fmt.Errorf("plugin %s: unknown permission %q", m.Name, m.Permission)
+	}
+	if m.Parameters == nil {
+		m.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	if m.WorkingDir == "" {
+		m.WorkingDir = "."
+	}
+	access := sandbox.AccessWrite
+	switch m.PathAccess {
+	case "", "write":
+		m.PathAccess = "write"
+	case "read":
+		access = sandbox.AccessRead
+	default:
+		return nil, fmt.Errorf("plugin %s: path_access must be read or write, got %q", m.Name, m.PathAccess)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	workDir := m.WorkingDir
+	if !filepath.IsAbs(workDir) {
+		workDir = filepath.Join(absDir, workDir)
+	}
+	command := m.Command
+	if filepath.IsAbs(command) || filepath.Base(command) != command {
+		if !filepath.IsAbs(command) {
+			command = filepath.Join(absDir, command)
+		}
+		if _, err := os.Stat(command); err != nil {
+			return nil, fmt.Errorf("plugin %s: command not found: %w", m.Name, err)
+		}
+	} else if local := filepath.Join(absDir, command); fileExists(local) {
+		command = local
+	} else if command, err = exec.LookPath(m.Command); err != nil {
+		return nil, fmt.Errorf("plugin %s: command not found: %w", m.Name, err)
+	}
+
+	return &PluginTool{Manifest: m, dir: absDir, command: command, workDir: filepath.Clean(workDir), access: access, checker: checker}, nil
+}
+
+// LoadPlugins loads every plugin under pluginsDir. A missing directory yields
+// no plugins; a broken plugin is reported in errs without stopping the rest.
+func LoadPlugins(pluginsDir string, checker PathChecker) (plugins []*PluginTool, errs []error) {
+	entries, err := os.ReadDir(pluginsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, []error{fmt.Errorf("failed to read plugins directory: %w", err)}
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(pluginsDir, e.Name())
+		if !fileExists(filepath.Join(dir, PluginManifestFile)) {
+			continue
+		}
+		p, err := LoadPluginManifest(dir, checker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, errs
+}
+
+func (t *PluginTool) Name() string           { return t.Manifest.Name }
+func (t *PluginTool) Description() string    { return t.Manifest.Description }
+func (t *PluginTool) Permission() Permission { return t.Manifest.Permission }
+func (t *PluginTool) Schema() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        t.Name(),
+		"description": t.Description(),
+		"parameters":  t.Manifest.Parameters,
+	}
+}
+
+func (t *PluginTool) Execute(args map[string]interface{}) (interface{}, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if required, ok := t.Manifest.Parameters["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if v, present := args[name]; !present || v == nil {
+					return nil, fmt.Errorf("%s argument is required", name)
+				}
+			}
+		}
+	}
+	// The plugin gets the paths that were checked, not ones it would
+	// resolve against its own working directory.
+	resolved := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		resolved[k] = v
+	}
+	for _, name := range t.Manifest.PathArguments {
+		v, present := args[name]
+		if !present {
+			continue
+		}
+		p, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s argument must be a string", name)
+		}
+		abs, err := t.resolvePath(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s argument: %w", name, err)
+		}
+		if err := t.checkPath(abs); err != nil {
+			return nil, fmt.Errorf("%s argument: %w", name, err)
+		}
+		resolved[name] = abs
+	}
+
+	if err := t.checkPath(t.workDir); err != nil {
+		return nil, fmt.Errorf("plugin working directory: %w", err)
+	}
+
+	timeout := defaultPluginTimeout
+	if t.Manifest.TimeoutSeconds > 0 {
+		timeout = time.Duration(t.Manifest.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	input, err := json.Marshal(PluginRequest{Name: t.Name(), Arguments: resolved})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, t.command, t.Manifest.Args...)
+	cmd.Dir = t.workDir
+	cmd.Env = append(os.Environ(), "NIRA_PLUGIN_DIR="+t.dir)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr limitedBuffer
+	stdout.limit, stderr.limit = maxPluginOutput, 64<<10
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Don't wait on grandchildren still holding the pipes after a timeout kill.
+	cmd.WaitDelay = 2 * time.Second
+
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s timed out after %v", t.Name(), timeout)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("plugin %s failed: %v: %s", t.Name(), runErr, truncate(stderr.String(), 500))
+	}
+	if stdout.overflow {
+		return nil, fmt.Errorf("plugin %s output exceeds %d bytes", t.Name(), maxPluginOutput)
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid JSON: %w (output: %s)", t.Name(), err, truncate(stdout.String(), 200))
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Result, nil
+}
+
+// resolvePath makes path absolute against the plugin's working directory,
+// where the plugin process would open it, and resolves symlinks.
+func (t *PluginTool) resolvePath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path must not be empty")
+	}
+	if !filepath.IsAbs(path) {
+		// Not filepath.Join: cleaning would drop ".." lexically.
+		path = t.workDir + string(filepath.Separator) + path
+	}
+	return sandbox.Resolve(path)
+}
+
+// checkPath checks a path the plugin will use for the manifest's path_access.
+// Without a checker nothing is allowed.
+func (t *PluginTool) checkPath(path string) error {
+	if t.checker == nil {
+		return fmt.Errorf("path '%s' is %w", path, sandbox.ErrNotAllowed)
+	}
+	return checkPathAccess(t.checker, nil, path, t.access)
+}
+
+// limitedBuffer keeps at most limit bytes and records whether more were written.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.overflow = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
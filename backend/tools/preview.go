@@ -0,0 +1,197 @@
+/**
+ * File change preview module.
+ *
+ * Mutating file tools describe the change they would make (a unified diff
+ * against the current content) before running, so the user can approve
+ * it. The content hash the preview was based on travels with the approved
+ * call; if the file changed in between, the tool refuses to write.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: preview.go
+ * Description: Dry-run previews for file-changing tools.
+ */
+
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// PreviewBaseArg is the transport argument carrying the approved preview's
+// content hashes (path -> sha256, "" for a file that did not exist).
+const PreviewBaseArg = "_preview_base"
+
+// maxPreviewBytes bounds the content size for which a diff is rendered.
+const maxPreviewBytes = 1 << 20
+
+// FilePreview describes one file change a tool would make.
+type FilePreview struct {
+	Path string `json:"path"`
+	// Action is create, modify, delete, move, replace or unchanged.
+	Action string `json:"action"`
+	// Summary describes changes a diff cannot show, such as moves, copies,
+	// directories and extractions.
+	Summary string `json:"summary,omitempty"`
+	Diff    string `json:"diff,omitempty"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	// Omitted is set when the content is binary or too large to diff.
+	Omitted    string `json:"omitted,omitempty"`
+	BaseSHA256 string `json:"base_sha256"`
+}
+
+// Lines is the number of changed lines, used for auto-apply thresholds.
+func (p FilePreview) Lines() int {
+	return p.Added + p.Removed
+}
+
+// Previewer is implemented by tools that can report their file changes
+// without applying them.
+type Previewer interface {
+	Preview(args map[string]interface{}) ([]FilePreview, error)
+}
+
+// previewChange builds the preview of replacing path's current content with
+// after; a nil after means the file is deleted.
+func previewChange(path string, after []byte) (FilePreview, error) {
+	before, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return FilePreview{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	p := FilePreview{Path: path, Action: "modify"}
+	if existed {
+		p.BaseSHA256 = contentSHA256(before)
+	}
+	switch {
+	case after == nil && !existed:
+		p.Action = "unchanged"
+		return p, nil
+	case after == nil:
+		p.Action = "delete"
+	case !existed:
+		p.Action = "create"
+	case string(before) == string(after):
+		p.Action = "unchanged"
+		return p, nil
+	}
+
+	switch {
+	case len(before) > maxPreviewBytes || len(after) > maxPreviewBytes:
+		p.Omitted = "too large to diff"
+	case !utf8.Valid(before) || !utf8.Valid(after):
+		p.Omitted = "binary content"
+	default:
+		p.Diff = UnifiedDiff(filepath.Base(path), string(before), string(after))
+		p.Added, p.Removed = DiffStats(p.Diff)
+	}
+	return p, nil
+}
+
+// checkPreviewBase fails when an approved preview no longer matches the file.
+// Calls without preview information (direct or unpreviewed) pass.
+func checkPreviewBase(args map[string]interface{}, path string) error {
+	bases, ok := args[PreviewBaseArg].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	want, ok := bases[path].(string)
+	if !ok {
+		return nil
+	}
+	if pathSHA256(path) != want {
+		return fmt.Errorf("'%s' changed after the preview was approved; nothing was written", path)
+	}
+	return nil
+}
+
+// checkPreviewBases runs checkPreviewBase for each path.
+func checkPreviewBases(args map[string]interface{}, paths ...string) error {
+	for _, p := range paths {
+		if err := checkPreviewBase(args, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contentSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// pathSHA256 fingerprints what is at path for preview pinning: the content
+// of a file (as previewChange hashes it), or the names, sizes and
+// modification times of everything below a directory. A missing path
+// gives "".
+func pathSHA256(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		if target, err := os.Readlink(path); err == nil {
+			return contentSHA256([]byte("symlink:" + target)) // dangling
+		}
+		return ""
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		return contentSHA256(data)
+	}
+	h := sha256.New()
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(path, p)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00error\n", rel)
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(h, "%s\x00%v\x00%d\x00%d\n", rel, info.Mode(), info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return "dir:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// describePath summarizes what is at path, e.g. "file (1.2 KB)" or
+// "directory (12 files, 3.4 MB)".
+func describePath(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, _ := os.Readlink(path)
+		return fmt.Sprintf("symlink to %s", target), nil
+	case !info.IsDir():
+		return fmt.Sprintf("file (%s)", formatBytes(info.Size())), nil
+	}
+	files, size := 0, int64(0)
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			files++
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return fmt.Sprintf("directory (%d %s, %s)", files, plural(files, "file", "files"), formatBytes(size)), nil
+}
+
+// previewPath builds a preview of a change that a diff cannot show, pinned
+// to what is at path now.
+func previewPath(path, action, summary string) FilePreview {
+	return FilePreview{Path: path, Action: action, Summary: summary, BaseSHA256: pathSHA256(path)}
+}
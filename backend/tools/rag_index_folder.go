@@ -3,6 +3,7 @@ package tools
 import (
     "fmt"
     "io"
+    "nira/sandbox"
     "os"
     "path/filepath"
     "strings"
@@ -14,6 +15,13 @@ type RagIndexWriter interface {
     Upsert(path, name, modTime string, size int64, content string) error
 }
 
+type ragIndexFolderArgs struct {
+    Root      string   `json:"root" desc:"Root directory to index" required:"true"`
+    Patterns  []string `json:"patterns" desc:"Glob patterns to include (e.g., *.md)" default:"*.md,*.txt,*.json,*.yaml,*.yml"`
+    MaxSizeMB int      `json:"max_size_mb" desc:"Max file size in MB" default:"2"`
+    MaxFiles  int      `json:"max_files" desc:"Max files to index" default:"500"`
+}
+
 // RagIndexFolderTool indexes text files under an allowed directory into the basic rag_index table.
 type RagIndexFolderTool struct {
     checker PathChecker
@@ -25,56 +33,43 @@ func NewRagIndexFolderTool(checker PathChecker, index RagIndexWriter) *RagIndexF
 }
 
 func (t *RagIndexFolderTool) Name() string        { return "rag_index_folder" }
+func (t *RagIndexFolderTool) Permission() Permission { return PermissionWrite }
 func (t *RagIndexFolderTool) Description() string { return "Indexes text files in a folder. Args: root (string), patterns ([string], optional), max_size_mb (int), max_files (int)." }
 func (t *RagIndexFolderTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "root": map[string]interface{}{"type": "string", "description": "Root directory to index"},
-                "patterns": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "Glob patterns to include (e.g., *.md)"},
-                "max_size_mb": map[string]interface{}{"type": "integer", "description": "Max file size in MB (default 2)"},
-                "max_files": map[string]interface{}{"type": "integer", "description": "Max files to index (default 500)"},
-            },
-            "required": []string{"root"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), ragIndexFolderArgs{})
 }
 
 func (t *RagIndexFolderTool) Execute(args map[string]interface{}) (interface{}, error) {
-    root, ok := args["root"].(string)
-    if !ok || root == "" { return nil, fmt.Errorf("root argument is required and must be a string") }
-    if t.checker == nil || !t.checker.IsAllowed(root) {
-        return nil, fmt.Errorf("root '%s' is not in allowed directories", root)
+    var a ragIndexFolderArgs
+    if err := DecodeArgs(args, &a); err != nil { return nil, err }
+    root := a.Root
+    if root == "" { return nil, fmt.Errorf("root argument must not be empty") }
+    if t.checker == nil {
+        return nil, fmt.Errorf("root '%s' is %w", root, sandbox.ErrNotAllowed)
+    }
+    if err := checkPathAccess(t.checker, nil, root, sandbox.AccessIndex); err != nil {
+        return nil, err
     }
 
-    // Extract patterns
     var patterns []string
-    if v, ok := args["patterns"].([]interface{}); ok {
-        for _, it := range v {
-            if s, ok := it.(string); ok && s != "" { patterns = append(patterns, s) }
-        }
+    for _, p := range a.Patterns {
+        if p != "" { patterns = append(patterns, p) }
     }
     if len(patterns) == 0 {
         patterns = []string{"*.md", "*.txt", "*.json", "*.yaml", "*.yml"}
     }
-    maxSizeMB := 2
-    if v, ok := args["max_size_mb"]; ok {
-        switch n := v.(type) { case float64: maxSizeMB = int(n); case int: maxSizeMB = n }
-    }
-    maxFiles := 500
-    if v, ok := args["max_files"]; ok {
-        switch n := v.(type) { case float64: maxFiles = int(n); case int: maxFiles = n }
-    }
+    maxSizeMB := a.MaxSizeMB
+    maxFiles := a.MaxFiles
     indexed := 0
     var lastErr error
 
     err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
         if err != nil { return nil }
+        if checkPathAccess(t.checker, nil, p, sandbox.AccessIndex) != nil {
+            if d.IsDir() { return filepath.SkipDir }
+            return nil
+        }
         if d.IsDir() { return nil }
-        if !t.checker.IsAllowed(p) { return nil }
         name := d.Name()
         // pattern match
         matched := false
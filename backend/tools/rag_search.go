@@ -7,6 +7,12 @@ type RagSearcher interface {
     Search(query string, limit int, pathPrefix string) ([]map[string]interface{}, error)
 }
 
+type ragSearchArgs struct {
+    Query      string `json:"query" desc:"Query text" required:"true"`
+    Limit      int    `json:"limit" desc:"Max results" default:"10"`
+    PathPrefix string `json:"path_prefix" desc:"Restrict to paths under this prefix"`
+}
+
 type RagSearchTool struct {
     search RagSearcher
     checker PathChecker
@@ -17,35 +23,23 @@ func NewRagSearchTool(search RagSearcher, checker PathChecker) *RagSearchTool {
 }
 
 func (t *RagSearchTool) Name() string { return "rag_search" }
+func (t *RagSearchTool) Permission() Permission { return PermissionRead }
 func (t *RagSearchTool) Description() string {
     return "Searches the lightweight index for files/snippets. Args: query (string), limit (int, optional), path_prefix (string, optional)."
 }
 func (t *RagSearchTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "query": map[string]interface{}{"type": "string", "description": "Query text"},
-                "limit": map[string]interface{}{"type": "integer", "description": "Max results (default 10)"},
-                "path_prefix": map[string]interface{}{"type": "string", "description": "Restrict to paths under this prefix"},
-            },
-            "required": []string{"query"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), ragSearchArgs{})
 }
 
 func (t *RagSearchTool) Execute(args map[string]interface{}) (interface{}, error) {
-    q, ok := args["query"].(string)
-    if !ok || q == "" {
-        return nil, fmt.Errorf("query is required")
+    var a ragSearchArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
     }
-    limit := 10
-    if v, ok := args["limit"]; ok {
-        switch n := v.(type) { case float64: limit = int(n); case int: limit = n }
+    q, limit, pathPrefix := a.Query, a.Limit, a.PathPrefix
+    if q == "" {
+        return nil, fmt.Errorf("query is required")
     }
-    pathPrefix, _ := args["path_prefix"].(string)
     if pathPrefix != "" && t.checker != nil && !t.checker.IsAllowed(pathPrefix) {
         return nil, fmt.Errorf("path_prefix '%s' is not in allowed directories", pathPrefix)
     }
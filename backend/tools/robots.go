@@ -0,0 +1,208 @@
+/**
+ * robots.txt module.
+ *
+ * Parses robots.txt (RFC 9309) for NIRA's user agent and answers whether a
+ * URL path may be fetched. Rules are read from the group naming "nira",
+ * or the "*" group when there is none; the longest matching rule wins and
+ * allow wins ties. Parsed files are kept per host for an hour.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: robots.go
+ * Description: robots.txt parsing, matching and caching.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// robotsAgent is the product token matched against User-agent lines.
+	robotsAgent = "nira"
+	robotsTTL   = time.Hour
+	// robotsRetry is how long an unreachable robots.txt blocks a host.
+	robotsRetry = time.Minute
+	// maxRobotsSize is the part of a robots.txt that is parsed.
+	maxRobotsSize = 512 << 10
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsRules are the rules that apply to NIRA on one host.
+type robotsRules struct {
+	rules []robotsRule
+	// disallowAll is set when robots.txt could not be fetched because of a
+	// server error, which RFC 9309 treats as a full disallow.
+	disallowAll bool
+}
+
+// parseRobots extracts the rules for agent from a robots.txt body.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	type group struct {
+		agents []string
+		rules  []robotsRule
+	}
+	var groups []*group
+	var cur *group
+	lastWasAgent := false
+	sc := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if cur == nil || !lastWasAgent {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if cur == nil || value == "" {
+				// An empty Disallow allows everything; nothing to record.
+				continue
+			}
+			cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)})
+		default:
+			// Sitemap, Crawl-delay and unknown keys do not end a group.
+		}
+	}
+
+	var mine, star []robotsRule
+	foundMine := false
+	for _, g := range groups {
+		isMine, isStar := false, false
+		for _, a := range g.agents {
+			isMine = isMine || (a != "*" && strings.Contains(a, agent))
+			isStar = isStar || a == "*"
+		}
+		if isMine {
+			mine = append(mine, g.rules...)
+			foundMine = true
+		} else if isStar {
+			star = append(star, g.rules...)
+		}
+	}
+	if foundMine {
+		return robotsRules{rules: mine}
+	}
+	return robotsRules{rules: star}
+}
+
+// robotsPattern compiles a rule path where * matches any characters and a
+// trailing $ anchors the end.
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed reports whether path (with its query) may be fetched.
+func (r robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+type robotsEntry struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+// robotsCache fetches and remembers robots.txt per scheme and host.
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}
+
+// check fetches robots.txt for u's host when needed and returns an error
+// when u may not be fetched.
+func (c *robotsCache) check(client *http.Client, u *url.URL) error {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		entry = robotsEntry{rules: fetchRobots(client, key+"/robots.txt"), expires: time.Now().Add(robotsTTL)}
+		if entry.rules.disallowAll {
+			entry.expires = time.Now().Add(robotsRetry)
+		}
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = map[string]robotsEntry{}
+		}
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	if entry.rules.disallowAll {
+		return fmt.Errorf("robots.txt of %s could not be read (server error or unreachable); not fetching %s", u.Host, u)
+	}
+	if !entry.rules.allowed(u.RequestURI()) {
+		return fmt.Errorf("robots.txt of %s disallows fetching %s", u.Host, u.RequestURI())
+	}
+	return nil
+}
+
+// fetchRobots downloads and parses a robots.txt. A missing file (4xx)
+// allows everything; a server error or an unreachable host disallows
+// everything.
+func fetchRobots(client *http.Client, robotsURL string) robotsRules {
+	req, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		return robotsRules{disallowAll: true}
+	}
+	req.Header.Set("User-Agent", webFetchUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(resp.Body, robotsAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsRules{}
+	}
+	return robotsRules{disallowAll: true}
+}
@@ -7,6 +7,45 @@ import (
 	"time"
 )
 
+// ---- Arguments ----
+
+type rpIDArgs struct {
+	ID string `json:"id" required:"true"`
+}
+
+type rpListArgs struct {
+	Query  string `json:"query"`
+	Limit  int    `json:"limit" default:"100"`
+	Offset int    `json:"offset"`
+}
+
+type rpStoryCardListArgs struct {
+	Query  string `json:"query"`
+	Kind   string `json:"kind"`
+	Limit  int    `json:"limit" default:"100"`
+	Offset int    `json:"offset"`
+}
+
+type rpCharacterSaveArgs struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name" required:"true"`
+	Summary    string   `json:"summary"`
+	Traits     []string `json:"traits"`
+	Background string   `json:"background"`
+	Goals      []string `json:"goals"`
+	Tags       []string `json:"tags"`
+	Notes      string   `json:"notes"`
+}
+
+type rpStoryCardSaveArgs struct {
+	ID      string   `json:"id"`
+	Title   string   `json:"title" required:"true"`
+	Kind    string   `json:"kind" required:"true"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	Links   []string `json:"links"`
+}
+
 // ---- Character tools ----
 
 type RPCharacterListTool struct{ store *memory.RPStore }
@@ -14,29 +53,20 @@ type RPCharacterListTool struct{ store *memory.RPStore }
 func NewRPCharacterListTool(store *memory.RPStore) *RPCharacterListTool {
 	return &RPCharacterListTool{store: store}
 }
-func (t *RPCharacterListTool) Name() string { return "rp_character_list" }
+func (t *RPCharacterListTool) Name() string           { return "rp_character_list" }
+func (t *RPCharacterListTool) Permission() Permission { return PermissionRead }
 func (t *RPCharacterListTool) Description() string {
 	return "Lists RP characters. Args: query (string, optional), limit (int), offset (int)."
 }
 func (t *RPCharacterListTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"query":  map[string]interface{}{"type": "string"},
-				"limit":  map[string]interface{}{"type": "integer"},
-				"offset": map[string]interface{}{"type": "integer"},
-			},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpListArgs{})
 }
 func (t *RPCharacterListTool) Execute(args map[string]interface{}) (interface{}, error) {
-	q, _ := args["query"].(string)
-	limit := intFrom(args["limit"], 100)
-	offset := intFrom(args["offset"], 0)
-	characters, err := t.store.ListCharacters(q, limit, offset)
+	var a rpListArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	characters, err := t.store.ListCharacters(a.Query, a.Limit, a.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -57,27 +87,18 @@ type RPCharacterGetTool struct{ store *memory.RPStore }
 func NewRPCharacterGetTool(store *memory.RPStore) *RPCharacterGetTool {
 	return &RPCharacterGetTool{store: store}
 }
-func (t *RPCharacterGetTool) Name() string { return "rp_character_get" }
+func (t *RPCharacterGetTool) Name() string           { return "rp_character_get" }
+func (t *RPCharacterGetTool) Permission() Permission { return PermissionRead }
 func (t *RPCharacterGetTool) Description() string {
 	return "Gets a character by id. Args: id (string)."
 }
 func (t *RPCharacterGetTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id": map[string]interface{}{"type": "string"},
-			},
-			"required": []string{"id"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpIDArgs{})
 }
 func (t *RPCharacterGetTool) Execute(args map[string]interface{}) (interface{}, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return nil, fmt.Errorf("id is required")
+	id, err := decodeID(args)
+	if err != nil {
+		return nil, err
 	}
 	character, err := t.store.GetCharacter(id)
 	if err != nil {
@@ -100,45 +121,35 @@ type RPCharacterSaveTool struct{ store *memory.RPStore }
 func NewRPCharacterSaveTool(store *memory.RPStore) *RPCharacterSaveTool {
 	return &RPCharacterSaveTool{store: store}
 }
-func (t *RPCharacterSaveTool) Name() string { return "rp_character_save" }
+func (t *RPCharacterSaveTool) Name() string           { return "rp_character_save" }
+func (t *RPCharacterSaveTool) Permission() Permission { return PermissionWrite }
 func (t *RPCharacterSaveTool) Description() string {
 	return "Creates or updates a character. Args: id (string, optional), name (string), summary (string), traits ([string]), background (string), goals ([string]), tags ([string]), notes (string)."
 }
 func (t *RPCharacterSaveTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id":         map[string]interface{}{"type": "string"},
-				"name":       map[string]interface{}{"type": "string"},
-				"summary":    map[string]interface{}{"type": "string"},
-				"traits":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-				"background": map[string]interface{}{"type": "string"},
-				"goals":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-				"tags":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-				"notes":      map[string]interface{}{"type": "string"},
-			},
-			"required": []string{"name"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpCharacterSaveArgs{})
 }
 func (t *RPCharacterSaveTool) Execute(args map[string]interface{}) (interface{}, error) {
-	name, ok := args["name"].(string)
-	if !ok || name == "" {
+	var a rpCharacterSaveArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Name == "" {
 		return nil, fmt.Errorf("name is required")
 	}
+	if a.ID == "" {
+		a.ID = genID()
+	}
 	c := &memory.RPCharacter{
-		ID:         stringFrom(args["id"], genID()),
-		Name:       name,
-		Summary:    stringFrom(args["summary"], ""),
-		Background: stringFrom(args["background"], ""),
-		Notes:      stringFrom(args["notes"], ""),
-	}
-	c.Traits = stringSlice(args["traits"])
-	c.Goals = stringSlice(args["goals"])
-	c.Tags = stringSlice(args["tags"])
+		ID:         a.ID,
+		Name:       a.Name,
+		Summary:    a.Summary,
+		Traits:     nonNil(a.Traits),
+		Background: a.Background,
+		Goals:      nonNil(a.Goals),
+		Tags:       nonNil(a.Tags),
+		Notes:      a.Notes,
+	}
 	if err := t.store.SaveCharacter(c); err != nil {
 		return nil, err
 	}
@@ -159,27 +170,18 @@ type RPCharacterDeleteTool struct{ store *memory.RPStore }
 func NewRPCharacterDeleteTool(store *memory.RPStore) *RPCharacterDeleteTool {
 	return &RPCharacterDeleteTool{store: store}
 }
-func (t *RPCharacterDeleteTool) Name() string { return "rp_character_delete" }
+func (t *RPCharacterDeleteTool) Name() string           { return "rp_character_delete" }
+func (t *RPCharacterDeleteTool) Permission() Permission { return PermissionDestructive }
 func (t *RPCharacterDeleteTool) Description() string {
 	return "Deletes a character. Args: id (string)."
 }
 func (t *RPCharacterDeleteTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id": map[string]interface{}{"type": "string"},
-			},
-			"required": []string{"id"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpIDArgs{})
 }
 func (t *RPCharacterDeleteTool) Execute(args map[string]interface{}) (interface{}, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return nil, fmt.Errorf("id is required")
+	id, err := decodeID(args)
+	if err != nil {
+		return nil, err
 	}
 	if err := t.store.DeleteCharacter(id); err != nil {
 		return nil, err
@@ -194,31 +196,20 @@ type RPStoryCardListTool struct{ store *memory.RPStore }
 func NewRPStoryCardListTool(store *memory.RPStore) *RPStoryCardListTool {
 	return &RPStoryCardListTool{store: store}
 }
-func (t *RPStoryCardListTool) Name() string { return "rp_storycard_list" }
+func (t *RPStoryCardListTool) Name() string           { return "rp_storycard_list" }
+func (t *RPStoryCardListTool) Permission() Permission { return PermissionRead }
 func (t *RPStoryCardListTool) Description() string {
 	return "Lists story cards. Args: query (string, optional), kind (string, optional), limit (int), offset (int)."
 }
 func (t *RPStoryCardListTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"query":  map[string]interface{}{"type": "string"},
-				"kind":   map[string]interface{}{"type": "string"},
-				"limit":  map[string]interface{}{"type": "integer"},
-				"offset": map[string]interface{}{"type": "integer"},
-			},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpStoryCardListArgs{})
 }
 func (t *RPStoryCardListTool) Execute(args map[string]interface{}) (interface{}, error) {
-	q, _ := args["query"].(string)
-	kind, _ := args["kind"].(string)
-	limit := intFrom(args["limit"], 100)
-	offset := intFrom(args["offset"], 0)
-	storyCards, err := t.store.ListStoryCards(q, kind, limit, offset)
+	var a rpStoryCardListArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	storyCards, err := t.store.ListStoryCards(a.Query, a.Kind, a.Limit, a.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -239,27 +230,18 @@ type RPStoryCardGetTool struct{ store *memory.RPStore }
 func NewRPStoryCardGetTool(store *memory.RPStore) *RPStoryCardGetTool {
 	return &RPStoryCardGetTool{store: store}
 }
-func (t *RPStoryCardGetTool) Name() string { return "rp_storycard_get" }
+func (t *RPStoryCardGetTool) Name() string           { return "rp_storycard_get" }
+func (t *RPStoryCardGetTool) Permission() Permission { return PermissionRead }
 func (t *RPStoryCardGetTool) Description() string {
 	return "Gets a story card by id. Args: id (string)."
 }
 func (t *RPStoryCardGetTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id": map[string]interface{}{"type": "string"},
-			},
-			"required": []string{"id"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpIDArgs{})
 }
 func (t *RPStoryCardGetTool) Execute(args map[string]interface{}) (interface{}, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return nil, fmt.Errorf("id is required")
+	id, err := decodeID(args)
+	if err != nil {
+		return nil, err
 	}
 	storyCard, err := t.store.GetStoryCard(id)
 	if err != nil {
@@ -282,45 +264,36 @@ type RPStoryCardSaveTool struct{ store *memory.RPStore }
 func NewRPStoryCardSaveTool(store *memory.RPStore) *RPStoryCardSaveTool {
 	return &RPStoryCardSaveTool{store: store}
 }
-func (t *RPStoryCardSaveTool) Name() string { return "rp_storycard_save" }
+func (t *RPStoryCardSaveTool) Name() string           { return "rp_storycard_save" }
+func (t *RPStoryCardSaveTool) Permission() Permission { return PermissionWrite }
 func (t *RPStoryCardSaveTool) Description() string {
 	return "Creates or updates a story card. Args: id (string, optional), title (string), kind (string), content (string), tags ([string]), links ([string])."
 }
 func (t *RPStoryCardSaveTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id":      map[string]interface{}{"type": "string"},
-				"title":   map[string]interface{}{"type": "string"},
-				"kind":    map[string]interface{}{"type": "string"},
-				"content": map[string]interface{}{"type": "string"},
-				"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-				"links":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
-			},
-			"required": []string{"title", "kind"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpStoryCardSaveArgs{})
 }
 func (t *RPStoryCardSaveTool) Execute(args map[string]interface{}) (interface{}, error) {
-	title, ok := args["title"].(string)
-	if !ok || title == "" {
+	var a rpStoryCardSaveArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Title == "" {
 		return nil, fmt.Errorf("title is required")
 	}
-	kind, ok := args["kind"].(string)
-	if !ok || kind == "" {
+	if a.Kind == "" {
 		return nil, fmt.Errorf("kind is required")
 	}
+	if a.ID == "" {
+		a.ID = genID()
+	}
 	sc := &memory.RPStoryCard{
-		ID:      stringFrom(args["id"], genID()),
-		Title:   title,
-		Kind:    kind,
-		Content: stringFrom(args["content"], ""),
+		ID:      a.ID,
+		Title:   a.Title,
+		Kind:    a.Kind,
+		Content: a.Content,
+		Tags:    nonNil(a.Tags),
+		Links:   nonNil(a.Links),
 	}
-	sc.Tags = stringSlice(args["tags"])
-	sc.Links = stringSlice(args["links"])
 	if err := t.store.SaveStoryCard(sc); err != nil {
 		return nil, err
 	}
@@ -341,27 +314,18 @@ type RPStoryCardDeleteTool struct{ store *memory.RPStore }
 func NewRPStoryCardDeleteTool(store *memory.RPStore) *RPStoryCardDeleteTool {
 	return &RPStoryCardDeleteTool{store: store}
 }
-func (t *RPStoryCardDeleteTool) Name() string { return "rp_storycard_delete" }
+func (t *RPStoryCardDeleteTool) Name() string           { return "rp_storycard_delete" }
+func (t *RPStoryCardDeleteTool) Permission() Permission { return PermissionDestructive }
 func (t *RPStoryCardDeleteTool) Description() string {
 	return "Deletes a story card. Args: id (string)."
 }
 func (t *RPStoryCardDeleteTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"parameters": map[string]interface{}{
-			"type": "object",
-			"properties": map[string]interface{}{
-				"id": map[string]interface{}{"type": "string"},
-			},
-			"required": []string{"id"},
-		},
-	}
+	return BuildSchema(t.Name(), t.Description(), rpIDArgs{})
 }
 func (t *RPStoryCardDeleteTool) Execute(args map[string]interface{}) (interface{}, error) {
-	id, ok := args["id"].(string)
-	if !ok || id == "" {
-		return nil, fmt.Errorf("id is required")
+	id, err := decodeID(args)
+	if err != nil {
+		return nil, err
 	}
 	if err := t.store.DeleteStoryCard(id); err != nil {
 		return nil, err
@@ -371,34 +335,23 @@ func (t *RPStoryCardDeleteTool) Execute(args map[string]interface{}) (interface{
 
 // ---- helpers ----
 
-func intFrom(v interface{}, def int) int {
-	switch n := v.(type) {
-	case float64:
-		return int(n)
-	case int:
-		return n
-	default:
-		return def
+func decodeID(args map[string]interface{}) (string, error) {
+	var a rpIDArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return "", err
 	}
-}
-
-func stringFrom(v interface{}, def string) string {
-	if s, ok := v.(string); ok {
-		return s
+	if a.ID == "" {
+		return "", fmt.Errorf("id is required")
 	}
-	return def
+	return a.ID, nil
 }
 
-func stringSlice(v interface{}) []string {
-	out := []string{}
-	if arr, ok := v.([]interface{}); ok {
-		for _, it := range arr {
-			if s, ok := it.(string); ok {
-				out = append(out, s)
-			}
-		}
-	}
-	return out
+// nonNil keeps empty lists serialised as [] rather than null.
+func nonNil(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
 }
 
 func genID() string {
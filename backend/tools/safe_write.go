@@ -0,0 +1,136 @@
+/**
+ * Safe file write module.
+ *
+ * All file-changing tools write through here: the previous content is
+ * saved to the file version store first, then the new content is written
+ * to a temporary file in the same directory and renamed over the target,
+ * so a crash never leaves a half-written file behind.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: safe_write.go
+ * Description: Backed-up, atomic file replacement.
+ */
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"nira/memory"
+	"os"
+	"path/filepath"
+)
+
+// maxBackupSize is the largest previous content kept as a version; bigger
+// files are refused rather than overwritten without a way back.
+const maxBackupSize = 20 << 20
+
+// safeWriteFile backs up the current content of path (or the fact that it
+// did not exist) and atomically replaces it with data. Returns the backup's
+// version ID, or 0 when versions is nil.
+func safeWriteFile(versions *memory.FileVersionStore, path string, data []byte, tool string) (int64, error) {
+	target, perm, err := resolveWriteTarget(path)
+	if err != nil {
+		return 0, err
+	}
+	versionID, err := backupFile(versions, target, tool)
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(target, data, perm); err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
+	}
+	return versionID, nil
+}
+
+// safeRemoveFile backs up a file and then deletes it.
+func safeRemoveFile(versions *memory.FileVersionStore, path string, tool string) (int64, error) {
+	versionID, err := backupFile(versions, path, tool)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, fmt.Errorf("failed to remove file: %w", err)
+	}
+	return versionID, nil
+}
+
+// resolveWriteTarget follows symlinks so the link's target is replaced
+// rather than the link itself, and returns the mode to keep.
+func resolveWriteTarget(path string) (string, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return path, 0644, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return "", 0, fmt.Errorf("path '%s' is a directory", path)
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	return target, info.Mode().Perm(), nil
+}
+
+func backupFile(versions *memory.FileVersionStore, path string, tool string) (int64, error) {
+	if versions == nil {
+		return 0, nil
+	}
+	var old []byte
+	info, err := os.Stat(path)
+	existed := err == nil
+	switch {
+	case existed && info.Size() > maxBackupSize:
+		return 0, fmt.Errorf("file '%s' is larger than %d MB and cannot be backed up; not modifying it", path, maxBackupSize>>20)
+	case existed:
+		if old, err = os.ReadFile(path); err != nil {
+			return 0, fmt.Errorf("failed to read current content for backup: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+	id, err := versions.Save(path, old, existed, tool)
+	if err != nil {
+		return 0, fmt.Errorf("failed to back up previous version: %w", err)
+	}
+	return id, nil
+}
+
+// writeFileAtomic writes to a temp file next to path and renames it into place.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".nira-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpName)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
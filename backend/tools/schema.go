@@ -0,0 +1,296 @@
+/**
+ * Typed tool arguments module.
+ *
+ * Lets a tool declare its arguments as a Go struct and derives both the
+ * JSON schema advertised to the model and the decoding of incoming
+ * argument maps from that single definition, so the two cannot drift.
+ *
+ * Supported struct tags:
+ *   json:"name"        argument name (fields without it are ignored)
+ *   desc:"..."         human readable description
+ *   required:"true"    argument must be present
+ *   default:"value"    value applied when the argument is absent
+ *   enum:"a,b,c"       restricts a string argument to the listed values
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: schema.go
+ * Description: Reflection-based schema generation and argument decoding.
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NoArgs is the argument struct for tools that take no arguments.
+type NoArgs struct{}
+
+// BuildSchema returns the uniform tool schema for the given argument struct:
+// {name, description, parameters: {type: object, properties, required}}.
+func BuildSchema(name, description string, args interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"parameters":  ParametersFor(args),
+	}
+}
+
+// ParametersFor generates the JSON schema object describing an argument struct.
+func ParametersFor(args interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	t := reflect.TypeOf(args)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := argName(f)
+			if name == "" {
+				continue
+			}
+			prop := typeSchema(f.Type)
+			if d := f.Tag.Get("desc"); d != "" {
+				prop["description"] = d
+			}
+			if def, ok := f.Tag.Lookup("default"); ok {
+				if v, err := parseScalar(f.Type, def); err == nil {
+					prop["default"] = v
+				}
+			}
+			if enum := f.Tag.Get("enum"); enum != "" {
+				prop["enum"] = strings.Split(enum, ",")
+			}
+			properties[name] = prop
+			if f.Tag.Get("required") == "true" {
+				required = append(required, name)
+			}
+		}
+	}
+
+	params := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		params["required"] = required
+	}
+	return params
+}
+
+// DecodeArgs copies a raw argument map into the struct pointed to by dst,
+// applying defaults, enforcing required fields and coercing loosely typed
+// values (e.g. "10" for an integer) produced by text-based tool calls.
+func DecodeArgs(args map[string]interface{}, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeArgs requires a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := argName(f)
+		if name == "" {
+			continue
+		}
+		raw, present := args[name]
+		if !present || raw == nil {
+			if f.Tag.Get("required") == "true" {
+				return fmt.Errorf("%s argument is required", name)
+			}
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			parsed, err := parseScalar(f.Type, def)
+			if err != nil {
+				return fmt.Errorf("invalid default for %s: %w", name, err)
+			}
+			raw = parsed
+		}
+		if err := assign(v.Field(i), raw); err != nil {
+			return fmt.Errorf("%s argument %w", name, err)
+		}
+		if enum := f.Tag.Get("enum"); enum != "" && v.Field(i).Kind() == reflect.String {
+			val := v.Field(i).String()
+			if val != "" && !containsString(strings.Split(enum, ","), val) {
+				return fmt.Errorf("%s argument must be one of: %s", name, enum)
+			}
+		}
+	}
+	return nil
+}
+
+func argName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	return strings.Split(tag, ",")[0]
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return ParametersFor(reflect.Zero(t).Interface())
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func assign(field reflect.Value, raw interface{}) error {
+	switch field.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		switch b := raw.(type) {
+		case bool:
+			field.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+			if err != nil {
+				return fmt.Errorf("must be a boolean")
+			}
+			field.SetBool(parsed)
+		default:
+			return fmt.Errorf("must be a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toFloat(raw)
+		if err != nil || n != math.Trunc(n) {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toFloat(raw)
+		if err != nil || n < 0 || n != math.Trunc(n) {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+		field.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, err := toFloat(raw)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			rv := reflect.ValueOf(raw)
+			if rv.Kind() == reflect.Slice {
+				items = make([]interface{}, rv.Len())
+				for i := range items {
+					items[i] = rv.Index(i).Interface()
+				}
+			} else {
+				// A single scalar is accepted as a one-element list.
+				items = []interface{}{raw}
+			}
+		}
+		out := reflect.MakeSlice(field.Type(), 0, len(items))
+		for i, it := range items {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := assign(elem, it); err != nil {
+				return fmt.Errorf("item %d %w", i, err)
+			}
+			out = reflect.Append(out, elem)
+		}
+		field.Set(out)
+	case reflect.Map, reflect.Struct, reflect.Interface:
+		// Fall back to a JSON round trip for structured values.
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return fmt.Errorf("is not valid: %v", err)
+		}
+		if err := json.Unmarshal(b, field.Addr().Interface()); err != nil {
+			return fmt.Errorf("must be an object")
+		}
+	default:
+		return fmt.Errorf("has unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+func parseScalar(t reflect.Type, s string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return float64(n), err
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	case reflect.Slice:
+		parts := []interface{}{}
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		return parts, nil
+	default:
+		return nil, fmt.Errorf("defaults not supported for %s", t)
+	}
+}
+
+func toFloat(raw interface{}) (float64, error) {
+	switch n := raw.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	default:
+		return 0, fmt.Errorf("not a number")
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, it := range list {
+		if it == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+/**
+ * File content search tool module.
+ *
+ * Walks an allowed root and returns the lines matching a literal or regex
+ * pattern, with line numbers and surrounding context. Files are filtered by
+ * include/exclude globs and .gitignore, binary and oversized files are
+ * skipped, and files are scanned concurrently until the result cap is hit.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: search_file_contents.go
+ * Description: grep-style search over files under an allowed directory.
+ */
+
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// binarySniffLen is how much of a file is checked for NUL bytes.
+	binarySniffLen = 8000
+	// maxMatchLineLen bounds the length of reported lines.
+	maxMatchLineLen  = 300
+	maxSearchWorkers = 8
+)
+
+type searchFileContentsArgs struct {
+	Root            string   `json:"root" desc:"Directory to search under" required:"true"`
+	Pattern         string   `json:"pattern" desc:"Text or regular expression to find" required:"true"`
+	Regex           bool     `json:"regex" desc:"Treat pattern as a regular expression (Go RE2 syntax)"`
+	CaseSensitive   bool     `json:"case_sensitive" desc:"Case-sensitive match"`
+	Include         []string `json:"include" desc:"Only search files matching these globs, e.g. *.go or src/**/*.ts"`
+	Exclude         []string `json:"exclude" desc:"Skip files and directories matching these globs"`
+	Context         int      `json:"context" desc:"Lines of context before and after each match" default:"0"`
+	MaxResults      int      `json:"max_results" desc:"Maximum number of matching lines" default:"100"`
+	MaxPerFile      int      `json:"max_per_file" desc:"Maximum matching lines per file" default:"20"`
+	MaxFileSizeKB   int      `json:"max_file_size_kb" desc:"Skip files larger than this" default:"1024"`
+	IgnoreGitignore bool     `json:"ignore_gitignore" desc:"Also search files excluded by .gitignore (and .git directories)"`
+}
+
+// ContentMatch is one matching line.
+type ContentMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+// SearchFileContentsTool finds lines matching a pattern in files under a root.
+type SearchFileContentsTool struct {
+	AllowedPaths []string
+	checker      PathChecker
+}
+
+func NewSearchFileContentsTool(allowedPaths []string) *SearchFileContentsTool {
+	return &SearchFileContentsTool{AllowedPaths: allowedPaths}
+}
+
+func NewSearchFileContentsToolWithChecker(allowedPaths []string, checker PathChecker) *SearchFileContentsTool {
+	return &SearchFileContentsTool{AllowedPaths: allowedPaths, checker: checker}
+}
+
+func (t *SearchFileContentsTool) Name() string           { return "search_file_contents" }
+func (t *SearchFileContentsTool) Permission() Permission { return PermissionRead }
+func (t *SearchFileContentsTool) Description() string {
+	return "Searches the contents of files under a directory (like grep) and returns matching lines with file, line number and optional context. Skips binary files and, by default, files ignored by .gitignore. Args: root (string), pattern (string), regex (bool), case_sensitive (bool), include/exclude ([glob]), context (int), max_results (int), max_per_file (int), max_file_size_kb (int), ignore_gitignore (bool)."
+}
+func (t *SearchFileContentsTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), searchFileContentsArgs{})
+}
+
+func (t *SearchFileContentsTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a searchFileContentsArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Pattern == "" {
+		return nil, fmt.Errorf("pattern argument must not be empty")
+	}
+	if err := checkPathAccess(t.checker, t.AllowedPaths, a.Root, sandbox.AccessRead); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(a.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat root: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("root '%s' is not a directory", a.Root)
+	}
+
+	expr := a.Pattern
+	if !a.Regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !a.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	if a.MaxResults <= 0 {
+		a.MaxResults = 100
+	}
+	if a.Context < 0 {
+		a.Context = 0
+	}
+
+	s := &contentSearch{args: a, re: re, maxSize: int64(a.MaxFileSizeKB) * 1024}
+	files := make(chan string, 64)
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	if workers > maxSearchWorkers {
+		workers = maxSearchWorkers
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range files {
+				if !s.full() {
+					s.scan(path)
+				}
+			}
+		}()
+	}
+	t.walk(a, s, files)
+	close(files)
+	wg.Wait()
+
+	sort.Slice(s.matches, func(i, j int) bool {
+		if s.matches[i].File != s.matches[j].File {
+			return s.matches[i].File < s.matches[j].File
+		}
+		return s.matches[i].Line < s.matches[j].Line
+	})
+	truncated := s.truncated.Load() || len(s.matches) > a.MaxResults
+	if len(s.matches) > a.MaxResults {
+		s.matches = s.matches[:a.MaxResults]
+	}
+	matchedFiles := map[string]bool{}
+	for _, m := range s.matches {
+		matchedFiles[m.File] = true
+	}
+	return map[string]interface{}{
+		"matches":        s.matches,
+		"files_searched": s.searched.Load(),
+		"files_matched":  len(matchedFiles),
+		"skipped_binary": s.binary.Load(),
+		"skipped_large":  s.large.Load(),
+		"truncated":      truncated,
+	}, nil
+}
+
+// walk sends the files to search to files, stopping once the search is full.
+func (t *SearchFileContentsTool) walk(a searchFileContentsArgs, s *contentSearch, files chan<- string) {
+	ignore := &gitignore{}
+	filepath.WalkDir(a.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if s.full() {
+			return filepath.SkipAll
+		}
+		rel, relErr := filepath.Rel(a.Root, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			if !a.IgnoreGitignore && (ignore.ignored(rel, d.IsDir()) || (d.IsDir() && d.Name() == ".git")) {
+				return skipEntry(d)
+			}
+			if matchesAnyGlob(a.Exclude, rel) {
+				return skipEntry(d)
+			}
+			if checkPathAccess(t.checker, t.AllowedPaths, p, sandbox.AccessRead) != nil {
+				return skipEntry(d)
+			}
+		}
+		if d.IsDir() {
+			if !a.IgnoreGitignore {
+				ignore.load(p, rel)
+			}
+			return nil
+		}
+		if len(a.Include) > 0 && !matchesAnyGlob(a.Include, rel) {
+			return nil
+		}
+		files <- p
+		return nil
+	})
+}
+
+func skipEntry(d fs.DirEntry) error {
+	if d.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+func matchesAnyGlob(globs []string, rel string) bool {
+	for _, g := range globs {
+		if g != "" && sandbox.MatchGlob(g, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentSearch is the state shared by the scanning workers.
+type contentSearch struct {
+	args    searchFileContentsArgs
+	re      *regexp.Regexp
+	maxSize int64
+
+	mu        sync.Mutex
+	matches   []ContentMatch
+	truncated atomic.Bool
+	searched  atomic.Int64
+	binary    atomic.Int64
+	large     atomic.Int64
+}
+
+func (s *contentSearch) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.matches) >= s.args.MaxResults
+}
+
+// scan searches one file and records its matches.
+func (s *contentSearch) scan(path string) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if s.maxSize > 0 && info.Size() > s.maxSize {
+		s.large.Add(1)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		s.binary.Add(1)
+		return
+	}
+	s.searched.Add(1)
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var found []ContentMatch
+	for i, line := range lines {
+		loc := s.re.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		if s.args.MaxPerFile > 0 && len(found) >= s.args.MaxPerFile {
+			s.truncated.Store(true)
+			break
+		}
+		m := ContentMatch{File: path, Line: i + 1, Column: loc[0] + 1, Text: truncate(line, maxMatchLineLen)}
+		if c := s.args.Context; c > 0 {
+			m.Before = contextLines(lines, i-c, i)
+			m.After = contextLines(lines, i+1, i+1+c)
+		}
+		found = append(found, m)
+	}
+	if len(found) == 0 {
+		return
+	}
+	s.mu.Lock()
+	s.matches = append(s.matches, found...)
+	s.mu.Unlock()
+}
+
+func contextLines(lines []string, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+	out := make([]string, 0, to-from)
+	for _, l := range lines[from:to] {
+		out = append(out, truncate(l, maxMatchLineLen))
+	}
+	return out
+}
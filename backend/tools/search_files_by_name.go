@@ -1,13 +1,21 @@
 package tools
 
 import (
-    "fmt"
     "io/fs"
+    "nira/sandbox"
     "path/filepath"
     "strings"
     "time"
 )
 
+type searchFilesByNameArgs struct {
+    Root          string `json:"root" desc:"Root directory to search within" required:"true"`
+    Pattern       string `json:"pattern" desc:"Substring or glob pattern to match against names" required:"true"`
+    MaxResults    int    `json:"max_results" desc:"Maximum number of results" default:"200"`
+    IncludeDirs   bool   `json:"include_dirs" desc:"Include directories in results"`
+    CaseSensitive bool   `json:"case_sensitive" desc:"Case-sensitive match"`
+}
+
 // SearchFilesByNameTool searches for files (and optionally directories) by name pattern under a root.
 type SearchFilesByNameTool struct {
     AllowedPaths []string
@@ -20,74 +28,29 @@ func NewSearchFilesByNameToolWithChecker(allowedPaths []string, checker PathChec
 }
 
 func (t *SearchFilesByNameTool) Name() string { return "search_files_by_name" }
+func (t *SearchFilesByNameTool) Permission() Permission { return PermissionRead }
 
 func (t *SearchFilesByNameTool) Description() string {
     return "Search for files by name under a root directory. Args: root (string), pattern (string, substring or glob), max_results (int), include_dirs (bool), case_sensitive (bool)."
 }
 
 func (t *SearchFilesByNameTool) Schema() map[string]interface{} {
-    return map[string]interface{}{
-        "name":        t.Name(),
-        "description": t.Description(),
-        "parameters": map[string]interface{}{
-            "type": "object",
-            "properties": map[string]interface{}{
-                "root": map[string]interface{}{
-                    "type":        "string",
-                    "description": "Root directory to search within",
-                },
-                "pattern": map[string]interface{}{
-                    "type":        "string",
-                    "description": "Substring or glob pattern to match against names",
-                },
-                "max_results": map[string]interface{}{
-                    "type":        "integer",
-                    "description": "Maximum number of results (default 200)",
-                },
-                "include_dirs": map[string]interface{}{
-                    "type":        "boolean",
-                    "description": "Include directories in results (default false)",
-                },
-                "case_sensitive": map[string]interface{}{
-                    "type":        "boolean",
-                    "description": "Case-sensitive match (default false)",
-                },
-            },
-            "required": []string{"root", "pattern"},
-        },
-    }
+    return BuildSchema(t.Name(), t.Description(), searchFilesByNameArgs{})
 }
 
 func (t *SearchFilesByNameTool) Execute(args map[string]interface{}) (interface{}, error) {
-    root, ok := args["root"].(string)
-    if !ok {
-        return nil, fmt.Errorf("root argument is required and must be a string")
+    var a searchFilesByNameArgs
+    if err := DecodeArgs(args, &a); err != nil {
+        return nil, err
     }
-    pattern, ok := args["pattern"].(string)
-    if !ok {
-        return nil, fmt.Errorf("pattern argument is required and must be a string")
-    }
-    if !t.isPathAllowed(root) {
-        return nil, fmt.Errorf("root '%s' is not in allowed directories", root)
+    root, pattern := a.Root, a.Pattern
+    if err := checkPathAccess(t.checker, t.AllowedPaths, root, sandbox.AccessRead); err != nil {
+        return nil, err
     }
 
-    maxResults := 200
-    if v, ok := args["max_results"]; ok {
-        switch n := v.(type) {
-        case float64:
-            maxResults = int(n)
-        case int:
-            maxResults = n
-        }
-    }
-    includeDirs := false
-    if v, ok := args["include_dirs"].(bool); ok {
-        includeDirs = v
-    }
-    caseSensitive := false
-    if v, ok := args["case_sensitive"].(bool); ok {
-        caseSensitive = v
-    }
+    maxResults := a.MaxResults
+    includeDirs := a.IncludeDirs
+    caseSensitive := a.CaseSensitive
 
     useGlob := strings.ContainsAny(pattern, "*?")
     pat := pattern
@@ -114,7 +77,7 @@ func (t *SearchFilesByNameTool) Execute(args map[string]interface{}) (interface{
             return nil // skip unreadable entries
         }
         // Validate we remain inside allowed paths even when following nested entries
-        if !t.isPathAllowed(p) {
+        if checkPathAccess(t.checker, t.AllowedPaths, p, sandbox.AccessRead) != nil {
             if d.IsDir() {
                 return filepath.SkipDir
             }
@@ -153,20 +116,3 @@ func (t *SearchFilesByNameTool) Execute(args map[string]interface{}) (interface{
 
     return results, nil
 }
-
-func (t *SearchFilesByNameTool) isPathAllowed(path string) bool {
-    if t.checker != nil { return t.checker.IsAllowed(path) }
-    if len(t.AllowedPaths) == 0 { return false }
-    absPath, err := filepath.Abs(path)
-    if err != nil { return false }
-    for _, allowed := range t.AllowedPaths {
-        absAllowed, err := filepath.Abs(allowed)
-        if err != nil { continue }
-        rel, err := filepath.Rel(absAllowed, absPath)
-        if err != nil { continue }
-        if rel != ".." && !filepath.IsAbs(rel) {
-            return true
-        }
-    }
-    return false
-}
@@ -0,0 +1,332 @@
+/**
+ * Web search provider module.
+ *
+ * A SearchProvider turns a query into WebSearchResults. Providers are built
+ * from SearchProviderConfig entries in the order they are configured, and
+ * web_search falls back to the next one when a provider fails. Besides the
+ * DuckDuckGo Instant Answer API there are providers for a (usually local)
+ * SearXNG instance, the Brave Search API and any JSON endpoint described
+ * by field paths.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: search_providers.go
+ * Description: SearXNG, Brave, generic JSON and DuckDuckGo search backends.
+ */
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchTimeout = 15 * time.Second
+	// maxSearchResponse bounds the response body read from a provider.
+	maxSearchResponse = 2 << 20
+	searchUserAgent   = "Mozilla/5.0 (compatible; NIRA/1.0)"
+)
+
+// Provider types accepted in SearchProviderConfig.Type.
+const (
+	SearchDuckDuckGo = "duckduckgo"
+	SearchSearXNG    = "searxng"
+	SearchBrave      = "brave"
+	SearchJSON       = "json"
+)
+
+// SearchProvider runs a web search against one backend.
+type SearchProvider interface {
+	Name() string
+	Search(query string, limit int) ([]WebSearchResult, error)
+}
+
+// SearchProviderConfig describes one search backend.
+type SearchProviderConfig struct {
+	// Type is duckduckgo, searxng, brave or json.
+	Type string `toml:"type,omitempty"`
+	// Name labels the provider in results and errors; defaults to Type.
+	Name string `toml:"name,omitempty"`
+	// URL is the SearXNG base URL, an alternative Brave endpoint, or for
+	// json the request URL with {query} and {limit} placeholders.
+	URL    string `toml:"url,omitempty"`
+	APIKey string `toml:"api_key,omitempty"`
+	// Headers are added to every request (json provider).
+	Headers map[string]string `toml:"headers,omitempty"`
+	// ResultsPath is the dot path of the result array in a json response
+	// (e.g. "data.items"); empty means the response itself is the array.
+	ResultsPath string `toml:"results_path,omitempty"`
+	// TitleField, URLField and SnippetField are dot paths inside each result.
+	// They default to title, url and snippet.
+	TitleField     string `toml:"title_field,omitempty"`
+	URLField       string `toml:"url_field,omitempty"`
+	SnippetField   string `toml:"snippet_field,omitempty"`
+	TimeoutSeconds int    `toml:"timeout_seconds,omitzero"`
+}
+
+// NewSearchProvider builds the provider described by cfg; its requests go
+// through egress.
+func NewSearchProvider(cfg SearchProviderConfig, egress *Egress) (SearchProvider, error) {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Type))
+	name := cfg.Name
+	if name == "" {
+		name = kind
+	}
+	timeout := defaultSearchTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	base := searchBackend{name: name, client: egress.ServiceClient(timeout, cfg.URL)}
+
+	switch kind {
+	case SearchDuckDuckGo:
+		return &duckDuckGoProvider{base}, nil
+	case SearchSearXNG:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("search provider %s: searxng needs URL (e.g. http://localhost:8888)", name)
+		}
+		return &searxngProvider{searchBackend: base, baseURL: strings.TrimRight(cfg.URL, "/")}, nil
+	case SearchBrave:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("search provider %s: brave needs APIKey", name)
+		}
+		endpoint := cfg.URL
+		if endpoint == "" {
+			endpoint = "https://api.search.brave.com/res/v1/web/search"
+		}
+		return &braveProvider{searchBackend: base, endpoint: endpoint, apiKey: cfg.APIKey}, nil
+	case SearchJSON:
+		if !strings.Contains(cfg.URL, "{query}") {
+			return nil, fmt.Errorf("search provider %s: json needs a URL containing {query}", name)
+		}
+		p := &jsonProvider{searchBackend: base, cfg: cfg}
+		for _, f := range []*string{&p.cfg.TitleField, &p.cfg.URLField, &p.cfg.SnippetField} {
+			*f = strings.TrimSpace(*f)
+		}
+		if p.cfg.TitleField == "" {
+			p.cfg.TitleField = "title"
+		}
+		if p.cfg.URLField == "" {
+			p.cfg.URLField = "url"
+		}
+		if p.cfg.SnippetField == "" {
+			p.cfg.SnippetField = "snippet"
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("search provider %s: unknown type '%s' (want duckduckgo, searxng, brave or json)", name, cfg.Type)
+}
+
+// NewSearchProviders builds providers in configured order. Entries that
+// fail to build are skipped and reported in errs.
+func NewSearchProviders(cfgs []SearchProviderConfig, egress *Egress) (providers []SearchProvider, errs []error) {
+	for _, cfg := range cfgs {
+		p, err := NewSearchProvider(cfg, egress)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers, errs
+}
+
+// searchBackend holds what the HTTP providers share.
+type searchBackend struct {
+	name   string
+	client *http.Client
+}
+
+func (b searchBackend) Name() string { return b.name }
+
+// getJSON fetches rawURL and decodes its JSON body into v.
+func (b searchBackend) getJSON(rawURL string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", searchUserAgent)
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSearchResponse))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(strings.TrimSpace(string(body)), 200))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse JSON response: %w (response: %s)", err, truncate(string(body), 200))
+	}
+	return nil
+}
+
+// searxngProvider queries a SearXNG instance. JSON output must be enabled
+// in its settings.yml (search.formats: [html, json]).
+type searxngProvider struct {
+	searchBackend
+	baseURL string
+}
+
+func (p *searxngProvider) Search(query string, limit int) ([]WebSearchResult, error) {
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+			Engine  string `json:"engine"`
+		} `json:"results"`
+	}
+	u := p.baseURL + "/search?format=json&q=" + url.QueryEscape(query)
+	if err := p.getJSON(u, nil, &resp); err != nil {
+		if strings.Contains(err.Error(), "HTTP 403") {
+			return nil, fmt.Errorf("%w (is the json format enabled in SearXNG settings?)", err)
+		}
+		return nil, err
+	}
+	var results []WebSearchResult
+	for _, r := range resp.Results {
+		results = append(results, WebSearchResult{Title: r.Title, Snippet: r.Content, URL: r.URL, Source: p.name})
+	}
+	return normalizeResults(results, limit), nil
+}
+
+// braveProvider queries the Brave Search API.
+type braveProvider struct {
+	searchBackend
+	endpoint string
+	apiKey   string
+}
+
+func (p *braveProvider) Search(query string, limit int) ([]WebSearchResult, error) {
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	u := p.endpoint + "?q=" + url.QueryEscape(query) + "&count=" + strconv.Itoa(min(limit, 20))
+	if err := p.getJSON(u, map[string]string{"X-Subscription-Token": p.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+	var results []WebSearchResult
+	for _, r := range resp.Web.Results {
+		results = append(results, WebSearchResult{Title: r.Title, Snippet: r.Description, URL: r.URL, Source: p.name})
+	}
+	return normalizeResults(results, limit), nil
+}
+
+// jsonProvider queries any JSON search endpoint described by field paths.
+type jsonProvider struct {
+	searchBackend
+	cfg SearchProviderConfig
+}
+
+func (p *jsonProvider) Search(query string, limit int) ([]WebSearchResult, error) {
+	u := strings.ReplaceAll(p.cfg.URL, "{query}", url.QueryEscape(query))
+	u = strings.ReplaceAll(u, "{limit}", strconv.Itoa(limit))
+	var resp interface{}
+	if err := p.getJSON(u, p.cfg.Headers, &resp); err != nil {
+		return nil, err
+	}
+	items, ok := jsonPath(resp, p.cfg.ResultsPath).([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no result array at '%s' in response", p.cfg.ResultsPath)
+	}
+	var results []WebSearchResult
+	for _, item := range items {
+		results = append(results, WebSearchResult{
+			Title:   jsonString(jsonPath(item, p.cfg.TitleField)),
+			Snippet: jsonString(jsonPath(item, p.cfg.SnippetField)),
+			URL:     jsonString(jsonPath(item, p.cfg.URLField)),
+			Source:  p.name,
+		})
+	}
+	return normalizeResults(results, limit), nil
+}
+
+// jsonPath follows a dot path such as "data.items.0.title" through decoded
+// JSON; it returns nil when a step is missing.
+func jsonPath(v interface{}, path string) interface{} {
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func jsonString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	case float64, bool:
+		return fmt.Sprint(s)
+	}
+	return ""
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// normalizeResults strips markup and extra whitespace, drops results without
+// an http(s) URL, removes duplicate URLs and keeps at most limit results.
+func normalizeResults(results []WebSearchResult, limit int) []WebSearchResult {
+	clean := func(s string) string {
+		s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+		return strings.Join(strings.Fields(s), " ")
+	}
+	seen := map[string]bool{}
+	out := []WebSearchResult{}
+	for _, r := range results {
+		r.URL = strings.TrimSpace(r.URL)
+		if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		if seen[r.URL] {
+			continue
+		}
+		seen[r.URL] = true
+		r.Title, r.Snippet = clean(r.Title), truncate(clean(r.Snippet), 500)
+		if r.Title == "" {
+			r.Title = extractTitle(r.Snippet)
+		}
+		out = append(out, r)
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
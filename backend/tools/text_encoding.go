@@ -0,0 +1,181 @@
+/**
+ * Text encoding detection module.
+ *
+ * Guesses whether a file is UTF-8, UTF-16 (with or without a byte order
+ * mark) or Latin-1 from its first bytes, and wraps a reader so callers see
+ * UTF-8 either way. Files with NUL bytes that do not look like UTF-16 are
+ * reported as binary.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: text_encoding.go
+ * Description: Encoding sniffing and decoding readers for text tools.
+ */
+
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingLatin1  = "latin-1"
+	encodingBinary  = "binary"
+)
+
+// encodingSniffLen is how many leading bytes detectEncoding looks at.
+const encodingSniffLen = 4096
+
+// detectEncoding guesses the encoding of a file from its first bytes and
+// returns the length of its byte order mark. more tells whether the file
+// continues past head, so a character cut off at the end is not held
+// against UTF-8.
+func detectEncoding(head []byte, more bool) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8, 3
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE, 2
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE, 2
+	}
+	if len(head) == 0 {
+		return encodingUTF8, 0
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		// ASCII text in UTF-16 has a NUL in every other byte.
+		var even, odd int
+		for i, b := range head {
+			if b == 0 {
+				if i%2 == 0 {
+					even++
+				} else {
+					odd++
+				}
+			}
+		}
+		half := len(head) / 2
+		switch {
+		case odd >= half/2 && even <= odd/10:
+			return encodingUTF16LE, 0
+		case even >= half/2 && odd <= even/10:
+			return encodingUTF16BE, 0
+		}
+		return encodingBinary, 0
+	}
+	if validUTF8Prefix(head, more) {
+		return encodingUTF8, 0
+	}
+	return encodingLatin1, 0
+}
+
+// validUTF8Prefix is utf8.Valid, tolerating a sequence cut off at the end
+// when more follows.
+func validUTF8Prefix(b []byte, more bool) bool {
+	if !more {
+		return utf8.Valid(b)
+	}
+	for cut := 0; cut <= utf8.UTFMax && cut <= len(b); cut++ {
+		if utf8.Valid(b[:len(b)-cut]) {
+			return cut == 0 || !utf8.FullRune(b[len(b)-cut:])
+		}
+	}
+	return false
+}
+
+// decodingReader returns a reader producing UTF-8 from r in the given
+// encoding. r must be positioned after any byte order mark.
+func decodingReader(r io.Reader, encoding string) io.Reader {
+	switch encoding {
+	case encodingUTF16LE, encodingUTF16BE:
+		return &utf16Reader{r: bufio.NewReader(r), bigEndian: encoding == encodingUTF16BE}
+	case encodingLatin1:
+		return &latin1Reader{r: r}
+	}
+	return r
+}
+
+// utf16Reader decodes UTF-16 into UTF-8.
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	out       []byte
+	err       error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.fill()
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// fill decodes up to a few thousand code units into out.
+func (u *utf16Reader) fill() {
+	var buf [4]byte
+	for i := 0; i < 2048; i++ {
+		c, err := u.unit(buf[:2])
+		if err != nil {
+			u.err = err
+			return
+		}
+		r := rune(c)
+		if utf16.IsSurrogate(r) {
+			low, err := u.unit(buf[2:])
+			if err != nil {
+				u.out = utf8.AppendRune(u.out, utf8.RuneError)
+				u.err = err
+				return
+			}
+			r = utf16.DecodeRune(r, rune(low))
+		}
+		u.out = utf8.AppendRune(u.out, r)
+	}
+}
+
+func (u *utf16Reader) unit(b []byte) (uint16, error) {
+	if _, err := io.ReadFull(u.r, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if u.bigEndian {
+		return uint16(b[0])<<8 | uint16(b[1]), nil
+	}
+	return uint16(b[1])<<8 | uint16(b[0]), nil
+}
+
+// latin1Reader decodes ISO-8859-1 into UTF-8.
+type latin1Reader struct {
+	r   io.Reader
+	buf [2048]byte
+	out []byte
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.out) == 0 {
+		n, err := l.r.Read(l.buf[:])
+		for _, b := range l.buf[:n] {
+			l.out = utf8.AppendRune(l.out, rune(b))
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+	n := copy(p, l.out)
+	l.out = l.out[n:]
+	return n, nil
+}
@@ -23,6 +23,7 @@ type Tool interface {
 	Description() string
 	Execute(args map[string]interface{}) (interface{}, error)
 	Schema() map[string]interface{}
+	Permission() Permission
 }
 
 type Registry struct {
@@ -39,6 +40,11 @@ func (tr *Registry) Register(tool Tool) {
 	tr.Tools[tool.Name()] = tool
 }
 
+// Unregister removes a tool; unknown names are ignored.
+func (tr *Registry) Unregister(name string) {
+	delete(tr.Tools, name)
+}
+
 func (tr *Registry) Get(name string) (Tool, bool) {
 	tool, exists := tr.Tools[name]
 	return tool, exists
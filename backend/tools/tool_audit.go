@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"encoding/json"
+	"nira/memory"
+)
+
+type toolAuditListArgs struct {
+	ConversationID int64  `json:"conversation_id" desc:"Conversation to inspect (default: current conversation)"`
+	Tool           string `json:"tool" desc:"Only show calls to this tool"`
+	Limit          int    `json:"limit" desc:"Max records" default:"20"`
+	Offset         int    `json:"offset" desc:"Records to skip"`
+}
+
+// ToolAuditListTool lets the model review which tools ran in a conversation.
+type ToolAuditListTool struct {
+	store   *memory.ToolAuditStore
+	current func() int64
+}
+
+// NewToolAuditListTool takes a function returning the current conversation ID,
+// used when the caller does not name one.
+func NewToolAuditListTool(store *memory.ToolAuditStore, current func() int64) *ToolAuditListTool {
+	return &ToolAuditListTool{store: store, current: current}
+}
+
+func (t *ToolAuditListTool) Name() string           { return "tool_audit_list" }
+func (t *ToolAuditListTool) Permission() Permission { return PermissionRead }
+func (t *ToolAuditListTool) Description() string {
+	return "Lists recorded tool calls (newest first) with arguments, result summary, error, duration and initiator. Args: conversation_id (int, optional), tool (string, optional), limit (int), offset (int)."
+}
+func (t *ToolAuditListTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), toolAuditListArgs{})
+}
+func (t *ToolAuditListTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a toolAuditListArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.ConversationID == 0 && t.current != nil {
+		a.ConversationID = t.current()
+	}
+	records, err := t.store.List(a.ConversationID, a.Tool, a.Limit, a.Offset)
+	if err != nil {
+		return nil, err
+	}
+	// Marshal as []map[string]interface{} for generic return
+	b, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	var resp []map[string]interface{}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
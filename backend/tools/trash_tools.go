@@ -0,0 +1,163 @@
+/**
+ * Trash tools module.
+ *
+ * trash_list shows what delete_path and overwriting moves and copies put
+ * in the trash; trash_restore moves an entry back to where it was, or to
+ * a new location.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: trash_tools.go
+ * Description: Trash listing and restore tools.
+ */
+
+package tools
+
+import (
+	"fmt"
+	"nira/memory"
+	"nira/sandbox"
+	"os"
+	"path/filepath"
+)
+
+type trashListArgs struct {
+	Limit int `json:"limit" desc:"Max entries" default:"50"`
+}
+
+type trashRestoreArgs struct {
+	ID          int64  `json:"id" desc:"Trash entry to restore" required:"true"`
+	Destination string `json:"destination" desc:"Where to restore it (default: its original path)"`
+}
+
+// TrashListTool lists trashed paths.
+type TrashListTool struct {
+	trash   *memory.TrashStore
+	checker PathChecker
+}
+
+func NewTrashListTool(trash *memory.TrashStore, checker PathChecker) *TrashListTool {
+	return &TrashListTool{trash: trash, checker: checker}
+}
+
+func (t *TrashListTool) Name() string           { return "trash_list" }
+func (t *TrashListTool) Permission() Permission { return PermissionRead }
+func (t *TrashListTool) Description() string {
+	return "Lists deleted files and directories in the trash (newest first): id, original_path, is_dir, size, tool, deleted_at. Args: limit (int)."
+}
+func (t *TrashListTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), trashListArgs{})
+}
+
+func (t *TrashListTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a trashListArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	items, err := t.trash.List(a.Limit)
+	if err != nil {
+		return nil, err
+	}
+	// Only show entries from directories the caller can still read.
+	visible := make([]memory.TrashItem, 0, len(items))
+	for _, it := range items {
+		if checkHistoryAccess(t.checker, it.OriginalPath, sandbox.AccessRead) == nil {
+			visible = append(visible, it)
+		}
+	}
+	return map[string]interface{}{"items": visible}, nil
+}
+
+// TrashRestoreTool moves a trashed path back.
+type TrashRestoreTool struct {
+	trash   *memory.TrashStore
+	checker PathChecker
+}
+
+func NewTrashRestoreTool(trash *memory.TrashStore, checker PathChecker) *TrashRestoreTool {
+	return &TrashRestoreTool{trash: trash, checker: checker}
+}
+
+func (t *TrashRestoreTool) Name() string           { return "trash_restore" }
+func (t *TrashRestoreTool) Permission() Permission { return PermissionWrite }
+func (t *TrashRestoreTool) Description() string {
+	return "Restores a trash entry to its original path, or to destination if given. Fails if something already exists there. Args: id (int), destination (string)."
+}
+func (t *TrashRestoreTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), trashRestoreArgs{})
+}
+
+func (t *TrashRestoreTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a trashRestoreArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	item, err := t.trash.Get(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	dest := a.Destination
+	if dest == "" {
+		dest = item.OriginalPath
+	}
+	if err := checkHistoryAccess(t.checker, dest, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	if err := checkPreviewBase(args, dest); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("'%s' already exists; restore to another destination", dest)
+	}
+	if _, err := os.Lstat(item.TrashPath); err != nil {
+		return nil, fmt.Errorf("trash entry #%d is missing from the trash directory: %w", item.ID, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	if err := movePath(item.TrashPath, dest); err != nil {
+		return nil, fmt.Errorf("failed to restore trash entry #%d: %w", item.ID, err)
+	}
+	if err := t.trash.Delete(item.ID); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": item.ID, "restored_to": dest, "is_dir": item.IsDir}, nil
+}
+
+// Preview reports what the restore would put back, with a diff for a file.
+func (t *TrashRestoreTool) Preview(args map[string]interface{}) ([]FilePreview, error) {
+	var a trashRestoreArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	item, err := t.trash.Get(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	dest := a.Destination
+	if dest == "" {
+		dest = item.OriginalPath
+	}
+	if err := checkHistoryAccess(t.checker, dest, sandbox.AccessWrite); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("'%s' already exists; restore to another destination", dest)
+	}
+	what, err := describePath(item.TrashPath)
+	if err != nil {
+		return nil, fmt.Errorf("trash entry #%d is missing from the trash directory: %w", item.ID, err)
+	}
+	summary := fmt.Sprintf("restores trash entry #%d, a %s removed by %s", item.ID, what, item.Tool)
+	if info, err := os.Lstat(item.TrashPath); err == nil && info.Mode().IsRegular() && info.Size() <= maxPreviewBytes {
+		if data, err := os.ReadFile(item.TrashPath); err == nil {
+			p, err := previewChange(dest, data)
+			if err != nil {
+				return nil, err
+			}
+			p.Summary = summary
+			return []FilePreview{p}, nil
+		}
+	}
+	return []FilePreview{previewPath(dest, "create", summary)}, nil
+}
@@ -0,0 +1,324 @@
+/**
+ * Web fetch tool module.
+ *
+ * web_fetch downloads one web page and returns its readable content as
+ * markdown, so the model can read what web_search found. Downloads are
+ * bounded in size and time, robots.txt is honored, responses are cached
+ * in SQLite and revalidated by ETag/Last-Modified, and pages can be added
+ * to the RAG index.
+ *
+ * Author: KleaSCM
+ * Email: KleaSCM@gmail.com
+ * File: web_fetch.go
+ * Description: Bounded, cached web page fetching with HTML to markdown.
+ */
+
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"nira/memory"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	webFetchUserAgent     = "Mozilla/5.0 (compatible; NIRA/1.0; +web_fetch)"
+	defaultFetchBytes     = 2 << 20
+	maxFetchBytes         = 10 << 20
+	defaultFetchChars     = 20000
+	maxFetchChars         = 200000
+	defaultFetchTimeout   = 20
+	maxFetchTimeout       = 60
+	maxFetchRedirects     = 5
+	defaultWebCacheMaxAge = time.Hour
+)
+
+// WebFetchTool downloads a URL and returns its content as markdown.
+type WebFetchTool struct {
+	// Cache stores responses for reuse and revalidation; nil disables caching.
+	Cache *memory.WebCacheStore
+	// Index receives pages fetched with index=true; nil disables it.
+	Index RagIndexWriter
+	// MaxAge is how long a cached page is served without revalidation.
+	MaxAge time.Duration
+	// IgnoreRobots skips robots.txt checks.
+	IgnoreRobots bool
+	// Egress carries every request, robots.txt included.
+	Egress *Egress
+
+	robots robotsCache
+}
+
+type webFetchArgs struct {
+	URL            string `json:"url" desc:"http(s) URL to fetch" required:"true"`
+	MaxChars       int    `json:"max_chars" desc:"Maximum characters of content to return" default:"20000"`
+	Full           bool   `json:"full" desc:"Convert the whole page instead of only its main content"`
+	Index          bool   `json:"index" desc:"Also add the page to the RAG index (searchable with rag_search)"`
+	Refresh        bool   `json:"refresh" desc:"Download again even if a fresh cached copy exists"`
+	TimeoutSeconds int    `json:"timeout_seconds" desc:"Download timeout in seconds (at most 60)" default:"20"`
+	MaxBytes       int    `json:"max_bytes" desc:"Maximum bytes to download (at most 10 MB)" default:"2097152"`
+}
+
+func NewWebFetchTool(cache *memory.WebCacheStore, index RagIndexWriter) *WebFetchTool {
+	return &WebFetchTool{Cache: cache, Index: index, MaxAge: defaultWebCacheMaxAge}
+}
+
+func (t *WebFetchTool) Name() string           { return "web_fetch" }
+func (t *WebFetchTool) Permission() Permission { return PermissionNetwork }
+func (t *WebFetchTool) Description() string {
+	return "Downloads a web page and returns its title and main content as markdown (cached; honors robots.txt). Args: url (string), max_chars (int), full (bool), index (bool), refresh (bool), timeout_seconds (int), max_bytes (int)."
+}
+func (t *WebFetchTool) Schema() map[string]interface{} {
+	return BuildSchema(t.Name(), t.Description(), webFetchArgs{})
+}
+
+// fetchedPage is a downloaded (or cached) response.
+type fetchedPage struct {
+	finalURL    string
+	contentType string
+	body        []byte
+	truncated   bool
+	cached      bool
+	fetchedAt   time.Time
+}
+
+func (t *WebFetchTool) Execute(args map[string]interface{}) (interface{}, error) {
+	var a webFetchArgs
+	if err := DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(strings.TrimSpace(a.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL, got '%s'", a.URL)
+	}
+	u.Fragment = ""
+	if a.TimeoutSeconds <= 0 {
+		a.TimeoutSeconds = defaultFetchTimeout
+	}
+	if a.MaxBytes <= 0 {
+		a.MaxBytes = defaultFetchBytes
+	}
+	if a.MaxChars <= 0 {
+		a.MaxChars = defaultFetchChars
+	}
+	timeout := time.Duration(min(a.TimeoutSeconds, maxFetchTimeout)) * time.Second
+	a.MaxBytes = min(a.MaxBytes, maxFetchBytes)
+	a.MaxChars = min(a.MaxChars, maxFetchChars)
+
+	page, err := t.fetch(u, a.Refresh, timeout, a.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	final, _ := url.Parse(page.finalURL)
+	title, content, err := pageToMarkdown(page, final, a.Full)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"url":          u.String(),
+		"final_url":    page.finalURL,
+		"title":        title,
+		"content_type": page.contentType,
+		"chars":        utf8.RuneCountInString(content),
+		"cached":       page.cached,
+		"fetched_at":   page.fetchedAt.UTC().Format(time.RFC3339),
+	}
+	if a.Index {
+		if t.Index == nil {
+			return nil, errors.New("the RAG index is not available")
+		}
+		if err := t.Index.Upsert(page.finalURL, title, page.fetchedAt.UTC().Format(time.RFC3339), int64(len(page.body)), content); err != nil {
+			return nil, fmt.Errorf("failed to index page: %w", err)
+		}
+		result["indexed"] = true
+	}
+	shown, cut := capText(content, a.MaxChars)
+	result["content"] = shown
+	result["truncated"] = cut || page.truncated
+	return result, nil
+}
+
+// fetch returns the page for u from the cache when it is fresh, after
+// revalidating it when it is stale, or by downloading it.
+func (t *WebFetchTool) fetch(u *url.URL, refresh bool, timeout time.Duration, maxBytes int) (*fetchedPage, error) {
+	key := u.String()
+	var entry *memory.WebCacheEntry
+	if t.Cache != nil {
+		var err error
+		if entry, err = t.Cache.Get(key); err != nil {
+			return nil, err
+		}
+	}
+	if entry != nil && !refresh && time.Since(entry.FetchedAt) < t.MaxAge {
+		return cachedPage(entry), nil
+	}
+
+	// robots.txt is fetched with its own client so that its redirects are
+	// not themselves checked against robots.txt.
+	robotsClient := t.Egress.Client(timeout)
+	if !t.IgnoreRobots {
+		if err := t.robots.check(robotsClient, u); err != nil {
+			return nil, err
+		}
+	}
+	client := t.Egress.Client(timeout)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported URL %s", req.URL)
+		}
+		if t.IgnoreRobots {
+			return nil
+		}
+		return t.robots.check(robotsClient, req.URL)
+	}
+
+	req, err := http.NewRequest("GET", key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", webFetchUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+	if entry != nil && !refresh {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		if err := t.Cache.Touch(key); err != nil {
+			return nil, err
+		}
+		page := cachedPage(entry)
+		page.fetchedAt = time.Now()
+		return page, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d fetching %s", resp.StatusCode, key)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !readableContentType(contentType) {
+		return nil, fmt.Errorf("unsupported content type '%s' at %s; web_fetch reads HTML and text", contentType, key)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	page := &fetchedPage{
+		finalURL:    resp.Request.URL.String(),
+		contentType: contentType,
+		body:        body,
+		fetchedAt:   time.Now(),
+	}
+	if len(body) > maxBytes {
+		page.body, page.truncated = body[:maxBytes], true
+	}
+	// Partial downloads are not cached, so a later call with a larger
+	// max_bytes gets the whole page.
+	if t.Cache != nil && !page.truncated {
+		err := t.Cache.Put(memory.WebCacheEntry{
+			URL:          key,
+			FinalURL:     page.finalURL,
+			Status:       resp.StatusCode,
+			ContentType:  contentType,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Body:         page.body,
+			FetchedAt:    page.fetchedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func cachedPage(e *memory.WebCacheEntry) *fetchedPage {
+	return &fetchedPage{finalURL: e.FinalURL, contentType: e.ContentType, body: e.Body, cached: true, fetchedAt: e.FetchedAt}
+}
+
+// readableContentType accepts HTML, text, JSON and XML; a missing type is
+// left to content sniffing.
+func readableContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, "text/") || mt == "application/xhtml+xml" ||
+		mt == "application/json" || mt == "application/xml" ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+// pageToMarkdown decodes the body and converts HTML to markdown; other text
+// is returned as is.
+func pageToMarkdown(page *fetchedPage, base *url.URL, full bool) (string, string, error) {
+	mt, params, _ := mime.ParseMediaType(page.contentType)
+	body := page.body
+	if mt == "" {
+		mt = strings.SplitN(http.DetectContentType(body), ";", 2)[0]
+	}
+
+	var text string
+	switch strings.ToLower(params["charset"]) {
+	case "iso-8859-1", "latin1", "windows-1252", "us-ascii":
+		decoded, err := io.ReadAll(decodingReader(bytes.NewReader(body), encodingLatin1))
+		if err != nil {
+			return "", "", err
+		}
+		text = string(decoded)
+	default:
+		decoded, _, err := decodeText(body, page.truncated)
+		if err != nil {
+			return "", "", fmt.Errorf("the page at %s looks like a binary file", page.finalURL)
+		}
+		text = decoded
+	}
+
+	if mt == "text/html" || mt == "application/xhtml+xml" {
+		return htmlToMarkdown(strings.NewReader(text), base, full)
+	}
+	return "", strings.TrimSpace(text), nil
+}
+
+// capText cuts s to at most n characters at a line boundary when possible.
+func capText(s string, n int) (string, bool) {
+	end, count := len(s), 0
+	for i := range s {
+		if count == n {
+			end = i
+			break
+		}
+		count++
+	}
+	if end == len(s) {
+		return s, false
+	}
+	cut := strings.LastIndexByte(s[:end], '\n')
+	if cut < end/2 {
+		cut = end
+	}
+	return s[:cut] + "\n\n[... truncated at max_chars; the rest of the page was not shown ...]", true
+}
@@ -1,7 +1,9 @@
 // WebSearchTool implementation for Nira
 //
 // Allows the AI to perform web searches and return summarized results.
-// Uses DuckDuckGo Instant Answer API (no API key required, privacy-friendly).
+// Queries the configured SearchProviders in order, falling back to the next
+// one when a provider fails; with none configured it uses the DuckDuckGo
+// Instant Answer API (no API key required, privacy-friendly).
 //
 // Author: KleaSCM
 // Email: KleaSCM@gmail.com
@@ -11,12 +13,17 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 )
 
+const (
+	defaultSearchResults = 5
+	maxSearchResults     = 20
+)
+
 // WebSearchResult represents a single search result
 type WebSearchResult struct {
 	Title   string
@@ -25,18 +32,27 @@ type WebSearchResult struct {
 	Source  string
 }
 
+type webSearchArgs struct {
+	Query string `json:"query" desc:"Search query" required:"true"`
+	Limit int    `json:"limit" desc:"Maximum results (at most 20)" default:"5"`
+}
+
 // WebSearchTool implements the Tool interface for web search
-type WebSearchTool struct{}
+type WebSearchTool struct {
+	// Providers are tried in order; empty means DuckDuckGo only.
+	Providers []SearchProvider
+	// Egress carries the default DuckDuckGo provider's requests.
+	Egress *Egress
+}
+
+// NewWebSearchTool creates a web search tool over the given providers.
+func NewWebSearchTool(providers []SearchProvider) *WebSearchTool {
+	return &WebSearchTool{Providers: providers}
+}
 
 // Schema returns the tool's metadata as a map for registry listing
 func (t *WebSearchTool) Schema() map[string]interface{} {
-	return map[string]interface{}{
-		"name":        t.Name(),
-		"description": t.Description(),
-		"permission":  t.PermissionLevel(),
-		"input":       t.InputSchema(),
-		"output":      t.OutputSchema(),
-	}
+	return BuildSchema(t.Name(), t.Description(), webSearchArgs{})
 }
 
 func (t *WebSearchTool) Name() string {
@@ -44,28 +60,60 @@ func (t *WebSearchTool) Name() string {
 }
 
 func (t *WebSearchTool) Description() string {
-	return "Searches the web for a query and returns summarized results."
+	return "Searches the web for a query and returns summarized results (title, snippet, URL). Args: query (string), limit (int)."
 }
 
-func (t *WebSearchTool) PermissionLevel() string {
-	return "internet"
-}
-
-func (t *WebSearchTool) InputSchema() string {
-	return `{ "query": "string" }`
-}
-
-func (t *WebSearchTool) OutputSchema() string {
-	return `[{ "Title": "string", "Snippet": "string", "URL": "string", "Source": "string" }]`
+func (t *WebSearchTool) Permission() Permission {
+	return PermissionNetwork
 }
 
 // Execute performs the web search
 func (t *WebSearchTool) Execute(input map[string]interface{}) (interface{}, error) {
-	query, ok := input["query"].(string)
-	if !ok || query == "" {
+	var a webSearchArgs
+	if err := DecodeArgs(input, &a); err != nil {
+		return nil, err
+	}
+	query := strings.TrimSpace(a.Query)
+	if query == "" {
 		return nil, fmt.Errorf("missing or invalid query")
 	}
+	limit := a.Limit
+	if limit <= 0 {
+		limit = defaultSearchResults
+	}
+	limit = min(limit, maxSearchResults)
+
+	providers := t.Providers
+	if len(providers) == 0 {
+		providers = []SearchProvider{&duckDuckGoProvider{searchBackend{name: SearchDuckDuckGo, client: t.Egress.Client(defaultSearchTimeout)}}}
+	}
+
+	// Fall back to the next provider on errors and on empty answers, so a
+	// narrow provider (DuckDuckGo) never hides results a later one has.
+	var failures []string
+	for _, p := range providers {
+		results, err := p.Search(query, limit)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", p.Name(), err))
+			continue
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	if len(failures) == len(providers) {
+		return nil, fmt.Errorf("web search failed for '%s':\n- %s", query, strings.Join(failures, "\n- "))
+	}
+	return nil, fmt.Errorf("no results found for '%s'. Try different keywords, or configure a general-purpose provider (SearXNG or Brave) in SearchProviders", query)
+}
 
+// duckDuckGoProvider queries the DuckDuckGo Instant Answer API, which only
+// answers for well-known topics (people, places, Wikipedia subjects).
+type duckDuckGoProvider struct {
+	searchBackend
+}
+
+func (p *duckDuckGoProvider) Search(query string, limit int) ([]WebSearchResult, error) {
 	// URL encode the query
 	encodedQuery := url.QueryEscape(query)
 
@@ -81,14 +129,13 @@ func (t *WebSearchTool) Execute(input map[string]interface{}) (interface{}, erro
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := p.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSearchResponse))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -96,7 +143,7 @@ func (t *WebSearchTool) Execute(input map[string]interface{}) (interface{}, erro
 	// Check if we got HTML instead of JSON (common DuckDuckGo issue)
 	bodyStr := string(body)
 	if strings.HasPrefix(strings.TrimSpace(bodyStr), "<") {
-		return nil, fmt.Errorf("DuckDuckGo returned HTML instead of JSON. The Instant Answer API may not have results for '%s'. Try a more specific query about well-known topics (e.g., famous people, places, or Wikipedia subjects)", query)
+		return nil, fmt.Errorf("DuckDuckGo returned HTML instead of JSON; the Instant Answer API has no answer for this query")
 	}
 
 	// Parse JSON response
@@ -131,8 +178,8 @@ func (t *WebSearchTool) Execute(input map[string]interface{}) (interface{}, erro
 		})
 	}
 
-	// Add related topics
-	for _, topic := range ddg.RelatedTopics {
+	// Add direct results, then related topics
+	for _, topic := range append(ddg.Results, ddg.RelatedTopics...) {
 		if topic.Text != "" && topic.FirstURL != "" {
 			results = append(results, WebSearchResult{
 				Title:   extractTitle(topic.Text),
@@ -141,31 +188,9 @@ func (t *WebSearchTool) Execute(input map[string]interface{}) (interface{}, erro
 				Source:  "DuckDuckGo",
 			})
 		}
-		if len(results) >= 5 {
-			break
-		}
-	}
-
-	// Add direct results
-	for _, result := range ddg.Results {
-		if result.Text != "" && result.FirstURL != "" {
-			results = append(results, WebSearchResult{
-				Title:   extractTitle(result.Text),
-				Snippet: result.Text,
-				URL:     result.FirstURL,
-				Source:  "DuckDuckGo",
-			})
-		}
-		if len(results) >= 5 {
-			break
-		}
-	}
-
-	if len(results) == 0 {
-		return nil, fmt.Errorf("no results found for '%s'. DuckDuckGo's Instant Answer API works best for:\n- Famous people (e.g., 'Albert Einstein')\n- Well-known places (e.g., 'Eiffel Tower')\n- Wikipedia topics (e.g., 'Artificial Intelligence')\n\nFor better results, consider using a proper search API like Brave Search or Google Custom Search", query)
 	}
 
-	return results, nil
+	return normalizeResults(results, limit), nil
 }
 
 // extractTitle extracts the first sentence or up to 60 chars as title
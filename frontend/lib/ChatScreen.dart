@@ -9,6 +9,7 @@
  * File: ChatScreen.dart
  * Description: Main chat interface component.
  */
+import 'dart:convert';
 import 'package:flutter/material.dart';
 import 'package:flutter/services.dart';
 import 'package:google_fonts/google_fonts.dart';
@@ -57,6 +58,10 @@ class _ChatScreenState extends State<ChatScreen> with SingleTickerProviderStateM
     final channel = WsService.connect('ws://localhost:8080/ws');
     if (channel != null) {
       WsService.messageStream?.listen((msg) {
+        if (msg.type == MessageType.confirm && msg.id != null) {
+          _showToolConfirmDialog(msg.id!, msg.content);
+          return;
+        }
         setState(() {
           if (msg.type == MessageType.chunk) {
             if (CurrentAssistantIndex == -1) {
@@ -76,6 +81,11 @@ class _ChatScreenState extends State<ChatScreen> with SingleTickerProviderStateM
               'text': 'Error: ${msg.content}',
               'sender': 'System',
             });
+          } else if (msg.type == MessageType.config) {
+            Messages.add({
+              'text': _describeConfigReload(msg.content),
+              'sender': 'System',
+            });
           }
         });
         _scrollToBottom();
@@ -83,6 +93,118 @@ class _ChatScreenState extends State<ChatScreen> with SingleTickerProviderStateM
     }
   }
 
+  // Summarizes a config reload notice from the backend
+  String _describeConfigReload(String payload) {
+    try {
+      final notice = jsonDecode(payload) as Map<String, dynamic>;
+      final applied = (notice['applied'] as List<dynamic>? ?? []).join(', ');
+      final restart = (notice['restart_required'] as List<dynamic>? ?? []).join(', ');
+      var text = 'Configuration reloaded';
+      if (applied.isNotEmpty) text += ': applied $applied (model: ${notice['model']})';
+      if (restart.isNotEmpty) text += '. Restart NIRA to apply $restart.';
+      return text;
+    } catch (_) {
+      return 'Configuration reloaded';
+    }
+  }
+
+  // Ask the user to approve a tool call the model wants to run
+  Future<void> _showToolConfirmDialog(String id, String payload) async {
+    String tool = 'tool';
+    String permission = '';
+    String args = '';
+    List<Map<String, dynamic>> previews = [];
+    try {
+      final request = jsonDecode(payload) as Map<String, dynamic>;
+      tool = request['tool']?.toString() ?? tool;
+      permission = request['permission']?.toString() ?? '';
+      args = const JsonEncoder.withIndent('  ').convert(request['arguments'] ?? {});
+      previews = (request['previews'] as List<dynamic>? ?? [])
+          .whereType<Map<String, dynamic>>()
+          .toList();
+    } catch (_) {
+      args = payload;
+    }
+
+    final approved = await showDialog<bool>(
+      context: context,
+      barrierDismissible: false,
+      builder: (context) {
+        return AlertDialog(
+          title: Text(previews.isEmpty ? 'Allow $tool?' : 'Apply changes from $tool?'),
+          content: SizedBox(
+            width: 500,
+            child: SingleChildScrollView(
+              child: Column(
+                crossAxisAlignment: CrossAxisAlignment.start,
+                mainAxisSize: MainAxisSize.min,
+                children: [
+                  if (permission.isNotEmpty) Text('Permission: $permission'),
+                  const SizedBox(height: 8),
+                  if (previews.isEmpty)
+                    SelectableText(args, style: GoogleFonts.robotoMono(fontSize: 12)),
+                  for (final preview in previews) ..._buildFilePreview(preview),
+                ],
+              ),
+            ),
+          ),
+          actions: [
+            TextButton(
+              onPressed: () => Navigator.of(context).pop(false),
+              child: const Text('Deny'),
+            ),
+            ElevatedButton(
+              onPressed: () => Navigator.of(context).pop(true),
+              child: const Text('Allow'),
+            ),
+          ],
+        );
+      },
+    );
+    WsService.sendConfirmation(id, approved == true);
+  }
+
+  // Renders one file change from a confirm request: header plus colored diff.
+  List<Widget> _buildFilePreview(Map<String, dynamic> preview) {
+    final path = preview['path']?.toString() ?? '';
+    final action = preview['action']?.toString() ?? 'modify';
+    final added = preview['added'] ?? 0;
+    final removed = preview['removed'] ?? 0;
+    final diff = preview['diff']?.toString() ?? '';
+    final omitted = preview['omitted']?.toString() ?? '';
+    final summary = preview['summary']?.toString() ?? '';
+    return [
+      Text(diff.isEmpty ? '$action $path' : '$action $path  (+$added −$removed)',
+          style: const TextStyle(fontWeight: FontWeight.bold)),
+      const SizedBox(height: 4),
+      if (summary.isNotEmpty) Text(summary),
+      if (omitted.isNotEmpty) Text('Diff not shown: $omitted'),
+      if (diff.isNotEmpty)
+        Container(
+          width: double.infinity,
+          padding: const EdgeInsets.all(8),
+          color: Colors.black.withOpacity(0.05),
+          child: SelectableText.rich(
+            TextSpan(
+              children: diff.split('\n').map((line) {
+                Color? color;
+                if (line.startsWith('+') && !line.startsWith('+++')) {
+                  color = Colors.green.shade700;
+                } else if (line.startsWith('-') && !line.startsWith('---')) {
+                  color = Colors.red.shade700;
+                } else if (line.startsWith('@@')) {
+                  color = Colors.blueGrey;
+                }
+                return TextSpan(text: '$line\n', style: TextStyle(color: color));
+              }).toList(),
+            ),
+            style: GoogleFonts.robotoMono(fontSize: 12),
+          ),
+        ),
+      const SizedBox(height: 12),
+    ];
+  }
+
   @override
   void dispose() {
     MessageController.dispose();
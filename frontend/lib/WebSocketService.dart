@@ -20,6 +20,9 @@ enum MessageType {
 	system,
 	error,
 	chunk,
+	confirm,
+	audit,
+	config,
 }
 
 class WSMessage {
@@ -119,6 +122,13 @@ class WebSocketService {
 		Channel!.sink.add(jsonEncode(msg.toJson()));
 	}
 
+	/// Answer a tool confirmation request (MessageType.confirm) from the backend.
+	void sendConfirmation(String id, bool approved) {
+		if (Channel == null || !IsConnected) return;
+		final msg = WSMessage(type: MessageType.confirm, content: approved ? 'approve' : 'deny', id: id);
+		Channel!.sink.add(jsonEncode(msg.toJson()));
+	}
+
 	/// Send a raw JSON-like object directly over the socket.
 	/// Use this when you need to send structured events (e.g. rp_start) that the backend
 	/// expects as top-level JSON objects.
@@ -149,6 +159,31 @@ class WebSocketService {
         });
     }
 
+    /// Fetch the tool-call audit history for a conversation (current one if omitted).
+    Future<List<dynamic>> fetchToolAudit({int? conversationId, String? tool, int limit = 50, Duration timeout = const Duration(seconds: 10)}) async {
+        if (Channel == null || !IsConnected) {
+            throw Exception('WebSocket not connected');
+        }
+        final id = DateTime.now().microsecondsSinceEpoch.toString();
+        final request = {
+            if (conversationId != null) 'conversation_id': conversationId,
+            if (tool != null) 'tool': tool,
+            'limit': limit,
+        };
+        final completer = Completer<WSMessage>();
+        _pending[id] = completer;
+        Channel!.sink.add(jsonEncode(WSMessage(type: MessageType.audit, content: jsonEncode(request), id: id).toJson()));
+
+        final msg = await completer.future.timeout(timeout, onTimeout: () {
+            _pending.remove(id);
+            throw TimeoutException('Audit request timed out');
+        });
+        if (msg.type == MessageType.error) {
+            throw Exception(msg.content);
+        }
+        return jsonDecode(msg.content) as List<dynamic>;
+    }
+
     /// RPC-style helper: call a tool and await a single structured JSON response.
     /// Sends a direct tool call with an ID and requests silent handling.
     /// Returns the decoded JSON object/array from the tool result.